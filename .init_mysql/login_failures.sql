@@ -0,0 +1,23 @@
+SET NAMES utf8;
+SET time_zone = '+00:00';
+SET foreign_key_checks = 0;
+
+DROP TABLE IF EXISTS `login_failures`;
+CREATE TABLE `login_failures` (
+    `key` varchar(255) PRIMARY KEY,
+    `failures` int NOT NULL,
+    `last_failure` datetime NOT NULL,
+    `locked_until` datetime NULL
+) ENGINE=InnoDB DEFAULT CHARSET=utf8;
+
+DROP TABLE IF EXISTS `login_audit`;
+CREATE TABLE `login_audit` (
+    `id` bigint AUTO_INCREMENT PRIMARY KEY,
+    `key` varchar(255) NOT NULL,
+    `username` varchar(255) NOT NULL,
+    `ip` varchar(255) NOT NULL,
+    `failures` int NOT NULL,
+    `locked_until` datetime NOT NULL,
+    `created` datetime NOT NULL,
+    INDEX (`key`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8;
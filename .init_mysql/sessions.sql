@@ -4,5 +4,10 @@ SET foreign_key_checks = 0;
 
 DROP TABLE IF EXISTS `sessions`;
 CREATE TABLE `sessions` (
-    `session_id` varchar(255) NOT NULL
-) ENGINE=InnoDB DEFAULT CHARSET=utf8;
\ No newline at end of file
+    `session_id` varchar(255) PRIMARY KEY,
+    `user_id` varchar(255) NOT NULL,
+    `refresh_token` varchar(255) NOT NULL,
+    `rotated_tokens` varchar(1100) NOT NULL DEFAULT '',
+    `expires` datetime NOT NULL,
+    INDEX (`user_id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8;
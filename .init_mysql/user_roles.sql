@@ -0,0 +1,16 @@
+SET NAMES utf8;
+SET time_zone = '+00:00';
+SET foreign_key_checks = 0;
+
+-- роль user есть у всех и не хранится; scope — сообщество модератора, у админа пустая строка
+DROP TABLE IF EXISTS `user_roles`;
+CREATE TABLE `user_roles` (
+    `user_id` varchar(255) NOT NULL,
+    `role` enum('moderator', 'admin') NOT NULL,
+    `scope` varchar(255) NOT NULL DEFAULT '',
+    PRIMARY KEY (`user_id`, `role`, `scope`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8;
+
+INSERT INTO `user_roles` (`user_id`, `role`, `scope`) VALUES
+('id_admin1', 'admin', ''),
+('id_admin2', 'moderator', 'music');
@@ -1,89 +1,266 @@
 package main
 
 import (
-	"flag"
+	"context"
+	"errors"
 	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
+	"asperitas/internal/community"
+	"asperitas/internal/config"
 	"asperitas/internal/handlers"
+	"asperitas/internal/instrument"
+	"asperitas/internal/metrics"
 	"asperitas/internal/middleware"
+	"asperitas/internal/mongodb"
 	"asperitas/internal/post"
+	"asperitas/internal/ratelimit"
 	"asperitas/internal/session"
+	"asperitas/internal/tracing"
 	"asperitas/internal/user"
+	"asperitas/pkg/clientip"
 
 	"github.com/gorilla/mux"
 	"go.uber.org/zap"
 )
 
-var (
-	mySQLAddr = flag.String("mySQLAddr", `root:admin@tcp(localhost:3306)/vk-go?charset=utf8&interpolateParams=true`, "mysql addr")
-	mongoAddr = flag.String("mongoAddr", `mongodb://localhost:27017`, "mongo addr")
-)
-
 func main() {
-	flag.Parse()
+	cfg, err := config.Load(os.Args[0], os.Args[1:])
+	panicOnErr(err)
+
+	zapLogger, err := zap.NewProduction()
+	panicOnErr(err)
+
+	defer zapLogger.Sync() // nolint:errcheck
+	logger := zapLogger.Sugar()
 
-	sessionManager, err := session.NewManagerMySQL(*mySQLAddr)
+	stopTracing, err := tracing.Setup(context.Background(), cfg.Tracing)
 	panicOnErr(err)
 
-	userRepo, err := user.NewRepoMySQL(*mySQLAddr)
+	keys, err := session.KeySetFromConfig(cfg.Session)
 	panicOnErr(err)
+	if cfg.Session.Keys == "" {
+		logger.Warnw("no jwt keys configured, using ephemeral key",
+			"type", "START",
+		)
+	}
 
-	postRepo, err := post.NewRepoMongo(*mongoAddr)
+	sessionManager, err := session.NewManagerMySQL(cfg.MySQL, cfg.Session, keys)
 	panicOnErr(err)
 
-	zapLogger, err := zap.NewProduction()
+	hasher, err := user.NewBcryptHasher(cfg.User.PasswCost)
 	panicOnErr(err)
 
-	defer zapLogger.Sync() // nolint:errcheck
-	logger := zapLogger.Sugar()
+	userRepo, err := user.NewRepoMySQL(cfg.MySQL, hasher)
+	panicOnErr(err)
+
+	loginGuard, err := user.NewLoginGuardMySQL(cfg.MySQL, cfg.Lockout)
+	panicOnErr(err)
+
+	mongoClient, err := mongodb.Connect(cfg.Mongo)
+	panicOnErr(err)
+
+	postRepo, err := post.NewRepoMongo(mongoClient.Database(), cfg.Mongo)
+	panicOnErr(err)
+
+	communityRepo, err := community.NewRepoMongo(mongoClient.Database(), cfg.Mongo)
+	panicOnErr(err)
+
+	clientIP, err := clientip.NewResolver(cfg.Server.TrustedProxies)
+	panicOnErr(err)
+
+	sessions := instrument.NewSessionManager("mysql", sessionManager)
+	users := instrument.NewUserRepo("mysql", userRepo)
+	guard := instrument.NewLoginGuard("mysql", loginGuard)
+	posts := instrument.NewPostRepo("mongo", postRepo)
+	communities := instrument.NewCommunityRepo("mongo", communityRepo)
 
 	usersHandler := &handlers.UserHandler{
-		Sess:   sessionManager,
-		Repo:   userRepo,
-		Logger: logger,
+		Sess:     sessions,
+		Repo:     users,
+		Guard:    guard,
+		ClientIP: clientIP,
+		Logger:   logger,
 	}
 
 	postsHandler := &handlers.PostHandler{
-		Sess:   sessionManager,
-		Repo:   postRepo,
+		Sess:        sessions,
+		Repo:        posts,
+		Communities: communities,
+		Logger:      logger,
+	}
+
+	communitiesHandler := &handlers.CommunityHandler{
+		Sess:   sessions,
+		Repo:   communities,
 		Logger: logger,
 	}
 
-	r := router(usersHandler, postsHandler)
-	mux := middleware.AccessLog(logger, r)
-	mux = middleware.Panic(mux)
+	keysHandler := &handlers.KeysHandler{
+		Keys:   keys,
+		Logger: logger,
+	}
 
-	addr := ":8080"
-	logger.Infow("starting server",
-		"type", "START",
-		"addr", addr,
-	)
+	healthHandler := &handlers.HealthHandler{
+		Checks: []handlers.HealthCheck{
+			{Name: "sessions", Pinger: sessionManager},
+			{Name: "users", Pinger: userRepo},
+			{Name: "login_failures", Pinger: loginGuard},
+			{Name: "mongo", Pinger: mongoClient},
+		},
+		Timeout: cfg.Server.ReadyTimeout,
+		Logger:  logger,
+	}
 
-	err = http.ListenAndServe(addr, mux)
-	panicOnErr(err)
+	limiter := rateLimiter(keys, clientIP, logger)
+
+	r := router(logger, limiter, usersHandler, postsHandler, communitiesHandler, keysHandler, healthHandler)
+	// внешний Panic ловит паники самих middleware, внутренний в роутере — обработчиков
+	mux := middleware.Panic(logger)(middleware.RequestID(logger, r))
+
+	srv := &http.Server{
+		Addr:         cfg.Server.Addr,
+		Handler:      mux,
+		ReadTimeout:  cfg.Server.ReadTimeout,
+		WriteTimeout: cfg.Server.WriteTimeout,
+		IdleTimeout:  cfg.Server.IdleTimeout,
+		ErrorLog:     zap.NewStdLog(zapLogger),
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	serveErr := make(chan error, 1)
+	go func() {
+		logger.Infow("starting server",
+			"type", "START",
+			"addr", srv.Addr,
+		)
+		serveErr <- srv.ListenAndServe()
+	}()
+
+	select {
+	case err = <-serveErr:
+	case <-ctx.Done():
+		logger.Infow("shutting down server",
+			"type", "STOP",
+			"drain_delay", cfg.Server.DrainDelay.String(),
+			"timeout", cfg.Server.ShutdownTimeout.String(),
+		)
+	}
+	// повторный сигнал завершает процесс сразу, не дожидаясь остановки
+	stop()
+
+	shutdown(cfg.Server, logger, srv, healthHandler, stopTracing, mongoClient, userRepo, loginGuard, sessionManager)
+	if !errors.Is(err, http.ErrServerClosed) {
+		panicOnErr(err)
+	}
+}
+
+// Сначала /readyz начинает отвечать отказом, и в течение DrainDelay сервер еще принимает запросы,
+// пока балансировщик не уберет его из ротации. Затем текущие запросы дорабатываются и закрываются
+// соединения с базами; общий дедлайн ShutdownTimeout делится между этими шагами.
+func shutdown(
+	cfg config.Server,
+	logger *zap.SugaredLogger,
+	srv *http.Server,
+	healthHandler *handlers.HealthHandler,
+	stopTracing func(context.Context) error,
+	mongoClient *mongodb.Client,
+	userRepo *user.UserRepositoryMySQL,
+	loginGuard *user.LoginGuardMySQL,
+	sessionManager *session.SessionManagerMySQL,
+) {
+	healthHandler.Drain()
+	time.Sleep(cfg.DrainDelay)
+
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+	defer cancel()
+
+	steps := []struct {
+		name  string
+		close func() error
+	}{
+		{"http server", func() error { return srv.Shutdown(ctx) }},
+		{"mongo", func() error { return mongoClient.Close(ctx) }},
+		{"user repo", userRepo.Close},
+		{"login guard", loginGuard.Close},
+		{"session manager", sessionManager.Close},
+		{"tracing", func() error { return stopTracing(ctx) }},
+	}
+	for _, step := range steps {
+		if err := step.close(); err != nil {
+			logger.Errorw("shutdown err",
+				"type", "STOP",
+				"step", step.name,
+				"error", err,
+			)
+		}
+	}
+	logger.Infow("server stopped",
+		"type", "STOP",
+	)
 }
 
-func router(usersHandler *handlers.UserHandler, postsHandler *handlers.PostHandler) *mux.Router {
+func router(
+	logger *zap.SugaredLogger,
+	limiter *ratelimit.Limiter,
+	usersHandler *handlers.UserHandler,
+	postsHandler *handlers.PostHandler,
+	communitiesHandler *handlers.CommunityHandler,
+	keysHandler *handlers.KeysHandler,
+	healthHandler *handlers.HealthHandler,
+) *mux.Router {
 	r := mux.NewRouter()
+	middleware.Use(
+		r,
+		middleware.Tracing,
+		middleware.Metrics,
+		middleware.AccessLog(logger),
+		limiter.Middleware,
+		middleware.Panic(logger),
+	)
 
 	r.PathPrefix("/static/").Handler(http.StripPrefix(
 		"/static/",
 		http.FileServer(http.Dir("./static")),
 	))
 
+	r.HandleFunc("/healthz", healthHandler.Healthz).Methods("GET")
+	r.HandleFunc("/readyz", healthHandler.Readyz).Methods("GET")
+	r.Handle("/metrics", metrics.Handler()).Methods("GET")
+
+	r.HandleFunc("/.well-known/jwks.json", keysHandler.JWKS).Methods("GET")
+
 	r.HandleFunc("/api/register", usersHandler.Register).Methods("POST")
 	r.HandleFunc("/api/login", usersHandler.Login).Methods("POST")
+	r.HandleFunc("/api/token/refresh", usersHandler.Refresh).Methods("POST")
+	r.HandleFunc("/api/logout", usersHandler.Logout).Methods("POST")
+	r.HandleFunc("/api/logout/all", usersHandler.LogoutAll).Methods("POST")
+
+	r.HandleFunc("/api/communities", communitiesHandler.ListCommunities).Methods("GET")
+	r.HandleFunc("/api/communities", communitiesHandler.CreateCommunity).Methods("POST")
+	r.HandleFunc("/api/communities/{name}", communitiesHandler.ShowCommunity).Methods("GET")
 
 	r.HandleFunc("/api/posts/", postsHandler.ListPosts).Methods("GET")
 	r.HandleFunc("/api/posts", postsHandler.CreatePost).Methods("POST")
 	r.HandleFunc("/api/posts/{categoryName}", postsHandler.ListPostsByCategory).Methods("GET")
 	r.HandleFunc("/api/post/{postID}", postsHandler.ShowPost).Methods("GET")
 	r.HandleFunc("/api/post/{postID}", postsHandler.DeletePost).Methods("DELETE")
+	r.HandleFunc("/api/post/{postID}", postsHandler.UpdatePost).Methods("PUT")
+	r.HandleFunc("/api/post/{postID}/revisions", postsHandler.ListRevisions).Methods("GET")
 	r.HandleFunc("/api/post/{postID}", postsHandler.CreateComment).Methods("POST")
 	r.HandleFunc("/api/post/{postID}/{commentID}", postsHandler.DeleteComment).Methods("DELETE")
+	r.HandleFunc("/api/post/{postID}/{commentID}/reply", postsHandler.ReplyComment).Methods("POST")
 	r.HandleFunc("/api/post/{postID}/upvote", postsHandler.UpvotePost).Methods("GET")
 	r.HandleFunc("/api/post/{postID}/downvote", postsHandler.DownvotePost).Methods("GET")
 	r.HandleFunc("/api/post/{postID}/unvote", postsHandler.UnvotePost).Methods("GET")
+	r.HandleFunc("/api/post/{postID}/{commentID}/upvote", postsHandler.UpvoteComment).Methods("GET")
+	r.HandleFunc("/api/post/{postID}/{commentID}/downvote", postsHandler.DownvoteComment).Methods("GET")
+	r.HandleFunc("/api/post/{postID}/{commentID}/unvote", postsHandler.UnvoteComment).Methods("GET")
 	r.HandleFunc("/api/user/{userName}", postsHandler.ListPostsByUser).Methods("GET")
 
 	r.PathPrefix("/").HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -93,6 +270,58 @@ func router(usersHandler *handlers.UserHandler, postsHandler *handlers.PostHandl
 	return r
 }
 
+// Политики по маршрутам: вход и регистрация ограничены жестко против перебора паролей,
+// запись и голоса — по пользователю, чтение — только по IP
+func rateLimiter(keys *session.KeySet, clientIP clientip.Resolver, logger *zap.SugaredLogger) *ratelimit.Limiter {
+	auth := ratelimit.Policy{Name: "auth", IP: ratelimit.Limit{Burst: 5, Interval: 20 * time.Second}}
+	write := ratelimit.Policy{
+		Name: "write",
+		IP:   ratelimit.Limit{Burst: 30, Interval: 2 * time.Second},
+		User: ratelimit.Limit{Burst: 10, Interval: 6 * time.Second},
+	}
+	vote := ratelimit.Policy{
+		Name: "vote",
+		IP:   ratelimit.Limit{Burst: 60, Interval: time.Second},
+		User: ratelimit.Limit{Burst: 30, Interval: 2 * time.Second},
+	}
+	probe := ratelimit.Policy{}
+
+	return &ratelimit.Limiter{
+		Store: ratelimit.NewMemoryStore(),
+		Identify: func(r *http.Request) (string, bool) {
+			claims, err := keys.ExtractAuthClaims(r.Header.Get("Authorization"))
+			if err != nil {
+				return "", false
+			}
+			return claims.User.ID, true
+		},
+		ClientIP: clientIP,
+		Policies: map[string]ratelimit.Policy{
+			"GET /healthz": probe,
+			"GET /readyz":  probe,
+			"GET /metrics": probe,
+
+			"POST /api/register":      auth,
+			"POST /api/login":         auth,
+			"POST /api/token/refresh": {Name: "refresh", IP: ratelimit.Limit{Burst: 10, Interval: 6 * time.Second}},
+
+			"POST /api/communities":                       write,
+			"POST /api/posts":                             write,
+			"PUT /api/post/{postID}":                      write,
+			"POST /api/post/{postID}":                     write,
+			"POST /api/post/{postID}/{commentID}/reply":   write,
+			"GET /api/post/{postID}/upvote":               vote,
+			"GET /api/post/{postID}/downvote":             vote,
+			"GET /api/post/{postID}/unvote":               vote,
+			"GET /api/post/{postID}/{commentID}/upvote":   vote,
+			"GET /api/post/{postID}/{commentID}/downvote": vote,
+			"GET /api/post/{postID}/{commentID}/unvote":   vote,
+		},
+		Default: ratelimit.Policy{Name: "default", IP: ratelimit.Limit{Burst: 100, Interval: 100 * time.Millisecond}},
+		Logger:  logger,
+	}
+}
+
 func panicOnErr(err error) {
 	if err != nil {
 		panic(err)
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"context"
+	"os"
+
+	"asperitas/internal/config"
+	"asperitas/internal/mongodb"
+	"asperitas/internal/post"
+
+	"go.uber.org/zap"
+)
+
+// Разносит встроенные в посты голоса, комментарии и историю правок по отдельным коллекциям
+// и досчитывает поля ранжирования у старых постов.
+// Таймаут монги из конфигурации ограничивает перенос одного поста.
+func main() {
+	cfg, err := config.Load(os.Args[0], os.Args[1:])
+	panicOnErr(err)
+
+	zapLogger, err := zap.NewProduction()
+	panicOnErr(err)
+
+	defer zapLogger.Sync() // nolint:errcheck
+	logger := zapLogger.Sugar()
+
+	mongoClient, err := mongodb.Connect(cfg.Mongo)
+	panicOnErr(err)
+	defer mongoClient.Close(context.Background()) // nolint:errcheck
+
+	postRepo, err := post.NewRepoMongo(mongoClient.Database(), cfg.Mongo)
+	panicOnErr(err)
+
+	migrations := []struct {
+		name string
+		run  func(ctx context.Context) (int, error)
+	}{
+		{"embedded votes and comments", postRepo.MigrateEmbedded},
+		{"embedded revisions", postRepo.MigrateRevisions},
+		{"ranking fields", postRepo.BackfillRanking},
+	}
+	for _, m := range migrations {
+		migrated, err := m.run(context.Background())
+		if err != nil {
+			logger.Fatalw("migration failed",
+				"type", "MIGRATE",
+				"migration", m.name,
+				"migrated", migrated,
+				"error", err,
+			)
+		}
+		logger.Infow("migration finished",
+			"type", "MIGRATE",
+			"migration", m.name,
+			"migrated", migrated,
+		)
+	}
+}
+
+func panicOnErr(err error) {
+	if err != nil {
+		panic(err)
+	}
+}
@@ -0,0 +1,50 @@
+package community
+
+import (
+	"context"
+	"time"
+
+	"asperitas/internal/user"
+)
+
+type Community struct {
+	Name          string    `json:"name"`
+	Description   string    `json:"description"`
+	Rules         []string  `json:"rules"`
+	Creator       user.User `json:"creator"`
+	Created       time.Time `json:"-"`
+	CreatedFormat string    `json:"created"`
+}
+
+type CommunityRepo interface {
+	GetAll(ctx context.Context) ([]*Community, error)
+	GetByName(ctx context.Context, name string) (*Community, error)
+	Add(ctx context.Context, comm *Community) error
+}
+
+func NewCommunity(usr user.User, name, description string, rules []string) *Community {
+	t := time.Now()
+	if rules == nil {
+		rules = []string{}
+	}
+	return &Community{
+		Name:          name,
+		Description:   description,
+		Rules:         rules,
+		Creator:       usr.WithoutRoles(),
+		Created:       t,
+		CreatedFormat: t.Format(time.RFC3339Nano),
+	}
+}
+
+// Сообщества, заменившие фиксированный список категорий постов
+func Seeds() []*Community {
+	return []*Community{
+		NewCommunity(user.User{}, "music", "Music", nil),
+		NewCommunity(user.User{}, "funny", "Funny", nil),
+		NewCommunity(user.User{}, "videos", "Videos", nil),
+		NewCommunity(user.User{}, "programming", "Programming", nil),
+		NewCommunity(user.User{}, "news", "News", nil),
+		NewCommunity(user.User{}, "fashion", "Fashion", nil),
+	}
+}
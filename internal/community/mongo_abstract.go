@@ -0,0 +1,75 @@
+package community
+
+import (
+	"context"
+
+	"asperitas/pkg/timeout"
+
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type MongoCursor interface {
+	All(context.Context, interface{}) error
+}
+
+type MongoSingleResult interface {
+	Decode(v interface{}) error
+	Err() error
+}
+
+type MongoCollection interface {
+	Find(context.Context, interface{}, ...*options.FindOptions) (MongoCursor, error)
+	FindOne(context.Context, interface{}) MongoSingleResult
+	InsertOne(context.Context, interface{}) (interface{}, error)
+	UpdateOne(context.Context, interface{}, interface{}, ...*options.UpdateOptions) (*mongo.UpdateResult, error)
+}
+
+type mongoCursor struct {
+	cs *mongo.Cursor
+}
+
+type mongoSingleResult struct {
+	sr  *mongo.SingleResult
+	ctx context.Context
+}
+
+type mongoCollection struct {
+	cln *mongo.Collection
+}
+
+func newMongoCollection(coll *mongo.Collection) MongoCollection {
+	return &mongoCollection{cln: coll}
+}
+
+func (mcs *mongoCursor) All(ctx context.Context, v interface{}) error {
+	return timeout.Wrap(ctx, mcs.cs.All(ctx, v))
+}
+
+func (msr *mongoSingleResult) Decode(v interface{}) error {
+	return timeout.Wrap(msr.ctx, msr.sr.Decode(v))
+}
+
+func (msr *mongoSingleResult) Err() error {
+	return timeout.Wrap(msr.ctx, msr.sr.Err())
+}
+
+func (mc *mongoCollection) Find(ctx context.Context, filter interface{}, opts ...*options.FindOptions) (MongoCursor, error) {
+	cursor, err := mc.cln.Find(ctx, filter, opts...)
+	return &mongoCursor{cs: cursor}, timeout.Wrap(ctx, err)
+}
+
+func (mc *mongoCollection) FindOne(ctx context.Context, filter interface{}) MongoSingleResult {
+	singleResult := mc.cln.FindOne(ctx, filter)
+	return &mongoSingleResult{sr: singleResult, ctx: ctx}
+}
+
+func (mc *mongoCollection) InsertOne(ctx context.Context, document interface{}) (interface{}, error) {
+	insertResult, err := mc.cln.InsertOne(ctx, document)
+	return insertResult, timeout.Wrap(ctx, err)
+}
+
+func (mc *mongoCollection) UpdateOne(ctx context.Context, filter interface{}, update interface{}, opts ...*options.UpdateOptions) (*mongo.UpdateResult, error) {
+	updateResult, err := mc.cln.UpdateOne(ctx, filter, update, opts...)
+	return updateResult, timeout.Wrap(ctx, err)
+}
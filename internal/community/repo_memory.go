@@ -0,0 +1,58 @@
+package community
+
+import (
+	"context"
+	"sort"
+	"sync"
+
+	"asperitas/internal/errs"
+)
+
+type CommunityMemoryRepository struct {
+	data map[string]*Community
+	mu   *sync.RWMutex
+}
+
+func NewMemoryRepo() *CommunityMemoryRepository {
+	repo := &CommunityMemoryRepository{
+		data: make(map[string]*Community),
+		mu:   &sync.RWMutex{},
+	}
+	for _, comm := range Seeds() {
+		repo.data[comm.Name] = comm
+	}
+	return repo
+}
+
+func (repo *CommunityMemoryRepository) GetAll(_ context.Context) ([]*Community, error) {
+	repo.mu.RLock()
+	result := make([]*Community, 0, len(repo.data))
+	for _, comm := range repo.data {
+		result = append(result, comm)
+	}
+	repo.mu.RUnlock()
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Name < result[j].Name
+	})
+	return result, nil
+}
+
+func (repo *CommunityMemoryRepository) GetByName(_ context.Context, name string) (*Community, error) {
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
+	comm, ok := repo.data[name]
+	if !ok {
+		return nil, errs.MsgError{Msg: "community not found", Status: 404}
+	}
+	return comm, nil
+}
+
+func (repo *CommunityMemoryRepository) Add(_ context.Context, comm *Community) error {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+	if _, exist := repo.data[comm.Name]; exist {
+		return errs.MsgError{Msg: "community already exists", Status: 409}
+	}
+	repo.data[comm.Name] = comm
+	return nil
+}
@@ -0,0 +1,79 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: community.go
+
+// Package community is a generated GoMock package.
+package community
+
+import (
+	context "context"
+	reflect "reflect"
+
+	gomock "github.com/golang/mock/gomock"
+)
+
+// MockCommunityRepo is a mock of CommunityRepo interface.
+type MockCommunityRepo struct {
+	ctrl     *gomock.Controller
+	recorder *MockCommunityRepoMockRecorder
+}
+
+// MockCommunityRepoMockRecorder is the mock recorder for MockCommunityRepo.
+type MockCommunityRepoMockRecorder struct {
+	mock *MockCommunityRepo
+}
+
+// NewMockCommunityRepo creates a new mock instance.
+func NewMockCommunityRepo(ctrl *gomock.Controller) *MockCommunityRepo {
+	mock := &MockCommunityRepo{ctrl: ctrl}
+	mock.recorder = &MockCommunityRepoMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockCommunityRepo) EXPECT() *MockCommunityRepoMockRecorder {
+	return m.recorder
+}
+
+// Add mocks base method.
+func (m *MockCommunityRepo) Add(ctx context.Context, comm *Community) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Add", ctx, comm)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Add indicates an expected call of Add.
+func (mr *MockCommunityRepoMockRecorder) Add(ctx, comm interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Add", reflect.TypeOf((*MockCommunityRepo)(nil).Add), ctx, comm)
+}
+
+// GetAll mocks base method.
+func (m *MockCommunityRepo) GetAll(ctx context.Context) ([]*Community, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetAll", ctx)
+	ret0, _ := ret[0].([]*Community)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetAll indicates an expected call of GetAll.
+func (mr *MockCommunityRepoMockRecorder) GetAll(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAll", reflect.TypeOf((*MockCommunityRepo)(nil).GetAll), ctx)
+}
+
+// GetByName mocks base method.
+func (m *MockCommunityRepo) GetByName(ctx context.Context, name string) (*Community, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetByName", ctx, name)
+	ret0, _ := ret[0].(*Community)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetByName indicates an expected call of GetByName.
+func (mr *MockCommunityRepoMockRecorder) GetByName(ctx, name interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetByName", reflect.TypeOf((*MockCommunityRepo)(nil).GetByName), ctx, name)
+}
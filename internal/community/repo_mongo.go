@@ -0,0 +1,99 @@
+package community
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"asperitas/internal/config"
+	"asperitas/internal/errs"
+	"asperitas/pkg/timeout"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type CommunityRepositoryMongo struct {
+	coll    MongoCollection
+	timeout time.Duration
+}
+
+// Подключение к базе общее с репозиторием постов и закрывается его владельцем
+func NewRepoMongo(mongoDB *mongo.Database, cfg config.Mongo) (*CommunityRepositoryMongo, error) {
+	ctx, cancel := timeout.Context(context.Background(), cfg.Timeout)
+	defer cancel()
+	mongoColl := mongoDB.Collection(cfg.Collections.Communities)
+	if _, err := mongoColl.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "name", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	}); err != nil {
+		return nil, fmt.Errorf("mongo create indexes err: %w", err)
+	}
+	repo := &CommunityRepositoryMongo{coll: newMongoCollection(mongoColl), timeout: cfg.Timeout}
+	if err := repo.seed(ctx); err != nil {
+		return nil, err
+	}
+	return repo, nil
+}
+
+// Создает недостающие стартовые сообщества, не трогая уже существующие
+func (repo *CommunityRepositoryMongo) seed(ctx context.Context) error {
+	for _, comm := range Seeds() {
+		if _, err := repo.coll.UpdateOne(
+			ctx,
+			bson.M{"name": comm.Name},
+			bson.M{"$setOnInsert": comm},
+			options.Update().SetUpsert(true),
+		); err != nil {
+			return fmt.Errorf("mongo seed community %q err: %w", comm.Name, err)
+		}
+	}
+	return nil
+}
+
+func (repo *CommunityRepositoryMongo) GetAll(ctx context.Context) ([]*Community, error) {
+	ctx, cancel := timeout.Context(ctx, repo.timeout)
+	defer cancel()
+	cursor, err := repo.coll.Find(
+		ctx,
+		bson.M{},
+		options.Find().SetSort(bson.D{{Key: "name", Value: 1}}),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("mongo find err: %w", timeout.Wrap(ctx, err))
+	}
+	comms := []*Community{}
+	if err = cursor.All(ctx, &comms); err != nil {
+		return nil, fmt.Errorf("mongo all err: %w", timeout.Wrap(ctx, err))
+	}
+	return comms, nil
+}
+
+func (repo *CommunityRepositoryMongo) GetByName(ctx context.Context, name string) (*Community, error) {
+	ctx, cancel := timeout.Context(ctx, repo.timeout)
+	defer cancel()
+	res := repo.coll.FindOne(ctx, bson.M{"name": name})
+	if errors.Is(res.Err(), mongo.ErrNoDocuments) {
+		return nil, errs.MsgError{Msg: "community not found", Status: 404}
+	}
+	comm := &Community{}
+	if err := res.Decode(comm); err != nil {
+		return nil, fmt.Errorf("mongo decode err: %w", timeout.Wrap(ctx, err))
+	}
+	return comm, nil
+}
+
+func (repo *CommunityRepositoryMongo) Add(ctx context.Context, comm *Community) error {
+	ctx, cancel := timeout.Context(ctx, repo.timeout)
+	defer cancel()
+	_, err := repo.coll.InsertOne(ctx, comm)
+	if mongo.IsDuplicateKeyError(err) {
+		return errs.MsgError{Msg: "community already exists", Status: 409}
+	}
+	if err != nil {
+		return fmt.Errorf("mongo insert one err: %w", timeout.Wrap(ctx, err))
+	}
+	return nil
+}
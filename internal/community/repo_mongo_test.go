@@ -0,0 +1,210 @@
+package community
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"asperitas/internal/errs"
+	"asperitas/internal/user"
+
+	"github.com/golang/mock/gomock"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+)
+
+var (
+	ctx = context.Background()
+	usr = user.User{Username: "admin1", ID: "id_admin1"}
+)
+
+func getDoc(v interface{}) (doc bson.D) {
+	data, _ := bson.Marshal(v) // nolint:errcheck
+	bson.Unmarshal(data, &doc) // nolint:errcheck
+	return doc
+}
+
+func TestGetAll_OK(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	defer mt.Close()
+
+	mt.Run(t.Name(), func(mt *mtest.T) {
+		expect := Seeds()[:2]
+		first := mtest.CreateCursorResponse(1, "db.mock", mtest.FirstBatch, getDoc(expect[0]))
+		next := mtest.CreateCursorResponse(1, "db.mock", mtest.NextBatch, getDoc(expect[1]))
+		last := mtest.CreateCursorResponse(0, "db.mock", mtest.NextBatch)
+		mt.AddMockResponses(first, next, last)
+		repo := &CommunityRepositoryMongo{coll: newMongoCollection(mt.Coll)}
+
+		result, err := repo.GetAll(ctx)
+
+		if err != nil {
+			mt.Fatalf("unexpected err: %s", err)
+		}
+		if len(result) != len(expect) {
+			mt.Fatalf("bad result len:\nwant:\t%v\nhave\t%v", len(expect), len(result))
+		}
+		for i := range expect {
+			expect[i].Created = result[i].Created
+			if !reflect.DeepEqual(expect[i], result[i]) {
+				mt.Errorf("results not match:\nwant:\t%#v\nhave\t%#v", expect[i], result[i])
+			}
+		}
+	})
+}
+
+func TestGetByName_OK(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	defer mt.Close()
+
+	mt.Run(t.Name(), func(mt *mtest.T) {
+		expect := NewCommunity(usr, "golang", "Go news", []string{"be nice"})
+		mt.AddMockResponses(mtest.CreateCursorResponse(0, "db.mock", mtest.FirstBatch, getDoc(expect)))
+		repo := &CommunityRepositoryMongo{coll: newMongoCollection(mt.Coll)}
+
+		result, err := repo.GetByName(ctx, "golang")
+
+		if err != nil {
+			mt.Fatalf("unexpected err: %s", err)
+		}
+		expect.Created = result.Created
+		if !reflect.DeepEqual(expect, result) {
+			mt.Errorf("results not match:\nwant:\t%#v\nhave\t%#v", expect, result)
+		}
+	})
+}
+
+func TestGetByName_ErrNotFound(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	defer mt.Close()
+
+	mt.Run(t.Name(), func(mt *mtest.T) {
+		mt.AddMockResponses(mtest.CreateCursorResponse(0, "db.mock", mtest.FirstBatch))
+		repo := &CommunityRepositoryMongo{coll: newMongoCollection(mt.Coll)}
+
+		expect := errs.MsgError{Msg: "community not found", Status: 404}
+		result, err := repo.GetByName(ctx, "cats")
+
+		if result != nil {
+			mt.Errorf("unexpected result: %#v", result)
+		}
+		if !reflect.DeepEqual(expect, err) {
+			mt.Errorf("results not match:\nwant:\t%#v\nhave\t%#v", expect, err)
+		}
+	})
+}
+
+func TestAdd_OK(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	defer mt.Close()
+
+	mt.Run(t.Name(), func(mt *mtest.T) {
+		mt.AddMockResponses(mtest.CreateSuccessResponse())
+		repo := &CommunityRepositoryMongo{coll: newMongoCollection(mt.Coll)}
+
+		if err := repo.Add(ctx, NewCommunity(usr, "golang", "", nil)); err != nil {
+			mt.Errorf("unexpected err: %s", err)
+		}
+	})
+}
+
+func TestAdd_ErrExists(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	defer mt.Close()
+
+	mt.Run(t.Name(), func(mt *mtest.T) {
+		mt.AddMockResponses(mtest.CreateWriteErrorsResponse(mtest.WriteError{
+			Code:    11000,
+			Message: "duplicate key error",
+		}))
+		repo := &CommunityRepositoryMongo{coll: newMongoCollection(mt.Coll)}
+
+		expect := errs.MsgError{Msg: "community already exists", Status: 409}
+		err := repo.Add(ctx, NewCommunity(usr, "music", "", nil))
+
+		if !reflect.DeepEqual(expect, err) {
+			mt.Errorf("results not match:\nwant:\t%#v\nhave\t%#v", expect, err)
+		}
+	})
+}
+
+func TestAdd_InsertErr(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	defer mt.Close()
+
+	mt.Run(t.Name(), func(mt *mtest.T) {
+		mt.AddMockResponses(mtest.CreateWriteErrorsResponse(mtest.WriteError{
+			Message: "some error",
+		}))
+		repo := &CommunityRepositoryMongo{coll: newMongoCollection(mt.Coll)}
+
+		expect := "mongo insert one err"
+		err := repo.Add(ctx, NewCommunity(usr, "golang", "", nil))
+
+		if err == nil || !strings.HasPrefix(err.Error(), expect) {
+			mt.Errorf("unexpected err:\nwant:\t%s\nhave\t%#v", expect, err)
+		}
+	})
+}
+
+func TestSeed_OK(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	defer mt.Close()
+
+	mt.Run(t.Name(), func(mt *mtest.T) {
+		for range Seeds() {
+			mt.AddMockResponses(bson.D{{Key: "ok", Value: 1}, {Key: "n", Value: 1}, {Key: "nModified", Value: 0},
+				{Key: "upserted", Value: bson.A{bson.D{{Key: "index", Value: 0}, {Key: "_id", Value: primitive.NewObjectID()}}}}})
+		}
+		repo := &CommunityRepositoryMongo{coll: newMongoCollection(mt.Coll)}
+
+		if err := repo.seed(ctx); err != nil {
+			mt.Errorf("unexpected err: %s", err)
+		}
+	})
+}
+
+// Дедлайн, истекший в драйвере, отдается как context.DeadlineExceeded
+func TestGetAll_CursorErr(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	coll := NewMockMongoCollection(ctrl)
+	cursor := NewMockMongoCursor(ctrl)
+	repo := &CommunityRepositoryMongo{coll: coll}
+
+	coll.EXPECT().
+		Find(gomock.Any(), bson.M{}, gomock.Any()).
+		Return(cursor, nil)
+	cursor.EXPECT().
+		All(gomock.Any(), gomock.Any()).
+		Return(fmt.Errorf("%w: cursor timeout", context.DeadlineExceeded))
+
+	result, err := repo.GetAll(ctx)
+
+	if result != nil {
+		t.Errorf("unexpected result: %#v", result)
+	}
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("results not match:\nwant:\t%#v\nhave\t%#v", context.DeadlineExceeded, err)
+	}
+}
+
+func TestSeed_UpdateErr(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	coll := NewMockMongoCollection(ctrl)
+	repo := &CommunityRepositoryMongo{coll: coll}
+
+	expect := fmt.Errorf("some err")
+	coll.EXPECT().
+		UpdateOne(gomock.Any(), bson.M{"name": Seeds()[0].Name}, gomock.Any(), gomock.Any()).
+		Return(nil, expect)
+
+	if err := repo.seed(ctx); !errors.Is(err, expect) {
+		t.Errorf("results not match:\nwant:\t%#v\nhave\t%#v", expect, err)
+	}
+}
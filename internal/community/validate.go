@@ -0,0 +1,28 @@
+package community
+
+import (
+	"fmt"
+	"regexp"
+
+	"asperitas/internal/validate"
+)
+
+const (
+	MaxDescriptionLen = 500
+	MaxRules          = 15
+	MaxRuleLen        = 100
+)
+
+var nameRe = regexp.MustCompile(`^[a-z0-9_]{3,21}$`)
+
+func (c *Community) Validate(v *validate.Validator) {
+	v.Check(nameRe.MatchString(c.Name), "name", c.Name, "must be 3 to 21 lowercase letters, digits or underscores")
+	v.Length("description", c.Description, 0, MaxDescriptionLen)
+	v.Printable("description", c.Description, true)
+	v.Check(len(c.Rules) <= MaxRules, "rules", "", fmt.Sprintf("must be at most %d rules", MaxRules))
+	for i, rule := range c.Rules {
+		param := fmt.Sprintf("rules[%d]", i)
+		v.Length(param, rule, 1, MaxRuleLen)
+		v.Printable(param, rule, false)
+	}
+}
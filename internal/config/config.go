@@ -0,0 +1,272 @@
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"net/url"
+	"os"
+	"time"
+
+	"asperitas/pkg/clientip"
+
+	"golang.org/x/crypto/bcrypt"
+	"gopkg.in/yaml.v3"
+)
+
+// Переменная окружения с путем к файлу конфигурации, если не задан флаг -config
+const EnvConfigPath = "ASPERITAS_CONFIG"
+
+type Config struct {
+	Server  Server  `yaml:"server"`
+	MySQL   MySQL   `yaml:"mysql"`
+	Mongo   Mongo   `yaml:"mongo"`
+	Session Session `yaml:"session"`
+	User    User    `yaml:"user"`
+	Lockout Lockout `yaml:"lockout"`
+	Tracing Tracing `yaml:"tracing"`
+}
+
+// TrustedProxies задаются через запятую подсетями CIDR или адресами; только от них
+// принимается X-Forwarded-For при определении адреса клиента
+type Server struct {
+	Addr            string        `yaml:"addr"`
+	ReadTimeout     time.Duration `yaml:"read_timeout"`
+	WriteTimeout    time.Duration `yaml:"write_timeout"`
+	IdleTimeout     time.Duration `yaml:"idle_timeout"`
+	DrainDelay      time.Duration `yaml:"drain_delay"`
+	ShutdownTimeout time.Duration `yaml:"shutdown_timeout"`
+	ReadyTimeout    time.Duration `yaml:"ready_timeout"`
+	TrustedProxies  string        `yaml:"trusted_proxies"`
+}
+
+// Timeout ограничивает одну операцию репозитория, 0 снимает ограничение
+type MySQL struct {
+	Addr    string        `yaml:"addr"`
+	Timeout time.Duration `yaml:"timeout"`
+}
+
+type Mongo struct {
+	Addr        string        `yaml:"addr"`
+	Database    string        `yaml:"database"`
+	Timeout     time.Duration `yaml:"timeout"`
+	Collections Collections   `yaml:"collections"`
+}
+
+type Collections struct {
+	Posts       string `yaml:"posts"`
+	Votes       string `yaml:"votes"`
+	Comments    string `yaml:"comments"`
+	Revisions   string `yaml:"revisions"`
+	Communities string `yaml:"communities"`
+}
+
+// Keys задаются в виде "kid:alg:path,kid:alg:path". Без ключей сервер не запускается,
+// если AllowEphemeralKey не разрешает случайный ключ, живущий до перезапуска процесса
+type Session struct {
+	Keys              string        `yaml:"keys"`
+	KeyID             string        `yaml:"key_id"`
+	AllowEphemeralKey bool          `yaml:"allow_ephemeral_key"`
+	AccessLifetime    time.Duration `yaml:"access_lifetime"`
+	Lifetime          time.Duration `yaml:"lifetime"`
+}
+
+type User struct {
+	PasswCost int `yaml:"passw_cost"`
+}
+
+// После Threshold неудачных входов в логин (IPThreshold — с одного IP) вход блокируется на Delay,
+// каждая следующая неудача удваивает блокировку, но не больше MaxDelay. Неудачи старше Window забываются.
+type Lockout struct {
+	Threshold   int           `yaml:"threshold"`
+	IPThreshold int           `yaml:"ip_threshold"`
+	Delay       time.Duration `yaml:"delay"`
+	MaxDelay    time.Duration `yaml:"max_delay"`
+	Window      time.Duration `yaml:"window"`
+}
+
+// Exporter: "none" отключает трассировку, "file" пишет спаны в формате OTLP/JSON в файл File,
+// "otlp" отправляет их по OTLP/HTTP на Endpoint вида http://localhost:4318
+type Tracing struct {
+	Exporter    string  `yaml:"exporter"`
+	File        string  `yaml:"file"`
+	Endpoint    string  `yaml:"endpoint"`
+	ServiceName string  `yaml:"service_name"`
+	SampleRatio float64 `yaml:"sample_ratio"`
+}
+
+func Default() Config {
+	return Config{
+		Server: Server{
+			Addr:            ":8080",
+			ReadTimeout:     10 * time.Second,
+			WriteTimeout:    15 * time.Second,
+			IdleTimeout:     60 * time.Second,
+			DrainDelay:      5 * time.Second,
+			ShutdownTimeout: 20 * time.Second,
+			ReadyTimeout:    time.Second,
+		},
+		MySQL: MySQL{
+			Addr:    `root:admin@tcp(localhost:3306)/vk-go?charset=utf8&interpolateParams=true&parseTime=true`,
+			Timeout: 5 * time.Second,
+		},
+		Mongo: Mongo{
+			Addr:     `mongodb://localhost:27017`,
+			Database: "vk-go",
+			Timeout:  5 * time.Second,
+			Collections: Collections{
+				Posts:       "posts",
+				Votes:       "votes",
+				Comments:    "comments",
+				Revisions:   "revisions",
+				Communities: "communities",
+			},
+		},
+		Session: Session{
+			AccessLifetime: 15 * time.Minute,
+			Lifetime:       7 * 24 * time.Hour,
+		},
+		User: User{
+			PasswCost: bcrypt.DefaultCost,
+		},
+		Lockout: Lockout{
+			Threshold:   5,
+			IPThreshold: 20,
+			Delay:       time.Minute,
+			MaxDelay:    time.Hour,
+			Window:      24 * time.Hour,
+		},
+		Tracing: Tracing{
+			Exporter:    "none",
+			File:        "traces.jsonl",
+			Endpoint:    "http://localhost:4318",
+			ServiceName: "asperitas",
+			SampleRatio: 1,
+		},
+	}
+}
+
+// Собирает конфигурацию по слоям: значения по умолчанию, файл, переменные окружения, флаги.
+// Каждый следующий слой перекрывает предыдущий.
+func Load(name string, args []string) (*Config, error) {
+	cfg := Default()
+	fs := flag.NewFlagSet(name, flag.ExitOnError)
+	path := fs.String("config", os.Getenv(EnvConfigPath), "path to yaml or json config file, also "+EnvConfigPath)
+	settings := cfg.settings()
+	for _, s := range settings {
+		fs.Var(s.value, s.flag, fmt.Sprintf("%s, also %s", s.usage, s.env))
+	}
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+	// флаги уже записаны в cfg, но должны перекрыть файл и окружение, поэтому запоминаем их отдельно
+	passed := map[string]string{}
+	fs.Visit(func(f *flag.Flag) {
+		passed[f.Name] = f.Value.String()
+	})
+
+	cfg = Default()
+	if *path != "" {
+		if err := cfg.readFile(*path); err != nil {
+			return nil, err
+		}
+	}
+	for _, s := range settings {
+		if env, ok := os.LookupEnv(s.env); ok {
+			if err := s.value.Set(env); err != nil {
+				return nil, fmt.Errorf("bad env %s: %w", s.env, err)
+			}
+		}
+	}
+	for _, s := range settings {
+		if value, ok := passed[s.flag]; ok {
+			if err := s.value.Set(value); err != nil {
+				return nil, fmt.Errorf("bad flag -%s: %w", s.flag, err)
+			}
+		}
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return &cfg, nil
+}
+
+// JSON является подмножеством YAML, поэтому оба формата читаются одним декодером.
+// Неизвестные ключи считаются ошибкой, чтобы опечатка не проходила молча.
+func (cfg *Config) readFile(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("open config err: %w", err)
+	}
+	defer file.Close()
+	dec := yaml.NewDecoder(file)
+	dec.KnownFields(true)
+	if err = dec.Decode(cfg); err != nil {
+		return fmt.Errorf("decode config %s err: %w", path, err)
+	}
+	return nil
+}
+
+func (cfg *Config) Validate() error {
+	var errList []error
+	check := func(ok bool, format string, args ...interface{}) {
+		if !ok {
+			errList = append(errList, fmt.Errorf(format, args...))
+		}
+	}
+
+	check(cfg.Server.Addr != "", "server addr is empty")
+	check(cfg.Server.ReadTimeout > 0, "server read timeout must be positive")
+	check(cfg.Server.WriteTimeout > 0, "server write timeout must be positive")
+	check(cfg.Server.IdleTimeout >= 0, "server idle timeout must not be negative")
+	check(cfg.Server.DrainDelay >= 0, "server drain delay must not be negative")
+	check(cfg.Server.ShutdownTimeout > 0, "server shutdown timeout must be positive")
+	check(cfg.Server.ReadyTimeout > 0, "server ready timeout must be positive")
+	_, err := clientip.NewResolver(cfg.Server.TrustedProxies)
+	check(err == nil, "server trusted proxies: %v", err)
+
+	check(cfg.MySQL.Addr != "", "mysql addr is empty")
+	check(cfg.MySQL.Timeout >= 0, "mysql timeout must not be negative")
+	check(cfg.Mongo.Addr != "", "mongo addr is empty")
+	check(cfg.Mongo.Database != "", "mongo database is empty")
+	check(cfg.Mongo.Timeout >= 0, "mongo timeout must not be negative")
+	colls := cfg.Mongo.Collections
+	check(colls.Posts != "", "mongo posts collection is empty")
+	check(colls.Votes != "", "mongo votes collection is empty")
+	check(colls.Comments != "", "mongo comments collection is empty")
+	check(colls.Revisions != "", "mongo revisions collection is empty")
+	check(colls.Communities != "", "mongo communities collection is empty")
+
+	check(cfg.Session.Keys == "" || cfg.Session.KeyID != "", "session key id is required with keys")
+	check(cfg.Session.AccessLifetime > 0, "session access lifetime must be positive")
+	check(cfg.Session.Lifetime >= cfg.Session.AccessLifetime, "session lifetime must not be shorter than access lifetime")
+
+	check(cfg.User.PasswCost >= bcrypt.MinCost && cfg.User.PasswCost <= bcrypt.MaxCost,
+		"user passw cost must be in [%d, %d]", bcrypt.MinCost, bcrypt.MaxCost)
+
+	check(cfg.Lockout.Threshold > 0, "lockout threshold must be positive")
+	check(cfg.Lockout.IPThreshold > 0, "lockout ip threshold must be positive")
+	check(cfg.Lockout.Delay > 0, "lockout delay must be positive")
+	check(cfg.Lockout.MaxDelay >= cfg.Lockout.Delay, "lockout max delay must not be shorter than delay")
+	check(cfg.Lockout.Window >= cfg.Lockout.MaxDelay, "lockout window must not be shorter than max delay")
+
+	switch cfg.Tracing.Exporter {
+	case "none":
+	case "file":
+		check(cfg.Tracing.File != "", "tracing file is empty")
+	case "otlp":
+		endpoint, err := url.Parse(cfg.Tracing.Endpoint)
+		check(err == nil && (endpoint.Scheme == "http" || endpoint.Scheme == "https") && endpoint.Host != "",
+			"tracing endpoint must be http(s) url")
+	default:
+		check(false, "unknown tracing exporter %q, want one of none, file, otlp", cfg.Tracing.Exporter)
+	}
+	check(cfg.Tracing.ServiceName != "", "tracing service name is empty")
+	check(cfg.Tracing.SampleRatio >= 0 && cfg.Tracing.SampleRatio <= 1, "tracing sample ratio must be in [0, 1]")
+
+	if len(errList) > 0 {
+		return fmt.Errorf("invalid config: %w", errors.Join(errList...))
+	}
+	return nil
+}
@@ -0,0 +1,129 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeFile(t *testing.T, name, data string) string {
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+		t.Fatalf("write config err: %s", err)
+	}
+	return path
+}
+
+func TestLoad_Default(t *testing.T) {
+	cfg, err := Load("test", nil)
+	if err != nil {
+		t.Fatalf("unexpected err: %s", err)
+	}
+	if expect := Default(); !reflect.DeepEqual(expect, *cfg) {
+		t.Errorf("results not match:\nwant:\t%#v\nhave\t%#v", expect, *cfg)
+	}
+}
+
+func TestLoad_Layers(t *testing.T) {
+	path := writeFile(t, "config.yaml", `
+server:
+  addr: ":9000"
+  read_timeout: 3s
+mongo:
+  database: staging
+  collections:
+    posts: staging_posts
+session:
+  lifetime: 24h
+`)
+	t.Setenv(EnvConfigPath, path)
+	t.Setenv("ASPERITAS_ADDR", ":9001")
+	t.Setenv("ASPERITAS_MONGO_DATABASE", "from_env")
+
+	cfg, err := Load("test", []string{"-addr", ":9002", "-passwCost", "5", "-jwtEphemeral"})
+	if err != nil {
+		t.Fatalf("unexpected err: %s", err)
+	}
+
+	expect := Default()
+	expect.Server.Addr = ":9002"
+	expect.Server.ReadTimeout = 3 * time.Second
+	expect.Mongo.Database = "from_env"
+	expect.Mongo.Collections.Posts = "staging_posts"
+	expect.Session.Lifetime = 24 * time.Hour
+	expect.Session.AllowEphemeralKey = true
+	expect.User.PasswCost = 5
+	if !reflect.DeepEqual(expect, *cfg) {
+		t.Errorf("results not match:\nwant:\t%#v\nhave\t%#v", expect, *cfg)
+	}
+}
+
+func TestLoad_JSON(t *testing.T) {
+	path := writeFile(t, "config.json", `{"mysql": {"addr": "user@tcp(db)/app", "timeout": "2s"}}`)
+
+	cfg, err := Load("test", []string{"-config", path})
+	if err != nil {
+		t.Fatalf("unexpected err: %s", err)
+	}
+	if expect := (MySQL{Addr: "user@tcp(db)/app", Timeout: 2 * time.Second}); cfg.MySQL != expect {
+		t.Errorf("results not match:\nwant:\t%#v\nhave\t%#v", expect, cfg.MySQL)
+	}
+}
+
+func TestLoad_UnknownField(t *testing.T) {
+	path := writeFile(t, "config.yaml", "server:\n  adr: \":9000\"\n")
+
+	if _, err := Load("test", []string{"-config", path}); err == nil {
+		t.Errorf("expected err on unknown field")
+	}
+}
+
+func TestLoad_BadEnv(t *testing.T) {
+	t.Setenv("ASPERITAS_MYSQL_TIMEOUT", "five seconds")
+
+	_, err := Load("test", nil)
+	if err == nil || !strings.Contains(err.Error(), "ASPERITAS_MYSQL_TIMEOUT") {
+		t.Errorf("unexpected err: %v", err)
+	}
+}
+
+func TestValidate_Err(t *testing.T) {
+	cfg := Default()
+	cfg.Server.Addr = ""
+	cfg.Server.TrustedProxies = "10.0.0.0/8,proxy.local"
+	cfg.Mongo.Collections.Votes = ""
+	cfg.Session.Keys = "kid:HS256:/some/path"
+	cfg.Session.Lifetime = time.Minute
+	cfg.User.PasswCost = 100
+
+	err := cfg.Validate()
+	if err == nil {
+		t.Fatalf("expected validation err")
+	}
+	for _, msg := range []string{
+		"server addr is empty",
+		"server trusted proxies",
+		"mongo votes collection is empty",
+		"session key id is required with keys",
+		"session lifetime must not be shorter than access lifetime",
+		"user passw cost must be in",
+	} {
+		if !strings.Contains(err.Error(), msg) {
+			t.Errorf("no %q in err: %s", msg, err)
+		}
+	}
+}
+
+// Пример из корня репозитория должен оставаться валидным и совпадать с умолчаниями
+func TestLoad_Example(t *testing.T) {
+	cfg, err := Load("test", []string{"-config", "../../config.example.yaml"})
+	if err != nil {
+		t.Fatalf("unexpected err: %s", err)
+	}
+	if expect := Default(); !reflect.DeepEqual(expect, *cfg) {
+		t.Errorf("results not match:\nwant:\t%#v\nhave\t%#v", expect, *cfg)
+	}
+}
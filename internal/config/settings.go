@@ -0,0 +1,152 @@
+package config
+
+import (
+	"flag"
+	"strconv"
+	"time"
+)
+
+// Настройка, которую можно переопределить флагом и переменной окружения
+type setting struct {
+	flag  string
+	env   string
+	usage string
+	value flag.Value
+}
+
+// Флаги mySQLAddr, mongoAddr, passwCost, jwtKeys и jwtKeyID сохранили прежние имена
+func (cfg *Config) settings() []setting {
+	return []setting{
+		{"addr", "ASPERITAS_ADDR", "listen addr", (*stringValue)(&cfg.Server.Addr)},
+		{"readTimeout", "ASPERITAS_READ_TIMEOUT", "deadline of reading a whole request", (*durationValue)(&cfg.Server.ReadTimeout)},
+		{"writeTimeout", "ASPERITAS_WRITE_TIMEOUT", "deadline of writing a response, should exceed db timeouts", (*durationValue)(&cfg.Server.WriteTimeout)},
+		{"idleTimeout", "ASPERITAS_IDLE_TIMEOUT", "keep-alive connection idle timeout", (*durationValue)(&cfg.Server.IdleTimeout)},
+		{"drainDelay", "ASPERITAS_DRAIN_DELAY", "pause between failing /readyz and closing listener on shutdown", (*durationValue)(&cfg.Server.DrainDelay)},
+		{"shutdownTimeout", "ASPERITAS_SHUTDOWN_TIMEOUT", "deadline of draining in-flight requests on shutdown", (*durationValue)(&cfg.Server.ShutdownTimeout)},
+		{"readyTimeout", "ASPERITAS_READY_TIMEOUT", "deadline of pinging dependencies in /readyz", (*durationValue)(&cfg.Server.ReadyTimeout)},
+		{"trustedProxies", "ASPERITAS_TRUSTED_PROXIES", "proxies allowed to set X-Forwarded-For as cidrs or ips separated by commas", (*stringValue)(&cfg.Server.TrustedProxies)},
+
+		{"mySQLAddr", "ASPERITAS_MYSQL_ADDR", "mysql addr", (*stringValue)(&cfg.MySQL.Addr)},
+		{"mySQLTimeout", "ASPERITAS_MYSQL_TIMEOUT", "deadline of a single mysql operation, 0 disables it", (*durationValue)(&cfg.MySQL.Timeout)},
+
+		{"mongoAddr", "ASPERITAS_MONGO_ADDR", "mongo addr", (*stringValue)(&cfg.Mongo.Addr)},
+		{"mongoDatabase", "ASPERITAS_MONGO_DATABASE", "mongo database name", (*stringValue)(&cfg.Mongo.Database)},
+		{"mongoTimeout", "ASPERITAS_MONGO_TIMEOUT", "deadline of a single mongo operation, 0 disables it", (*durationValue)(&cfg.Mongo.Timeout)},
+		{"postsCollection", "ASPERITAS_POSTS_COLLECTION", "mongo posts collection", (*stringValue)(&cfg.Mongo.Collections.Posts)},
+		{"votesCollection", "ASPERITAS_VOTES_COLLECTION", "mongo votes collection", (*stringValue)(&cfg.Mongo.Collections.Votes)},
+		{"commentsCollection", "ASPERITAS_COMMENTS_COLLECTION", "mongo comments collection", (*stringValue)(&cfg.Mongo.Collections.Comments)},
+		{"revisionsCollection", "ASPERITAS_REVISIONS_COLLECTION", "mongo post revisions collection", (*stringValue)(&cfg.Mongo.Collections.Revisions)},
+		{"communitiesCollection", "ASPERITAS_COMMUNITIES_COLLECTION", "mongo communities collection", (*stringValue)(&cfg.Mongo.Collections.Communities)},
+
+		{"jwtKeys", "ASPERITAS_JWT_KEYS", "jwt keys as kid:alg:path separated by commas, alg is one of HS256, RS256, EdDSA", (*stringValue)(&cfg.Session.Keys)},
+		{"jwtKeyID", "ASPERITAS_JWT_KEY_ID", "kid of jwt key used for signing new tokens", (*stringValue)(&cfg.Session.KeyID)},
+		{"jwtEphemeral", "ASPERITAS_JWT_EPHEMERAL", "allow random jwt key living until restart when no keys set, for development only", (*boolValue)(&cfg.Session.AllowEphemeralKey)},
+		{"accessLifetime", "ASPERITAS_ACCESS_LIFETIME", "lifetime of access token", (*durationValue)(&cfg.Session.AccessLifetime)},
+		{"sessionLifetime", "ASPERITAS_SESSION_LIFETIME", "lifetime of session and its refresh token", (*durationValue)(&cfg.Session.Lifetime)},
+
+		{"passwCost", "ASPERITAS_PASSW_COST", "bcrypt cost of password hashing", (*intValue)(&cfg.User.PasswCost)},
+
+		{"lockoutThreshold", "ASPERITAS_LOCKOUT_THRESHOLD", "failed logins into one username before lockout", (*intValue)(&cfg.Lockout.Threshold)},
+		{"lockoutIPThreshold", "ASPERITAS_LOCKOUT_IP_THRESHOLD", "failed logins from one ip before lockout", (*intValue)(&cfg.Lockout.IPThreshold)},
+		{"lockoutDelay", "ASPERITAS_LOCKOUT_DELAY", "first lockout duration, doubled by each next failure", (*durationValue)(&cfg.Lockout.Delay)},
+		{"lockoutMaxDelay", "ASPERITAS_LOCKOUT_MAX_DELAY", "max lockout duration", (*durationValue)(&cfg.Lockout.MaxDelay)},
+		{"lockoutWindow", "ASPERITAS_LOCKOUT_WINDOW", "failed logins older than this are forgotten", (*durationValue)(&cfg.Lockout.Window)},
+
+		{"tracingExporter", "ASPERITAS_TRACING_EXPORTER", "span exporter, one of none, file, otlp", (*stringValue)(&cfg.Tracing.Exporter)},
+		{"tracingFile", "ASPERITAS_TRACING_FILE", "file for otlp json spans of file exporter", (*stringValue)(&cfg.Tracing.File)},
+		{"tracingEndpoint", "ASPERITAS_TRACING_ENDPOINT", "otlp/http collector url of otlp exporter", (*stringValue)(&cfg.Tracing.Endpoint)},
+		{"tracingServiceName", "ASPERITAS_TRACING_SERVICE_NAME", "service.name resource attribute of spans", (*stringValue)(&cfg.Tracing.ServiceName)},
+		{"tracingSampleRatio", "ASPERITAS_TRACING_SAMPLE_RATIO", "share of sampled root traces in [0, 1]", (*floatValue)(&cfg.Tracing.SampleRatio)},
+	}
+}
+
+type stringValue string
+
+func (v *stringValue) String() string {
+	if v == nil {
+		return ""
+	}
+	return string(*v)
+}
+
+func (v *stringValue) Set(s string) error {
+	*v = stringValue(s)
+	return nil
+}
+
+type durationValue time.Duration
+
+func (v *durationValue) String() string {
+	if v == nil {
+		return "0s"
+	}
+	return time.Duration(*v).String()
+}
+
+func (v *durationValue) Set(s string) error {
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*v = durationValue(d)
+	return nil
+}
+
+type floatValue float64
+
+func (v *floatValue) String() string {
+	if v == nil {
+		return "0"
+	}
+	return strconv.FormatFloat(float64(*v), 'g', -1, 64)
+}
+
+func (v *floatValue) Set(s string) error {
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return err
+	}
+	*v = floatValue(f)
+	return nil
+}
+
+type boolValue bool
+
+func (v *boolValue) String() string {
+	if v == nil {
+		return "false"
+	}
+	return strconv.FormatBool(bool(*v))
+}
+
+func (v *boolValue) Set(s string) error {
+	b, err := strconv.ParseBool(s)
+	if err != nil {
+		return err
+	}
+	*v = boolValue(b)
+	return nil
+}
+
+// Флаг можно передать без значения: -jwtEphemeral
+func (v *boolValue) IsBoolFlag() bool {
+	return true
+}
+
+type intValue int
+
+func (v *intValue) String() string {
+	if v == nil {
+		return "0"
+	}
+	return strconv.Itoa(int(*v))
+}
+
+func (v *intValue) Set(s string) error {
+	n, err := strconv.Atoi(s)
+	if err != nil {
+		return err
+	}
+	*v = intValue(n)
+	return nil
+}
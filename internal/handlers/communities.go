@@ -0,0 +1,65 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+
+	"asperitas/internal/community"
+	"asperitas/internal/session"
+	"asperitas/internal/validate"
+
+	"github.com/gorilla/mux"
+	"go.uber.org/zap"
+)
+
+type CommunityHandler struct {
+	Sess   session.SessionManager
+	Repo   community.CommunityRepo
+	Logger *zap.SugaredLogger
+}
+
+func (h *CommunityHandler) ListCommunities(w http.ResponseWriter, r *http.Request) {
+	comms, err := h.Repo.GetAll(r.Context())
+	if err != nil {
+		WriteAndLogErr(w, r, err, h.Logger, "get all communities err")
+		return
+	}
+	WriteAndLogData(w, r, comms, h.Logger, "listed all communities")
+}
+
+func (h *CommunityHandler) CreateCommunity(w http.ResponseWriter, r *http.Request) {
+	usr, ok := sessionCheck(w, r, h.Logger, h.Sess)
+	if !ok {
+		return
+	}
+	defer r.Body.Close()
+	var req createCommunityRequest
+	if err := decodeBody(w, r, &req, maxPostBody); err != nil {
+		WriteAndLogErr(w, r, err, h.Logger, "decode json err")
+		return
+	}
+	comm := req.toCommunity(usr)
+	v := validate.New()
+	comm.Validate(v)
+	if err := v.Err(); err != nil {
+		WriteAndLogErr(w, r, err, h.Logger, "community valid err")
+		return
+	}
+	if err := h.Repo.Add(r.Context(), comm); err != nil {
+		WriteAndLogErr(w, r, err, h.Logger, "add community err")
+		return
+	}
+	logStr := fmt.Sprintf("created community: name=%s", comm.Name)
+	WriteAndLogData(w, r, comm, h.Logger, logStr)
+}
+
+func (h *CommunityHandler) ShowCommunity(w http.ResponseWriter, r *http.Request) {
+	name := mux.Vars(r)["name"]
+	comm, err := h.Repo.GetByName(r.Context(), name)
+	if err != nil {
+		WriteAndLogErr(w, r, err, h.Logger, "get community by name err")
+		return
+	}
+	logStr := fmt.Sprintf("showed community: name=%s", name)
+	WriteAndLogData(w, r, comm, h.Logger, logStr)
+}
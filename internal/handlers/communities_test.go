@@ -0,0 +1,185 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http/httptest"
+	"testing"
+
+	"asperitas/internal/community"
+	"asperitas/internal/errs"
+	"asperitas/internal/session"
+
+	"github.com/golang/mock/gomock"
+	"github.com/gorilla/mux"
+	"go.uber.org/zap"
+)
+
+func getMockCommunityService(t *testing.T) (*CommunityHandler, *session.MockSessionManager, *community.MockCommunityRepo) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mng := session.NewMockSessionManager(ctrl)
+	db := community.NewMockCommunityRepo(ctrl)
+	return &CommunityHandler{
+		Sess:   mng,
+		Repo:   db,
+		Logger: zap.NewNop().Sugar(),
+	}, mng, db
+}
+
+func TestListCommunities_OK(t *testing.T) {
+	service, _, db := getMockCommunityService(t)
+
+	expect := community.Seeds()
+	expectBody, _ := json.Marshal(expect) // nolint:errcheck
+
+	req := httptest.NewRequest("GET", "/api/communities", nil)
+	w := httptest.NewRecorder()
+
+	db.EXPECT().
+		GetAll(gomock.Any()).
+		Return(expect, nil)
+
+	service.ListCommunities(w, req)
+
+	resp := w.Result()
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body) // nolint:errcheck
+
+	if resp.StatusCode != 200 {
+		t.Errorf("wrong status code:\nwant:\t%d\nhave\t%d", 200, resp.StatusCode)
+	}
+	if string(body) != string(expectBody) {
+		t.Errorf("results not match:\nwant:\t%s\nhave\t%s", expectBody, body)
+	}
+}
+
+func TestCreateCommunity_OK(t *testing.T) {
+	service, mng, db := getMockCommunityService(t)
+
+	reqBody := bytes.NewBufferString(`{"name":"golang","description":"Go news","rules":["be nice"]}`)
+	req := httptest.NewRequest("POST", "/api/communities", reqBody)
+	w := httptest.NewRecorder()
+
+	mng.EXPECT().
+		Check(gomock.Any(), gomock.Any()).
+		Return(usr1, nil)
+	db.EXPECT().
+		Add(gomock.Any(), gomock.Any()).
+		DoAndReturn(func(_ context.Context, comm *community.Community) error {
+			if comm.Name != "golang" || comm.Description != "Go news" || comm.Creator.ID != usr1.ID {
+				t.Errorf("wrong community: %+v", comm)
+			}
+			return nil
+		})
+
+	service.CreateCommunity(w, req)
+
+	resp := w.Result()
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body) // nolint:errcheck
+
+	if resp.StatusCode != 200 {
+		t.Errorf("wrong status code:\nwant:\t%d\nhave\t%d", 200, resp.StatusCode)
+	}
+	result := community.Community{}
+	if err := json.Unmarshal(body, &result); err != nil || result.Name != "golang" || len(result.Rules) != 1 {
+		t.Errorf("bad resp body: %s", body)
+	}
+}
+
+func TestCreateCommunity_InvalidNameErr(t *testing.T) {
+	service, mng, _ := getMockCommunityService(t)
+
+	expect := errs.DetailErrors{Errors: []errs.DetailError{
+		{
+			Location: "body",
+			Param:    "name",
+			Value:    "Go!",
+			Msg:      "must be 3 to 21 lowercase letters, digits or underscores",
+		},
+	}, Status: 422}
+	expectBody, _ := json.Marshal(expect) // nolint:errcheck
+
+	reqBody := bytes.NewBufferString(`{"name":"Go!"}`)
+	req := httptest.NewRequest("POST", "/api/communities", reqBody)
+	w := httptest.NewRecorder()
+
+	mng.EXPECT().
+		Check(gomock.Any(), gomock.Any()).
+		Return(usr1, nil)
+
+	service.CreateCommunity(w, req)
+
+	resp := w.Result()
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body) // nolint:errcheck
+
+	if resp.StatusCode != expect.Status {
+		t.Errorf("wrong status code:\nwant:\t%d\nhave\t%d", expect.Status, resp.StatusCode)
+	}
+	if string(body) != string(expectBody) {
+		t.Errorf("results not match:\nwant:\t%s\nhave\t%s", expectBody, body)
+	}
+}
+
+func TestCreateCommunity_ExistsErr(t *testing.T) {
+	service, mng, db := getMockCommunityService(t)
+
+	expect := errs.MsgError{Msg: "community already exists", Status: 409}
+	expectBody, _ := json.Marshal(expect) // nolint:errcheck
+
+	reqBody := bytes.NewBufferString(`{"name":"music"}`)
+	req := httptest.NewRequest("POST", "/api/communities", reqBody)
+	w := httptest.NewRecorder()
+
+	mng.EXPECT().
+		Check(gomock.Any(), gomock.Any()).
+		Return(usr1, nil)
+	db.EXPECT().
+		Add(gomock.Any(), gomock.Any()).
+		Return(expect)
+
+	service.CreateCommunity(w, req)
+
+	resp := w.Result()
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body) // nolint:errcheck
+
+	if resp.StatusCode != expect.Status {
+		t.Errorf("wrong status code:\nwant:\t%d\nhave\t%d", expect.Status, resp.StatusCode)
+	}
+	if string(body) != string(expectBody) {
+		t.Errorf("results not match:\nwant:\t%s\nhave\t%s", expectBody, body)
+	}
+}
+
+func TestShowCommunity_ErrNotFound(t *testing.T) {
+	service, _, db := getMockCommunityService(t)
+
+	expect := errs.MsgError{Msg: "community not found", Status: 404}
+	expectBody, _ := json.Marshal(expect) // nolint:errcheck
+
+	req := httptest.NewRequest("GET", "/api/communities/{name}", nil)
+	req = mux.SetURLVars(req, map[string]string{"name": "cats"})
+	w := httptest.NewRecorder()
+
+	db.EXPECT().
+		GetByName(gomock.Any(), "cats").
+		Return(nil, expect)
+
+	service.ShowCommunity(w, req)
+
+	resp := w.Result()
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body) // nolint:errcheck
+
+	if resp.StatusCode != expect.Status {
+		t.Errorf("wrong status code:\nwant:\t%d\nhave\t%d", expect.Status, resp.StatusCode)
+	}
+	if string(body) != string(expectBody) {
+		t.Errorf("results not match:\nwant:\t%s\nhave\t%s", expectBody, body)
+	}
+}
@@ -0,0 +1,101 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Зависимость, доступность которой проверяет /readyz
+type Pinger interface {
+	Ping(ctx context.Context) error
+}
+
+type HealthCheck struct {
+	Name   string
+	Pinger Pinger
+}
+
+type HealthHandler struct {
+	Checks  []HealthCheck
+	Timeout time.Duration
+	Logger  *zap.SugaredLogger
+
+	draining atomic.Bool
+}
+
+// Текст ошибки только логируется: /readyz открыт наружу, а в ошибках бывают адреса и имена баз
+type checkStatus struct {
+	Status string `json:"status"`
+}
+
+type healthStatus struct {
+	Status string                 `json:"status"`
+	Checks map[string]checkStatus `json:"checks,omitempty"`
+}
+
+// Переводит /readyz в отказ, чтобы балансировщик перестал слать новые запросы,
+// пока сервер дорабатывает текущие
+func (h *HealthHandler) Drain() {
+	h.draining.Store(true)
+}
+
+// Процесс жив и обрабатывает запросы, базы не проверяются
+func (h *HealthHandler) Healthz(w http.ResponseWriter, r *http.Request) {
+	h.write(w, http.StatusOK, healthStatus{Status: "ok"})
+}
+
+// Все зависимости пингуются параллельно, каждая со своим таймаутом
+func (h *HealthHandler) Readyz(w http.ResponseWriter, r *http.Request) {
+	if h.draining.Load() {
+		h.write(w, http.StatusServiceUnavailable, healthStatus{Status: "shutting down"})
+		return
+	}
+
+	resp := healthStatus{Status: "ok", Checks: make(map[string]checkStatus, len(h.Checks))}
+	errList := make([]error, len(h.Checks))
+	wg := &sync.WaitGroup{}
+	for i, check := range h.Checks {
+		wg.Add(1)
+		go func(i int, check HealthCheck) {
+			defer wg.Done()
+			ctx, cancel := context.WithTimeout(r.Context(), h.Timeout)
+			defer cancel()
+			errList[i] = check.Pinger.Ping(ctx)
+		}(i, check)
+	}
+	wg.Wait()
+
+	code := http.StatusOK
+	for i, check := range h.Checks {
+		if errList[i] == nil {
+			resp.Checks[check.Name] = checkStatus{Status: "ok"}
+			continue
+		}
+		resp.Checks[check.Name] = checkStatus{Status: "fail"}
+		resp.Status = "unavailable"
+		code = http.StatusServiceUnavailable
+		h.Logger.Warnf("readiness check %s failed: %s", check.Name, errList[i])
+	}
+	h.write(w, code, resp)
+}
+
+// Пробы дергаются часто, поэтому успешные ответы не логируются
+func (h *HealthHandler) write(w http.ResponseWriter, code int, resp healthStatus) {
+	data, err := json.Marshal(resp)
+	if err != nil {
+		h.Logger.Errorf("encode data to json err: %s", err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(code)
+	if _, err = w.Write(data); err != nil {
+		h.Logger.Errorf("write resp err: %s", err)
+	}
+}
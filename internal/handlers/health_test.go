@@ -0,0 +1,134 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+type pingerFunc func(ctx context.Context) error
+
+func (f pingerFunc) Ping(ctx context.Context) error {
+	return f(ctx)
+}
+
+var pingOK = pingerFunc(func(context.Context) error { return nil })
+
+func getHealthService(checks ...HealthCheck) *HealthHandler {
+	return &HealthHandler{
+		Checks:  checks,
+		Timeout: 50 * time.Millisecond,
+		Logger:  zap.NewNop().Sugar(),
+	}
+}
+
+func checkHealthResp(t *testing.T, w *httptest.ResponseRecorder, expectCode int, expectBody string) {
+	t.Helper()
+	resp := w.Result()
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body) // nolint:errcheck
+
+	if resp.StatusCode != expectCode {
+		t.Errorf("wrong status code:\nwant:\t%d\nhave\t%d", expectCode, resp.StatusCode)
+	}
+	if string(body) != expectBody {
+		t.Errorf("results not match:\nwant:\t%s\nhave\t%s", expectBody, body)
+	}
+}
+
+func TestHealthz_OK(t *testing.T) {
+	service := getHealthService(HealthCheck{
+		Name:   "users",
+		Pinger: pingerFunc(func(context.Context) error { return fmt.Errorf("mysql ping err") }),
+	})
+
+	w := httptest.NewRecorder()
+	service.Healthz(w, httptest.NewRequest("GET", "/healthz", nil))
+
+	checkHealthResp(t, w, 200, `{"status":"ok"}`)
+}
+
+func TestReadyz_OK(t *testing.T) {
+	service := getHealthService(
+		HealthCheck{Name: "sessions", Pinger: pingOK},
+		HealthCheck{Name: "posts", Pinger: pingOK},
+	)
+
+	w := httptest.NewRecorder()
+	service.Readyz(w, httptest.NewRequest("GET", "/readyz", nil))
+
+	checkHealthResp(t, w, 200, `{"status":"ok","checks":{"posts":{"status":"ok"},"sessions":{"status":"ok"}}}`)
+}
+
+func TestReadyz_Fail(t *testing.T) {
+	service := getHealthService(
+		HealthCheck{Name: "sessions", Pinger: pingOK},
+		HealthCheck{
+			Name:   "users",
+			Pinger: pingerFunc(func(context.Context) error { return fmt.Errorf("mysql ping err") }),
+		},
+	)
+
+	w := httptest.NewRecorder()
+	service.Readyz(w, httptest.NewRequest("GET", "/readyz", nil))
+
+	checkHealthResp(t, w, 503,
+		`{"status":"unavailable","checks":{"sessions":{"status":"ok"},"users":{"status":"fail"}}}`)
+}
+
+// Текст ошибки не отдается наружу, но попадает в лог
+func TestReadyz_ErrLogged(t *testing.T) {
+	core, logs := observer.New(zap.InfoLevel)
+	service := getHealthService(HealthCheck{
+		Name:   "users",
+		Pinger: pingerFunc(func(context.Context) error { return fmt.Errorf("dial tcp 10.0.0.5:3306: refused") }),
+	})
+	service.Logger = zap.New(core).Sugar()
+
+	w := httptest.NewRecorder()
+	service.Readyz(w, httptest.NewRequest("GET", "/readyz", nil))
+
+	checkHealthResp(t, w, 503, `{"status":"unavailable","checks":{"users":{"status":"fail"}}}`)
+	if entries := logs.FilterMessage("readiness check users failed: dial tcp 10.0.0.5:3306: refused").All(); len(entries) != 1 {
+		t.Errorf("wrong readiness log entries:\nwant:\t%d\nhave\t%d", 1, len(entries))
+	}
+}
+
+// Зависшая база не должна подвешивать пробу дольше таймаута
+func TestReadyz_Timeout(t *testing.T) {
+	service := getHealthService(HealthCheck{
+		Name: "posts",
+		Pinger: pingerFunc(func(ctx context.Context) error {
+			<-ctx.Done()
+			return ctx.Err()
+		}),
+	})
+
+	w := httptest.NewRecorder()
+	service.Readyz(w, httptest.NewRequest("GET", "/readyz", nil))
+
+	checkHealthResp(t, w, 503,
+		`{"status":"unavailable","checks":{"posts":{"status":"fail"}}}`)
+}
+
+func TestReadyz_Draining(t *testing.T) {
+	service := getHealthService(HealthCheck{
+		Name: "posts",
+		Pinger: pingerFunc(func(context.Context) error {
+			t.Errorf("unexpected ping while draining")
+			return nil
+		}),
+	})
+	service.Drain()
+
+	w := httptest.NewRecorder()
+	service.Readyz(w, httptest.NewRequest("GET", "/readyz", nil))
+
+	checkHealthResp(t, w, 503, `{"status":"shutting down"}`)
+}
@@ -0,0 +1,18 @@
+package handlers
+
+import (
+	"net/http"
+
+	"asperitas/internal/session"
+
+	"go.uber.org/zap"
+)
+
+type KeysHandler struct {
+	Keys   *session.KeySet
+	Logger *zap.SugaredLogger
+}
+
+func (h *KeysHandler) JWKS(w http.ResponseWriter, r *http.Request) {
+	WriteAndLogData(w, r, h.Keys.JWKS(), h.Logger, "listed jwks")
+}
@@ -1,24 +1,33 @@
 package handlers
 
 import (
-	"encoding/json"
+	"context"
+	"errors"
 	"fmt"
 	"net/http"
+	"strconv"
 
+	"asperitas/internal/community"
 	"asperitas/internal/errs"
+	"asperitas/internal/logging"
+	"asperitas/internal/policy"
 	"asperitas/internal/post"
 	"asperitas/internal/session"
 	"asperitas/internal/user"
+	"asperitas/internal/validate"
 	"asperitas/pkg/rand"
 
 	"github.com/gorilla/mux"
 	"go.uber.org/zap"
 )
 
+const NextCursorHeader = "X-Next-Cursor"
+
 type PostHandler struct {
-	Sess   session.SessionManager
-	Repo   post.PostRepo
-	Logger *zap.SugaredLogger
+	Sess        session.SessionManager
+	Repo        post.PostRepo
+	Communities community.CommunityRepo
+	Logger      *zap.SugaredLogger
 }
 
 // Проверяет валидность входящего ID по длине до похода в репу
@@ -26,7 +35,7 @@ func isValid(reqID string, respMsg string, w http.ResponseWriter, r *http.Reques
 	id := mux.Vars(r)[reqID]
 	if len(id) != rand.LengthOfID {
 		err := errs.MsgError{Msg: respMsg, Status: http.StatusBadRequest}
-		WriteAndLogErr(w, err, logger, fmt.Sprintf("%s valid err", reqID))
+		WriteAndLogErr(w, r, err, logger, fmt.Sprintf("%s valid err", reqID))
 		return "", false
 	}
 	return id, true
@@ -34,21 +43,86 @@ func isValid(reqID string, respMsg string, w http.ResponseWriter, r *http.Reques
 
 // Проверяет валидность сессии по полученному jwt-токену и сохраненному в базе session_id
 func sessionCheck(w http.ResponseWriter, r *http.Request, logger *zap.SugaredLogger, sm session.SessionManager) (user.User, bool) {
-	usr, err := sm.Check(r.Header.Get("Authorization"))
+	usr, err := sm.Check(r.Context(), r.Header.Get("Authorization"))
 	if err != nil {
-		WriteAndLogErr(w, err, logger, "session check err")
+		WriteAndLogErr(w, r, err, logger, "session check err")
 		return user.User{}, false
 	}
+	logging.With(r.Context(), "user_id", usr.ID)
 	return usr, true
 }
 
+// Лента доступна без входа: с невалидным токеном она отдается как анонимному читателю
+func viewerID(r *http.Request, sm session.SessionManager) string {
+	token := r.Header.Get("Authorization")
+	if token == "" {
+		return ""
+	}
+	usr, err := sm.Check(r.Context(), token)
+	if err != nil {
+		return ""
+	}
+	return usr.ID
+}
+
+// Читает параметры пагинации limit и after из query-строки
+func parsePage(w http.ResponseWriter, r *http.Request, logger *zap.SugaredLogger) (post.Page, bool) {
+	query := r.URL.Query()
+	page := post.Page{After: query.Get("after")}
+	if limitStr := query.Get("limit"); limitStr != "" {
+		limit, err := strconv.Atoi(limitStr)
+		if err != nil || limit < 1 || limit > post.MaxPageLimit {
+			err := errs.DetailErrors{Errors: []errs.DetailError{
+				{
+					Location: "query",
+					Param:    "limit",
+					Value:    limitStr,
+					Msg:      fmt.Sprintf("must be an integer from 1 to %d", post.MaxPageLimit),
+				},
+			}, Status: http.StatusBadRequest}
+			WriteAndLogErr(w, r, err, logger, "limit valid err")
+			return post.Page{}, false
+		}
+		page.Limit = limit
+	}
+	return page, true
+}
+
+// Читает режим ленты sort и окно ранжирования t из query-строки
+func parseRanking(w http.ResponseWriter, r *http.Request, logger *zap.SugaredLogger, def post.Ranking) (post.Ranking, bool) {
+	query := r.URL.Query()
+	rank, err := post.ParseRanking(query.Get("sort"), query.Get("t"), def)
+	if err != nil {
+		WriteAndLogErr(w, r, err, logger, "sort valid err")
+		return post.Ranking{}, false
+	}
+	return rank, true
+}
+
+// Курсор следующей страницы отдается в заголовке, тело ответа остается списком постов.
+// Запрос без limit и after получает ленту целиком, и курсора в нем не бывает.
+func setNextCursor(w http.ResponseWriter, next string) {
+	if next != "" {
+		w.Header().Set(NextCursorHeader, next)
+	}
+}
+
 func (h *PostHandler) ListPosts(w http.ResponseWriter, r *http.Request) {
-	posts, err := h.Repo.GetAll()
+	rank, ok := parseRanking(w, r, h.Logger, post.Top)
+	if !ok {
+		return
+	}
+	page, ok := parsePage(w, r, h.Logger)
+	if !ok {
+		return
+	}
+	posts, next, err := h.Repo.GetAll(r.Context(), rank, page, viewerID(r, h.Sess))
 	if err != nil {
-		WriteAndLogErr(w, err, h.Logger, "get all posts err")
+		WriteAndLogErr(w, r, err, h.Logger, "get all posts err")
 		return
 	}
-	WriteAndLogData(w, posts, h.Logger, "listed all posts")
+	setNextCursor(w, next)
+	WriteAndLogData(w, r, posts, h.Logger, "listed all posts")
 }
 
 func (h *PostHandler) CreatePost(w http.ResponseWriter, r *http.Request) {
@@ -56,29 +130,59 @@ func (h *PostHandler) CreatePost(w http.ResponseWriter, r *http.Request) {
 	if !ok {
 		return
 	}
-	p := post.NewPost(usr)
+	var req createPostRequest
 	defer r.Body.Close()
-	if err := json.NewDecoder(r.Body).Decode(p); err != nil {
-		WriteAndLogErr(w, err, h.Logger, "decode json err")
+	if err := decodeBody(w, r, &req, maxPostBody); err != nil {
+		WriteAndLogErr(w, r, err, h.Logger, "decode json err")
 		return
 	}
-	if err := h.Repo.AddPost(p); err != nil {
-		WriteAndLogErr(w, err, h.Logger, "add post err")
+	p := req.toPost(usr)
+	v := validate.New()
+	p.Validate(v)
+	if p.Category != "" {
+		_, err := h.Communities.GetByName(r.Context(), string(p.Category))
+		var msgErr errs.MsgError
+		if errors.As(err, &msgErr) && msgErr.Status == http.StatusNotFound {
+			v.Check(false, "category", string(p.Category), "community not found")
+		} else if err != nil {
+			WriteAndLogErr(w, r, err, h.Logger, "get community err")
+			return
+		}
+	}
+	if err := v.Err(); err != nil {
+		WriteAndLogErr(w, r, err, h.Logger, "post valid err")
+		return
+	}
+	if err := h.Repo.AddPost(r.Context(), p); err != nil {
+		WriteAndLogErr(w, r, err, h.Logger, "add post err")
 		return
 	}
 	logStr := fmt.Sprintf("created post: id=%s", p.ID)
-	WriteAndLogData(w, p, h.Logger, logStr)
+	WriteAndLogData(w, r, p, h.Logger, logStr)
 }
 
 func (h *PostHandler) ListPostsByCategory(w http.ResponseWriter, r *http.Request) {
 	category := mux.Vars(r)["categoryName"]
-	posts, err := h.Repo.GetByCategory(category)
+	if _, err := h.Communities.GetByName(r.Context(), category); err != nil {
+		WriteAndLogErr(w, r, err, h.Logger, "get community err")
+		return
+	}
+	rank, ok := parseRanking(w, r, h.Logger, post.Top)
+	if !ok {
+		return
+	}
+	page, ok := parsePage(w, r, h.Logger)
+	if !ok {
+		return
+	}
+	posts, next, err := h.Repo.GetByCategory(r.Context(), category, rank, page, viewerID(r, h.Sess))
 	if err != nil {
-		WriteAndLogErr(w, err, h.Logger, "get posts by category err")
+		WriteAndLogErr(w, r, err, h.Logger, "get posts by category err")
 		return
 	}
+	setNextCursor(w, next)
 	logStr := fmt.Sprintf("listed posts by: category=%s", category)
-	WriteAndLogData(w, posts, h.Logger, logStr)
+	WriteAndLogData(w, r, posts, h.Logger, logStr)
 }
 
 func (h *PostHandler) ShowPost(w http.ResponseWriter, r *http.Request) {
@@ -86,13 +190,18 @@ func (h *PostHandler) ShowPost(w http.ResponseWriter, r *http.Request) {
 	if !ok {
 		return
 	}
-	p, err := h.Repo.GetByID(id)
+	order, err := post.ParseCommentOrder(r.URL.Query().Get("sort"))
+	if err != nil {
+		WriteAndLogErr(w, r, err, h.Logger, "sort valid err")
+		return
+	}
+	p, err := h.Repo.GetByID(r.Context(), id, viewerID(r, h.Sess))
 	if err != nil {
-		WriteAndLogErr(w, err, h.Logger, "get post by id err")
+		WriteAndLogErr(w, r, err, h.Logger, "get post by id err")
 		return
 	}
 	logStr := fmt.Sprintf("showed post: id=%s", id)
-	WriteAndLogData(w, p, h.Logger, logStr)
+	WriteAndLogData(w, r, p.WithCommentOrder(order), h.Logger, logStr)
 }
 
 func (h *PostHandler) DeletePost(w http.ResponseWriter, r *http.Request) {
@@ -104,17 +213,19 @@ func (h *PostHandler) DeletePost(w http.ResponseWriter, r *http.Request) {
 	if !ok {
 		return
 	}
-	err := h.Repo.DeletePost(postID, usr.ID)
+	err := h.Repo.DeletePost(r.Context(), postID, func(p *post.Post) bool {
+		return policy.CanDeletePost(usr, p)
+	})
 	msgErr, ok := err.(errs.MsgError)
 	if ok && msgErr.Status == http.StatusOK {
 		logStr := fmt.Sprintf("deleted post: id=%s", postID)
-		WriteAndLogData(w, msgErr, h.Logger, logStr)
+		WriteAndLogData(w, r, msgErr, h.Logger, logStr)
 		return
 	}
-	WriteAndLogErr(w, err, h.Logger, "delete post err")
+	WriteAndLogErr(w, r, err, h.Logger, "delete post err")
 }
 
-func (h *PostHandler) CreateComment(w http.ResponseWriter, r *http.Request) {
+func (h *PostHandler) UpdatePost(w http.ResponseWriter, r *http.Request) {
 	postID, ok := isValid("postID", "invalid post id", w, r, h.Logger)
 	if !ok {
 		return
@@ -123,31 +234,107 @@ func (h *PostHandler) CreateComment(w http.ResponseWriter, r *http.Request) {
 	if !ok {
 		return
 	}
+	var req editPostRequest
 	defer r.Body.Close()
-	reqBody := struct{ Comment string }{}
-	if err := json.NewDecoder(r.Body).Decode(&reqBody); err != nil {
-		WriteAndLogErr(w, err, h.Logger, "decode json err")
+	if err := decodeBody(w, r, &req, maxPostBody); err != nil {
+		WriteAndLogErr(w, r, err, h.Logger, "decode json err")
+		return
+	}
+	upd := req.toPostEdit()
+	v := validate.New()
+	upd.Validate(v)
+	if err := v.Err(); err != nil {
+		WriteAndLogErr(w, r, err, h.Logger, "post edit valid err")
+		return
+	}
+	p, err := h.Repo.UpdatePost(r.Context(), postID, usr.ID, upd)
+	if err != nil {
+		WriteAndLogErr(w, r, err, h.Logger, "update post err")
+		return
+	}
+	logStr := fmt.Sprintf("updated post: id=%s", postID)
+	WriteAndLogData(w, r, p, h.Logger, logStr)
+}
+
+func (h *PostHandler) ListRevisions(w http.ResponseWriter, r *http.Request) {
+	postID, ok := isValid("postID", "invalid post id", w, r, h.Logger)
+	if !ok {
+		return
+	}
+	revs, err := h.Repo.GetRevisions(r.Context(), postID)
+	if err != nil {
+		WriteAndLogErr(w, r, err, h.Logger, "get post revisions err")
+		return
+	}
+	logStr := fmt.Sprintf("listed post revisions: id=%s", postID)
+	WriteAndLogData(w, r, revs, h.Logger, logStr)
+}
+
+func (h *PostHandler) CreateComment(w http.ResponseWriter, r *http.Request) {
+	postID, ok := isValid("postID", "invalid post id", w, r, h.Logger)
+	if !ok {
+		return
+	}
+	usr, ok := sessionCheck(w, r, h.Logger, h.Sess)
+	if !ok {
 		return
 	}
-	if reqBody.Comment == "" {
-		err := errs.DetailErrors{Errors: []errs.DetailError{
-			{
-				Location: "body",
-				Param:    "comment",
-				Msg:      "is required",
-			},
-		}, Status: 422}
-		WriteAndLogErr(w, err, h.Logger, "create empty comment err")
+	body, ok := decodeComment(w, r, h.Logger)
+	if !ok {
 		return
 	}
-	comm := post.NewComment(usr, reqBody.Comment)
-	p, err := h.Repo.AddComment(postID, comm)
+	comm := post.NewComment(usr, body)
+	p, err := h.Repo.AddComment(r.Context(), postID, comm)
 	if err != nil {
-		WriteAndLogErr(w, err, h.Logger, "get post by id err")
+		WriteAndLogErr(w, r, err, h.Logger, "get post by id err")
 		return
 	}
 	logStr := fmt.Sprintf("created comment: id=%s", comm.ID)
-	WriteAndLogData(w, p, h.Logger, logStr)
+	WriteAndLogData(w, r, p, h.Logger, logStr)
+}
+
+func (h *PostHandler) ReplyComment(w http.ResponseWriter, r *http.Request) {
+	postID, ok := isValid("postID", "invalid post id", w, r, h.Logger)
+	if !ok {
+		return
+	}
+	parentID, ok := isValid("commentID", "invalid comment id", w, r, h.Logger)
+	if !ok {
+		return
+	}
+	usr, ok := sessionCheck(w, r, h.Logger, h.Sess)
+	if !ok {
+		return
+	}
+	body, ok := decodeComment(w, r, h.Logger)
+	if !ok {
+		return
+	}
+	comm := post.NewReply(usr, parentID, body)
+	p, err := h.Repo.AddComment(r.Context(), postID, comm)
+	if err != nil {
+		WriteAndLogErr(w, r, err, h.Logger, "add reply err")
+		return
+	}
+	logStr := fmt.Sprintf("replied to comment: id=%s, reply_id=%s", parentID, comm.ID)
+	WriteAndLogData(w, r, p, h.Logger, logStr)
+}
+
+// Читает тело комментария вида {"comment": "..."}
+func decodeComment(w http.ResponseWriter, r *http.Request, logger *zap.SugaredLogger) (string, bool) {
+	defer r.Body.Close()
+	var req commentRequest
+	if err := decodeBody(w, r, &req, maxCommentBody); err != nil {
+		WriteAndLogErr(w, r, err, logger, "decode json err")
+		return "", false
+	}
+	v := validate.New()
+	post.ValidateComment(v, req.Comment)
+	if err := v.Err(); err != nil {
+		WriteAndLogErr(w, r, err, logger, "comment valid err")
+		return "", false
+	}
+	return req.Comment, true
 }
 
 func (h *PostHandler) DeleteComment(w http.ResponseWriter, r *http.Request) {
@@ -163,13 +350,15 @@ func (h *PostHandler) DeleteComment(w http.ResponseWriter, r *http.Request) {
 	if !ok {
 		return
 	}
-	p, err := h.Repo.DeleteComment(postID, commID, usr.ID)
+	p, err := h.Repo.DeleteComment(r.Context(), postID, commID, usr.ID, func(p *post.Post, c *post.Comment) bool {
+		return policy.CanDeleteComment(usr, p, c)
+	})
 	if err != nil {
-		WriteAndLogErr(w, err, h.Logger, "delete comment err")
+		WriteAndLogErr(w, r, err, h.Logger, "delete comment err")
 		return
 	}
 	logStr := fmt.Sprintf("deleted comment: id=%s", commID)
-	WriteAndLogData(w, p, h.Logger, logStr)
+	WriteAndLogData(w, r, p, h.Logger, logStr)
 }
 
 func (h *PostHandler) UpvotePost(w http.ResponseWriter, r *http.Request) {
@@ -181,13 +370,13 @@ func (h *PostHandler) UpvotePost(w http.ResponseWriter, r *http.Request) {
 	if !ok {
 		return
 	}
-	p, err := h.Repo.UpvotePost(postID, usr.ID)
+	p, err := h.Repo.UpvotePost(r.Context(), postID, usr.ID)
 	if err != nil {
-		WriteAndLogErr(w, err, h.Logger, "upvote post err")
+		WriteAndLogErr(w, r, err, h.Logger, "upvote post err")
 		return
 	}
 	logStr := fmt.Sprintf("upvoted post: id=%s", postID)
-	WriteAndLogData(w, p, h.Logger, logStr)
+	WriteAndLogData(w, r, p, h.Logger, logStr)
 }
 
 func (h *PostHandler) DownvotePost(w http.ResponseWriter, r *http.Request) {
@@ -199,13 +388,13 @@ func (h *PostHandler) DownvotePost(w http.ResponseWriter, r *http.Request) {
 	if !ok {
 		return
 	}
-	p, err := h.Repo.DownvotePost(postID, usr.ID)
+	p, err := h.Repo.DownvotePost(r.Context(), postID, usr.ID)
 	if err != nil {
-		WriteAndLogErr(w, err, h.Logger, "downvote post err")
+		WriteAndLogErr(w, r, err, h.Logger, "downvote post err")
 		return
 	}
 	logStr := fmt.Sprintf("downvoted post: id=%s", postID)
-	WriteAndLogData(w, p, h.Logger, logStr)
+	WriteAndLogData(w, r, p, h.Logger, logStr)
 }
 
 func (h *PostHandler) UnvotePost(w http.ResponseWriter, r *http.Request) {
@@ -217,22 +406,65 @@ func (h *PostHandler) UnvotePost(w http.ResponseWriter, r *http.Request) {
 	if !ok {
 		return
 	}
-	p, err := h.Repo.UnvotePost(postID, usr.ID)
+	p, err := h.Repo.UnvotePost(r.Context(), postID, usr.ID)
 	if err != nil {
-		WriteAndLogErr(w, err, h.Logger, "unvote post err")
+		WriteAndLogErr(w, r, err, h.Logger, "unvote post err")
 		return
 	}
 	logStr := fmt.Sprintf("unvoted post: id=%s", postID)
-	WriteAndLogData(w, p, h.Logger, logStr)
+	WriteAndLogData(w, r, p, h.Logger, logStr)
+}
+
+func (h *PostHandler) UpvoteComment(w http.ResponseWriter, r *http.Request) {
+	h.voteComment(w, r, h.Repo.UpvoteComment, "upvoted")
+}
+
+func (h *PostHandler) DownvoteComment(w http.ResponseWriter, r *http.Request) {
+	h.voteComment(w, r, h.Repo.DownvoteComment, "downvoted")
+}
+
+func (h *PostHandler) UnvoteComment(w http.ResponseWriter, r *http.Request) {
+	h.voteComment(w, r, h.Repo.UnvoteComment, "unvoted")
+}
+
+func (h *PostHandler) voteComment(w http.ResponseWriter, r *http.Request, vote func(ctx context.Context, postID, commID, userID string) (*post.Post, error), action string) {
+	postID, ok := isValid("postID", "invalid post id", w, r, h.Logger)
+	if !ok {
+		return
+	}
+	commID, ok := isValid("commentID", "invalid comment id", w, r, h.Logger)
+	if !ok {
+		return
+	}
+	usr, ok := sessionCheck(w, r, h.Logger, h.Sess)
+	if !ok {
+		return
+	}
+	p, err := vote(r.Context(), postID, commID, usr.ID)
+	if err != nil {
+		WriteAndLogErr(w, r, err, h.Logger, "vote comment err")
+		return
+	}
+	logStr := fmt.Sprintf("%s comment: id=%s", action, commID)
+	WriteAndLogData(w, r, p, h.Logger, logStr)
 }
 
 func (h *PostHandler) ListPostsByUser(w http.ResponseWriter, r *http.Request) {
 	username := mux.Vars(r)["userName"]
-	posts, err := h.Repo.GetByUser(username)
+	rank, ok := parseRanking(w, r, h.Logger, post.New)
+	if !ok {
+		return
+	}
+	page, ok := parsePage(w, r, h.Logger)
+	if !ok {
+		return
+	}
+	posts, next, err := h.Repo.GetByUser(r.Context(), username, rank, page, viewerID(r, h.Sess))
 	if err != nil {
-		WriteAndLogErr(w, err, h.Logger, "get posts by user err")
+		WriteAndLogErr(w, r, err, h.Logger, "get posts by user err")
 		return
 	}
+	setNextCursor(w, next)
 	logStr := fmt.Sprintf("listed posts by: username=%s", username)
-	WriteAndLogData(w, posts, h.Logger, logStr)
+	WriteAndLogData(w, r, posts, h.Logger, logStr)
 }
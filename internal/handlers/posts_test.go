@@ -2,12 +2,15 @@ package handlers
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http/httptest"
 	"testing"
+	"time"
 
+	"asperitas/internal/community"
 	"asperitas/internal/errs"
 	"asperitas/internal/post"
 	"asperitas/internal/session"
@@ -32,9 +35,10 @@ func getMockPostService(t *testing.T) (*PostHandler, *session.MockSessionManager
 	mng := session.NewMockSessionManager(ctrl)
 	db := post.NewMockPostRepo(ctrl)
 	return &PostHandler{
-		Sess:   mng,
-		Repo:   db,
-		Logger: zap.NewNop().Sugar(),
+		Sess:        mng,
+		Repo:        db,
+		Communities: community.NewMemoryRepo(),
+		Logger:      zap.NewNop().Sugar(),
 	}, mng, db
 }
 
@@ -48,8 +52,39 @@ func TestListPosts_OK(t *testing.T) {
 	w := httptest.NewRecorder()
 
 	db.EXPECT().
-		GetAll().
-		Return(expect, nil)
+		GetAll(gomock.Any(), post.Top, post.Page{}, "").
+		Return(expect, "", nil)
+
+	service.ListPosts(w, req)
+
+	resp := w.Result()
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body) // nolint:errcheck
+
+	if resp.StatusCode != 200 {
+		t.Errorf("wrong status code:\nwant:\t%#v\nhave\t%#v", 200, resp.StatusCode)
+	}
+	if string(body) != string(expectBody) {
+		t.Errorf("results not match:\n\nwant:\t%s\n\nhave\t%s", expectBody, body)
+	}
+}
+
+func TestListPosts_Viewer(t *testing.T) {
+	service, sm, db := getMockPostService(t)
+
+	expect := []*post.Post{post.NewPost(usr1)}
+	expectBody, _ := json.Marshal(expect) // nolint:errcheck
+
+	req := httptest.NewRequest("GET", "/api/posts/", nil)
+	req.Header.Set("Authorization", "Bearer token")
+	w := httptest.NewRecorder()
+
+	sm.EXPECT().
+		Check(gomock.Any(), "Bearer token").
+		Return(usr2, nil)
+	db.EXPECT().
+		GetAll(gomock.Any(), post.Top, post.Page{}, usr2.ID).
+		Return(expect, "", nil)
 
 	service.ListPosts(w, req)
 
@@ -65,6 +100,30 @@ func TestListPosts_OK(t *testing.T) {
 	}
 }
 
+func TestListPosts_InvalidSessionAnonymous(t *testing.T) {
+	service, sm, db := getMockPostService(t)
+
+	req := httptest.NewRequest("GET", "/api/posts/", nil)
+	req.Header.Set("Authorization", "Bearer expired")
+	w := httptest.NewRecorder()
+
+	sm.EXPECT().
+		Check(gomock.Any(), "Bearer expired").
+		Return(user.User{}, errs.MsgError{Msg: "unauthorized", Status: 401})
+	db.EXPECT().
+		GetAll(gomock.Any(), post.Top, post.Page{}, "").
+		Return([]*post.Post{}, "", nil)
+
+	service.ListPosts(w, req)
+
+	resp := w.Result()
+	defer resp.Body.Close()
+
+	if resp.StatusCode != 200 {
+		t.Errorf("wrong status code:\nwant:\t%#v\nhave\t%#v", 200, resp.StatusCode)
+	}
+}
+
 func TestListPosts_GetErr(t *testing.T) {
 	service, _, db := getMockPostService(t)
 
@@ -75,8 +134,8 @@ func TestListPosts_GetErr(t *testing.T) {
 	w := httptest.NewRecorder()
 
 	db.EXPECT().
-		GetAll().
-		Return(nil, fmt.Errorf("some err"))
+		GetAll(gomock.Any(), post.Top, post.Page{}, "").
+		Return(nil, "", fmt.Errorf("some err"))
 
 	service.ListPosts(w, req)
 
@@ -105,10 +164,10 @@ func TestCreatePost_OK(t *testing.T) {
 	w := httptest.NewRecorder()
 
 	mng.EXPECT().
-		Check(gomock.Any()).
+		Check(gomock.Any(), gomock.Any()).
 		Return(usr1, nil)
 	db.EXPECT().
-		AddPost(gomock.Any()).
+		AddPost(gomock.Any(), gomock.Any()).
 		Return(nil)
 
 	service.CreatePost(w, req)
@@ -143,7 +202,7 @@ func TestCreatePost_AuthErr(t *testing.T) {
 	w := httptest.NewRecorder()
 
 	mng.EXPECT().
-		Check(gomock.Any()).
+		Check(gomock.Any(), gomock.Any()).
 		Return(user.User{}, expect)
 
 	service.CreatePost(w, req)
@@ -163,7 +222,7 @@ func TestCreatePost_AuthErr(t *testing.T) {
 func TestCreatePost_DecodeErr(t *testing.T) {
 	service, mng, _ := getMockPostService(t)
 
-	expect := errs.MsgError{Msg: "internal server error", Status: 500}
+	expect := errs.MsgError{Msg: "invalid json body", Status: 400}
 	expectBody, _ := json.Marshal(expect) // nolint:errcheck
 
 	reqBody := bytes.NewBufferString(`bad json`)
@@ -171,7 +230,7 @@ func TestCreatePost_DecodeErr(t *testing.T) {
 	w := httptest.NewRecorder()
 
 	mng.EXPECT().
-		Check(gomock.Any()).
+		Check(gomock.Any(), gomock.Any()).
 		Return(usr1, nil)
 
 	service.CreatePost(w, req)
@@ -194,15 +253,15 @@ func TestCreatePost_AddErr(t *testing.T) {
 	expect := errs.MsgError{Msg: "internal server error", Status: 500}
 	expectBody, _ := json.Marshal(expect) // nolint:errcheck
 
-	reqBody := bytes.NewBufferString(`{}`)
+	reqBody := bytes.NewBufferString(`{"category":"music","type":"link","title":"some title","url":"https://go.dev"}`)
 	req := httptest.NewRequest("POST", "/api/posts", reqBody)
 	w := httptest.NewRecorder()
 
 	mng.EXPECT().
-		Check(gomock.Any()).
+		Check(gomock.Any(), gomock.Any()).
 		Return(usr1, nil)
 	db.EXPECT().
-		AddPost(gomock.Any()).
+		AddPost(gomock.Any(), gomock.Any()).
 		Return(fmt.Errorf("some err"))
 
 	service.CreatePost(w, req)
@@ -219,6 +278,112 @@ func TestCreatePost_AddErr(t *testing.T) {
 	}
 }
 
+func TestCreatePost_UnknownCommunityErr(t *testing.T) {
+	service, mng, _ := getMockPostService(t)
+
+	expect := errs.DetailErrors{Errors: []errs.DetailError{
+		{
+			Location: "body",
+			Param:    "category",
+			Value:    "cats",
+			Msg:      "community not found",
+		},
+	}, Status: 422}
+	expectBody, _ := json.Marshal(expect) // nolint:errcheck
+
+	reqBody := bytes.NewBufferString(`{"category":"cats","type":"text","title":"some title","text":"some text"}`)
+	req := httptest.NewRequest("POST", "/api/posts", reqBody)
+	w := httptest.NewRecorder()
+
+	mng.EXPECT().
+		Check(gomock.Any(), gomock.Any()).
+		Return(usr1, nil)
+
+	service.CreatePost(w, req)
+
+	resp := w.Result()
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body) // nolint:errcheck
+
+	if resp.StatusCode != expect.Status {
+		t.Errorf("wrong status code:\nwant:\t%d\nhave\t%d", expect.Status, resp.StatusCode)
+	}
+	if string(body) != string(expectBody) {
+		t.Errorf("results not match:\nwant:\t%s\nhave\t%s", expectBody, body)
+	}
+}
+
+// Все ошибки тела, включая несуществующее сообщество, возвращаются одним ответом
+func TestCreatePost_ValidErr(t *testing.T) {
+	service, mng, _ := getMockPostService(t)
+
+	expect := errs.DetailErrors{Errors: []errs.DetailError{
+		{
+			Location: "body",
+			Param:    "title",
+			Msg:      "is required",
+		},
+		{
+			Location: "body",
+			Param:    "url",
+			Value:    "javascript:alert(1)",
+			Msg:      "must be http or https url",
+		},
+		{
+			Location: "body",
+			Param:    "category",
+			Value:    "cats",
+			Msg:      "community not found",
+		},
+	}, Status: 422}
+	expectBody, _ := json.Marshal(expect) // nolint:errcheck
+
+	reqBody := bytes.NewBufferString(`{"category":"cats","type":"link","url":"javascript:alert(1)"}`)
+	req := httptest.NewRequest("POST", "/api/posts", reqBody)
+	w := httptest.NewRecorder()
+
+	mng.EXPECT().
+		Check(gomock.Any(), gomock.Any()).
+		Return(usr1, nil)
+
+	service.CreatePost(w, req)
+
+	resp := w.Result()
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body) // nolint:errcheck
+
+	if resp.StatusCode != expect.Status {
+		t.Errorf("wrong status code:\nwant:\t%d\nhave\t%d", expect.Status, resp.StatusCode)
+	}
+	if string(body) != string(expectBody) {
+		t.Errorf("results not match:\nwant:\t%s\nhave\t%s", expectBody, body)
+	}
+}
+
+func TestListPostsByCategory_ErrNoCommunity(t *testing.T) {
+	service, _, _ := getMockPostService(t)
+
+	expect := errs.MsgError{Msg: "community not found", Status: 404}
+	expectBody, _ := json.Marshal(expect) // nolint:errcheck
+
+	req := httptest.NewRequest("GET", "/api/posts/{categoryName}", nil)
+	req = mux.SetURLVars(req, map[string]string{"categoryName": "cats"})
+	w := httptest.NewRecorder()
+
+	service.ListPostsByCategory(w, req)
+
+	resp := w.Result()
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body) // nolint:errcheck
+
+	if resp.StatusCode != expect.Status {
+		t.Errorf("wrong status code:\nwant:\t%d\nhave\t%d", expect.Status, resp.StatusCode)
+	}
+	if string(body) != string(expectBody) {
+		t.Errorf("results not match:\nwant:\t%s\nhave\t%s", expectBody, body)
+	}
+}
+
 func TestListPostsByCategory_OK(t *testing.T) {
 	service, _, db := getMockPostService(t)
 
@@ -230,8 +395,8 @@ func TestListPostsByCategory_OK(t *testing.T) {
 	w := httptest.NewRecorder()
 
 	db.EXPECT().
-		GetByCategory("music").
-		Return(expect, nil)
+		GetByCategory(gomock.Any(), "music", post.Top, post.Page{}, "").
+		Return(expect, "", nil)
 
 	service.ListPostsByCategory(w, req)
 
@@ -258,8 +423,8 @@ func TestListPostsByCategory_GetErr(t *testing.T) {
 	w := httptest.NewRecorder()
 
 	db.EXPECT().
-		GetByCategory("music").
-		Return(nil, fmt.Errorf("some err"))
+		GetByCategory(gomock.Any(), "music", post.Top, post.Page{}, "").
+		Return(nil, "", fmt.Errorf("some err"))
 
 	service.ListPostsByCategory(w, req)
 
@@ -286,7 +451,39 @@ func TestShowPost_OK(t *testing.T) {
 	w := httptest.NewRecorder()
 
 	db.EXPECT().
-		GetByID(expect.ID).
+		GetByID(gomock.Any(), expect.ID, "").
+		Return(expect, nil)
+
+	service.ShowPost(w, req)
+
+	resp := w.Result()
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body) // nolint:errcheck
+
+	if resp.StatusCode != 200 {
+		t.Errorf("wrong status code:\nwant:\t%d\nhave\t%d", 200, resp.StatusCode)
+	}
+	if string(body) != string(expectBody) {
+		t.Errorf("results not match:\n\nwant:\t%s\n\nhave\t%s", expectBody, body)
+	}
+}
+
+func TestShowPost_Viewer(t *testing.T) {
+	service, sm, db := getMockPostService(t)
+
+	expect := post.NewPost(usr1)
+	expectBody, _ := json.Marshal(expect) // nolint:errcheck
+
+	req := httptest.NewRequest("GET", "/api/post/{postID}", nil)
+	req = mux.SetURLVars(req, map[string]string{"postID": expect.ID})
+	req.Header.Set("Authorization", "Bearer token")
+	w := httptest.NewRecorder()
+
+	sm.EXPECT().
+		Check(gomock.Any(), "Bearer token").
+		Return(usr2, nil)
+	db.EXPECT().
+		GetByID(gomock.Any(), expect.ID, usr2.ID).
 		Return(expect, nil)
 
 	service.ShowPost(w, req)
@@ -303,6 +500,75 @@ func TestShowPost_OK(t *testing.T) {
 	}
 }
 
+func TestShowPost_CommentSort(t *testing.T) {
+	service, _, db := getMockPostService(t)
+
+	p := post.NewPost(usr1)
+	old := post.NewComment(usr1, "old")
+	fresh := post.NewComment(usr2, "fresh")
+	fresh.Created = old.Created.Add(time.Second)
+	p.Comments.Add(old)   // nolint:errcheck
+	p.Comments.Add(fresh) // nolint:errcheck
+	expect := *p
+	expect.Comments = post.CommentList{fresh, old}
+	expectBody, _ := json.Marshal(&expect) // nolint:errcheck
+
+	req := httptest.NewRequest("GET", "/api/post/{postID}?sort=new", nil)
+	req = mux.SetURLVars(req, map[string]string{"postID": p.ID})
+	w := httptest.NewRecorder()
+
+	db.EXPECT().
+		GetByID(gomock.Any(), p.ID, "").
+		Return(p, nil)
+
+	service.ShowPost(w, req)
+
+	resp := w.Result()
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body) // nolint:errcheck
+
+	if resp.StatusCode != 200 {
+		t.Errorf("wrong status code:\nwant:\t%d\nhave\t%d", 200, resp.StatusCode)
+	}
+	if string(body) != string(expectBody) {
+		t.Errorf("results not match:\n\nwant:\t%s\n\nhave\t%s", expectBody, body)
+	}
+	if p.Comments[0] != old {
+		t.Errorf("stored comments must keep chronological order")
+	}
+}
+
+func TestShowPost_InvalidSortErr(t *testing.T) {
+	service, _, _ := getMockPostService(t)
+
+	expect := errs.DetailErrors{Errors: []errs.DetailError{
+		{
+			Location: "query",
+			Param:    "sort",
+			Value:    "hot",
+			Msg:      "must be one of best, controversial, new, top",
+		},
+	}, Status: 400}
+	expectBody, _ := json.Marshal(expect) // nolint:errcheck
+
+	req := httptest.NewRequest("GET", "/api/post/{postID}?sort=hot", nil)
+	req = mux.SetURLVars(req, map[string]string{"postID": randID})
+	w := httptest.NewRecorder()
+
+	service.ShowPost(w, req)
+
+	resp := w.Result()
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body) // nolint:errcheck
+
+	if resp.StatusCode != expect.Status {
+		t.Errorf("wrong status code:\nwant:\t%d\nhave\t%d", expect.Status, resp.StatusCode)
+	}
+	if string(body) != string(expectBody) {
+		t.Errorf("results not match:\n\nwant:\t%s\n\nhave\t%s", expectBody, body)
+	}
+}
+
 func TestShowPost_InvalidErr(t *testing.T) {
 	service, _, _ := getMockPostService(t)
 
@@ -338,7 +604,7 @@ func TestShowPost_GetErr(t *testing.T) {
 	w := httptest.NewRecorder()
 
 	db.EXPECT().
-		GetByID(randID).
+		GetByID(gomock.Any(), randID, "").
 		Return(nil, expect)
 
 	service.ShowPost(w, req)
@@ -355,6 +621,33 @@ func TestShowPost_GetErr(t *testing.T) {
 	}
 }
 
+func TestShowPost_TimeoutErr(t *testing.T) {
+	service, _, db := getMockPostService(t)
+
+	expectBody := []byte(`{"message":"gateway timeout"}`)
+
+	req := httptest.NewRequest("GET", "/api/post/{postID}", nil)
+	req = mux.SetURLVars(req, map[string]string{"postID": randID})
+	w := httptest.NewRecorder()
+
+	db.EXPECT().
+		GetByID(req.Context(), randID, "").
+		Return(nil, fmt.Errorf("mongo find one err: %w", context.DeadlineExceeded))
+
+	service.ShowPost(w, req)
+
+	resp := w.Result()
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body) // nolint:errcheck
+
+	if resp.StatusCode != 504 {
+		t.Errorf("wrong status code:\nwant:\t%d\nhave\t%d", 504, resp.StatusCode)
+	}
+	if string(body) != string(expectBody) {
+		t.Errorf("results not match:\n\nwant:\t%s\n\nhave\t%s", expectBody, body)
+	}
+}
+
 func TestDeletePost_OK(t *testing.T) {
 	service, mng, db := getMockPostService(t)
 
@@ -366,10 +659,10 @@ func TestDeletePost_OK(t *testing.T) {
 	w := httptest.NewRecorder()
 
 	mng.EXPECT().
-		Check(gomock.Any()).
+		Check(gomock.Any(), gomock.Any()).
 		Return(usr1, nil)
 	db.EXPECT().
-		DeletePost(randID, usr1.ID).
+		DeletePost(gomock.Any(), randID, gomock.Any()).
 		Return(expect)
 
 	service.DeletePost(w, req)
@@ -386,6 +679,43 @@ func TestDeletePost_OK(t *testing.T) {
 	}
 }
 
+// Модератор удаляет чужой пост только в своем сообществе
+func TestDeletePost_Moderator(t *testing.T) {
+	service, mng, db := getMockPostService(t)
+
+	moderator := usr2
+	moderator.Roles = []user.Role{{Name: user.RoleModerator, Scope: "music"}}
+	inScope := post.NewPost(usr1)
+	inScope.Category = "music"
+	outOfScope := post.NewPost(usr1)
+	outOfScope.Category = "golang"
+
+	req := httptest.NewRequest("DELETE", "/api/post/{postID}", nil)
+	req = mux.SetURLVars(req, map[string]string{"postID": randID})
+	w := httptest.NewRecorder()
+
+	mng.EXPECT().
+		Check(gomock.Any(), gomock.Any()).
+		Return(moderator, nil)
+	db.EXPECT().
+		DeletePost(gomock.Any(), randID, gomock.Any()).
+		DoAndReturn(func(_ context.Context, _ string, allow post.PostPermit) error {
+			if !allow(inScope) {
+				t.Errorf("moderator denied to delete post in own community")
+			}
+			if allow(outOfScope) {
+				t.Errorf("moderator allowed to delete post in another community")
+			}
+			return errs.MsgError{Msg: "success", Status: 200}
+		})
+
+	service.DeletePost(w, req)
+
+	if resp := w.Result(); resp.StatusCode != 200 {
+		t.Errorf("wrong status code:\nwant:\t%d\nhave\t%d", 200, resp.StatusCode)
+	}
+}
+
 func TestDeletePost_InvalidErr(t *testing.T) {
 	service, _, _ := getMockPostService(t)
 
@@ -421,7 +751,7 @@ func TestDeletePost_AuthErr(t *testing.T) {
 	w := httptest.NewRecorder()
 
 	mng.EXPECT().
-		Check(gomock.Any()).
+		Check(gomock.Any(), gomock.Any()).
 		Return(user.User{}, expect)
 
 	service.DeletePost(w, req)
@@ -449,10 +779,10 @@ func TestDeletePost_DeleteErr(t *testing.T) {
 	w := httptest.NewRecorder()
 
 	mng.EXPECT().
-		Check(gomock.Any()).
+		Check(gomock.Any(), gomock.Any()).
 		Return(usr1, nil)
 	db.EXPECT().
-		DeletePost(randID, usr1.ID).
+		DeletePost(gomock.Any(), randID, gomock.Any()).
 		Return(expect)
 
 	service.DeletePost(w, req)
@@ -483,10 +813,10 @@ func TestCreateComment_OK(t *testing.T) {
 	w := httptest.NewRecorder()
 
 	mng.EXPECT().
-		Check(gomock.Any()).
+		Check(gomock.Any(), gomock.Any()).
 		Return(usr2, nil)
 	db.EXPECT().
-		AddComment(expect.ID, gomock.Any()).
+		AddComment(gomock.Any(), expect.ID, gomock.Any()).
 		Return(expect, nil)
 
 	service.CreateComment(w, req)
@@ -540,7 +870,7 @@ func TestCreateComment_AuthErr(t *testing.T) {
 	w := httptest.NewRecorder()
 
 	mng.EXPECT().
-		Check(gomock.Any()).
+		Check(gomock.Any(), gomock.Any()).
 		Return(user.User{}, expect)
 
 	service.CreateComment(w, req)
@@ -560,7 +890,7 @@ func TestCreateComment_AuthErr(t *testing.T) {
 func TestCreateComment_DecodeErr(t *testing.T) {
 	service, mng, _ := getMockPostService(t)
 
-	expect := errs.MsgError{Msg: "internal server error", Status: 500}
+	expect := errs.MsgError{Msg: "invalid json body", Status: 400}
 	expectBody, _ := json.Marshal(expect) // nolint:errcheck
 
 	reqBody := bytes.NewBufferString(`bad json`)
@@ -569,7 +899,7 @@ func TestCreateComment_DecodeErr(t *testing.T) {
 	w := httptest.NewRecorder()
 
 	mng.EXPECT().
-		Check(gomock.Any()).
+		Check(gomock.Any(), gomock.Any()).
 		Return(usr1, nil)
 
 	service.CreateComment(w, req)
@@ -604,7 +934,7 @@ func TestCreateComment_EmptyCommentErr(t *testing.T) {
 	w := httptest.NewRecorder()
 
 	mng.EXPECT().
-		Check(gomock.Any()).
+		Check(gomock.Any(), gomock.Any()).
 		Return(usr1, nil)
 
 	service.CreateComment(w, req)
@@ -633,10 +963,10 @@ func TestCreateComment_AddErr(t *testing.T) {
 	w := httptest.NewRecorder()
 
 	mng.EXPECT().
-		Check(gomock.Any()).
+		Check(gomock.Any(), gomock.Any()).
 		Return(usr1, nil)
 	db.EXPECT().
-		AddComment(randID, gomock.Any()).
+		AddComment(gomock.Any(), randID, gomock.Any()).
 		Return(nil, expect)
 
 	service.CreateComment(w, req)
@@ -653,27 +983,34 @@ func TestCreateComment_AddErr(t *testing.T) {
 	}
 }
 
-func TestDeleteComment_OK(t *testing.T) {
+func TestReplyComment_OK(t *testing.T) {
 	service, mng, db := getMockPostService(t)
 
 	expect := post.NewPost(usr1)
-	comm := post.NewComment(usr2, "some comment")
-	expect.Comments.Add(comm)                // nolint:errcheck
-	expect.Comments.Delete(comm.ID, usr2.ID) // nolint:errcheck
-	expectBody, _ := json.Marshal(expect)    // nolint:errcheck
+	parent := post.NewComment(usr1, "some comment")
+	expect.Comments.Add(parent) // nolint:errcheck
+	reply := post.NewReply(usr2, parent.ID, "some reply")
+	expect.Comments.Add(reply)            // nolint:errcheck
+	expectBody, _ := json.Marshal(expect) // nolint:errcheck
 
-	req := httptest.NewRequest("DELETE", "/api/post/{postID}/{commentID}", nil)
-	req = mux.SetURLVars(req, map[string]string{"postID": expect.ID, "commentID": comm.ID})
+	reqBody := bytes.NewBufferString(`{"comment":"some reply"}`)
+	req := httptest.NewRequest("POST", "/api/post/{postID}/{commentID}/reply", reqBody)
+	req = mux.SetURLVars(req, map[string]string{"postID": expect.ID, "commentID": parent.ID})
 	w := httptest.NewRecorder()
 
 	mng.EXPECT().
-		Check(gomock.Any()).
+		Check(gomock.Any(), gomock.Any()).
 		Return(usr2, nil)
 	db.EXPECT().
-		DeleteComment(expect.ID, comm.ID, usr2.ID).
-		Return(expect, nil)
+		AddComment(gomock.Any(), expect.ID, gomock.Any()).
+		DoAndReturn(func(_ context.Context, postID string, comm *post.Comment) (*post.Post, error) {
+			if comm.ParentID != parent.ID || comm.Body != "some reply" {
+				t.Errorf("wrong reply: %+v", comm)
+			}
+			return expect, nil
+		})
 
-	service.DeleteComment(w, req)
+	service.ReplyComment(w, req)
 
 	resp := w.Result()
 	defer resp.Body.Close()
@@ -687,20 +1024,118 @@ func TestDeleteComment_OK(t *testing.T) {
 	}
 }
 
-func TestDeleteComment_InvalidPostErr(t *testing.T) {
-	service, _, _ := getMockPostService(t)
+func TestReplyComment_ParentErr(t *testing.T) {
+	service, mng, db := getMockPostService(t)
 
-	expect := errs.MsgError{Msg: "invalid post id", Status: 400}
+	randID := rand.GetRandID()
+	expect := errs.MsgError{Msg: "parent comment not found", Status: 404}
 	expectBody, _ := json.Marshal(expect) // nolint:errcheck
 
-	req := httptest.NewRequest("DELETE", "/api/post/{postID}/{commentID}", nil)
-	req = mux.SetURLVars(req, map[string]string{"postID": "invalid id", "commentID": randID})
+	reqBody := bytes.NewBufferString(`{"comment":"some reply"}`)
+	req := httptest.NewRequest("POST", "/api/post/{postID}/{commentID}/reply", reqBody)
+	req = mux.SetURLVars(req, map[string]string{"postID": randID, "commentID": randID})
 	w := httptest.NewRecorder()
 
-	service.DeleteComment(w, req)
-
-	resp := w.Result()
-	defer resp.Body.Close()
+	mng.EXPECT().
+		Check(gomock.Any(), gomock.Any()).
+		Return(usr2, nil)
+	db.EXPECT().
+		AddComment(gomock.Any(), randID, gomock.Any()).
+		Return(nil, expect)
+
+	service.ReplyComment(w, req)
+
+	resp := w.Result()
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body) // nolint:errcheck
+
+	if resp.StatusCode != expect.Status {
+		t.Errorf("wrong status code:\nwant:\t%d\nhave\t%d", expect.Status, resp.StatusCode)
+	}
+	if string(body) != string(expectBody) {
+		t.Errorf("results not match:\n\nwant:\t%s\n\nhave\t%s", expectBody, body)
+	}
+}
+
+func TestDeleteComment_OK(t *testing.T) {
+	service, mng, db := getMockPostService(t)
+
+	expect := post.NewPost(usr1)
+	comm := post.NewComment(usr2, "some comment")
+	expect.Comments.Add(comm)                                                 // nolint:errcheck
+	expect.Comments.Delete(comm.ID, func(*post.Comment) bool { return true }) // nolint:errcheck
+	expectBody, _ := json.Marshal(expect)                                     // nolint:errcheck
+
+	req := httptest.NewRequest("DELETE", "/api/post/{postID}/{commentID}", nil)
+	req = mux.SetURLVars(req, map[string]string{"postID": expect.ID, "commentID": comm.ID})
+	w := httptest.NewRecorder()
+
+	mng.EXPECT().
+		Check(gomock.Any(), gomock.Any()).
+		Return(usr2, nil)
+	db.EXPECT().
+		DeleteComment(gomock.Any(), expect.ID, comm.ID, usr2.ID, gomock.Any()).
+		Return(expect, nil)
+
+	service.DeleteComment(w, req)
+
+	resp := w.Result()
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body) // nolint:errcheck
+
+	if resp.StatusCode != 200 {
+		t.Errorf("wrong status code:\nwant:\t%d\nhave\t%d", 200, resp.StatusCode)
+	}
+	if string(body) != string(expectBody) {
+		t.Errorf("results not match:\n\nwant:\t%s\n\nhave\t%s", expectBody, body)
+	}
+}
+
+func TestDeleteComment_Admin(t *testing.T) {
+	service, mng, db := getMockPostService(t)
+
+	admin := usr1
+	admin.Roles = []user.Role{{Name: user.RoleAdmin}}
+	p := post.NewPost(usr2)
+	comm := post.NewComment(usr2, "some comment")
+
+	req := httptest.NewRequest("DELETE", "/api/post/{postID}/{commentID}", nil)
+	req = mux.SetURLVars(req, map[string]string{"postID": p.ID, "commentID": comm.ID})
+	w := httptest.NewRecorder()
+
+	mng.EXPECT().
+		Check(gomock.Any(), gomock.Any()).
+		Return(admin, nil)
+	db.EXPECT().
+		DeleteComment(gomock.Any(), p.ID, comm.ID, admin.ID, gomock.Any()).
+		DoAndReturn(func(_ context.Context, _, _, _ string, allow post.CommentPermit) (*post.Post, error) {
+			if !allow(p, comm) {
+				t.Errorf("admin denied to delete comment")
+			}
+			return p, nil
+		})
+
+	service.DeleteComment(w, req)
+
+	if resp := w.Result(); resp.StatusCode != 200 {
+		t.Errorf("wrong status code:\nwant:\t%d\nhave\t%d", 200, resp.StatusCode)
+	}
+}
+
+func TestDeleteComment_InvalidPostErr(t *testing.T) {
+	service, _, _ := getMockPostService(t)
+
+	expect := errs.MsgError{Msg: "invalid post id", Status: 400}
+	expectBody, _ := json.Marshal(expect) // nolint:errcheck
+
+	req := httptest.NewRequest("DELETE", "/api/post/{postID}/{commentID}", nil)
+	req = mux.SetURLVars(req, map[string]string{"postID": "invalid id", "commentID": randID})
+	w := httptest.NewRecorder()
+
+	service.DeleteComment(w, req)
+
+	resp := w.Result()
+	defer resp.Body.Close()
 	body, _ := io.ReadAll(resp.Body) // nolint:errcheck
 
 	if resp.StatusCode != expect.Status {
@@ -746,7 +1181,7 @@ func TestDeleteComment_AuthErr(t *testing.T) {
 	w := httptest.NewRecorder()
 
 	mng.EXPECT().
-		Check(gomock.Any()).
+		Check(gomock.Any(), gomock.Any()).
 		Return(user.User{}, expect)
 
 	service.DeleteComment(w, req)
@@ -774,10 +1209,10 @@ func TestDeleteComment_DeleteErr(t *testing.T) {
 	w := httptest.NewRecorder()
 
 	mng.EXPECT().
-		Check(gomock.Any()).
+		Check(gomock.Any(), gomock.Any()).
 		Return(usr1, nil)
 	db.EXPECT().
-		DeleteComment(randID, randID, usr1.ID).
+		DeleteComment(gomock.Any(), randID, randID, usr1.ID, gomock.Any()).
 		Return(nil, expect)
 
 	service.DeleteComment(w, req)
@@ -806,10 +1241,10 @@ func TestUpvotePost_OK(t *testing.T) {
 	w := httptest.NewRecorder()
 
 	mng.EXPECT().
-		Check(gomock.Any()).
+		Check(gomock.Any(), gomock.Any()).
 		Return(usr2, nil)
 	db.EXPECT().
-		UpvotePost(expect.ID, usr2.ID).
+		UpvotePost(gomock.Any(), expect.ID, usr2.ID).
 		Return(expect, nil)
 
 	service.UpvotePost(w, req)
@@ -861,7 +1296,7 @@ func TestUpvotePost_AuthErr(t *testing.T) {
 	w := httptest.NewRecorder()
 
 	mng.EXPECT().
-		Check(gomock.Any()).
+		Check(gomock.Any(), gomock.Any()).
 		Return(user.User{}, expect)
 
 	service.UpvotePost(w, req)
@@ -889,10 +1324,10 @@ func TestUpvotePost_UpvoteErr(t *testing.T) {
 	w := httptest.NewRecorder()
 
 	mng.EXPECT().
-		Check(gomock.Any()).
+		Check(gomock.Any(), gomock.Any()).
 		Return(usr1, nil)
 	db.EXPECT().
-		UpvotePost(randID, usr1.ID).
+		UpvotePost(gomock.Any(), randID, usr1.ID).
 		Return(nil, expect)
 
 	service.UpvotePost(w, req)
@@ -921,10 +1356,10 @@ func TestDownvotePost_OK(t *testing.T) {
 	w := httptest.NewRecorder()
 
 	mng.EXPECT().
-		Check(gomock.Any()).
+		Check(gomock.Any(), gomock.Any()).
 		Return(usr2, nil)
 	db.EXPECT().
-		DownvotePost(expect.ID, usr2.ID).
+		DownvotePost(gomock.Any(), expect.ID, usr2.ID).
 		Return(expect, nil)
 
 	service.DownvotePost(w, req)
@@ -976,7 +1411,7 @@ func TestDownvotePost_AuthErr(t *testing.T) {
 	w := httptest.NewRecorder()
 
 	mng.EXPECT().
-		Check(gomock.Any()).
+		Check(gomock.Any(), gomock.Any()).
 		Return(user.User{}, expect)
 
 	service.DownvotePost(w, req)
@@ -1004,10 +1439,10 @@ func TestDownvotePost_DownvoteErr(t *testing.T) {
 	w := httptest.NewRecorder()
 
 	mng.EXPECT().
-		Check(gomock.Any()).
+		Check(gomock.Any(), gomock.Any()).
 		Return(usr1, nil)
 	db.EXPECT().
-		DownvotePost(randID, usr1.ID).
+		DownvotePost(gomock.Any(), randID, usr1.ID).
 		Return(nil, expect)
 
 	service.DownvotePost(w, req)
@@ -1036,10 +1471,10 @@ func TestUnvotePost_OK(t *testing.T) {
 	w := httptest.NewRecorder()
 
 	mng.EXPECT().
-		Check(gomock.Any()).
+		Check(gomock.Any(), gomock.Any()).
 		Return(usr1, nil)
 	db.EXPECT().
-		UnvotePost(expect.ID, usr1.ID).
+		UnvotePost(gomock.Any(), expect.ID, usr1.ID).
 		Return(expect, nil)
 
 	service.UnvotePost(w, req)
@@ -1091,7 +1526,7 @@ func TestUnvotePost_AuthErr(t *testing.T) {
 	w := httptest.NewRecorder()
 
 	mng.EXPECT().
-		Check(gomock.Any()).
+		Check(gomock.Any(), gomock.Any()).
 		Return(user.User{}, expect)
 
 	service.UnvotePost(w, req)
@@ -1119,10 +1554,10 @@ func TestUnvotePost_UnvoteErr(t *testing.T) {
 	w := httptest.NewRecorder()
 
 	mng.EXPECT().
-		Check(gomock.Any()).
+		Check(gomock.Any(), gomock.Any()).
 		Return(usr1, nil)
 	db.EXPECT().
-		UnvotePost(randID, usr1.ID).
+		UnvotePost(gomock.Any(), randID, usr1.ID).
 		Return(nil, expect)
 
 	service.UnvotePost(w, req)
@@ -1150,8 +1585,8 @@ func TestListPostsByUser_OK(t *testing.T) {
 	w := httptest.NewRecorder()
 
 	db.EXPECT().
-		GetByUser("grant").
-		Return(expect, nil)
+		GetByUser(gomock.Any(), "grant", post.New, post.Page{}, "").
+		Return(expect, "", nil)
 
 	service.ListPostsByUser(w, req)
 
@@ -1178,8 +1613,8 @@ func TestListPostsByUser_GetErr(t *testing.T) {
 	w := httptest.NewRecorder()
 
 	db.EXPECT().
-		GetByUser("grant").
-		Return(nil, fmt.Errorf("some err"))
+		GetByUser(gomock.Any(), "grant", post.New, post.Page{}, "").
+		Return(nil, "", fmt.Errorf("some err"))
 
 	service.ListPostsByUser(w, req)
 
@@ -1194,3 +1629,414 @@ func TestListPostsByUser_GetErr(t *testing.T) {
 		t.Errorf("results not match:\n\nwant:\t%s\n\nhave\t%s", expectBody, body)
 	}
 }
+
+func TestUpdatePost_OK(t *testing.T) {
+	service, mng, db := getMockPostService(t)
+
+	upd := post.PostEdit{Title: "new title", Text: "new text"}
+	expect := post.NewPost(usr1)
+	expectBody, _ := json.Marshal(expect) // nolint:errcheck
+
+	reqBody := bytes.NewBufferString(`{"title":"new title","text":"new text"}`)
+	req := httptest.NewRequest("PUT", "/api/post/{postID}", reqBody)
+	req = mux.SetURLVars(req, map[string]string{"postID": randID})
+	w := httptest.NewRecorder()
+
+	mng.EXPECT().
+		Check(gomock.Any(), gomock.Any()).
+		Return(usr1, nil)
+	db.EXPECT().
+		UpdatePost(gomock.Any(), randID, usr1.ID, upd).
+		Return(expect, nil)
+
+	service.UpdatePost(w, req)
+
+	resp := w.Result()
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body) // nolint:errcheck
+
+	if resp.StatusCode != 200 {
+		t.Errorf("wrong status code:\nwant:\t%d\nhave\t%d", 200, resp.StatusCode)
+	}
+	if string(body) != string(expectBody) {
+		t.Errorf("results not match:\n\nwant:\t%s\n\nhave\t%s", expectBody, body)
+	}
+}
+
+func TestUpdatePost_EmptyEditErr(t *testing.T) {
+	service, mng, _ := getMockPostService(t)
+
+	expect := errs.DetailErrors{Errors: []errs.DetailError{
+		{
+			Location: "body",
+			Param:    "title",
+			Msg:      "nothing to update",
+		},
+	}, Status: 422}
+	expectBody, _ := json.Marshal(expect) // nolint:errcheck
+
+	reqBody := bytes.NewBufferString(`{}`)
+	req := httptest.NewRequest("PUT", "/api/post/{postID}", reqBody)
+	req = mux.SetURLVars(req, map[string]string{"postID": randID})
+	w := httptest.NewRecorder()
+
+	mng.EXPECT().
+		Check(gomock.Any(), gomock.Any()).
+		Return(usr1, nil)
+
+	service.UpdatePost(w, req)
+
+	resp := w.Result()
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body) // nolint:errcheck
+
+	if resp.StatusCode != expect.Status {
+		t.Errorf("wrong status code:\nwant:\t%d\nhave\t%d", expect.Status, resp.StatusCode)
+	}
+	if string(body) != string(expectBody) {
+		t.Errorf("results not match:\n\nwant:\t%s\n\nhave\t%s", expectBody, body)
+	}
+}
+
+func TestUpdatePost_UpdateErr(t *testing.T) {
+	service, mng, db := getMockPostService(t)
+
+	expect := errs.MsgError{Msg: "unauthorized", Status: 401}
+	expectBody, _ := json.Marshal(expect) // nolint:errcheck
+
+	reqBody := bytes.NewBufferString(`{"title":"new title"}`)
+	req := httptest.NewRequest("PUT", "/api/post/{postID}", reqBody)
+	req = mux.SetURLVars(req, map[string]string{"postID": randID})
+	w := httptest.NewRecorder()
+
+	mng.EXPECT().
+		Check(gomock.Any(), gomock.Any()).
+		Return(usr2, nil)
+	db.EXPECT().
+		UpdatePost(gomock.Any(), randID, usr2.ID, post.PostEdit{Title: "new title"}).
+		Return(nil, expect)
+
+	service.UpdatePost(w, req)
+
+	resp := w.Result()
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body) // nolint:errcheck
+
+	if resp.StatusCode != expect.Status {
+		t.Errorf("wrong status code:\nwant:\t%d\nhave\t%d", expect.Status, resp.StatusCode)
+	}
+	if string(body) != string(expectBody) {
+		t.Errorf("results not match:\n\nwant:\t%s\n\nhave\t%s", expectBody, body)
+	}
+}
+
+func TestListRevisions_OK(t *testing.T) {
+	service, _, db := getMockPostService(t)
+
+	expect := []post.Revision{{Title: "old title", Text: "old text", CreatedFormat: "some time"}}
+	expectBody, _ := json.Marshal(expect) // nolint:errcheck
+
+	req := httptest.NewRequest("GET", "/api/post/{postID}/revisions", nil)
+	req = mux.SetURLVars(req, map[string]string{"postID": randID})
+	w := httptest.NewRecorder()
+
+	db.EXPECT().
+		GetRevisions(gomock.Any(), randID).
+		Return(expect, nil)
+
+	service.ListRevisions(w, req)
+
+	resp := w.Result()
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body) // nolint:errcheck
+
+	if resp.StatusCode != 200 {
+		t.Errorf("wrong status code:\nwant:\t%d\nhave\t%d", 200, resp.StatusCode)
+	}
+	if string(body) != string(expectBody) {
+		t.Errorf("results not match:\n\nwant:\t%s\n\nhave\t%s", expectBody, body)
+	}
+}
+
+func TestListRevisions_GetErr(t *testing.T) {
+	service, _, db := getMockPostService(t)
+
+	expect := errs.MsgError{Msg: "post not found", Status: 404}
+	expectBody, _ := json.Marshal(expect) // nolint:errcheck
+
+	req := httptest.NewRequest("GET", "/api/post/{postID}/revisions", nil)
+	req = mux.SetURLVars(req, map[string]string{"postID": randID})
+	w := httptest.NewRecorder()
+
+	db.EXPECT().
+		GetRevisions(gomock.Any(), randID).
+		Return(nil, expect)
+
+	service.ListRevisions(w, req)
+
+	resp := w.Result()
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body) // nolint:errcheck
+
+	if resp.StatusCode != expect.Status {
+		t.Errorf("wrong status code:\nwant:\t%d\nhave\t%d", expect.Status, resp.StatusCode)
+	}
+	if string(body) != string(expectBody) {
+		t.Errorf("results not match:\n\nwant:\t%s\n\nhave\t%s", expectBody, body)
+	}
+}
+
+func TestListPosts_NextCursor(t *testing.T) {
+	service, _, db := getMockPostService(t)
+
+	expect := []*post.Post{post.NewPost(usr1)}
+	expectBody, _ := json.Marshal(expect) // nolint:errcheck
+
+	req := httptest.NewRequest("GET", "/api/posts/?limit=1&after=prev", nil)
+	w := httptest.NewRecorder()
+
+	db.EXPECT().
+		GetAll(gomock.Any(), post.Top, post.Page{Limit: 1, After: "prev"}, "").
+		Return(expect, "next", nil)
+
+	service.ListPosts(w, req)
+
+	resp := w.Result()
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body) // nolint:errcheck
+
+	if resp.StatusCode != 200 {
+		t.Errorf("wrong status code:\nwant:\t%#v\nhave\t%#v", 200, resp.StatusCode)
+	}
+	if cursor := resp.Header.Get(NextCursorHeader); cursor != "next" {
+		t.Errorf("wrong next cursor:\nwant:\t%s\nhave\t%s", "next", cursor)
+	}
+	if string(body) != string(expectBody) {
+		t.Errorf("results not match:\n\nwant:\t%s\n\nhave\t%s", expectBody, body)
+	}
+}
+
+// Клиент проходит ленту по курсору из заголовка до последней страницы,
+// а без limit получает ее целиком
+func TestListPosts_WalkPages(t *testing.T) {
+	repo := post.NewMemoryRepo()
+	for i := 0; i < post.MaxPageLimit+1; i++ {
+		repo.AddPost(context.Background(), post.NewPost(usr1)) // nolint:errcheck
+	}
+	service := &PostHandler{Repo: repo, Logger: zap.NewNop().Sugar()}
+
+	list := func(query string) ([]*post.Post, string) {
+		w := httptest.NewRecorder()
+		service.ListPosts(w, httptest.NewRequest("GET", "/api/posts/"+query, nil))
+		resp := w.Result()
+		defer resp.Body.Close()
+		if resp.StatusCode != 200 {
+			t.Fatalf("wrong status code:\nwant:\t%d\nhave\t%d", 200, resp.StatusCode)
+		}
+		posts := []*post.Post{}
+		if err := json.NewDecoder(resp.Body).Decode(&posts); err != nil {
+			t.Fatalf("decode err: %s", err)
+		}
+		return posts, resp.Header.Get(NextCursorHeader)
+	}
+
+	seen := map[string]bool{}
+	pages := 0
+	for query := "?limit=40"; query != ""; pages++ {
+		posts, next := list(query)
+		for _, p := range posts {
+			if seen[p.ID] {
+				t.Errorf("post repeated on page %d: %s", pages, p.ID)
+			}
+			seen[p.ID] = true
+		}
+		query = ""
+		if next != "" {
+			query = "?limit=40&after=" + next
+		}
+	}
+	if pages != 3 || len(seen) != post.MaxPageLimit+1 {
+		t.Errorf("wrong walk: %d pages, %d posts", pages, len(seen))
+	}
+
+	posts, next := list("")
+	if len(posts) != post.MaxPageLimit+1 || next != "" {
+		t.Errorf("listing without limit cut: %d posts, next=%q", len(posts), next)
+	}
+}
+
+func TestListPosts_InvalidLimitErr(t *testing.T) {
+	service, _, _ := getMockPostService(t)
+
+	expect := errs.DetailErrors{Errors: []errs.DetailError{
+		{
+			Location: "query",
+			Param:    "limit",
+			Value:    "0",
+			Msg:      fmt.Sprintf("must be an integer from 1 to %d", post.MaxPageLimit),
+		},
+	}, Status: 400}
+	expectBody, _ := json.Marshal(expect) // nolint:errcheck
+
+	req := httptest.NewRequest("GET", "/api/posts/?limit=0", nil)
+	w := httptest.NewRecorder()
+
+	service.ListPosts(w, req)
+
+	resp := w.Result()
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body) // nolint:errcheck
+
+	if resp.StatusCode != expect.Status {
+		t.Errorf("wrong status code:\nwant:\t%#v\nhave\t%#v", expect.Status, resp.StatusCode)
+	}
+	if string(body) != string(expectBody) {
+		t.Errorf("results not match:\n\nwant:\t%s\n\nhave\t%s", expectBody, body)
+	}
+}
+
+func TestListPostsByCategory_Sort(t *testing.T) {
+	service, _, db := getMockPostService(t)
+
+	expect := []*post.Post{post.NewPost(usr1)}
+	expectBody, _ := json.Marshal(expect) // nolint:errcheck
+
+	req := httptest.NewRequest("GET", "/api/posts/music?sort=top&t=week", nil)
+	req = mux.SetURLVars(req, map[string]string{"categoryName": "music"})
+	w := httptest.NewRecorder()
+
+	db.EXPECT().
+		GetByCategory(gomock.Any(), "music", post.Ranking{Name: "top", Window: 7 * 24 * time.Hour}, post.Page{}, "").
+		Return(expect, "", nil)
+
+	service.ListPostsByCategory(w, req)
+
+	resp := w.Result()
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body) // nolint:errcheck
+
+	if resp.StatusCode != 200 {
+		t.Errorf("wrong status code:\nwant:\t%#v\nhave\t%#v", 200, resp.StatusCode)
+	}
+	if string(body) != string(expectBody) {
+		t.Errorf("results not match:\n\nwant:\t%s\n\nhave\t%s", expectBody, body)
+	}
+}
+
+func TestListPosts_InvalidSortErr(t *testing.T) {
+	service, _, _ := getMockPostService(t)
+
+	expect := errs.DetailErrors{Errors: []errs.DetailError{
+		{
+			Location: "query",
+			Param:    "sort",
+			Value:    "best",
+			Msg:      "must be one of controversial, hot, new, rising, top",
+		},
+	}, Status: 400}
+	expectBody, _ := json.Marshal(expect) // nolint:errcheck
+
+	req := httptest.NewRequest("GET", "/api/posts/?sort=best", nil)
+	w := httptest.NewRecorder()
+
+	service.ListPosts(w, req)
+
+	resp := w.Result()
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body) // nolint:errcheck
+
+	if resp.StatusCode != expect.Status {
+		t.Errorf("wrong status code:\nwant:\t%#v\nhave\t%#v", expect.Status, resp.StatusCode)
+	}
+	if string(body) != string(expectBody) {
+		t.Errorf("results not match:\n\nwant:\t%s\n\nhave\t%s", expectBody, body)
+	}
+}
+
+func TestUpvoteComment_OK(t *testing.T) {
+	service, mng, db := getMockPostService(t)
+
+	expect := post.NewPost(usr1)
+	comm := post.NewComment(usr1, "some comment")
+	expect.Comments.Add(comm)             // nolint:errcheck
+	expectBody, _ := json.Marshal(expect) // nolint:errcheck
+
+	req := httptest.NewRequest("GET", "/api/post/{postID}/{commentID}/upvote", nil)
+	req = mux.SetURLVars(req, map[string]string{"postID": expect.ID, "commentID": comm.ID})
+	w := httptest.NewRecorder()
+
+	mng.EXPECT().
+		Check(gomock.Any(), gomock.Any()).
+		Return(usr2, nil)
+	db.EXPECT().
+		UpvoteComment(gomock.Any(), expect.ID, comm.ID, usr2.ID).
+		Return(expect, nil)
+
+	service.UpvoteComment(w, req)
+
+	resp := w.Result()
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body) // nolint:errcheck
+
+	if resp.StatusCode != 200 {
+		t.Errorf("wrong status code:\nwant:\t%d\nhave\t%d", 200, resp.StatusCode)
+	}
+	if string(body) != string(expectBody) {
+		t.Errorf("results not match:\n\nwant:\t%s\n\nhave\t%s", expectBody, body)
+	}
+}
+
+func TestDownvoteComment_ErrNoComment(t *testing.T) {
+	service, mng, db := getMockPostService(t)
+
+	expect := errs.MsgError{Msg: "comment not found", Status: 404}
+	expectBody, _ := json.Marshal(expect) // nolint:errcheck
+
+	req := httptest.NewRequest("GET", "/api/post/{postID}/{commentID}/downvote", nil)
+	req = mux.SetURLVars(req, map[string]string{"postID": randID, "commentID": randID})
+	w := httptest.NewRecorder()
+
+	mng.EXPECT().
+		Check(gomock.Any(), gomock.Any()).
+		Return(usr2, nil)
+	db.EXPECT().
+		DownvoteComment(gomock.Any(), randID, randID, usr2.ID).
+		Return(nil, expect)
+
+	service.DownvoteComment(w, req)
+
+	resp := w.Result()
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body) // nolint:errcheck
+
+	if resp.StatusCode != expect.Status {
+		t.Errorf("wrong status code:\nwant:\t%d\nhave\t%d", expect.Status, resp.StatusCode)
+	}
+	if string(body) != string(expectBody) {
+		t.Errorf("results not match:\n\nwant:\t%s\n\nhave\t%s", expectBody, body)
+	}
+}
+
+func TestUnvoteComment_InvalidCommentErr(t *testing.T) {
+	service, _, _ := getMockPostService(t)
+
+	expect := errs.MsgError{Msg: "invalid comment id", Status: 400}
+	expectBody, _ := json.Marshal(expect) // nolint:errcheck
+
+	req := httptest.NewRequest("GET", "/api/post/{postID}/{commentID}/unvote", nil)
+	req = mux.SetURLVars(req, map[string]string{"postID": randID, "commentID": "bad"})
+	w := httptest.NewRecorder()
+
+	service.UnvoteComment(w, req)
+
+	resp := w.Result()
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body) // nolint:errcheck
+
+	if resp.StatusCode != expect.Status {
+		t.Errorf("wrong status code:\nwant:\t%d\nhave\t%d", expect.Status, resp.StatusCode)
+	}
+	if string(body) != string(expectBody) {
+		t.Errorf("results not match:\n\nwant:\t%s\n\nhave\t%s", expectBody, body)
+	}
+}
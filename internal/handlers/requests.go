@@ -0,0 +1,118 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+
+	"asperitas/internal/community"
+	"asperitas/internal/errs"
+	"asperitas/internal/post"
+	"asperitas/internal/user"
+)
+
+// Тела запросов на запись. Декодируются в отдельные структуры, а не в доменные типы,
+// чтобы клиент не мог проставить поля, которые задает сервер (автор, рейтинг, id и т.д.).
+
+const (
+	maxAuthBody    = 4 << 10
+	maxCommentBody = 64 << 10
+	maxPostBody    = 256 << 10
+)
+
+type credentialsRequest struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+func (req credentialsRequest) toCredentials() user.Credentials {
+	return user.Credentials{Username: req.Username, Password: req.Password}
+}
+
+type refreshRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+type createCommunityRequest struct {
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+	Rules       []string `json:"rules"`
+}
+
+func (req createCommunityRequest) toCommunity(usr user.User) *community.Community {
+	return community.NewCommunity(usr, req.Name, req.Description, req.Rules)
+}
+
+type createPostRequest struct {
+	Type     post.PostType     `json:"type"`
+	Title    string            `json:"title"`
+	URL      string            `json:"url"`
+	Text     string            `json:"text"`
+	Category post.PostCategory `json:"category"`
+}
+
+func (req createPostRequest) toPost(usr user.User) *post.Post {
+	p := post.NewPost(usr)
+	p.Type = req.Type
+	p.Title = req.Title
+	p.URL = req.URL
+	p.Text = req.Text
+	p.Category = req.Category
+	return p
+}
+
+type editPostRequest struct {
+	Title string `json:"title"`
+	URL   string `json:"url"`
+	Text  string `json:"text"`
+}
+
+func (req editPostRequest) toPostEdit() post.PostEdit {
+	return post.PostEdit{Title: req.Title, URL: req.URL, Text: req.Text}
+}
+
+type commentRequest struct {
+	Comment string `json:"comment"`
+}
+
+// Декодирует ровно один json объект не длиннее limit байт без неизвестных полей.
+// Неизвестное поле отклоняется с 422, как и невалидное значение, а тело, которое
+// не разбирается как json или не подходит по типам, с 400.
+func decodeBody(w http.ResponseWriter, r *http.Request, v interface{}, limit int64) error {
+	r.Body = http.MaxBytesReader(w, r.Body, limit)
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	err := dec.Decode(v)
+	if err == nil {
+		if dec.Decode(&struct{}{}) != io.EOF {
+			return errs.MsgError{Msg: "request body must contain a single json object", Status: http.StatusBadRequest}
+		}
+		return nil
+	}
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(err, &maxBytesErr) {
+		return errs.MsgError{Msg: "request body too large", Status: http.StatusRequestEntityTooLarge}
+	}
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	switch {
+	case errors.Is(err, io.EOF):
+		return errs.MsgError{Msg: "request body must contain a single json object", Status: http.StatusBadRequest}
+	case errors.As(err, &syntaxErr), errors.As(err, &typeErr), errors.Is(err, io.ErrUnexpectedEOF):
+		return errs.MsgError{Msg: "invalid json body", Status: http.StatusBadRequest}
+	}
+	// encoding/json не экспортирует тип этой ошибки, поле достается из текста;
+	// смену формата текста ловит TestDecodeBody_UnknownField
+	if field, ok := strings.CutPrefix(err.Error(), "json: unknown field "); ok {
+		return errs.DetailErrors{Errors: []errs.DetailError{
+			{
+				Location: "body",
+				Param:    strings.Trim(field, `"`),
+				Msg:      "is not allowed",
+			},
+		}, Status: 422}
+	}
+	return err
+}
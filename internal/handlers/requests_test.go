@@ -0,0 +1,213 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"asperitas/internal/errs"
+
+	"github.com/golang/mock/gomock"
+	"github.com/gorilla/mux"
+)
+
+// Каждое поле, которое задает сервер, должно отклоняться, а не молча перезаписывать значение
+func checkForbiddenField(t *testing.T, handler http.HandlerFunc, method, target, validBody, field string) {
+	t.Helper()
+	body := strings.TrimSuffix(validBody, "}") + fmt.Sprintf(`,%q:"injected"}`, field)
+	req := httptest.NewRequest(method, target, bytes.NewBufferString(body))
+	req = mux.SetURLVars(req, map[string]string{"postID": randID, "commentID": randID})
+	w := httptest.NewRecorder()
+
+	handler(w, req)
+
+	expect := errs.DetailErrors{Errors: []errs.DetailError{
+		{
+			Location: "body",
+			Param:    field,
+			Msg:      "is not allowed",
+		},
+	}, Status: 422}
+	expectBody, _ := json.Marshal(expect) // nolint:errcheck
+
+	resp := w.Result()
+	defer resp.Body.Close()
+	respBody, _ := io.ReadAll(resp.Body) // nolint:errcheck
+
+	if resp.StatusCode != expect.Status {
+		t.Errorf("wrong status code for %s:\nwant:\t%d\nhave\t%d", field, expect.Status, resp.StatusCode)
+	}
+	if string(respBody) != string(expectBody) {
+		t.Errorf("results not match:\nwant:\t%s\nhave\t%s", expectBody, respBody)
+	}
+}
+
+func TestCreatePost_ForbiddenFields(t *testing.T) {
+	fields := []string{"score", "views", "votes", "author", "id", "comments", "created", "edited", "upvotePercentage"}
+	service, mng, _ := getMockPostService(t)
+	mng.EXPECT().
+		Check(gomock.Any(), gomock.Any()).
+		Return(usr1, nil).
+		Times(len(fields))
+
+	for _, field := range fields {
+		checkForbiddenField(t, service.CreatePost, "POST", "/api/posts",
+			`{"category":"music","type":"text","title":"some title","text":"some text"}`, field)
+	}
+}
+
+func TestUpdatePost_ForbiddenFields(t *testing.T) {
+	fields := []string{"type", "category", "score", "author", "id"}
+	service, mng, _ := getMockPostService(t)
+	mng.EXPECT().
+		Check(gomock.Any(), gomock.Any()).
+		Return(usr1, nil).
+		Times(len(fields))
+
+	for _, field := range fields {
+		checkForbiddenField(t, service.UpdatePost, "PUT", "/api/post/{postID}", `{"title":"new title"}`, field)
+	}
+}
+
+func TestCreateComment_ForbiddenFields(t *testing.T) {
+	fields := []string{"author", "id", "votes", "created"}
+	service, mng, _ := getMockPostService(t)
+	mng.EXPECT().
+		Check(gomock.Any(), gomock.Any()).
+		Return(usr1, nil).
+		Times(2 * len(fields))
+
+	for _, field := range fields {
+		checkForbiddenField(t, service.CreateComment, "POST", "/api/post/{postID}", `{"comment":"some comment"}`, field)
+		checkForbiddenField(t, service.ReplyComment, "POST", "/api/post/{postID}/{commentID}/reply",
+			`{"comment":"some comment"}`, field)
+	}
+}
+
+func TestCreateCommunity_ForbiddenFields(t *testing.T) {
+	fields := []string{"creator", "created"}
+	service, mng, _ := getMockCommunityService(t)
+	mng.EXPECT().
+		Check(gomock.Any(), gomock.Any()).
+		Return(usr1, nil).
+		Times(len(fields))
+
+	for _, field := range fields {
+		checkForbiddenField(t, service.CreateCommunity, "POST", "/api/communities",
+			`{"name":"cats","description":"about cats"}`, field)
+	}
+}
+
+func TestAuth_ForbiddenFields(t *testing.T) {
+	service, _, _, _ := getMockUserService(t)
+
+	checkForbiddenField(t, service.Register, "POST", "/api/register", `{"username":"admin","password":"passw0rd"}`, "id")
+	checkForbiddenField(t, service.Login, "POST", "/api/login", `{"username":"admin","password":"passw0rd"}`, "id")
+	checkForbiddenField(t, service.Refresh, "POST", "/api/token/refresh", `{"refresh_token":"token"}`, "user_id")
+}
+
+func TestDecodeBody_TooLarge(t *testing.T) {
+	service, _, _, _ := getMockUserService(t)
+
+	expect := errs.MsgError{Msg: "request body too large", Status: 413}
+	expectBody, _ := json.Marshal(expect) // nolint:errcheck
+
+	reqBody := bytes.NewBufferString(`{"username":"admin","password":"` + strings.Repeat("a", maxAuthBody) + `"}`)
+	req := httptest.NewRequest("POST", "/api/register", reqBody)
+	w := httptest.NewRecorder()
+
+	service.Register(w, req)
+
+	resp := w.Result()
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body) // nolint:errcheck
+
+	if resp.StatusCode != expect.Status {
+		t.Errorf("wrong status code:\nwant:\t%d\nhave\t%d", expect.Status, resp.StatusCode)
+	}
+	if string(body) != string(expectBody) {
+		t.Errorf("results not match:\nwant:\t%s\nhave\t%s", expectBody, body)
+	}
+}
+
+func TestDecodeBody_TrailingData(t *testing.T) {
+	service, _, _, _ := getMockUserService(t)
+
+	expect := errs.MsgError{Msg: "request body must contain a single json object", Status: 400}
+	expectBody, _ := json.Marshal(expect) // nolint:errcheck
+
+	reqBody := bytes.NewBufferString(`{"username":"admin","password":"passw0rd"}{"username":"root"}`)
+	req := httptest.NewRequest("POST", "/api/register", reqBody)
+	w := httptest.NewRecorder()
+
+	service.Register(w, req)
+
+	resp := w.Result()
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body) // nolint:errcheck
+
+	if resp.StatusCode != expect.Status {
+		t.Errorf("wrong status code:\nwant:\t%d\nhave\t%d", expect.Status, resp.StatusCode)
+	}
+	if string(body) != string(expectBody) {
+		t.Errorf("results not match:\nwant:\t%s\nhave\t%s", expectBody, body)
+	}
+}
+
+func TestDecodeBody_InvalidJSON(t *testing.T) {
+	cases := []struct {
+		body   string
+		expect errs.MsgError
+	}{
+		{``, errs.MsgError{Msg: "request body must contain a single json object", Status: 400}},
+		{`bad json`, errs.MsgError{Msg: "invalid json body", Status: 400}},
+		{`{"username":"admin",`, errs.MsgError{Msg: "invalid json body", Status: 400}},
+		{`{"username":1,"password":"passw0rd"}`, errs.MsgError{Msg: "invalid json body", Status: 400}},
+		{`["admin","passw0rd"]`, errs.MsgError{Msg: "invalid json body", Status: 400}},
+	}
+	service, _, _, _ := getMockUserService(t)
+
+	for _, c := range cases {
+		expectBody, _ := json.Marshal(c.expect) // nolint:errcheck
+		req := httptest.NewRequest("POST", "/api/register", bytes.NewBufferString(c.body))
+		w := httptest.NewRecorder()
+
+		service.Register(w, req)
+
+		resp := w.Result()
+		body, _ := io.ReadAll(resp.Body) // nolint:errcheck
+		resp.Body.Close()
+
+		if resp.StatusCode != c.expect.Status {
+			t.Errorf("wrong status code for %q:\nwant:\t%d\nhave\t%d", c.body, c.expect.Status, resp.StatusCode)
+		}
+		if string(body) != string(expectBody) {
+			t.Errorf("results not match for %q:\nwant:\t%s\nhave\t%s", c.body, expectBody, body)
+		}
+	}
+}
+
+// Имя неизвестного поля достается из текста ошибки encoding/json: тест упадет,
+// если формат текста изменится в новой версии go
+func TestDecodeBody_UnknownField(t *testing.T) {
+	req := httptest.NewRequest("POST", "/api/register", bytes.NewBufferString(`{"user_name":"admin"}`))
+
+	err := decodeBody(httptest.NewRecorder(), req, &credentialsRequest{}, maxAuthBody)
+
+	expect := errs.DetailErrors{Errors: []errs.DetailError{
+		{
+			Location: "body",
+			Param:    "user_name",
+			Msg:      "is not allowed",
+		},
+	}, Status: 422}
+	if !reflect.DeepEqual(expect, err) {
+		t.Errorf("results not match:\nwant:\t%#v\nhave\t%#v", expect, err)
+	}
+}
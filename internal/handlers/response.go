@@ -1,18 +1,21 @@
 package handlers
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"net/http"
 
 	"asperitas/internal/errs"
+	"asperitas/internal/logging"
 
 	"go.uber.org/zap"
 )
 
-// Логирует выполненные действия и пишет http ответ
-func WriteAndLogData(w http.ResponseWriter, v interface{}, logger *zap.SugaredLogger, logString string) {
+// Логирует выполненные действия логгером запроса (logger — если его нет в контексте) и пишет http ответ
+func WriteAndLogData(w http.ResponseWriter, r *http.Request, v interface{}, logger *zap.SugaredLogger, logString string) {
+	logger = logging.FromContext(r.Context(), logger)
 	data, err := json.Marshal(v)
 	if err != nil {
 		logger.Errorf("encode data to json err: %s", err)
@@ -26,7 +29,8 @@ func WriteAndLogData(w http.ResponseWriter, v interface{}, logger *zap.SugaredLo
 }
 
 // Логирует входящие ошибки (в том числе и кастомные) и пишет http ответ
-func WriteAndLogErr(w http.ResponseWriter, err error, logger *zap.SugaredLogger, logPrefix string) {
+func WriteAndLogErr(w http.ResponseWriter, r *http.Request, err error, logger *zap.SugaredLogger, logPrefix string) {
+	logger = logging.FromContext(r.Context(), logger)
 	var (
 		detailErrs errs.DetailErrors
 		msgErr     errs.MsgError
@@ -42,6 +46,10 @@ func WriteAndLogErr(w http.ResponseWriter, err error, logger *zap.SugaredLogger,
 		code = msgErr.Status
 		resp = msgErr.Error()
 		logger.Infof("%s: code=%d msg=%s", logPrefix, code, msgErr.Msg)
+	case errors.Is(err, context.DeadlineExceeded):
+		code = http.StatusGatewayTimeout
+		resp = `{"message":"gateway timeout"}`
+		logger.Warnf("%s: code=%d msg=%s", logPrefix, code, err)
 	default:
 		code = http.StatusInternalServerError
 		resp = `{"message":"internal server error"}`
@@ -1,60 +1,130 @@
 package handlers
 
 import (
-	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 
+	"asperitas/internal/errs"
+	"asperitas/internal/logging"
 	"asperitas/internal/session"
 	"asperitas/internal/user"
+	"asperitas/internal/validate"
+	"asperitas/pkg/clientip"
 
 	"go.uber.org/zap"
 )
 
 type UserHandler struct {
-	Sess   session.SessionManager
-	Repo   user.UserRepo
-	Logger *zap.SugaredLogger
+	Sess  session.SessionManager
+	Repo  user.UserRepo
+	Guard user.LoginGuard
+	// Адрес клиента для блокировки входа по IP
+	ClientIP clientip.Resolver
+	Logger   *zap.SugaredLogger
 }
 
 func (h *UserHandler) Login(w http.ResponseWriter, r *http.Request) {
-	var creds user.Credentials
+	var req credentialsRequest
 	defer r.Body.Close()
-	if err := json.NewDecoder(r.Body).Decode(&creds); err != nil {
-		WriteAndLogErr(w, err, h.Logger, "decode json err")
+	if err := decodeBody(w, r, &req, maxAuthBody); err != nil {
+		WriteAndLogErr(w, r, err, h.Logger, "decode json err")
 		return
 	}
-	usr, err := h.Repo.Authorize(creds.Username, creds.Password)
+	creds := req.toCredentials()
+	ip := h.ClientIP.FromRequest(r)
+	if err := h.Guard.Check(r.Context(), creds.Username, ip); err != nil {
+		WriteAndLogErr(w, r, err, h.Logger, "login guard err")
+		return
+	}
+	usr, err := h.Repo.Authorize(r.Context(), creds.Username, creds.Password)
 	if err != nil {
-		WriteAndLogErr(w, err, h.Logger, "authorize err")
+		// неудачей считается только неверная пара логин-пароль, а не сбой базы
+		var msgErr errs.MsgError
+		if errors.As(err, &msgErr) && msgErr.Status == http.StatusUnauthorized {
+			if failErr := h.Guard.Fail(r.Context(), creds.Username, ip); failErr != nil {
+				logging.FromContext(r.Context(), h.Logger).Errorf("login guard fail err: %s", failErr)
+			}
+		}
+		WriteAndLogErr(w, r, err, h.Logger, "authorize err")
 		return
 	}
-	sess, err := h.Sess.Create(usr)
+	logging.With(r.Context(), "user_id", usr.ID)
+	if err = h.Guard.Reset(r.Context(), creds.Username); err != nil {
+		logging.FromContext(r.Context(), h.Logger).Errorf("login guard reset err: %s", err)
+	}
+	sess, err := h.Sess.Create(r.Context(), usr)
 	if err != nil {
-		WriteAndLogErr(w, err, h.Logger, "create session err")
+		WriteAndLogErr(w, r, err, h.Logger, "create session err")
 		return
 	}
 	logStr := fmt.Sprintf("logged user: username=%s id=%s", usr.Username, usr.ID)
-	WriteAndLogData(w, sess, h.Logger, logStr)
+	WriteAndLogData(w, r, sess, h.Logger, logStr)
 }
 
 func (h *UserHandler) Register(w http.ResponseWriter, r *http.Request) {
-	var creds user.Credentials
+	var req credentialsRequest
 	defer r.Body.Close()
-	if err := json.NewDecoder(r.Body).Decode(&creds); err != nil {
-		WriteAndLogErr(w, err, h.Logger, "decode json err")
+	if err := decodeBody(w, r, &req, maxAuthBody); err != nil {
+		WriteAndLogErr(w, r, err, h.Logger, "decode json err")
 		return
 	}
-	usr, err := h.Repo.SignUp(creds.Username, creds.Password)
+	creds := req.toCredentials()
+	v := validate.New()
+	creds.Validate(v)
+	if err := v.Err(); err != nil {
+		WriteAndLogErr(w, r, err, h.Logger, "credentials valid err")
+		return
+	}
+	usr, err := h.Repo.SignUp(r.Context(), creds.Username, creds.Password)
 	if err != nil {
-		WriteAndLogErr(w, err, h.Logger, "sign up err")
+		WriteAndLogErr(w, r, err, h.Logger, "sign up err")
 		return
 	}
-	sess, err := h.Sess.Create(usr)
+	logging.With(r.Context(), "user_id", usr.ID)
+	sess, err := h.Sess.Create(r.Context(), usr)
 	if err != nil {
-		WriteAndLogErr(w, err, h.Logger, "create session err")
+		WriteAndLogErr(w, r, err, h.Logger, "create session err")
 		return
 	}
 	logStr := fmt.Sprintf("registered user: username=%s id=%s", usr.Username, usr.ID)
-	WriteAndLogData(w, sess, h.Logger, logStr)
+	WriteAndLogData(w, r, sess, h.Logger, logStr)
+}
+
+func (h *UserHandler) Refresh(w http.ResponseWriter, r *http.Request) {
+	var req refreshRequest
+	defer r.Body.Close()
+	if err := decodeBody(w, r, &req, maxAuthBody); err != nil {
+		WriteAndLogErr(w, r, err, h.Logger, "decode json err")
+		return
+	}
+	sess, err := h.Sess.Refresh(r.Context(), req.RefreshToken)
+	if err != nil {
+		WriteAndLogErr(w, r, err, h.Logger, "refresh session err")
+		return
+	}
+	logging.With(r.Context(), "user_id", sess.UserID)
+	logStr := fmt.Sprintf("refreshed session: user_id=%s", sess.UserID)
+	WriteAndLogData(w, r, sess, h.Logger, logStr)
+}
+
+func (h *UserHandler) Logout(w http.ResponseWriter, r *http.Request) {
+	if err := h.Sess.Destroy(r.Context(), r.Header.Get("Authorization")); err != nil {
+		WriteAndLogErr(w, r, err, h.Logger, "destroy session err")
+		return
+	}
+	WriteAndLogData(w, r, errs.MsgError{Msg: "success", Status: http.StatusOK}, h.Logger, "logged out session")
+}
+
+func (h *UserHandler) LogoutAll(w http.ResponseWriter, r *http.Request) {
+	usr, ok := sessionCheck(w, r, h.Logger, h.Sess)
+	if !ok {
+		return
+	}
+	if err := h.Sess.DestroyAllForUser(r.Context(), usr.ID); err != nil {
+		WriteAndLogErr(w, r, err, h.Logger, "destroy user sessions err")
+		return
+	}
+	logStr := fmt.Sprintf("logged out all sessions: username=%s id=%s", usr.Username, usr.ID)
+	WriteAndLogData(w, r, errs.MsgError{Msg: "success", Status: http.StatusOK}, h.Logger, logStr)
 }
@@ -11,6 +11,7 @@ import (
 	"asperitas/internal/errs"
 	"asperitas/internal/session"
 	"asperitas/internal/user"
+	"asperitas/pkg/clientip"
 	"asperitas/pkg/rand"
 
 	"github.com/golang/mock/gomock"
@@ -19,19 +20,21 @@ import (
 
 var (
 	usr   = &user.User{ID: rand.GetRandID(), Username: "admin", Password: "passw"}
-	creds = user.Credentials{Username: "admin", Password: "passw"}
+	creds = user.Credentials{Username: "admin", Password: "passw0rd"}
 )
 
-func getMockUserService(t *testing.T) (*UserHandler, *session.MockSessionManager, *user.MockUserRepo) {
+func getMockUserService(t *testing.T) (*UserHandler, *session.MockSessionManager, *user.MockUserRepo, *user.MockLoginGuard) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
 	mng := session.NewMockSessionManager(ctrl)
 	db := user.NewMockUserRepo(ctrl)
+	guard := user.NewMockLoginGuard(ctrl)
 	return &UserHandler{
 		Sess:   mng,
 		Repo:   db,
+		Guard:  guard,
 		Logger: zap.NewNop().Sugar(),
-	}, mng, db
+	}, mng, db, guard
 }
 
 func getCredsBuffer() *bytes.Buffer {
@@ -41,7 +44,7 @@ func getCredsBuffer() *bytes.Buffer {
 }
 
 func TestLogin_OK(t *testing.T) {
-	service, mng, db := getMockUserService(t)
+	service, mng, db, guard := getMockUserService(t)
 
 	expect := session.Session{Token: "some token"}
 	expectBody, _ := json.Marshal(expect) // nolint:errcheck
@@ -50,12 +53,18 @@ func TestLogin_OK(t *testing.T) {
 	req := httptest.NewRequest("POST", "/api/login", reqBody)
 	w := httptest.NewRecorder()
 
+	guard.EXPECT().
+		Check(gomock.Any(), creds.Username, "192.0.2.1").
+		Return(nil)
 	db.EXPECT().
-		Authorize(creds.Username, creds.Password).
+		Authorize(gomock.Any(), creds.Username, creds.Password).
 		Return(usr, nil)
 	mng.EXPECT().
-		Create(usr).
+		Create(gomock.Any(), usr).
 		Return(expect, nil)
+	guard.EXPECT().
+		Reset(gomock.Any(), creds.Username).
+		Return(nil)
 
 	service.Login(w, req)
 
@@ -72,9 +81,9 @@ func TestLogin_OK(t *testing.T) {
 }
 
 func TestLogin_DecodeErr(t *testing.T) {
-	service, _, _ := getMockUserService(t)
+	service, _, _, _ := getMockUserService(t)
 
-	expect := errs.MsgError{Msg: "internal server error", Status: 500}
+	expect := errs.MsgError{Msg: "invalid json body", Status: 400}
 	expectBody, _ := json.Marshal(expect) // nolint:errcheck
 
 	reqBody := bytes.NewBufferString(`bad json`)
@@ -96,7 +105,7 @@ func TestLogin_DecodeErr(t *testing.T) {
 }
 
 func TestLogin_AuthErr(t *testing.T) {
-	service, _, db := getMockUserService(t)
+	service, _, db, guard := getMockUserService(t)
 
 	expect := errs.MsgError{Msg: "internal server error", Status: 500}
 	expectBody, _ := json.Marshal(expect) // nolint:errcheck
@@ -105,8 +114,11 @@ func TestLogin_AuthErr(t *testing.T) {
 	req := httptest.NewRequest("POST", "/api/login", reqBody)
 	w := httptest.NewRecorder()
 
+	guard.EXPECT().
+		Check(gomock.Any(), creds.Username, "192.0.2.1").
+		Return(nil)
 	db.EXPECT().
-		Authorize(creds.Username, creds.Password).
+		Authorize(gomock.Any(), creds.Username, creds.Password).
 		Return(nil, fmt.Errorf("mysql scan err"))
 
 	service.Login(w, req)
@@ -124,18 +136,24 @@ func TestLogin_AuthErr(t *testing.T) {
 }
 
 func TestLogin_AuthMsgErr(t *testing.T) {
-	service, _, db := getMockUserService(t)
+	service, _, db, guard := getMockUserService(t)
 
-	expect := errs.MsgError{Msg: "user not found", Status: 401}
+	expect := errs.MsgError{Msg: "invalid credentials", Status: 401}
 	expectBody, _ := json.Marshal(expect) // nolint:errcheck
 
 	reqBody := getCredsBuffer()
 	req := httptest.NewRequest("POST", "/api/login", reqBody)
 	w := httptest.NewRecorder()
 
+	guard.EXPECT().
+		Check(gomock.Any(), creds.Username, "192.0.2.1").
+		Return(nil)
 	db.EXPECT().
-		Authorize(creds.Username, creds.Password).
+		Authorize(gomock.Any(), creds.Username, creds.Password).
 		Return(nil, expect)
+	guard.EXPECT().
+		Fail(gomock.Any(), creds.Username, "192.0.2.1").
+		Return(nil)
 
 	service.Login(w, req)
 
@@ -151,8 +169,65 @@ func TestLogin_AuthMsgErr(t *testing.T) {
 	}
 }
 
+// Заблокированный вход отклоняется до проверки пароля
+func TestLogin_Locked(t *testing.T) {
+	service, _, _, guard := getMockUserService(t)
+
+	expect := errs.MsgError{Msg: "too many failed login attempts, try again later", Status: 429}
+	expectBody, _ := json.Marshal(expect) // nolint:errcheck
+
+	reqBody := getCredsBuffer()
+	req := httptest.NewRequest("POST", "/api/login", reqBody)
+	w := httptest.NewRecorder()
+
+	guard.EXPECT().
+		Check(gomock.Any(), creds.Username, "192.0.2.1").
+		Return(expect)
+
+	service.Login(w, req)
+
+	resp := w.Result()
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body) // nolint:errcheck
+
+	if resp.StatusCode != expect.Status {
+		t.Errorf("wrong status code:\nwant:\t%d\nhave\t%d", expect.Status, resp.StatusCode)
+	}
+	if string(body) != string(expectBody) {
+		t.Errorf("results not match:\nwant:\t%s\nhave\t%s", expectBody, body)
+	}
+}
+
+// За доверенным прокси блокировка считается по адресу клиента из X-Forwarded-For
+func TestLogin_TrustedProxy(t *testing.T) {
+	service, _, _, guard := getMockUserService(t)
+	var err error
+	if service.ClientIP, err = clientip.NewResolver("192.0.2.0/24"); err != nil {
+		t.Fatalf("unexpected err: %s", err)
+	}
+
+	expect := errs.MsgError{Msg: "too many failed login attempts, try again later", Status: 429}
+
+	req := httptest.NewRequest("POST", "/api/login", getCredsBuffer())
+	req.Header.Set("X-Forwarded-For", "198.51.100.7")
+	w := httptest.NewRecorder()
+
+	guard.EXPECT().
+		Check(gomock.Any(), creds.Username, "198.51.100.7").
+		Return(expect)
+
+	service.Login(w, req)
+
+	resp := w.Result()
+	defer resp.Body.Close()
+
+	if resp.StatusCode != expect.Status {
+		t.Errorf("wrong status code:\nwant:\t%d\nhave\t%d", expect.Status, resp.StatusCode)
+	}
+}
+
 func TestLogin_SessCreateErr(t *testing.T) {
-	service, mng, db := getMockUserService(t)
+	service, mng, db, guard := getMockUserService(t)
 
 	expect := errs.MsgError{Msg: "internal server error", Status: 500}
 	expectBody, _ := json.Marshal(expect) // nolint:errcheck
@@ -161,12 +236,18 @@ func TestLogin_SessCreateErr(t *testing.T) {
 	req := httptest.NewRequest("POST", "/api/login", reqBody)
 	w := httptest.NewRecorder()
 
+	guard.EXPECT().
+		Check(gomock.Any(), creds.Username, "192.0.2.1").
+		Return(nil)
 	db.EXPECT().
-		Authorize(creds.Username, creds.Password).
+		Authorize(gomock.Any(), creds.Username, creds.Password).
 		Return(usr, nil)
 	mng.EXPECT().
-		Create(usr).
+		Create(gomock.Any(), usr).
 		Return(session.Session{}, fmt.Errorf("mysql exec err"))
+	guard.EXPECT().
+		Reset(gomock.Any(), creds.Username).
+		Return(nil)
 
 	service.Login(w, req)
 
@@ -183,7 +264,7 @@ func TestLogin_SessCreateErr(t *testing.T) {
 }
 
 func TestRegister_OK(t *testing.T) {
-	service, mng, db := getMockUserService(t)
+	service, mng, db, _ := getMockUserService(t)
 
 	expect := session.Session{Token: "some token"}
 	expectBody, _ := json.Marshal(expect) // nolint:errcheck
@@ -193,10 +274,10 @@ func TestRegister_OK(t *testing.T) {
 	w := httptest.NewRecorder()
 
 	db.EXPECT().
-		SignUp(creds.Username, creds.Password).
+		SignUp(gomock.Any(), creds.Username, creds.Password).
 		Return(usr, nil)
 	mng.EXPECT().
-		Create(usr).
+		Create(gomock.Any(), usr).
 		Return(expect, nil)
 
 	service.Register(w, req)
@@ -214,9 +295,9 @@ func TestRegister_OK(t *testing.T) {
 }
 
 func TestRegister_DecodeErr(t *testing.T) {
-	service, _, _ := getMockUserService(t)
+	service, _, _, _ := getMockUserService(t)
 
-	expect := errs.MsgError{Msg: "internal server error", Status: 500}
+	expect := errs.MsgError{Msg: "invalid json body", Status: 400}
 	expectBody, _ := json.Marshal(expect) // nolint:errcheck
 
 	reqBody := bytes.NewBufferString(`bad json`)
@@ -237,8 +318,44 @@ func TestRegister_DecodeErr(t *testing.T) {
 	}
 }
 
+func TestRegister_ValidErr(t *testing.T) {
+	service, _, _, _ := getMockUserService(t)
+
+	expect := errs.DetailErrors{Errors: []errs.DetailError{
+		{
+			Location: "body",
+			Param:    "username",
+			Value:    "a",
+			Msg:      "must be 3 to 32 characters long",
+		},
+		{
+			Location: "body",
+			Param:    "password",
+			Msg:      "must be at least 8 characters long",
+		},
+	}, Status: 422}
+	expectBody, _ := json.Marshal(expect) // nolint:errcheck
+
+	reqBody := bytes.NewBufferString(`{"username":"a","password":"1"}`)
+	req := httptest.NewRequest("POST", "/api/register", reqBody)
+	w := httptest.NewRecorder()
+
+	service.Register(w, req)
+
+	resp := w.Result()
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body) // nolint:errcheck
+
+	if resp.StatusCode != expect.Status {
+		t.Errorf("wrong status code:\nwant:\t%d\nhave\t%d", expect.Status, resp.StatusCode)
+	}
+	if string(body) != string(expectBody) {
+		t.Errorf("results not match:\nwant:\t%s\nhave\t%s", expectBody, body)
+	}
+}
+
 func TestRegister_SignUpErr(t *testing.T) {
-	service, _, db := getMockUserService(t)
+	service, _, db, _ := getMockUserService(t)
 
 	expect := errs.MsgError{Msg: "internal server error", Status: 500}
 	expectBody, _ := json.Marshal(expect) // nolint:errcheck
@@ -248,7 +365,7 @@ func TestRegister_SignUpErr(t *testing.T) {
 	w := httptest.NewRecorder()
 
 	db.EXPECT().
-		SignUp(creds.Username, creds.Password).
+		SignUp(gomock.Any(), creds.Username, creds.Password).
 		Return(nil, fmt.Errorf("mysql scan err"))
 
 	service.Register(w, req)
@@ -266,7 +383,7 @@ func TestRegister_SignUpErr(t *testing.T) {
 }
 
 func TestRegister_SignUpMsgErr(t *testing.T) {
-	service, _, db := getMockUserService(t)
+	service, _, db, _ := getMockUserService(t)
 
 	expect := errs.DetailErrors{
 		Errors: []errs.DetailError{
@@ -286,7 +403,7 @@ func TestRegister_SignUpMsgErr(t *testing.T) {
 	w := httptest.NewRecorder()
 
 	db.EXPECT().
-		SignUp(creds.Username, creds.Password).
+		SignUp(gomock.Any(), creds.Username, creds.Password).
 		Return(nil, expect)
 
 	service.Register(w, req)
@@ -304,7 +421,7 @@ func TestRegister_SignUpMsgErr(t *testing.T) {
 }
 
 func TestRegister_SessCreateErr(t *testing.T) {
-	service, mng, db := getMockUserService(t)
+	service, mng, db, _ := getMockUserService(t)
 
 	expect := errs.MsgError{Msg: "internal server error", Status: 500}
 	expectBody, _ := json.Marshal(expect) // nolint:errcheck
@@ -314,10 +431,10 @@ func TestRegister_SessCreateErr(t *testing.T) {
 	w := httptest.NewRecorder()
 
 	db.EXPECT().
-		SignUp(creds.Username, creds.Password).
+		SignUp(gomock.Any(), creds.Username, creds.Password).
 		Return(usr, nil)
 	mng.EXPECT().
-		Create(usr).
+		Create(gomock.Any(), usr).
 		Return(session.Session{}, fmt.Errorf("mysql exec err"))
 
 	service.Register(w, req)
@@ -333,3 +450,225 @@ func TestRegister_SessCreateErr(t *testing.T) {
 		t.Errorf("results not match:\nwant:\t%s\nhave\t%s", expectBody, body)
 	}
 }
+
+func TestLogout_OK(t *testing.T) {
+	service, mng, _, _ := getMockUserService(t)
+
+	expect := errs.MsgError{Msg: "success", Status: 200}
+	expectBody, _ := json.Marshal(expect) // nolint:errcheck
+
+	req := httptest.NewRequest("POST", "/api/logout", nil)
+	req.Header.Set("Authorization", "Bearer some token")
+	w := httptest.NewRecorder()
+
+	mng.EXPECT().
+		Destroy(gomock.Any(), "Bearer some token").
+		Return(nil)
+
+	service.Logout(w, req)
+
+	resp := w.Result()
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body) // nolint:errcheck
+
+	if resp.StatusCode != expect.Status {
+		t.Errorf("wrong status code:\nwant:\t%d\nhave\t%d", expect.Status, resp.StatusCode)
+	}
+	if string(body) != string(expectBody) {
+		t.Errorf("results not match:\nwant:\t%s\nhave\t%s", expectBody, body)
+	}
+}
+
+func TestLogout_DestroyErr(t *testing.T) {
+	service, mng, _, _ := getMockUserService(t)
+
+	expect := errs.MsgError{Msg: "unauthorized", Status: 401}
+	expectBody, _ := json.Marshal(expect) // nolint:errcheck
+
+	req := httptest.NewRequest("POST", "/api/logout", nil)
+	w := httptest.NewRecorder()
+
+	mng.EXPECT().
+		Destroy(gomock.Any(), gomock.Any()).
+		Return(expect)
+
+	service.Logout(w, req)
+
+	resp := w.Result()
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body) // nolint:errcheck
+
+	if resp.StatusCode != expect.Status {
+		t.Errorf("wrong status code:\nwant:\t%d\nhave\t%d", expect.Status, resp.StatusCode)
+	}
+	if string(body) != string(expectBody) {
+		t.Errorf("results not match:\nwant:\t%s\nhave\t%s", expectBody, body)
+	}
+}
+
+func TestLogoutAll_OK(t *testing.T) {
+	service, mng, _, _ := getMockUserService(t)
+
+	expect := errs.MsgError{Msg: "success", Status: 200}
+	expectBody, _ := json.Marshal(expect) // nolint:errcheck
+
+	req := httptest.NewRequest("POST", "/api/logout/all", nil)
+	w := httptest.NewRecorder()
+
+	mng.EXPECT().
+		Check(gomock.Any(), gomock.Any()).
+		Return(*usr, nil)
+	mng.EXPECT().
+		DestroyAllForUser(gomock.Any(), usr.ID).
+		Return(nil)
+
+	service.LogoutAll(w, req)
+
+	resp := w.Result()
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body) // nolint:errcheck
+
+	if resp.StatusCode != expect.Status {
+		t.Errorf("wrong status code:\nwant:\t%d\nhave\t%d", expect.Status, resp.StatusCode)
+	}
+	if string(body) != string(expectBody) {
+		t.Errorf("results not match:\nwant:\t%s\nhave\t%s", expectBody, body)
+	}
+}
+
+func TestLogoutAll_AuthErr(t *testing.T) {
+	service, mng, _, _ := getMockUserService(t)
+
+	expect := errs.MsgError{Msg: "unauthorized", Status: 401}
+	expectBody, _ := json.Marshal(expect) // nolint:errcheck
+
+	req := httptest.NewRequest("POST", "/api/logout/all", nil)
+	w := httptest.NewRecorder()
+
+	mng.EXPECT().
+		Check(gomock.Any(), gomock.Any()).
+		Return(user.User{}, expect)
+
+	service.LogoutAll(w, req)
+
+	resp := w.Result()
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body) // nolint:errcheck
+
+	if resp.StatusCode != expect.Status {
+		t.Errorf("wrong status code:\nwant:\t%d\nhave\t%d", expect.Status, resp.StatusCode)
+	}
+	if string(body) != string(expectBody) {
+		t.Errorf("results not match:\nwant:\t%s\nhave\t%s", expectBody, body)
+	}
+}
+
+func TestLogoutAll_DestroyErr(t *testing.T) {
+	service, mng, _, _ := getMockUserService(t)
+
+	expect := errs.MsgError{Msg: "internal server error", Status: 500}
+	expectBody, _ := json.Marshal(expect) // nolint:errcheck
+
+	req := httptest.NewRequest("POST", "/api/logout/all", nil)
+	w := httptest.NewRecorder()
+
+	mng.EXPECT().
+		Check(gomock.Any(), gomock.Any()).
+		Return(*usr, nil)
+	mng.EXPECT().
+		DestroyAllForUser(gomock.Any(), usr.ID).
+		Return(fmt.Errorf("mysql exec delete err"))
+
+	service.LogoutAll(w, req)
+
+	resp := w.Result()
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body) // nolint:errcheck
+
+	if resp.StatusCode != expect.Status {
+		t.Errorf("wrong status code:\nwant:\t%d\nhave\t%d", expect.Status, resp.StatusCode)
+	}
+	if string(body) != string(expectBody) {
+		t.Errorf("results not match:\nwant:\t%s\nhave\t%s", expectBody, body)
+	}
+}
+
+func TestRefresh_OK(t *testing.T) {
+	service, mng, _, _ := getMockUserService(t)
+
+	expect := session.Session{Token: "some token", RefreshToken: "some refresh token"}
+	expectBody, _ := json.Marshal(expect) // nolint:errcheck
+
+	reqBody := bytes.NewBufferString(`{"refresh_token":"old refresh token"}`)
+	req := httptest.NewRequest("POST", "/api/token/refresh", reqBody)
+	w := httptest.NewRecorder()
+
+	mng.EXPECT().
+		Refresh(gomock.Any(), "old refresh token").
+		Return(expect, nil)
+
+	service.Refresh(w, req)
+
+	resp := w.Result()
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body) // nolint:errcheck
+
+	if resp.StatusCode != 200 {
+		t.Errorf("wrong status code:\nwant:\t%d\nhave\t%d", 200, resp.StatusCode)
+	}
+	if string(body) != string(expectBody) {
+		t.Errorf("results not match:\nwant:\t%s\nhave\t%s", expectBody, body)
+	}
+}
+
+func TestRefresh_DecodeErr(t *testing.T) {
+	service, _, _, _ := getMockUserService(t)
+
+	expect := errs.MsgError{Msg: "invalid json body", Status: 400}
+	expectBody, _ := json.Marshal(expect) // nolint:errcheck
+
+	reqBody := bytes.NewBufferString(`bad json`)
+	req := httptest.NewRequest("POST", "/api/token/refresh", reqBody)
+	w := httptest.NewRecorder()
+
+	service.Refresh(w, req)
+
+	resp := w.Result()
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body) // nolint:errcheck
+
+	if resp.StatusCode != expect.Status {
+		t.Errorf("wrong status code:\nwant:\t%d\nhave\t%d", expect.Status, resp.StatusCode)
+	}
+	if string(body) != string(expectBody) {
+		t.Errorf("results not match:\nwant:\t%s\nhave\t%s", expectBody, body)
+	}
+}
+
+func TestRefresh_ReusedErr(t *testing.T) {
+	service, mng, _, _ := getMockUserService(t)
+
+	expect := errs.MsgError{Msg: "refresh token reused", Status: 401}
+	expectBody, _ := json.Marshal(expect) // nolint:errcheck
+
+	reqBody := bytes.NewBufferString(`{"refresh_token":"old refresh token"}`)
+	req := httptest.NewRequest("POST", "/api/token/refresh", reqBody)
+	w := httptest.NewRecorder()
+
+	mng.EXPECT().
+		Refresh(gomock.Any(), "old refresh token").
+		Return(session.Session{}, expect)
+
+	service.Refresh(w, req)
+
+	resp := w.Result()
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body) // nolint:errcheck
+
+	if resp.StatusCode != expect.Status {
+		t.Errorf("wrong status code:\nwant:\t%d\nhave\t%d", expect.Status, resp.StatusCode)
+	}
+	if string(body) != string(expectBody) {
+		t.Errorf("results not match:\nwant:\t%s\nhave\t%s", expectBody, body)
+	}
+}
@@ -0,0 +1,350 @@
+package instrument
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"asperitas/internal/community"
+	"asperitas/internal/errs"
+	"asperitas/internal/metrics"
+	"asperitas/internal/post"
+	"asperitas/internal/session"
+	"asperitas/internal/tracing"
+	"asperitas/internal/user"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Обертки над репозиториями открывают span на каждую операцию, замеряют ее
+// и считают бизнес-события. db в метках различает хранилища, например "mongo" и "mysql".
+
+// Операция репозитория от вызова до результата
+type operation struct {
+	span  trace.Span
+	db    string
+	repo  string
+	name  string
+	start time.Time
+}
+
+// iface дает имя спану вида PostRepo.GetByID, repo — метку метрик
+func start(ctx context.Context, db, iface, repo, name string) (context.Context, *operation) {
+	ctx, span := tracing.Start(ctx, iface+"."+name, trace.WithAttributes(attribute.String("db", db)))
+	return ctx, &operation{span: span, db: db, repo: repo, name: name, start: time.Now()}
+}
+
+func (op *operation) end(err error) {
+	failed := isFailure(err)
+	metrics.ObserveRepo(op.db, op.repo, op.name, time.Since(op.start), failed)
+	tracing.End(op.span, err, failed)
+}
+
+// Ошибки, адресованные клиенту (не найдено, нет прав, невалидные данные), сбоем репозитория не считаются
+func isFailure(err error) bool {
+	var (
+		detailErrs errs.DetailErrors
+		msgErr     errs.MsgError
+	)
+	return err != nil && !errors.As(err, &detailErrs) && !errors.As(err, &msgErr)
+}
+
+type postRepo struct {
+	db   string
+	next post.PostRepo
+}
+
+func NewPostRepo(db string, next post.PostRepo) post.PostRepo {
+	return &postRepo{db: db, next: next}
+}
+
+func (r *postRepo) start(ctx context.Context, name string) (context.Context, *operation) {
+	return start(ctx, r.db, "PostRepo", "posts", name)
+}
+
+func (r *postRepo) GetAll(ctx context.Context, rank post.Ranking, page post.Page, viewerID string) ([]*post.Post, string, error) {
+	ctx, op := r.start(ctx, "GetAll")
+	posts, next, err := r.next.GetAll(ctx, rank, page, viewerID)
+	op.end(err)
+	return posts, next, err
+}
+
+func (r *postRepo) GetByCategory(ctx context.Context, category string, rank post.Ranking, page post.Page, viewerID string) ([]*post.Post, string, error) {
+	ctx, op := r.start(ctx, "GetByCategory")
+	posts, next, err := r.next.GetByCategory(ctx, category, rank, page, viewerID)
+	op.end(err)
+	return posts, next, err
+}
+
+func (r *postRepo) GetByUser(ctx context.Context, username string, rank post.Ranking, page post.Page, viewerID string) ([]*post.Post, string, error) {
+	ctx, op := r.start(ctx, "GetByUser")
+	posts, next, err := r.next.GetByUser(ctx, username, rank, page, viewerID)
+	op.end(err)
+	return posts, next, err
+}
+
+func (r *postRepo) AddPost(ctx context.Context, p *post.Post) error {
+	ctx, op := r.start(ctx, "AddPost")
+	err := r.next.AddPost(ctx, p)
+	op.end(err)
+	if err == nil {
+		metrics.PostCreated()
+	}
+	return err
+}
+
+func (r *postRepo) GetByID(ctx context.Context, postID, viewerID string) (*post.Post, error) {
+	ctx, op := r.start(ctx, "GetByID")
+	p, err := r.next.GetByID(ctx, postID, viewerID)
+	op.end(err)
+	return p, err
+}
+
+func (r *postRepo) DeletePost(ctx context.Context, postID string, allow post.PostPermit) error {
+	ctx, op := r.start(ctx, "DeletePost")
+	err := r.next.DeletePost(ctx, postID, allow)
+	op.end(err)
+	return err
+}
+
+func (r *postRepo) UpdatePost(ctx context.Context, postID, userID string, upd post.PostEdit) (*post.Post, error) {
+	ctx, op := r.start(ctx, "UpdatePost")
+	p, err := r.next.UpdatePost(ctx, postID, userID, upd)
+	op.end(err)
+	return p, err
+}
+
+func (r *postRepo) GetRevisions(ctx context.Context, postID string) ([]post.Revision, error) {
+	ctx, op := r.start(ctx, "GetRevisions")
+	revs, err := r.next.GetRevisions(ctx, postID)
+	op.end(err)
+	return revs, err
+}
+
+func (r *postRepo) AddComment(ctx context.Context, postID string, comment *post.Comment) (*post.Post, error) {
+	ctx, op := r.start(ctx, "AddComment")
+	p, err := r.next.AddComment(ctx, postID, comment)
+	op.end(err)
+	if err == nil {
+		metrics.CommentCreated()
+	}
+	return p, err
+}
+
+func (r *postRepo) DeleteComment(ctx context.Context, postID, commentID, userID string, allow post.CommentPermit) (*post.Post, error) {
+	ctx, op := r.start(ctx, "DeleteComment")
+	p, err := r.next.DeleteComment(ctx, postID, commentID, userID, allow)
+	op.end(err)
+	return p, err
+}
+
+func (r *postRepo) UpvotePost(ctx context.Context, postID, userID string) (*post.Post, error) {
+	ctx, op := r.start(ctx, "UpvotePost")
+	p, err := r.next.UpvotePost(ctx, postID, userID)
+	op.end(err)
+	if err == nil {
+		metrics.VoteCast("post", "upvote")
+	}
+	return p, err
+}
+
+func (r *postRepo) DownvotePost(ctx context.Context, postID, userID string) (*post.Post, error) {
+	ctx, op := r.start(ctx, "DownvotePost")
+	p, err := r.next.DownvotePost(ctx, postID, userID)
+	op.end(err)
+	if err == nil {
+		metrics.VoteCast("post", "downvote")
+	}
+	return p, err
+}
+
+func (r *postRepo) UnvotePost(ctx context.Context, postID, userID string) (*post.Post, error) {
+	ctx, op := r.start(ctx, "UnvotePost")
+	p, err := r.next.UnvotePost(ctx, postID, userID)
+	op.end(err)
+	if err == nil {
+		metrics.VoteCast("post", "unvote")
+	}
+	return p, err
+}
+
+func (r *postRepo) UpvoteComment(ctx context.Context, postID, commentID, userID string) (*post.Post, error) {
+	ctx, op := r.start(ctx, "UpvoteComment")
+	p, err := r.next.UpvoteComment(ctx, postID, commentID, userID)
+	op.end(err)
+	if err == nil {
+		metrics.VoteCast("comment", "upvote")
+	}
+	return p, err
+}
+
+func (r *postRepo) DownvoteComment(ctx context.Context, postID, commentID, userID string) (*post.Post, error) {
+	ctx, op := r.start(ctx, "DownvoteComment")
+	p, err := r.next.DownvoteComment(ctx, postID, commentID, userID)
+	op.end(err)
+	if err == nil {
+		metrics.VoteCast("comment", "downvote")
+	}
+	return p, err
+}
+
+func (r *postRepo) UnvoteComment(ctx context.Context, postID, commentID, userID string) (*post.Post, error) {
+	ctx, op := r.start(ctx, "UnvoteComment")
+	p, err := r.next.UnvoteComment(ctx, postID, commentID, userID)
+	op.end(err)
+	if err == nil {
+		metrics.VoteCast("comment", "unvote")
+	}
+	return p, err
+}
+
+type communityRepo struct {
+	db   string
+	next community.CommunityRepo
+}
+
+func NewCommunityRepo(db string, next community.CommunityRepo) community.CommunityRepo {
+	return &communityRepo{db: db, next: next}
+}
+
+func (r *communityRepo) start(ctx context.Context, name string) (context.Context, *operation) {
+	return start(ctx, r.db, "CommunityRepo", "communities", name)
+}
+
+func (r *communityRepo) GetAll(ctx context.Context) ([]*community.Community, error) {
+	ctx, op := r.start(ctx, "GetAll")
+	comms, err := r.next.GetAll(ctx)
+	op.end(err)
+	return comms, err
+}
+
+func (r *communityRepo) GetByName(ctx context.Context, name string) (*community.Community, error) {
+	ctx, op := r.start(ctx, "GetByName")
+	comm, err := r.next.GetByName(ctx, name)
+	op.end(err)
+	return comm, err
+}
+
+func (r *communityRepo) Add(ctx context.Context, comm *community.Community) error {
+	ctx, op := r.start(ctx, "Add")
+	err := r.next.Add(ctx, comm)
+	op.end(err)
+	return err
+}
+
+type userRepo struct {
+	db   string
+	next user.UserRepo
+}
+
+func NewUserRepo(db string, next user.UserRepo) user.UserRepo {
+	return &userRepo{db: db, next: next}
+}
+
+func (r *userRepo) start(ctx context.Context, name string) (context.Context, *operation) {
+	return start(ctx, r.db, "UserRepo", "users", name)
+}
+
+// Отказ из-за неверных логина или пароля приходит клиентской ошибкой
+func (r *userRepo) Authorize(ctx context.Context, username, passw string) (*user.User, error) {
+	ctx, op := r.start(ctx, "Authorize")
+	usr, err := r.next.Authorize(ctx, username, passw)
+	op.end(err)
+	if err != nil && !isFailure(err) {
+		metrics.LoginFailed()
+	}
+	return usr, err
+}
+
+func (r *userRepo) SignUp(ctx context.Context, username, passw string) (*user.User, error) {
+	ctx, op := r.start(ctx, "SignUp")
+	usr, err := r.next.SignUp(ctx, username, passw)
+	op.end(err)
+	if err == nil {
+		metrics.UserRegistered()
+	}
+	return usr, err
+}
+
+type sessionManager struct {
+	db   string
+	next session.SessionManager
+}
+
+func NewSessionManager(db string, next session.SessionManager) session.SessionManager {
+	return &sessionManager{db: db, next: next}
+}
+
+func (sm *sessionManager) start(ctx context.Context, name string) (context.Context, *operation) {
+	return start(ctx, sm.db, "SessionManager", "sessions", name)
+}
+
+func (sm *sessionManager) Create(ctx context.Context, usr *user.User) (session.Session, error) {
+	ctx, op := sm.start(ctx, "Create")
+	sess, err := sm.next.Create(ctx, usr)
+	op.end(err)
+	return sess, err
+}
+
+func (sm *sessionManager) Check(ctx context.Context, authHeader string) (user.User, error) {
+	ctx, op := sm.start(ctx, "Check")
+	usr, err := sm.next.Check(ctx, authHeader)
+	op.end(err)
+	return usr, err
+}
+
+func (sm *sessionManager) Refresh(ctx context.Context, refreshToken string) (session.Session, error) {
+	ctx, op := sm.start(ctx, "Refresh")
+	sess, err := sm.next.Refresh(ctx, refreshToken)
+	op.end(err)
+	return sess, err
+}
+
+func (sm *sessionManager) Destroy(ctx context.Context, authHeader string) error {
+	ctx, op := sm.start(ctx, "Destroy")
+	err := sm.next.Destroy(ctx, authHeader)
+	op.end(err)
+	return err
+}
+
+func (sm *sessionManager) DestroyAllForUser(ctx context.Context, userID string) error {
+	ctx, op := sm.start(ctx, "DestroyAllForUser")
+	err := sm.next.DestroyAllForUser(ctx, userID)
+	op.end(err)
+	return err
+}
+
+type loginGuard struct {
+	db   string
+	next user.LoginGuard
+}
+
+func NewLoginGuard(db string, next user.LoginGuard) user.LoginGuard {
+	return &loginGuard{db: db, next: next}
+}
+
+func (g *loginGuard) start(ctx context.Context, name string) (context.Context, *operation) {
+	return start(ctx, g.db, "LoginGuard", "login_failures", name)
+}
+
+func (g *loginGuard) Check(ctx context.Context, username, ip string) error {
+	ctx, op := g.start(ctx, "Check")
+	err := g.next.Check(ctx, username, ip)
+	op.end(err)
+	return err
+}
+
+func (g *loginGuard) Fail(ctx context.Context, username, ip string) error {
+	ctx, op := g.start(ctx, "Fail")
+	err := g.next.Fail(ctx, username, ip)
+	op.end(err)
+	return err
+}
+
+func (g *loginGuard) Reset(ctx context.Context, username string) error {
+	ctx, op := g.start(ctx, "Reset")
+	err := g.next.Reset(ctx, username)
+	op.end(err)
+	return err
+}
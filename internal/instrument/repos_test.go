@@ -0,0 +1,163 @@
+package instrument
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"asperitas/internal/errs"
+	"asperitas/internal/metrics"
+	"asperitas/internal/post"
+	"asperitas/internal/session"
+	"asperitas/internal/tracing"
+	"asperitas/internal/user"
+
+	"github.com/golang/mock/gomock"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var (
+	ctx      = context.Background()
+	recorder = tracetest.NewSpanRecorder()
+)
+
+func init() {
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+}
+
+// Значение счетчика из реестра по имени и меткам, 0 если его еще нет
+func counter(t *testing.T, name string, labels map[string]string) float64 {
+	families, err := metrics.Registry.Gather()
+	if err != nil {
+		t.Fatalf("gather err: %s", err)
+	}
+	for _, family := range families {
+		if family.GetName() != name {
+			continue
+		}
+	metrics:
+		for _, m := range family.GetMetric() {
+			for _, pair := range m.GetLabel() {
+				if labels[pair.GetName()] != pair.GetValue() {
+					continue metrics
+				}
+			}
+			return m.GetCounter().GetValue()
+		}
+	}
+	return 0
+}
+
+// Последний завершенный span с заданным именем
+func endedSpan(t *testing.T, name string) sdktrace.ReadOnlySpan {
+	spans := recorder.Ended()
+	for i := len(spans) - 1; i >= 0; i-- {
+		if spans[i].Name() == name {
+			return spans[i]
+		}
+	}
+	t.Fatalf("no ended span %s", name)
+	return nil
+}
+
+func TestPostRepo_Counters(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	db := post.NewMockPostRepo(ctrl)
+	repo := NewPostRepo("mongo", db)
+
+	upvotes := map[string]string{"target": "comment", "action": "upvote"}
+	addErrs := map[string]string{"db": "mongo", "repo": "posts", "op": "AddPost"}
+	voteErrs := map[string]string{"db": "mongo", "repo": "posts", "op": "UpvoteComment"}
+	created := counter(t, "asperitas_posts_created_total", nil)
+	voted := counter(t, "asperitas_votes_cast_total", upvotes)
+	failed := counter(t, "asperitas_repo_operation_errors_total", addErrs)
+
+	db.EXPECT().AddPost(gomock.Any(), gomock.Any()).Return(nil)
+	db.EXPECT().AddPost(gomock.Any(), gomock.Any()).Return(fmt.Errorf("mongo insert err"))
+	db.EXPECT().UpvoteComment(gomock.Any(), "post", "comm", "user").Return(&post.Post{}, nil)
+	db.EXPECT().UpvoteComment(gomock.Any(), "post", "comm", "user").
+		Return(nil, errs.MsgError{Msg: "comment not found", Status: 404})
+
+	repo.AddPost(ctx, &post.Post{})                 // nolint:errcheck
+	repo.AddPost(ctx, &post.Post{})                 // nolint:errcheck
+	repo.UpvoteComment(ctx, "post", "comm", "user") // nolint:errcheck
+	repo.UpvoteComment(ctx, "post", "comm", "user") // nolint:errcheck
+
+	if diff := counter(t, "asperitas_posts_created_total", nil) - created; diff != 1 {
+		t.Errorf("wrong posts created:\nwant:\t%d\nhave\t%v", 1, diff)
+	}
+	if diff := counter(t, "asperitas_votes_cast_total", upvotes) - voted; diff != 1 {
+		t.Errorf("wrong votes cast:\nwant:\t%d\nhave\t%v", 1, diff)
+	}
+	if diff := counter(t, "asperitas_repo_operation_errors_total", addErrs) - failed; diff != 1 {
+		t.Errorf("wrong repo errors:\nwant:\t%d\nhave\t%v", 1, diff)
+	}
+	// клиентская ошибка не считается сбоем репозитория
+	if n := counter(t, "asperitas_repo_operation_errors_total", voteErrs); n != 0 {
+		t.Errorf("wrong repo errors:\nwant:\t%d\nhave\t%v", 0, n)
+	}
+}
+
+func TestUserRepo_Counters(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	db := user.NewMockUserRepo(ctrl)
+	repo := NewUserRepo("mysql", db)
+
+	registered := counter(t, "asperitas_registrations_total", nil)
+	failedLogins := counter(t, "asperitas_logins_failed_total", nil)
+
+	db.EXPECT().SignUp(gomock.Any(), "admin", "passw").Return(&user.User{}, nil)
+	db.EXPECT().Authorize(gomock.Any(), "admin", "wrong").
+		Return(nil, errs.MsgError{Msg: "invalid credentials", Status: 401})
+	db.EXPECT().Authorize(gomock.Any(), "admin", "passw").
+		Return(nil, fmt.Errorf("mysql scan err"))
+
+	repo.SignUp(ctx, "admin", "passw")    // nolint:errcheck
+	repo.Authorize(ctx, "admin", "wrong") // nolint:errcheck
+	repo.Authorize(ctx, "admin", "passw") // nolint:errcheck
+
+	if diff := counter(t, "asperitas_registrations_total", nil) - registered; diff != 1 {
+		t.Errorf("wrong registrations:\nwant:\t%d\nhave\t%v", 1, diff)
+	}
+	if diff := counter(t, "asperitas_logins_failed_total", nil) - failedLogins; diff != 1 {
+		t.Errorf("wrong failed logins:\nwant:\t%d\nhave\t%v", 1, diff)
+	}
+	if status := endedSpan(t, "UserRepo.Authorize").Status(); status.Code != codes.Error {
+		t.Errorf("wrong span status:\nwant:\t%v\nhave\t%v", codes.Error, status.Code)
+	}
+}
+
+// Span операции должен быть дочерним к span'у запроса, а контекст с ним уходить в репозиторий
+func TestSessionManager_Span(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	db := session.NewMockSessionManager(ctrl)
+	sm := NewSessionManager("mysql", db)
+
+	reqCtx, reqSpan := tracing.Start(ctx, "GET /api/post/{postID}")
+	db.EXPECT().
+		Check(gomock.Any(), "Bearer token").
+		DoAndReturn(func(ctx context.Context, _ string) (user.User, error) {
+			if trace.SpanFromContext(ctx).SpanContext().SpanID() == reqSpan.SpanContext().SpanID() {
+				t.Errorf("repo called with request span instead of operation span")
+			}
+			return user.User{}, errs.MsgError{Msg: "unauthorized", Status: 401}
+		})
+
+	sm.Check(reqCtx, "Bearer token") // nolint:errcheck
+	reqSpan.End()
+
+	span := endedSpan(t, "SessionManager.Check")
+	if span.Parent().SpanID() != reqSpan.SpanContext().SpanID() {
+		t.Errorf("wrong parent span:\nwant:\t%s\nhave\t%s", reqSpan.SpanContext().SpanID(), span.Parent().SpanID())
+	}
+	if status := span.Status(); status.Code == codes.Error {
+		t.Errorf("client error marked span as failed")
+	}
+}
@@ -0,0 +1,43 @@
+package logging
+
+import (
+	"context"
+	"sync"
+
+	"go.uber.org/zap"
+)
+
+type ctxKey struct{}
+
+// Логгер запроса общий для всех middleware и обработчиков: поля, добавленные
+// внутри (маршрут, пользователь), видны и в access log снаружи
+type scope struct {
+	mu     sync.Mutex
+	logger *zap.SugaredLogger
+}
+
+func NewContext(ctx context.Context, logger *zap.SugaredLogger) context.Context {
+	return context.WithValue(ctx, ctxKey{}, &scope{logger: logger})
+}
+
+// Логгер запроса или fallback, если middleware не подключен (например, в тестах)
+func FromContext(ctx context.Context, fallback *zap.SugaredLogger) *zap.SugaredLogger {
+	s, ok := ctx.Value(ctxKey{}).(*scope)
+	if !ok {
+		return fallback
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.logger
+}
+
+// Добавляет поля логгеру запроса, без логгера в контексте ничего не делает
+func With(ctx context.Context, keysAndValues ...interface{}) {
+	s, ok := ctx.Value(ctxKey{}).(*scope)
+	if !ok {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.logger = s.logger.With(keysAndValues...)
+}
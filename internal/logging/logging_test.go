@@ -0,0 +1,31 @@
+package logging
+
+import (
+	"context"
+	"testing"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestWith_SharedScope(t *testing.T) {
+	core, logs := observer.New(zap.InfoLevel)
+	ctx := NewContext(context.Background(), zap.New(core).Sugar().With("request_id", "req"))
+
+	// поле добавлено глубже по стеку, а логирует внешний код с тем же контекстом
+	With(ctx, "user_id", "user")
+	FromContext(ctx, nil).Info("done")
+
+	fields := logs.All()[0].ContextMap()
+	if fields["request_id"] != "req" || fields["user_id"] != "user" {
+		t.Errorf("results not match:\nwant:\t%#v\nhave\t%#v", map[string]interface{}{"request_id": "req", "user_id": "user"}, fields)
+	}
+}
+
+func TestFromContext_Fallback(t *testing.T) {
+	fallback := zap.NewNop().Sugar()
+	With(context.Background(), "user_id", "user")
+	if logger := FromContext(context.Background(), fallback); logger != fallback {
+		t.Errorf("results not match:\nwant:\t%#v\nhave\t%#v", fallback, logger)
+	}
+}
@@ -0,0 +1,139 @@
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "asperitas"
+
+// Собственный реестр вместо глобального, чтобы в /metrics попадало только то, что мы регистрируем
+var Registry = prometheus.NewRegistry()
+
+var (
+	httpRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_requests_total",
+		Help:      "Number of handled http requests by mux route template, method and status code.",
+	}, []string{"route", "method", "status"})
+
+	httpDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "Latency of handled http requests by mux route template, method and status code.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"route", "method", "status"})
+
+	httpPanics = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_panics_total",
+		Help:      "Number of panics recovered in http handlers.",
+	})
+
+	repoDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "repo_operation_duration_seconds",
+		Help:      "Latency of repository operations by database, repository and operation.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"db", "repo", "op"})
+
+	repoErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "repo_operation_errors_total",
+		Help:      "Number of repository operations failed with internal error or timeout.",
+	}, []string{"db", "repo", "op"})
+
+	postsCreated = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "posts_created_total",
+		Help:      "Number of created posts.",
+	})
+
+	votesCast = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "votes_cast_total",
+		Help:      "Number of votes by target (post or comment) and action (upvote, downvote, unvote).",
+	}, []string{"target", "action"})
+
+	commentsCreated = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "comments_created_total",
+		Help:      "Number of created comments and replies.",
+	})
+
+	registrations = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "registrations_total",
+		Help:      "Number of registered users.",
+	})
+
+	loginsFailed = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "logins_failed_total",
+		Help:      "Number of logins rejected because of wrong credentials.",
+	})
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		httpRequests,
+		httpDuration,
+		httpPanics,
+		repoDuration,
+		repoErrors,
+		postsCreated,
+		votesCast,
+		commentsCreated,
+		registrations,
+		loginsFailed,
+	)
+}
+
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{})
+}
+
+func ObserveHTTP(route, method string, status int, elapsed time.Duration) {
+	code := strconv.Itoa(status)
+	httpRequests.WithLabelValues(route, method, code).Inc()
+	httpDuration.WithLabelValues(route, method, code).Observe(elapsed.Seconds())
+}
+
+func PanicRecovered() {
+	httpPanics.Inc()
+}
+
+func ObserveRepo(db, repo, op string, elapsed time.Duration, failed bool) {
+	repoDuration.WithLabelValues(db, repo, op).Observe(elapsed.Seconds())
+	if failed {
+		repoErrors.WithLabelValues(db, repo, op).Inc()
+	}
+}
+
+func PostCreated() {
+	postsCreated.Inc()
+}
+
+// target — post или comment, action — upvote, downvote или unvote
+func VoteCast(target, action string) {
+	votesCast.WithLabelValues(target, action).Inc()
+}
+
+func CommentCreated() {
+	commentsCreated.Inc()
+}
+
+func UserRegistered() {
+	registrations.Inc()
+}
+
+func LoginFailed() {
+	loginsFailed.Inc()
+}
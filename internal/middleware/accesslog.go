@@ -1,23 +1,38 @@
 package middleware
 
 import (
-	"fmt"
 	"net/http"
 	"time"
 
+	"asperitas/internal/logging"
+
+	"go.opentelemetry.io/otel/trace"
 	"go.uber.org/zap"
 )
 
-func AccessLog(logger *zap.SugaredLogger, next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		fmt.Println("access log middleware")
-		start := time.Now()
-		next.ServeHTTP(w, r)
-		logger.Infow("New request",
-			"method", r.Method,
-			"remote_addr", r.RemoteAddr,
-			"url", r.URL.Path,
-			"time", time.Since(start),
-		)
-	})
+// Подключается через Use после RequestID: добавляет в логгер запроса маршрут и trace_id,
+// чтобы их видели и обработчики, а после ответа пишет код и размер ответа
+func AccessLog(logger *zap.SugaredLogger) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			ctx := r.Context()
+			logging.With(ctx, "route", routeTemplate(r))
+			if sc := trace.SpanContextFromContext(ctx); sc.IsValid() {
+				logging.With(ctx, "trace_id", sc.TraceID().String())
+			}
+
+			sw := newStatusWriter(w)
+			next.ServeHTTP(sw, r)
+
+			logging.FromContext(ctx, logger).Infow("access log",
+				"method", r.Method,
+				"remote_addr", r.RemoteAddr,
+				"url", r.URL.Path,
+				"status", sw.status,
+				"size", sw.size,
+				"time", time.Since(start),
+			)
+		})
+	}
 }
@@ -0,0 +1,30 @@
+package middleware
+
+import (
+	"net/http"
+	"time"
+
+	"asperitas/internal/metrics"
+
+	"github.com/gorilla/mux"
+)
+
+// Подключается через Use внутри роутера: шаблон маршрута известен только после сопоставления,
+// а в метке нужен именно шаблон, а не путь с идентификаторами
+func Metrics(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		sw := newStatusWriter(w)
+		next.ServeHTTP(sw, r)
+		metrics.ObserveHTTP(routeTemplate(r), r.Method, sw.status, time.Since(start))
+	})
+}
+
+func routeTemplate(r *http.Request) string {
+	if current := mux.CurrentRoute(r); current != nil {
+		if tpl, err := current.GetPathTemplate(); err == nil {
+			return tpl
+		}
+	}
+	return "unmatched"
+}
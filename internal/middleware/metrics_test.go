@@ -0,0 +1,51 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"asperitas/internal/metrics"
+
+	"github.com/gorilla/mux"
+)
+
+// Значение счетчика из реестра по имени и меткам, 0 если его еще нет
+func counter(t *testing.T, name string, labels map[string]string) float64 {
+	families, err := metrics.Registry.Gather()
+	if err != nil {
+		t.Fatalf("gather err: %s", err)
+	}
+	for _, family := range families {
+		if family.GetName() != name {
+			continue
+		}
+	metrics:
+		for _, m := range family.GetMetric() {
+			for _, pair := range m.GetLabel() {
+				if labels[pair.GetName()] != pair.GetValue() {
+					continue metrics
+				}
+			}
+			return m.GetCounter().GetValue()
+		}
+	}
+	return 0
+}
+
+func TestMetrics_RouteTemplate(t *testing.T) {
+	r := mux.NewRouter()
+	r.Use(Metrics)
+	r.HandleFunc("/api/post/{postID}", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	}).Methods("GET")
+
+	labels := map[string]string{"method": "GET", "route": "/api/post/{postID}", "status": "404"}
+	before := counter(t, "asperitas_http_requests_total", labels)
+
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/api/post/some_id", nil))
+
+	if diff := counter(t, "asperitas_http_requests_total", labels) - before; diff != 1 {
+		t.Errorf("wrong requests count:\nwant:\t%d\nhave\t%v", 1, diff)
+	}
+}
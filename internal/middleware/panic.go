@@ -1,19 +1,46 @@
 package middleware
 
 import (
-	"fmt"
 	"net/http"
+	"runtime/debug"
+
+	"asperitas/internal/logging"
+	"asperitas/internal/metrics"
+
+	"go.uber.org/zap"
 )
 
-func Panic(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		fmt.Println("\n\npanic middleware", r.URL.Path)
-		defer func() {
-			if err := recover(); err != nil {
-				fmt.Println("recovered with err:", err)
-				http.Error(w, "Internal server error", 500)
-			}
-		}()
-		next.ServeHTTP(w, r)
-	})
+// Подключается последним в цепочке роутера: паника перехватывается до выхода из Tracing,
+// Metrics и AccessLog, и они видят ответ 500 так же, как у обычной ошибки. Второй экземпляр
+// оборачивает весь сервер, чтобы не уронить соединение из-за паники в самих middleware.
+func Panic(logger *zap.SugaredLogger) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			sw := newStatusWriter(w)
+			defer func() {
+				err := recover()
+				if err == nil {
+					return
+				}
+				// http.ErrAbortHandler — штатный способ оборвать ответ, его обрабатывает сам net/http
+				if err == http.ErrAbortHandler { // nolint:errorlint
+					panic(err)
+				}
+				metrics.PanicRecovered()
+				logging.FromContext(r.Context(), logger).Errorw("recovered from panic",
+					"error", err,
+					"stack", string(debug.Stack()),
+				)
+				// Код уже отправлен, и дописать в ответ ошибку нельзя: обрываем соединение,
+				// чтобы клиент не принял обрезанный ответ за полный
+				if sw.wroteHeader {
+					panic(http.ErrAbortHandler)
+				}
+				sw.Header().Set("Content-Type", "application/json; charset=utf-8")
+				sw.WriteHeader(http.StatusInternalServerError)
+				sw.Write([]byte(`{"message":"internal server error"}`)) // nolint:errcheck
+			}()
+			next.ServeHTTP(sw, r)
+		})
+	}
 }
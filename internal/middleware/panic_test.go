@@ -0,0 +1,84 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"asperitas/internal/logging"
+
+	"github.com/gorilla/mux"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestPanic_JSON(t *testing.T) {
+	core, logs := observer.New(zap.InfoLevel)
+	handler := Panic(zap.NewNop().Sugar())(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic("nil map")
+	}))
+	before := counter(t, "asperitas_http_panics_total", nil)
+
+	req := httptest.NewRequest("GET", "/api/posts/", nil)
+	req = req.WithContext(logging.NewContext(req.Context(), zap.New(core).Sugar().With("request_id", "req")))
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+
+	expected := `{"message":"internal server error"}`
+	if w.Code != http.StatusInternalServerError || w.Body.String() != expected {
+		t.Errorf("results not match:\nwant:\t%d %s\nhave\t%d %s", 500, expected, w.Code, w.Body.String())
+	}
+	if diff := counter(t, "asperitas_http_panics_total", nil) - before; diff != 1 {
+		t.Errorf("wrong panics count:\nwant:\t%d\nhave\t%v", 1, diff)
+	}
+	entries := logs.FilterMessage("recovered from panic").All()
+	if len(entries) != 1 {
+		t.Fatalf("wrong panic log entries:\nwant:\t%d\nhave\t%d", 1, len(entries))
+	}
+	fields := entries[0].ContextMap()
+	if fields["request_id"] != "req" || fields["stack"] == "" {
+		t.Errorf("no request id or stack in panic log: %#v", fields)
+	}
+}
+
+// Если обработчик уже начал отвечать, второй раз заголовки не пишутся, а ответ обрывается
+func TestPanic_HeaderWritten(t *testing.T) {
+	handler := Panic(zap.NewNop().Sugar())(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`[{"id":`)) // nolint:errcheck
+		panic("nil map")
+	}))
+
+	w := httptest.NewRecorder()
+	defer func() {
+		if err := recover(); err != http.ErrAbortHandler { // nolint:errorlint
+			t.Errorf("results not match:\nwant:\t%#v\nhave\t%#v", http.ErrAbortHandler, err)
+		}
+		if w.Code != http.StatusOK || w.Body.String() != `[{"id":` {
+			t.Errorf("response rewritten after panic: %d %s", w.Code, w.Body.String())
+		}
+	}()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "/api/posts/", nil))
+}
+
+// Упавший обработчик учитывается в метриках запросов с кодом 500
+func TestPanic_Metrics(t *testing.T) {
+	r := mux.NewRouter()
+	r.Use(Metrics, Panic(zap.NewNop().Sugar()))
+	r.HandleFunc("/api/panic", func(w http.ResponseWriter, r *http.Request) {
+		panic("nil map")
+	}).Methods("GET")
+
+	labels := map[string]string{"method": "GET", "route": "/api/panic", "status": "500"}
+	before := counter(t, "asperitas_http_requests_total", labels)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/api/panic", nil))
+
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("wrong status code:\nwant:\t%d\nhave\t%d", 500, w.Code)
+	}
+	if diff := counter(t, "asperitas_http_requests_total", labels) - before; diff != 1 {
+		t.Errorf("wrong requests count:\nwant:\t%d\nhave\t%v", 1, diff)
+	}
+}
@@ -0,0 +1,43 @@
+package middleware
+
+import (
+	"net/http"
+
+	"asperitas/internal/logging"
+	"asperitas/pkg/rand"
+
+	"go.uber.org/zap"
+)
+
+const (
+	RequestIDHeader = "X-Request-ID"
+
+	maxRequestIDLen = 128
+)
+
+// Берет идентификатор запроса из X-Request-ID (если прокси его уже проставил) или генерирует новый,
+// возвращает его в ответе и кладет в контекст логгер с этим идентификатором
+func RequestID(logger *zap.SugaredLogger, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		reqID := r.Header.Get(RequestIDHeader)
+		if !validRequestID(reqID) {
+			reqID = rand.GetRandID()
+		}
+		w.Header().Set(RequestIDHeader, reqID)
+		ctx := logging.NewContext(r.Context(), logger.With("request_id", reqID))
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// Чужой идентификатор попадает в логи и заголовки, поэтому допускаются только печатные ascii символы
+func validRequestID(reqID string) bool {
+	if reqID == "" || len(reqID) > maxRequestIDLen {
+		return false
+	}
+	for i := 0; i < len(reqID); i++ {
+		if reqID[i] <= ' ' || reqID[i] > '~' {
+			return false
+		}
+	}
+	return true
+}
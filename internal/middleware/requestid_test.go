@@ -0,0 +1,79 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"asperitas/internal/logging"
+
+	"github.com/gorilla/mux"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestRequestID_Incoming(t *testing.T) {
+	handler := RequestID(zap.NewNop().Sugar(), http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	req := httptest.NewRequest("GET", "/api/posts/", nil)
+	req.Header.Set(RequestIDHeader, "proxy-id-1")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+
+	if reqID := w.Header().Get(RequestIDHeader); reqID != "proxy-id-1" {
+		t.Errorf("results not match:\nwant:\t%#v\nhave\t%#v", "proxy-id-1", reqID)
+	}
+}
+
+// Пустой или небезопасный для логов идентификатор заменяется сгенерированным
+func TestRequestID_Generated(t *testing.T) {
+	handler := RequestID(zap.NewNop().Sugar(), http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	for _, incoming := range []string{"", "bad\nid", strings.Repeat("a", maxRequestIDLen+1)} {
+		req := httptest.NewRequest("GET", "/api/posts/", nil)
+		req.Header.Set(RequestIDHeader, incoming)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+
+		if reqID := w.Header().Get(RequestIDHeader); reqID == incoming || len(reqID) != 24 {
+			t.Errorf("wrong generated request id for %q: %q", incoming, reqID)
+		}
+	}
+}
+
+// В access log попадают идентификатор запроса, маршрут и пользователь, добавленный обработчиком
+func TestAccessLog_Fields(t *testing.T) {
+	core, logs := observer.New(zap.InfoLevel)
+	logger := zap.New(core).Sugar()
+
+	r := mux.NewRouter()
+	r.Use(AccessLog(logger))
+	r.HandleFunc("/api/post/{postID}", func(w http.ResponseWriter, r *http.Request) {
+		logging.With(r.Context(), "user_id", "user")
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"id":"post"}`)) // nolint:errcheck
+	}).Methods("POST")
+
+	req := httptest.NewRequest("POST", "/api/post/some_id", nil)
+	req.Header.Set(RequestIDHeader, "req")
+	RequestID(logger, r).ServeHTTP(httptest.NewRecorder(), req)
+
+	entries := logs.FilterMessage("access log").All()
+	if len(entries) != 1 {
+		t.Fatalf("wrong access log entries:\nwant:\t%d\nhave\t%d", 1, len(entries))
+	}
+	fields := entries[0].ContextMap()
+	expected := map[string]interface{}{
+		"request_id": "req",
+		"route":      "/api/post/{postID}",
+		"user_id":    "user",
+		"status":     int64(201),
+		"size":       int64(13),
+	}
+	for key, value := range expected {
+		if fields[key] != value {
+			t.Errorf("results not match for %s:\nwant:\t%#v\nhave\t%#v", key, value, fields[key])
+		}
+	}
+}
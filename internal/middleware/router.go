@@ -0,0 +1,31 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+// Подключает цепочку к роутеру. mux.Router.Use срабатывает только на найденных маршрутах,
+// поэтому ответы 404 и 405 оборачиваются той же цепочкой отдельно: иначе они не попадут
+// в метрики, трейсы и access log
+func Use(r *mux.Router, mws ...mux.MiddlewareFunc) {
+	r.Use(mws...)
+	notFound, notAllowed := r.NotFoundHandler, r.MethodNotAllowedHandler
+	if notFound == nil {
+		notFound = http.NotFoundHandler()
+	}
+	if notAllowed == nil {
+		notAllowed = http.HandlerFunc(methodNotAllowed)
+	}
+	for i := len(mws) - 1; i >= 0; i-- {
+		notFound = mws[i](notFound)
+		notAllowed = mws[i](notAllowed)
+	}
+	r.NotFoundHandler, r.MethodNotAllowedHandler = notFound, notAllowed
+}
+
+// Повторяет ответ mux по умолчанию
+func methodNotAllowed(w http.ResponseWriter, _ *http.Request) {
+	w.WriteHeader(http.StatusMethodNotAllowed)
+}
@@ -0,0 +1,38 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/gorilla/mux"
+)
+
+func TestUse_Unmatched(t *testing.T) {
+	r := mux.NewRouter()
+	Use(r, Metrics)
+	r.HandleFunc("/api/posts/", func(w http.ResponseWriter, r *http.Request) {}).Methods("GET")
+
+	cases := []struct {
+		method, path string
+		status       int
+	}{
+		{"GET", "/api/unknown", http.StatusNotFound},
+		{"POST", "/api/posts/", http.StatusMethodNotAllowed},
+	}
+	for _, c := range cases {
+		labels := map[string]string{"method": c.method, "route": "unmatched", "status": strconv.Itoa(c.status)}
+		before := counter(t, "asperitas_http_requests_total", labels)
+		w := httptest.NewRecorder()
+
+		r.ServeHTTP(w, httptest.NewRequest(c.method, c.path, nil))
+
+		if w.Code != c.status {
+			t.Errorf("wrong status code:\nwant:\t%d\nhave\t%d", c.status, w.Code)
+		}
+		if diff := counter(t, "asperitas_http_requests_total", labels) - before; diff != 1 {
+			t.Errorf("%s %s: wrong requests count:\nwant:\t%d\nhave\t%v", c.method, c.path, 1, diff)
+		}
+	}
+}
@@ -0,0 +1,56 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"asperitas/internal/config"
+	"asperitas/internal/tracing"
+
+	"github.com/gorilla/mux"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestTracing_Propagation(t *testing.T) {
+	if _, err := tracing.Setup(context.Background(), config.Default().Tracing); err != nil {
+		t.Fatalf("setup err: %s", err)
+	}
+	recorder := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+
+	var handlerSpan trace.SpanContext
+	r := mux.NewRouter()
+	r.Use(Tracing)
+	r.HandleFunc("/api/post/{postID}", func(w http.ResponseWriter, r *http.Request) {
+		handlerSpan = trace.SpanContextFromContext(r.Context())
+		w.WriteHeader(http.StatusInternalServerError)
+	}).Methods("GET")
+
+	req := httptest.NewRequest("GET", "/api/post/some_id", nil)
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	r.ServeHTTP(httptest.NewRecorder(), req)
+
+	spans := recorder.Ended()
+	if len(spans) != 1 {
+		t.Fatalf("wrong spans count:\nwant:\t%d\nhave\t%d", 1, len(spans))
+	}
+	span := spans[0]
+	if span.Name() != "GET /api/post/{postID}" || span.SpanKind() != trace.SpanKindServer {
+		t.Errorf("wrong span: %s %s", span.Name(), span.SpanKind())
+	}
+	if span.SpanContext().TraceID().String() != "4bf92f3577b34da6a3ce929d0e0e4736" ||
+		span.Parent().SpanID().String() != "00f067aa0ba902b7" {
+		t.Errorf("trace not continued from traceparent: %s parent %s", span.SpanContext().TraceID(), span.Parent().SpanID())
+	}
+	if handlerSpan.SpanID() != span.SpanContext().SpanID() {
+		t.Errorf("handler got no request span in context")
+	}
+	if span.Status().Description != "Internal Server Error" {
+		t.Errorf("wrong span status: %+v", span.Status())
+	}
+}
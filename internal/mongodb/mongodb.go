@@ -0,0 +1,50 @@
+package mongodb
+
+import (
+	"context"
+	"fmt"
+
+	"asperitas/internal/config"
+	"asperitas/internal/tracing"
+	"asperitas/pkg/timeout"
+
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
+)
+
+// Одно подключение к монге на процесс: у драйвера свой пул соединений,
+// и репозитории получают из подключения общую базу
+type Client struct {
+	client *mongo.Client
+	db     *mongo.Database
+}
+
+func Connect(cfg config.Mongo) (*Client, error) {
+	ctx, cancel := timeout.Context(context.Background(), cfg.Timeout)
+	defer cancel()
+	client, err := mongo.Connect(ctx, options.Client().ApplyURI(cfg.Addr).SetMonitor(tracing.NewMongoMonitor()))
+	if err != nil {
+		return nil, fmt.Errorf("mongo connect err: %w", err)
+	}
+	return &Client{client: client, db: client.Database(cfg.Database)}, nil
+}
+
+func (c *Client) Database() *mongo.Database {
+	return c.db
+}
+
+func (c *Client) Ping(ctx context.Context) error {
+	if err := c.client.Ping(ctx, readpref.Primary()); err != nil {
+		return fmt.Errorf("mongo ping err: %w", err)
+	}
+	return nil
+}
+
+// Дожидается завершения текущих операций не дольше дедлайна ctx
+func (c *Client) Close(ctx context.Context) error {
+	if err := c.client.Disconnect(ctx); err != nil {
+		return fmt.Errorf("mongo disconnect err: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,27 @@
+package policy
+
+import (
+	"asperitas/internal/post"
+	"asperitas/internal/user"
+)
+
+// Админ модерирует все сообщества, модератор — только то, что указано в его роли
+func Moderates(usr user.User, community string) bool {
+	return usr.HasRole(user.RoleAdmin, "") || usr.HasRole(user.RoleModerator, community)
+}
+
+// Пост удаляет его автор или модератор сообщества, в котором он опубликован
+func CanDeletePost(usr user.User, p *post.Post) bool {
+	if !usr.HasRole(user.RoleUser, "") {
+		return false
+	}
+	return p.Author.ID == usr.ID || Moderates(usr, string(p.Category))
+}
+
+// Комментарий удаляет его автор или модератор сообщества поста
+func CanDeleteComment(usr user.User, p *post.Post, c *post.Comment) bool {
+	if !usr.HasRole(user.RoleUser, "") {
+		return false
+	}
+	return c.Author.ID == usr.ID || Moderates(usr, string(p.Category))
+}
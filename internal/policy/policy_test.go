@@ -0,0 +1,81 @@
+package policy
+
+import (
+	"testing"
+
+	"asperitas/internal/post"
+	"asperitas/internal/user"
+)
+
+var (
+	author    = user.User{Username: "admin1", ID: "id_admin1"}
+	stranger  = user.User{Username: "admin2", ID: "id_admin2"}
+	moderator = user.User{
+		Username: "moderator",
+		ID:       "id_moderator",
+		Roles:    []user.Role{{Name: user.RoleModerator, Scope: "music"}},
+	}
+	admin = user.User{
+		Username: "admin",
+		ID:       "id_admin",
+		Roles:    []user.Role{{Name: user.RoleAdmin}},
+	}
+)
+
+func getPost(category post.PostCategory) (*post.Post, *post.Comment) {
+	p := post.NewPost(author)
+	p.Category = category
+	return p, post.NewComment(author, "text")
+}
+
+func TestCanDeletePost(t *testing.T) {
+	cases := []struct {
+		name     string
+		usr      user.User
+		category post.PostCategory
+		expect   bool
+	}{
+		{"author", author, "music", true},
+		{"stranger", stranger, "music", false},
+		{"moderator in scope", moderator, "music", true},
+		{"moderator out of scope", moderator, "golang", false},
+		{"admin", admin, "golang", true},
+		{"anonymous", user.User{}, "music", false},
+	}
+	for _, c := range cases {
+		p, _ := getPost(c.category)
+		if have := CanDeletePost(c.usr, p); have != c.expect {
+			t.Errorf("%s: results not match:\nwant:\t%t\nhave\t%t", c.name, c.expect, have)
+		}
+	}
+}
+
+func TestCanDeleteComment(t *testing.T) {
+	cases := []struct {
+		name     string
+		usr      user.User
+		category post.PostCategory
+		expect   bool
+	}{
+		{"author", author, "music", true},
+		{"stranger", stranger, "music", false},
+		{"moderator in scope", moderator, "music", true},
+		{"moderator out of scope", moderator, "golang", false},
+		{"admin", admin, "golang", true},
+	}
+	for _, c := range cases {
+		p, comm := getPost(c.category)
+		if have := CanDeleteComment(c.usr, p, comm); have != c.expect {
+			t.Errorf("%s: results not match:\nwant:\t%t\nhave\t%t", c.name, c.expect, have)
+		}
+	}
+}
+
+// Роль модератора с пустым сообществом не дает прав админа
+func TestModerates_EmptyScope(t *testing.T) {
+	usr := user.User{ID: "id", Roles: []user.Role{{Name: user.RoleModerator}}}
+
+	if Moderates(usr, "music") {
+		t.Errorf("moderator without scope moderates every community")
+	}
+}
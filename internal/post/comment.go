@@ -1,6 +1,7 @@
 package post
 
 import (
+	"encoding/json"
 	"fmt"
 	"time"
 
@@ -9,53 +10,174 @@ import (
 	"asperitas/pkg/rand"
 )
 
+const deletedCommentBody = "[deleted]"
+
 type Comment struct {
 	Created       time.Time `json:"-"`
 	CreatedFormat string    `json:"created"`
 	Author        user.User `json:"author"`
 	Body          string    `json:"body"`
+	Votes         VoteList  `json:"votes" bson:"-"`
+	Score         int64     `json:"score"`
+	LikesPercent  int       `json:"upvotePercentage"`
+	LikesCount    int       `json:"-"`
+	VotesCount    int       `json:"-"`
+	ParentID      string    `json:"parentID,omitempty"`
+	Deleted       bool      `json:"deleted,omitempty"`
 	ID            string    `json:"id"`
 }
 
+// Комментарии хранятся плоским списком в порядке создания,
+// дерево ответов собирается только при отдаче в json
 type CommentList []*Comment
 
+type commentNode struct {
+	*Comment
+	Replies []*commentNode `json:"replies"`
+}
+
 func NewComment(usr user.User, body string) *Comment {
 	t := time.Now()
 	return &Comment{
 		Created:       t,
 		CreatedFormat: t.Format(time.RFC3339Nano),
-		Author:        usr,
+		Author:        usr.WithoutRoles(),
 		Body:          body,
+		Votes:         NewVoteList(usr.ID),
+		Score:         1,
+		LikesPercent:  100,
+		LikesCount:    1,
+		VotesCount:    1,
 		ID:            rand.GetRandID(),
 	}
 }
 
+func NewReply(usr user.User, parentID, body string) *Comment {
+	comm := NewComment(usr, body)
+	comm.ParentID = parentID
+	return comm
+}
+
+func (list CommentList) MarshalJSON() ([]byte, error) {
+	if list == nil {
+		return []byte("null"), nil
+	}
+	return json.Marshal(list.tree())
+}
+
+// Собирает дерево ответов; ответы каждого уровня идут в порядке списка
+func (list CommentList) tree() []*commentNode {
+	nodes := make(map[string]*commentNode, len(list))
+	for _, comm := range list {
+		nodes[comm.ID] = &commentNode{Comment: comm, Replies: []*commentNode{}}
+	}
+	roots := make([]*commentNode, 0, len(list))
+	for _, comm := range list {
+		if parent, ok := nodes[comm.ParentID]; ok {
+			parent.Replies = append(parent.Replies, nodes[comm.ID])
+		} else {
+			roots = append(roots, nodes[comm.ID])
+		}
+	}
+	return roots
+}
+
+func (list CommentList) index(id string) int {
+	for i, comm := range list {
+		if comm.ID == id {
+			return i
+		}
+	}
+	return -1
+}
+
+// Ищет комментарий, за который еще можно голосовать
+func (list CommentList) find(id string) (*Comment, error) {
+	if i := list.index(id); i >= 0 && !list[i].Deleted {
+		return list[i], nil
+	}
+	return nil, errs.MsgError{Msg: "comment not found", Status: 404}
+}
+
+func (list CommentList) hasReplies(id string) bool {
+	for _, comm := range list {
+		if comm.ParentID == id {
+			return true
+		}
+	}
+	return false
+}
+
+// Удаляет элемент, сохраняя порядок остальных
+func (list *CommentList) remove(i int) {
+	*list = append((*list)[:i], (*list)[i+1:]...)
+}
+
 func (list *CommentList) Add(comm *Comment) error {
 	if list == nil || *list == nil {
 		return fmt.Errorf("nil comment list")
 	}
+	if comm.ParentID != "" {
+		if i := list.index(comm.ParentID); i < 0 || (*list)[i].Deleted {
+			return errs.MsgError{Msg: "parent comment not found", Status: 404}
+		}
+	}
 	*list = append(*list, comm)
 	return nil
 }
 
-func (list *CommentList) Delete(id, reqID string) error {
+// Комментарий с ответами не удаляется, а заменяется заглушкой, чтобы не потерять ветку.
+// Заглушки, у которых не осталось ответов, удаляются вслед за последним ответом.
+func (list *CommentList) Delete(id string, allow func(c *Comment) bool) error {
 	if list == nil || *list == nil {
 		return fmt.Errorf("nil comment list")
 	}
-	i := -1
-	for idx, comm := range *list {
-		if comm.ID == id {
-			i = idx
+	i := list.index(id)
+	if i < 0 || (*list)[i].Deleted {
+		return errs.MsgError{Msg: "comment not found", Status: 404}
+	}
+	comm := (*list)[i]
+	if !allow(comm) {
+		return errs.MsgError{Msg: "unauthorized", Status: 401}
+	}
+	if list.hasReplies(id) {
+		comm.Body = deletedCommentBody
+		comm.Author = user.User{}
+		comm.Deleted = true
+		return nil
+	}
+	list.remove(i)
+	for parentID := comm.ParentID; parentID != ""; {
+		j := list.index(parentID)
+		if j < 0 || !(*list)[j].Deleted || list.hasReplies(parentID) {
 			break
 		}
+		parentID = (*list)[j].ParentID
+		list.remove(j)
 	}
-	if i < 0 {
-		return errs.MsgError{Msg: "comment not found", Status: 404}
+	return nil
+}
+
+// Применяет голос к комментарию и пересчитывает его рейтинг
+func (c *Comment) vote(userID string, vote func(l *VoteList, userID string) error) error {
+	// у комментариев, созданных до появления голосования, списка голосов нет
+	if c.Votes.List == nil {
+		c.Votes.List = make([]Vote, 0)
 	}
-	if (*list)[i].Author.ID != reqID {
-		return errs.MsgError{Msg: "unauthorized", Status: 401}
+	if err := vote(&c.Votes, userID); err != nil {
+		return err
 	}
-	(*list)[i] = (*list)[len(*list)-1]
-	*list = (*list)[:len(*list)-1]
+	c.setScore(c.Votes.LikesCount, len(c.Votes.List))
 	return nil
 }
+
+func (c *Comment) setScore(likes, total int) {
+	c.LikesCount = likes
+	c.VotesCount = total
+	c.Score = int64(2*likes - total)
+	if total == 0 {
+		c.LikesPercent = 0
+	} else {
+		c.LikesPercent = likes * 100 / total
+	}
+}
@@ -0,0 +1,104 @@
+package post
+
+import (
+	"math"
+	"sort"
+	"strings"
+
+	"asperitas/internal/errs"
+)
+
+// z-оценка для нижней границы доверительного интервала Уилсона (80%)
+const wilsonZ = 1.281551565545
+
+// Порядок комментариев внутри каждого уровня дерева.
+// Пустой порядок оставляет комментарии в хронологическом порядке.
+type CommentOrder string
+
+const (
+	CommentsBest          CommentOrder = "best"
+	CommentsTop           CommentOrder = "top"
+	CommentsNew           CommentOrder = "new"
+	CommentsControversial CommentOrder = "controversial"
+)
+
+var commentOrders = map[CommentOrder]func(a, b *Comment) bool{
+	CommentsBest: func(a, b *Comment) bool {
+		return wilsonRank(a.Votes.ups(), a.Votes.downs()) > wilsonRank(b.Votes.ups(), b.Votes.downs())
+	},
+	CommentsTop: func(a, b *Comment) bool {
+		return a.Score > b.Score
+	},
+	CommentsNew: func(a, b *Comment) bool {
+		return a.Created.After(b.Created)
+	},
+	CommentsControversial: func(a, b *Comment) bool {
+		return controversyRank(a.Votes.ups(), a.Votes.downs()) > controversyRank(b.Votes.ups(), b.Votes.downs())
+	},
+}
+
+func ParseCommentOrder(name string) (CommentOrder, error) {
+	order := CommentOrder(name)
+	if _, ok := commentOrders[order]; ok || name == "" {
+		return order, nil
+	}
+	names := make([]string, 0, len(commentOrders))
+	for name := range commentOrders {
+		names = append(names, string(name))
+	}
+	sort.Strings(names)
+	return "", errs.DetailErrors{Errors: []errs.DetailError{
+		{
+			Location: "query",
+			Param:    "sort",
+			Value:    name,
+			Msg:      "must be one of " + strings.Join(names, ", "),
+		},
+	}, Status: 400}
+}
+
+// Возвращает копию списка в заданном порядке; при равенстве раньше идут старые комментарии
+func (list CommentList) Sorted(order CommentOrder) CommentList {
+	if list == nil {
+		return nil
+	}
+	sorted := make(CommentList, len(list))
+	copy(sorted, list)
+	less, ok := commentOrders[order]
+	if !ok {
+		return sorted
+	}
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return less(sorted[i], sorted[j])
+	})
+	return sorted
+}
+
+// Копия поста для отдачи с комментариями в заданном порядке
+func (p *Post) WithCommentOrder(order CommentOrder) *Post {
+	if order == "" {
+		return p
+	}
+	sorted := *p
+	sorted.Comments = p.Comments.Sorted(order)
+	return &sorted
+}
+
+func (l *VoteList) ups() int {
+	return l.LikesCount
+}
+
+func (l *VoteList) downs() int {
+	return len(l.List) - l.LikesCount
+}
+
+// Нижняя граница доверительного интервала доли положительных голосов
+func wilsonRank(ups, downs int) float64 {
+	n := float64(ups + downs)
+	if n == 0 {
+		return 0
+	}
+	p := float64(ups) / n
+	z2 := wilsonZ * wilsonZ
+	return (p + z2/(2*n) - wilsonZ*math.Sqrt((p*(1-p)+z2/(4*n))/n)) / (1 + z2/n)
+}
@@ -0,0 +1,192 @@
+package post
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+
+	"asperitas/internal/errs"
+)
+
+// Право на удаление только у автора, как у обычного пользователя
+func authoredBy(userID string) func(c *Comment) bool {
+	return func(c *Comment) bool { return c.Author.ID == userID }
+}
+
+func TestCommentList_Tree(t *testing.T) {
+	list := make(CommentList, 0)
+	root1 := NewComment(usr1, "root1")
+	root2 := NewComment(usr2, "root2")
+	reply1 := NewReply(usr2, root1.ID, "reply1")
+	reply2 := NewReply(usr1, root1.ID, "reply2")
+	nested := NewReply(usr1, reply1.ID, "nested")
+	for i, comm := range []*Comment{root1, root2, reply1, reply2, nested} {
+		comm.Created = comm.Created.Add(time.Duration(i) * time.Second)
+		if err := list.Add(comm); err != nil {
+			t.Fatalf("unexpected err: %s", err)
+		}
+	}
+
+	data, err := json.Marshal(list)
+	if err != nil {
+		t.Fatalf("unexpected err: %s", err)
+	}
+	var result []struct {
+		Body    string
+		Replies []struct {
+			Body    string
+			Replies []struct{ Body string }
+		}
+	}
+	if err = json.Unmarshal(data, &result); err != nil {
+		t.Fatalf("unexpected err: %s", err)
+	}
+
+	if len(result) != 2 || result[0].Body != "root1" || result[1].Body != "root2" {
+		t.Fatalf("wrong roots: %s", data)
+	}
+	replies := result[0].Replies
+	if len(replies) != 2 || replies[0].Body != "reply1" || replies[1].Body != "reply2" {
+		t.Fatalf("wrong replies: %s", data)
+	}
+	if len(replies[0].Replies) != 1 || replies[0].Replies[0].Body != "nested" {
+		t.Errorf("wrong nested replies: %s", data)
+	}
+}
+
+func TestCommentList_AddErrNoParent(t *testing.T) {
+	list := make(CommentList, 0)
+
+	err := list.Add(NewReply(usr1, "no such id", "reply"))
+
+	if err == nil || len(list) != 0 {
+		t.Errorf("expected parent not found err, got: %v", err)
+	}
+}
+
+func TestCommentList_DeleteKeepsOrder(t *testing.T) {
+	comms := []*Comment{NewComment(usr1, "1"), NewComment(usr1, "2"), NewComment(usr1, "3")}
+	list := make(CommentList, 0)
+	for _, comm := range comms {
+		list.Add(comm) // nolint:errcheck
+	}
+
+	if err := list.Delete(comms[0].ID, authoredBy(usr1.ID)); err != nil {
+		t.Fatalf("unexpected err: %s", err)
+	}
+
+	if expect := (CommentList{comms[1], comms[2]}); !reflect.DeepEqual(expect, list) {
+		t.Errorf("results not match:\nwant:\t%v\nhave\t%v", expect, list)
+	}
+}
+
+func TestCommentList_DeleteTombstone(t *testing.T) {
+	list := make(CommentList, 0)
+	root := NewComment(usr1, "root")
+	reply := NewReply(usr2, root.ID, "reply")
+	list.Add(root)  // nolint:errcheck
+	list.Add(reply) // nolint:errcheck
+
+	if err := list.Delete(root.ID, authoredBy(usr1.ID)); err != nil {
+		t.Fatalf("unexpected err: %s", err)
+	}
+	if len(list) != 2 || !root.Deleted || root.Body != deletedCommentBody || root.Author.ID != "" {
+		t.Fatalf("expected tombstone, have: %+v", root)
+	}
+	if err := list.Delete(root.ID, authoredBy(usr1.ID)); err == nil {
+		t.Errorf("expected not found err on deleted comment")
+	}
+
+	if err := list.Delete(reply.ID, authoredBy(usr2.ID)); err != nil {
+		t.Fatalf("unexpected err: %s", err)
+	}
+	if len(list) != 0 {
+		t.Errorf("expected tombstone to be removed with last reply, have: %v", list)
+	}
+}
+
+func TestCommentList_DeleteDenied(t *testing.T) {
+	list := make(CommentList, 0)
+	comm := NewComment(usr1, "text")
+	list.Add(comm) // nolint:errcheck
+
+	err := list.Delete(comm.ID, authoredBy(usr2.ID))
+
+	if expect := (errs.MsgError{Msg: "unauthorized", Status: 401}); err != expect {
+		t.Errorf("results not match:\nwant:\t%#v\nhave\t%#v", expect, err)
+	}
+	if len(list) != 1 || comm.Deleted {
+		t.Errorf("comment deleted without permission: %+v", comm)
+	}
+}
+
+func TestComment_Vote(t *testing.T) {
+	comm := NewComment(usr1, "text")
+
+	if err := comm.vote(usr2.ID, (*VoteList).Downvote); err != nil {
+		t.Fatalf("unexpected err: %s", err)
+	}
+	if comm.Score != 0 || comm.LikesPercent != 50 {
+		t.Errorf("wrong score after downvote: %d, %d%%", comm.Score, comm.LikesPercent)
+	}
+	if err := comm.vote(usr1.ID, (*VoteList).Unvote); err != nil {
+		t.Fatalf("unexpected err: %s", err)
+	}
+	if comm.Score != -1 || comm.LikesPercent != 0 {
+		t.Errorf("wrong score after unvote: %d, %d%%", comm.Score, comm.LikesPercent)
+	}
+}
+
+func TestComment_VoteLegacy(t *testing.T) {
+	comm := &Comment{ID: randID}
+
+	if err := comm.vote(usr1.ID, (*VoteList).Upvote); err != nil {
+		t.Fatalf("unexpected err: %s", err)
+	}
+	if comm.Score != 1 || comm.LikesPercent != 100 {
+		t.Errorf("wrong score: %d, %d%%", comm.Score, comm.LikesPercent)
+	}
+}
+
+func TestCommentList_Sorted(t *testing.T) {
+	now := time.Now()
+	// 1 голос за; 10 за и 1 против; 3 за и 3 против
+	few, popular, disputed := NewComment(usr1, "few"), NewComment(usr1, "popular"), NewComment(usr1, "disputed")
+	for i, comm := range []*Comment{few, popular, disputed} {
+		comm.Created = now.Add(time.Duration(i) * time.Second)
+	}
+	popular.Votes = VoteList{List: make([]Vote, 11), LikesCount: 10}
+	popular.Score = 9
+	disputed.Votes = VoteList{List: make([]Vote, 6), LikesCount: 3}
+	disputed.Score = 0
+	list := CommentList{few, popular, disputed}
+
+	cases := map[CommentOrder]CommentList{
+		"":                    {few, popular, disputed},
+		CommentsBest:          {popular, few, disputed},
+		CommentsTop:           {popular, few, disputed},
+		CommentsNew:           {disputed, popular, few},
+		CommentsControversial: {disputed, popular, few},
+	}
+	for order, expect := range cases {
+		if result := list.Sorted(order); !reflect.DeepEqual(expect, result) {
+			t.Errorf("order %q:\nwant:\t%v\nhave\t%v", order, expect, result)
+		}
+	}
+	if list[0] != few {
+		t.Errorf("source list must not be reordered")
+	}
+}
+
+func TestParseCommentOrder(t *testing.T) {
+	if order, err := ParseCommentOrder("best"); err != nil || order != CommentsBest {
+		t.Errorf("unexpected result: %q, %v", order, err)
+	}
+	if order, err := ParseCommentOrder(""); err != nil || order != "" {
+		t.Errorf("unexpected result: %q, %v", order, err)
+	}
+	if _, err := ParseCommentOrder("hot"); err == nil {
+		t.Errorf("expected err on unknown order")
+	}
+}
@@ -0,0 +1,190 @@
+package post
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+)
+
+func getConcurrentRepos() map[string]func(p *Post) PostRepo {
+	return map[string]func(p *Post) PostRepo{
+		"mongo": func(p *Post) PostRepo {
+			votes, comments := newFakeChildren(p)
+			return &PostRepositoryMongo{
+				coll:      newFakeCollection(p),
+				votes:     votes,
+				comments:  comments,
+				revisions: newFakeCollection(),
+			}
+		},
+		"memory": func(p *Post) PostRepo {
+			repo := NewMemoryRepo()
+			repo.AddPost(emptyCtx, p) // nolint:errcheck
+			return repo
+		},
+	}
+}
+
+// Запускает по одному действию на каждого голосующего параллельно
+func runParallel(t *testing.T, voters int, action func(i int) error) {
+	wg := &sync.WaitGroup{}
+	errCh := make(chan error, voters)
+	for i := 0; i < voters; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if err := action(i); err != nil {
+				errCh <- err
+			}
+		}(i)
+	}
+	wg.Wait()
+	close(errCh)
+	for err := range errCh {
+		t.Errorf("unexpected err: %s", err)
+	}
+}
+
+func getStored(t *testing.T, repo PostRepo, postID string) *Post {
+	switch repo := repo.(type) {
+	case *PostRepositoryMongo:
+		p, err := repo.loadPost(emptyCtx, postID, "")
+		if err != nil {
+			t.Fatalf("unexpected err: %s", err)
+		}
+		p.Votes.List = storedVotes(repo.votes, postID, "")
+		for _, comm := range p.Comments {
+			comm.Votes.List = storedVotes(repo.votes, postID, comm.ID)
+		}
+		return p
+	case *PostMemoryRepository:
+		return repo.data[0]
+	}
+	return nil
+}
+
+func TestVotePost_ConcurrentNoLostUpdates(t *testing.T) {
+	const voters = 20
+	for name, newRepo := range getConcurrentRepos() {
+		t.Run(name, func(t *testing.T) {
+			p := NewPost(usr1)
+			repo := newRepo(p)
+
+			runParallel(t, voters, func(i int) error {
+				userID := fmt.Sprintf("voter_%d", i)
+				var err error
+				if i%2 == 0 {
+					_, err = repo.UpvotePost(emptyCtx, p.ID, userID)
+				} else {
+					_, err = repo.DownvotePost(emptyCtx, p.ID, userID)
+				}
+				return err
+			})
+
+			result := getStored(t, repo, p.ID)
+			if len(result.Votes.List) != voters+1 || result.Votes.LikesCount != voters/2+1 {
+				t.Errorf("lost votes: %d likes of %d", result.Votes.LikesCount, len(result.Votes.List))
+			}
+			if expect := int64(2*result.Votes.LikesCount - len(result.Votes.List)); result.Score != expect {
+				t.Errorf("wrong score:\nwant:\t%d\nhave\t%d", expect, result.Score)
+			}
+		})
+	}
+}
+
+func TestVotePost_ConcurrentIdempotent(t *testing.T) {
+	const voters = 20
+	for name, newRepo := range getConcurrentRepos() {
+		t.Run(name, func(t *testing.T) {
+			p := NewPost(usr1)
+			repo := newRepo(p)
+
+			runParallel(t, voters, func(i int) error {
+				_, err := repo.UpvotePost(emptyCtx, p.ID, usr2.ID)
+				return err
+			})
+
+			result := getStored(t, repo, p.ID)
+			if len(result.Votes.List) != 2 || result.Votes.LikesCount != 2 || result.Score != 2 {
+				t.Errorf("repeated upvotes counted: %d likes of %d, score %d",
+					result.Votes.LikesCount, len(result.Votes.List), result.Score)
+			}
+		})
+	}
+}
+
+func TestUnvotePost_ConcurrentNoLostUpdates(t *testing.T) {
+	const voters = 20
+	for name, newRepo := range getConcurrentRepos() {
+		t.Run(name, func(t *testing.T) {
+			p := NewPost(usr1)
+			for i := 0; i < voters; i++ {
+				p.Votes.Upvote(fmt.Sprintf("voter_%d", i)) // nolint:errcheck
+			}
+			p.updatePostScore()
+			repo := newRepo(p)
+
+			runParallel(t, voters, func(i int) error {
+				_, err := repo.UnvotePost(emptyCtx, p.ID, fmt.Sprintf("voter_%d", i))
+				return err
+			})
+
+			result := getStored(t, repo, p.ID)
+			if len(result.Votes.List) != 1 || result.Votes.LikesCount != 1 || result.Score != 1 {
+				t.Errorf("lost unvotes: %d likes of %d, score %d",
+					result.Votes.LikesCount, len(result.Votes.List), result.Score)
+			}
+		})
+	}
+}
+
+func TestVoteComment_ConcurrentNoLostUpdates(t *testing.T) {
+	const voters = 20
+	for name, newRepo := range getConcurrentRepos() {
+		t.Run(name, func(t *testing.T) {
+			p := NewPost(usr1)
+			comm := NewComment(usr1, "some text")
+			p.Comments.Add(comm) // nolint:errcheck
+			repo := newRepo(p)
+
+			runParallel(t, voters, func(i int) error {
+				_, err := repo.UpvoteComment(emptyCtx, p.ID, comm.ID, fmt.Sprintf("voter_%d", i))
+				return err
+			})
+
+			result := getStored(t, repo, p.ID).Comments[0]
+			if len(result.Votes.List) != voters+1 || result.Score != voters+1 {
+				t.Errorf("lost comment votes: %d votes, score %d", len(result.Votes.List), result.Score)
+			}
+		})
+	}
+}
+
+// Каждая из параллельных правок должна оставить в истории ровно ту версию, которую заменила
+func TestUpdatePost_ConcurrentKeepsHistory(t *testing.T) {
+	const editors = 20
+	for name, newRepo := range getConcurrentRepos() {
+		t.Run(name, func(t *testing.T) {
+			p := NewPost(usr1)
+			p.Title = "title"
+			repo := newRepo(p)
+
+			runParallel(t, editors, func(i int) error {
+				_, err := repo.UpdatePost(emptyCtx, p.ID, usr1.ID, PostEdit{Title: fmt.Sprintf("title_%d", i)})
+				return err
+			})
+
+			revs, err := repo.GetRevisions(emptyCtx, p.ID)
+			if err != nil {
+				t.Fatalf("unexpected err: %s", err)
+			}
+			titles := map[string]bool{getStored(t, repo, p.ID).Title: true}
+			for _, rev := range revs {
+				titles[rev.Title] = true
+			}
+			if len(revs) != editors || len(titles) != editors+1 {
+				t.Errorf("lost revisions: %d revisions, %d distinct titles", len(revs), len(titles))
+			}
+		})
+	}
+}
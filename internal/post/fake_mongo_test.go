@@ -0,0 +1,361 @@
+package post
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"runtime"
+	"strings"
+	"sync"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Коллекция в памяти с подмножеством семантики монги, которого хватает репозиторию:
+// фильтры на равенство, в том числе по вложенным полям, $in, $exists и $or, операторы $set, $inc, $push и $unset, upsert.
+// Сортировка в Find не поддерживается, документы отдаются в порядке вставки.
+type fakeCollection struct {
+	mu   sync.Mutex
+	docs []bson.M
+}
+
+type fakeSingleResult struct {
+	data []byte
+	err  error
+}
+
+type fakeCursor struct {
+	docs bson.A
+	pos  int
+}
+
+func newFakeCollection(docs ...interface{}) *fakeCollection {
+	coll := &fakeCollection{}
+	for _, doc := range docs {
+		coll.InsertOne(emptyCtx, doc) // nolint:errcheck
+	}
+	return coll
+}
+
+// Раскладывает встроенные в посты голоса и комментарии по отдельным коллекциям
+func newFakeChildren(posts ...*Post) (votes, comments *fakeCollection) {
+	votes, comments = newFakeCollection(), newFakeCollection()
+	for _, p := range posts {
+		for _, vote := range p.Votes.List {
+			votes.InsertOne(emptyCtx, voteDoc{PostID: p.ID, UserID: vote.UserID, Value: vote.Value}) // nolint:errcheck
+		}
+		for _, comm := range p.Comments {
+			comments.InsertOne(emptyCtx, commentDoc{PostID: p.ID, Comment: *comm}) // nolint:errcheck
+			for _, vote := range comm.Votes.List {
+				votes.InsertOne(emptyCtx, voteDoc{ // nolint:errcheck
+					PostID:    p.ID,
+					CommentID: comm.ID,
+					UserID:    vote.UserID,
+					Value:     vote.Value,
+				})
+			}
+		}
+	}
+	return votes, comments
+}
+
+// Все голоса за пост или его комментарий, лежащие в коллекции голосов
+func storedVotes(coll MongoCollection, postID, commID string) []Vote {
+	votes := []Vote{}
+	for _, doc := range fakeDocs(coll) {
+		vote := voteDoc{}
+		data, _ := bson.Marshal(doc) // nolint:errcheck
+		bson.Unmarshal(data, &vote)  // nolint:errcheck
+		if vote.PostID == postID && vote.CommentID == commID {
+			votes = append(votes, Vote{UserID: vote.UserID, Value: vote.Value})
+		}
+	}
+	return votes
+}
+
+func toDoc(v interface{}) bson.M {
+	data, _ := bson.Marshal(v) // nolint:errcheck
+	doc := bson.M{}
+	bson.Unmarshal(data, &doc) // nolint:errcheck
+	return doc
+}
+
+// Приводит значение к виду, в котором оно лежит в документе после декодирования
+func toValue(v interface{}) interface{} {
+	value := toDoc(bson.M{"v": v})["v"]
+	if n, ok := value.(int32); ok {
+		return int64(n)
+	}
+	return value
+}
+
+func (res *fakeSingleResult) Decode(v interface{}) error {
+	return bson.Unmarshal(res.data, v)
+}
+
+func (res *fakeSingleResult) Err() error {
+	return res.err
+}
+
+func (cur *fakeCursor) All(_ context.Context, v interface{}) error {
+	t, data, err := bson.MarshalValue(cur.docs)
+	if err != nil {
+		return err
+	}
+	return bson.RawValue{Type: t, Value: data}.Unmarshal(v)
+}
+
+func (cur *fakeCursor) Next(_ context.Context) bool {
+	cur.pos++
+	return cur.pos <= len(cur.docs)
+}
+
+func (cur *fakeCursor) Decode(v interface{}) error {
+	data, err := bson.Marshal(cur.docs[cur.pos-1])
+	if err != nil {
+		return err
+	}
+	return bson.Unmarshal(data, v)
+}
+
+func (cur *fakeCursor) Err() error {
+	return nil
+}
+
+func (cur *fakeCursor) Close(_ context.Context) error {
+	return nil
+}
+
+// Достает поле по пути через точку, как author.id
+func lookup(doc bson.M, key string) (interface{}, bool) {
+	path := strings.Split(key, ".")
+	for _, field := range path[:len(path)-1] {
+		nested, ok := doc[field].(bson.M)
+		if !ok {
+			return nil, false
+		}
+		doc = nested
+	}
+	value, ok := doc[path[len(path)-1]]
+	return value, ok
+}
+
+func matches(doc bson.M, filter interface{}) bool {
+	for key, cond := range toDoc(filter) {
+		if key == "$or" {
+			found := false
+			for _, alt := range cond.(bson.A) {
+				found = found || matches(doc, alt)
+			}
+			if !found {
+				return false
+			}
+			continue
+		}
+		stored, exist := lookup(doc, key)
+		ops, isOps := cond.(bson.M)
+		if !isOps {
+			if !exist || !reflect.DeepEqual(toValue(stored), toValue(cond)) {
+				return false
+			}
+			continue
+		}
+		if want, ok := ops["$exists"]; ok && want != exist {
+			return false
+		}
+		if list, ok := ops["$in"].(bson.A); ok {
+			found := false
+			for _, item := range list {
+				found = found || exist && reflect.DeepEqual(toValue(stored), toValue(item))
+			}
+			if !found {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func apply(doc bson.M, update interface{}) error {
+	for op, fields := range toDoc(update) {
+		for key, value := range fields.(bson.M) {
+			switch op {
+			case "$set":
+				doc[key] = value
+			case "$inc":
+				doc[key] = toValue(doc[key]).(int64) + toValue(value).(int64)
+			case "$push":
+				arr, _ := doc[key].(bson.A) // nolint:errcheck
+				doc[key] = append(arr, value)
+			case "$unset":
+				delete(doc, key)
+			default:
+				return fmt.Errorf("unsupported update op %s", op)
+			}
+		}
+	}
+	return nil
+}
+
+func (c *fakeCollection) find(filter interface{}) int {
+	for i, doc := range c.docs {
+		if matches(doc, filter) {
+			return i
+		}
+	}
+	return -1
+}
+
+func singleResult(doc bson.M) MongoSingleResult {
+	if doc == nil {
+		return &fakeSingleResult{err: mongo.ErrNoDocuments}
+	}
+	data, err := bson.Marshal(doc)
+	return &fakeSingleResult{data: data, err: err}
+}
+
+func (c *fakeCollection) Find(_ context.Context, filter interface{}, _ ...*options.FindOptions) (MongoCursor, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	cur := &fakeCursor{docs: bson.A{}}
+	for _, doc := range c.docs {
+		if matches(doc, filter) {
+			cur.docs = append(cur.docs, toDoc(doc))
+		}
+	}
+	return cur, nil
+}
+
+func (c *fakeCollection) FindOne(_ context.Context, filter interface{}) MongoSingleResult {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if i := c.find(filter); i >= 0 {
+		return singleResult(c.docs[i])
+	}
+	return singleResult(nil)
+}
+
+func (c *fakeCollection) InsertOne(_ context.Context, document interface{}) (interface{}, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.docs = append(c.docs, toDoc(document))
+	return nil, nil
+}
+
+// Добавляет документ из полей фильтра, проверяемых на равенство
+func (c *fakeCollection) upsert(filter interface{}) int {
+	doc := bson.M{}
+	for key, value := range toDoc(filter) {
+		if _, isOps := value.(bson.M); !isOps {
+			doc[key] = value
+		}
+	}
+	c.docs = append(c.docs, doc)
+	return len(c.docs) - 1
+}
+
+func (c *fakeCollection) UpdateOne(_ context.Context, filter, update interface{}, opts ...*options.UpdateOptions) (*mongo.UpdateResult, error) {
+	// дает другим горутинам вклиниться между чтением и записью
+	runtime.Gosched()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	opt := options.MergeUpdateOptions(opts...)
+	result := &mongo.UpdateResult{MatchedCount: 1, ModifiedCount: 1}
+	i := c.find(filter)
+	switch {
+	case i >= 0:
+	case opt.Upsert != nil && *opt.Upsert:
+		i = c.upsert(filter)
+		result = &mongo.UpdateResult{UpsertedCount: 1}
+	default:
+		return &mongo.UpdateResult{}, nil
+	}
+	if err := apply(c.docs[i], update); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (c *fakeCollection) DeleteOne(_ context.Context, filter interface{}) (interface{}, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if i := c.find(filter); i >= 0 {
+		c.docs = append(c.docs[:i], c.docs[i+1:]...)
+	}
+	return nil, nil
+}
+
+func (c *fakeCollection) DeleteMany(_ context.Context, filter interface{}) (*mongo.DeleteResult, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	kept := c.docs[:0]
+	for _, doc := range c.docs {
+		if !matches(doc, filter) {
+			kept = append(kept, doc)
+		}
+	}
+	deleted := len(c.docs) - len(kept)
+	c.docs = kept
+	return &mongo.DeleteResult{DeletedCount: int64(deleted)}, nil
+}
+
+func (c *fakeCollection) FindOneAndUpdate(_ context.Context, filter, update interface{}, opts ...*options.FindOneAndUpdateOptions) MongoSingleResult {
+	runtime.Gosched()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	opt := options.MergeFindOneAndUpdateOptions(opts...)
+	var before bson.M
+	i := c.find(filter)
+	switch {
+	case i >= 0:
+		before = toDoc(c.docs[i])
+	case opt.Upsert != nil && *opt.Upsert:
+		i = c.upsert(filter)
+	default:
+		return singleResult(nil)
+	}
+	if err := apply(c.docs[i], update); err != nil {
+		return &fakeSingleResult{err: err}
+	}
+	if opt.ReturnDocument != nil && *opt.ReturnDocument == options.After {
+		return singleResult(c.docs[i])
+	}
+	return singleResult(before)
+}
+
+func (c *fakeCollection) FindOneAndDelete(_ context.Context, filter interface{}) MongoSingleResult {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	i := c.find(filter)
+	if i < 0 {
+		return singleResult(nil)
+	}
+	doc := c.docs[i]
+	c.docs = append(c.docs[:i], c.docs[i+1:]...)
+	return singleResult(doc)
+}
+
+// Коллекция, у которой однократно отказывает запись: для проверки недописанных операций
+type failingCollection struct {
+	*fakeCollection
+	insertErr error
+	deleteErr error
+}
+
+func (c *failingCollection) InsertOne(ctx context.Context, document interface{}) (interface{}, error) {
+	if err := c.insertErr; err != nil {
+		c.insertErr = nil
+		return nil, err
+	}
+	return c.fakeCollection.InsertOne(ctx, document)
+}
+
+func (c *failingCollection) DeleteMany(ctx context.Context, filter interface{}) (*mongo.DeleteResult, error) {
+	if err := c.deleteErr; err != nil {
+		c.deleteErr = nil
+		return nil, err
+	}
+	return c.fakeCollection.DeleteMany(ctx, filter)
+}
@@ -0,0 +1,240 @@
+package post
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"asperitas/pkg/timeout"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Пост в старом формате, где голоса и комментарии хранились внутри документа
+type legacyPost struct {
+	ID       string          `bson:"id"`
+	Created  time.Time       `bson:"created"`
+	Votes    VoteList        `bson:"votes"`
+	Comments []legacyComment `bson:"comments"`
+}
+
+type legacyComment struct {
+	Comment `bson:",inline"`
+	Votes   VoteList `bson:"votes"`
+}
+
+// Переносит встроенные голоса и комментарии в отдельные коллекции и возвращает число
+// перенесенных постов. Все записи делаются upsert'ом, поэтому прерванную миграцию
+// можно просто запустить заново.
+func (repo *PostRepositoryMongo) MigrateEmbedded(ctx context.Context) (int, error) {
+	cursor, err := repo.coll.Find(ctx, bson.M{"votes": bson.M{"$exists": true}})
+	if err != nil {
+		return 0, fmt.Errorf("mongo find err: %w", err)
+	}
+	defer cursor.Close(ctx) // nolint:errcheck
+	// старые документы могут весить мегабайты, поэтому в памяти держится только один пост
+	migrated := 0
+	for cursor.Next(ctx) {
+		lp := &legacyPost{}
+		if err = cursor.Decode(lp); err != nil {
+			return migrated, fmt.Errorf("mongo decode err: %w", err)
+		}
+		if err = repo.migratePost(ctx, lp); err != nil {
+			return migrated, fmt.Errorf("migrate post %s err: %w", lp.ID, err)
+		}
+		migrated++
+	}
+	if err = cursor.Err(); err != nil {
+		return migrated, fmt.Errorf("mongo cursor err: %w", err)
+	}
+	return migrated, nil
+}
+
+// Таймаут репозитория ограничивает перенос одного поста, а не всю миграцию
+func (repo *PostRepositoryMongo) migratePost(ctx context.Context, lp *legacyPost) error {
+	ctx, cancel := timeout.Context(ctx, repo.timeout)
+	defer cancel()
+	if err := repo.migrateVotes(ctx, lp.ID, "", lp.Votes); err != nil {
+		return err
+	}
+	for _, lc := range lp.Comments {
+		comm := lc.Comment
+		comm.setScore(countLikes(lc.Votes.List), len(lc.Votes.List))
+		if _, err := repo.comments.UpdateOne(
+			ctx,
+			bson.M{"postid": lp.ID, "id": comm.ID},
+			bson.M{"$set": commentDoc{PostID: lp.ID, Comment: comm}},
+			options.Update().SetUpsert(true),
+		); err != nil {
+			return fmt.Errorf("mongo update one err: %w", err)
+		}
+		if err := repo.migrateVotes(ctx, lp.ID, comm.ID, lc.Votes); err != nil {
+			return err
+		}
+	}
+
+	p := &Post{Created: lp.Created}
+	p.setScore(countLikes(lp.Votes.List), len(lp.Votes.List))
+	if _, err := repo.coll.UpdateOne(
+		ctx,
+		bson.M{"id": lp.ID},
+		bson.M{
+			"$set": bson.M{
+				"likescount":    p.LikesCount,
+				"votescount":    p.VotesCount,
+				"score":         p.Score,
+				"likespercent":  p.LikesPercent,
+				"hot":           p.Hot,
+				"rising":        p.Rising,
+				"controversy":   p.Controversy,
+				"commentscount": len(lp.Comments),
+			},
+			"$unset": bson.M{"votes": "", "comments": ""},
+		},
+	); err != nil {
+		return fmt.Errorf("mongo update one err: %w", err)
+	}
+	return nil
+}
+
+// Старый VoteList.Upvote увеличивал LikesCount и на повторный лайк,
+// поэтому сохраненному счетчику верить нельзя и лайки пересчитываются по списку голосов
+func countLikes(votes []Vote) int {
+	likes := 0
+	for _, vote := range votes {
+		if vote.Value == Like {
+			likes++
+		}
+	}
+	return likes
+}
+
+func (repo *PostRepositoryMongo) migrateVotes(ctx context.Context, postID, commID string, votes VoteList) error {
+	for _, vote := range votes.List {
+		if _, err := repo.votes.UpdateOne(
+			ctx,
+			bson.M{"postid": postID, "commentid": commID, "userid": vote.UserID},
+			bson.M{"$set": bson.M{"vote": vote.Value}},
+			options.Update().SetUpsert(true),
+		); err != nil {
+			return fmt.Errorf("mongo update one err: %w", err)
+		}
+	}
+	return nil
+}
+
+// Пост со встроенной историей правок
+type legacyRevisions struct {
+	ID        string     `bson:"id"`
+	Revisions []Revision `bson:"revisions"`
+}
+
+// Переносит встроенную в посты историю правок в отдельную коллекцию и возвращает
+// число перенесенных постов. Как и MigrateEmbedded, безопасна для повторного запуска.
+func (repo *PostRepositoryMongo) MigrateRevisions(ctx context.Context) (int, error) {
+	cursor, err := repo.coll.Find(ctx, bson.M{"revisions": bson.M{"$exists": true}})
+	if err != nil {
+		return 0, fmt.Errorf("mongo find err: %w", err)
+	}
+	defer cursor.Close(ctx) // nolint:errcheck
+	migrated := 0
+	for cursor.Next(ctx) {
+		lr := &legacyRevisions{}
+		if err = cursor.Decode(lr); err != nil {
+			return migrated, fmt.Errorf("mongo decode err: %w", err)
+		}
+		if err = repo.migrateRevisions(ctx, lr); err != nil {
+			return migrated, fmt.Errorf("migrate post %s err: %w", lr.ID, err)
+		}
+		migrated++
+	}
+	if err = cursor.Err(); err != nil {
+		return migrated, fmt.Errorf("mongo cursor err: %w", err)
+	}
+	return migrated, nil
+}
+
+func (repo *PostRepositoryMongo) migrateRevisions(ctx context.Context, lr *legacyRevisions) error {
+	ctx, cancel := timeout.Context(ctx, repo.timeout)
+	defer cancel()
+	for _, rev := range lr.Revisions {
+		if _, err := repo.revisions.UpdateOne(
+			ctx,
+			bson.M{"postid": lr.ID, "createdformat": rev.CreatedFormat},
+			bson.M{"$set": revisionDoc{PostID: lr.ID, Revision: rev}},
+			options.Update().SetUpsert(true),
+		); err != nil {
+			return fmt.Errorf("mongo update one err: %w", err)
+		}
+	}
+	if _, err := repo.coll.UpdateOne(
+		ctx,
+		bson.M{"id": lr.ID},
+		bson.M{"$unset": bson.M{"revisions": ""}},
+	); err != nil {
+		return fmt.Errorf("mongo update one err: %w", err)
+	}
+	return nil
+}
+
+// Пост, сохраненный до появления полей ранжирования
+type unrankedPost struct {
+	ID         string    `bson:"id"`
+	Created    time.Time `bson:"created"`
+	LikesCount int       `bson:"likescount"`
+	VotesCount int       `bson:"votescount"`
+}
+
+// Досчитывает поля ранжирования у постов, сохраненных без них: такие посты иначе
+// оказываются в конце ленты и не достигаются курсорной пагинацией. Возвращает число
+// обновленных постов. Запускается после MigrateEmbedded, которая сама заполняет эти поля.
+func (repo *PostRepositoryMongo) BackfillRanking(ctx context.Context) (int, error) {
+	cursor, err := repo.coll.Find(ctx, bson.M{"$or": bson.A{
+		bson.M{"hot": bson.M{"$exists": false}},
+		bson.M{"rising": bson.M{"$exists": false}},
+		bson.M{"controversy": bson.M{"$exists": false}},
+	}})
+	if err != nil {
+		return 0, fmt.Errorf("mongo find err: %w", err)
+	}
+	defer cursor.Close(ctx) // nolint:errcheck
+	updated := 0
+	for cursor.Next(ctx) {
+		up := &unrankedPost{}
+		if err = cursor.Decode(up); err != nil {
+			return updated, fmt.Errorf("mongo decode err: %w", err)
+		}
+		if err = repo.backfillPost(ctx, up); err != nil {
+			return updated, fmt.Errorf("backfill post %s err: %w", up.ID, err)
+		}
+		updated++
+	}
+	if err = cursor.Err(); err != nil {
+		return updated, fmt.Errorf("mongo cursor err: %w", err)
+	}
+	return updated, nil
+}
+
+func (repo *PostRepositoryMongo) backfillPost(ctx context.Context, up *unrankedPost) error {
+	ctx, cancel := timeout.Context(ctx, repo.timeout)
+	defer cancel()
+	p := &Post{Created: up.Created}
+	p.setScore(up.LikesCount, up.VotesCount)
+	if _, err := repo.coll.UpdateOne(
+		ctx,
+		bson.M{"id": up.ID},
+		bson.M{"$set": bson.M{
+			"likescount":   p.LikesCount,
+			"votescount":   p.VotesCount,
+			"score":        p.Score,
+			"likespercent": p.LikesPercent,
+			"hot":          p.Hot,
+			"rising":       p.Rising,
+			"controversy":  p.Controversy,
+		}},
+	); err != nil {
+		return fmt.Errorf("mongo update one err: %w", err)
+	}
+	return nil
+}
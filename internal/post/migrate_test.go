@@ -0,0 +1,215 @@
+package post
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Документ поста в старом формате со встроенными голосами и комментариями
+func getLegacyDoc(p *Post) interface{} {
+	doc := toDoc(p)
+	doc["votes"] = p.Votes
+	comments := make([]legacyComment, 0, len(p.Comments))
+	for _, comm := range p.Comments {
+		comments = append(comments, legacyComment{Comment: *comm, Votes: comm.Votes})
+	}
+	doc["comments"] = comments
+	return doc
+}
+
+func TestMigrateEmbedded_OK(t *testing.T) {
+	post := NewPost(usr1)
+	post.Votes.Downvote(usr2.ID) // nolint:errcheck
+	post.updatePostScore()
+	comm := NewComment(usr2, "some text")
+	comm.vote(usr1.ID, (*VoteList).Downvote)                 // nolint:errcheck
+	post.Comments.Add(comm)                                  // nolint:errcheck
+	post.Comments.Add(NewReply(usr1, comm.ID, "some reply")) // nolint:errcheck
+
+	repo := &PostRepositoryMongo{
+		coll:     newFakeCollection(getLegacyDoc(post)),
+		votes:    newFakeCollection(),
+		comments: newFakeCollection(),
+	}
+
+	// повторный запуск не должен ничего менять
+	for i, expect := range []int{1, 0} {
+		migrated, err := repo.MigrateEmbedded(emptyCtx)
+		if err != nil {
+			t.Fatalf("[%d] unexpected err: %s", i, err)
+		}
+		if migrated != expect {
+			t.Errorf("[%d] wrong migrated count:\nwant:\t%d\nhave\t%d", i, expect, migrated)
+		}
+	}
+
+	if votes, comments := fakeDocs(repo.votes), fakeDocs(repo.comments); len(votes) != 5 || len(comments) != 2 {
+		t.Errorf("wrong stored docs: %d votes, %d comments", len(votes), len(comments))
+	}
+	stored := fakeDocs(repo.coll)[0]
+	if _, ok := stored["votes"]; ok {
+		t.Errorf("embedded votes not removed: %v", stored)
+	}
+	result, err := repo.loadPost(emptyCtx, post.ID, "")
+	if err != nil {
+		t.Fatalf("unexpected err: %s", err)
+	}
+	if result.LikesCount != 1 || result.VotesCount != 2 || result.CommentsCount != 2 {
+		t.Errorf("wrong counters: %d likes of %d, %d comments",
+			result.LikesCount, result.VotesCount, result.CommentsCount)
+	}
+	if votes := storedVotes(repo.votes, post.ID, ""); len(votes) != 2 || result.Votes.LikesCount != 1 {
+		t.Errorf("wrong votes: %#v", votes)
+	}
+	if len(result.Comments) != 2 || result.Comments[1].ParentID != comm.ID {
+		t.Fatalf("wrong comments: %#v", result.Comments)
+	}
+	first := result.Comments[0]
+	if votes := storedVotes(repo.votes, post.ID, first.ID); first.Score != 0 || first.VotesCount != 2 || len(votes) != 2 {
+		t.Errorf("wrong comment votes: score %d, %d votes", first.Score, len(votes))
+	}
+}
+
+// Завышенный старым Upvote счетчик лайков не должен переехать в новые счетчики
+func TestMigrateEmbedded_InflatedLikes(t *testing.T) {
+	post := NewPost(usr1)
+	post.Votes.Downvote(usr2.ID) // nolint:errcheck
+	post.Votes.LikesCount = 5
+	comm := NewComment(usr2, "some text")
+	comm.Votes.LikesCount = 3
+	post.Comments.Add(comm) // nolint:errcheck
+	legacy := getLegacyDoc(post).(bson.M)
+	for _, key := range []string{"score", "likespercent", "hot", "controversy"} {
+		delete(legacy, key)
+	}
+
+	repo := &PostRepositoryMongo{
+		coll:     newFakeCollection(legacy),
+		votes:    newFakeCollection(),
+		comments: newFakeCollection(),
+	}
+	if _, err := repo.MigrateEmbedded(emptyCtx); err != nil {
+		t.Fatalf("unexpected err: %s", err)
+	}
+
+	result, err := repo.loadPost(emptyCtx, post.ID, "")
+	if err != nil {
+		t.Fatalf("unexpected err: %s", err)
+	}
+	expect := &Post{Created: post.Created}
+	expect.setScore(1, 2)
+	if result.LikesCount != 1 || result.Score != expect.Score || result.LikesPercent != 50 {
+		t.Errorf("wrong post counters: %d likes, score %d, %d%%", result.LikesCount, result.Score, result.LikesPercent)
+	}
+	if result.Hot != expect.Hot || result.Controversy != expect.Controversy {
+		t.Errorf("results not match:\nwant:\t%v, %v\nhave\t%v, %v", expect.Hot, expect.Controversy, result.Hot, result.Controversy)
+	}
+	if first := result.Comments[0]; first.LikesCount != 1 || first.Score != 1 || first.LikesPercent != 100 {
+		t.Errorf("wrong comment counters: %d likes, score %d, %d%%", first.LikesCount, first.Score, first.LikesPercent)
+	}
+}
+
+// Посты читаются с курсора по одному; при ошибке возвращается число уже перенесенных
+func TestMigrateEmbedded_CursorErr(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	cln := NewMockMongoCollection(ctrl)
+	cs := NewMockMongoCursor(ctrl)
+	repo := &PostRepositoryMongo{coll: cln, votes: newFakeCollection(), comments: newFakeCollection()}
+
+	post := NewPost(usr1)
+	expect := fmt.Errorf("cursor err")
+
+	cln.EXPECT().
+		Find(gomock.Any(), bson.M{"votes": bson.M{"$exists": true}}).
+		Return(cs, nil)
+	gomock.InOrder(
+		cs.EXPECT().Next(gomock.Any()).Return(true),
+		cs.EXPECT().Decode(&legacyPost{}).SetArg(0, legacyPost{ID: post.ID, Votes: post.Votes}).Return(nil),
+		cs.EXPECT().Next(gomock.Any()).Return(false),
+		cs.EXPECT().Err().Return(expect),
+		cs.EXPECT().Close(gomock.Any()).Return(nil),
+	)
+	cln.EXPECT().
+		UpdateOne(gomock.Any(), bson.M{"id": post.ID}, gomock.Any()).
+		Return(&mongo.UpdateResult{MatchedCount: 1}, nil)
+
+	migrated, err := repo.MigrateEmbedded(emptyCtx)
+
+	if migrated != 1 {
+		t.Errorf("wrong migrated count:\nwant:\t%d\nhave\t%d", 1, migrated)
+	}
+	if !errors.Is(err, expect) {
+		t.Errorf("results not match:\nwant:\t%#v\nhave\t%#v", expect, err)
+	}
+}
+
+func TestMigrateRevisions_OK(t *testing.T) {
+	post := NewPost(usr1)
+	post.Title = "first title"
+	post.edit(PostEdit{Title: "second title"})
+	post.edit(PostEdit{Title: "third title"})
+	legacy := toDoc(post)
+	legacy["revisions"] = post.Revisions
+
+	repo := &PostRepositoryMongo{coll: newFakeCollection(legacy), revisions: newFakeCollection()}
+
+	for i, expect := range []int{1, 0} {
+		migrated, err := repo.MigrateRevisions(emptyCtx)
+		if err != nil {
+			t.Fatalf("[%d] unexpected err: %s", i, err)
+		}
+		if migrated != expect {
+			t.Errorf("[%d] wrong migrated count:\nwant:\t%d\nhave\t%d", i, expect, migrated)
+		}
+	}
+
+	if _, ok := fakeDocs(repo.coll)[0]["revisions"]; ok {
+		t.Errorf("embedded revisions not removed")
+	}
+	revs, err := repo.GetRevisions(emptyCtx, post.ID)
+	if err != nil {
+		t.Fatalf("unexpected err: %s", err)
+	}
+	if len(revs) != 2 || revs[0].Title != "first title" || revs[1].Title != "second title" {
+		t.Errorf("bad revisions: %#v", revs)
+	}
+}
+
+// Пост без полей ранжирования получает их, уже ранжированный пост не трогается
+func TestBackfillRanking_OK(t *testing.T) {
+	old := NewPost(usr1)
+	old.Created = old.Created.Add(-48 * time.Hour)
+	old.setScore(2, 3)
+	legacy := toDoc(old)
+	delete(legacy, "hot")
+	delete(legacy, "controversy")
+	delete(legacy, "rising")
+	ranked := NewPost(usr2)
+
+	repo := &PostRepositoryMongo{coll: newFakeCollection(legacy, ranked)}
+
+	for i, expect := range []int{1, 0} {
+		updated, err := repo.BackfillRanking(emptyCtx)
+		if err != nil {
+			t.Fatalf("[%d] unexpected err: %s", i, err)
+		}
+		if updated != expect {
+			t.Errorf("[%d] wrong updated count:\nwant:\t%d\nhave\t%d", i, expect, updated)
+		}
+	}
+
+	result, err := findPost(emptyCtx, repo.coll, old.ID)
+	if err != nil {
+		t.Fatalf("unexpected err: %s", err)
+	}
+	if result.Hot != old.Hot || result.Rising != old.Rising || result.Controversy != old.Controversy || result.Score != 1 {
+		t.Errorf("results not match:\nwant:\t%#v\nhave\t%#v", old, result)
+	}
+}
@@ -3,11 +3,18 @@ package post
 import (
 	"context"
 
+	"asperitas/pkg/timeout"
+
 	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 type MongoCursor interface {
 	All(context.Context, interface{}) error
+	Next(context.Context) bool
+	Decode(v interface{}) error
+	Err() error
+	Close(context.Context) error
 }
 
 type MongoSingleResult interface {
@@ -16,19 +23,24 @@ type MongoSingleResult interface {
 }
 
 type MongoCollection interface {
-	Find(context.Context, interface{}) (MongoCursor, error)
+	Find(context.Context, interface{}, ...*options.FindOptions) (MongoCursor, error)
 	FindOne(context.Context, interface{}) MongoSingleResult
 	InsertOne(context.Context, interface{}) (interface{}, error)
-	UpdateOne(context.Context, interface{}, interface{}) (interface{}, error)
+	UpdateOne(context.Context, interface{}, interface{}, ...*options.UpdateOptions) (*mongo.UpdateResult, error)
 	DeleteOne(context.Context, interface{}) (interface{}, error)
+	DeleteMany(context.Context, interface{}) (*mongo.DeleteResult, error)
+	FindOneAndUpdate(context.Context, interface{}, interface{}, ...*options.FindOneAndUpdateOptions) MongoSingleResult
+	FindOneAndDelete(context.Context, interface{}) MongoSingleResult
 }
 
 type mongoCursor struct {
-	cs *mongo.Cursor
+	cs  *mongo.Cursor
+	ctx context.Context
 }
 
 type mongoSingleResult struct {
-	sr *mongo.SingleResult
+	sr  *mongo.SingleResult
+	ctx context.Context
 }
 
 type mongoCollection struct {
@@ -40,38 +52,70 @@ func newMongoCollection(coll *mongo.Collection) MongoCollection {
 }
 
 func (mcs *mongoCursor) All(ctx context.Context, v interface{}) error {
-	return mcs.cs.All(ctx, v)
+	return timeout.Wrap(ctx, mcs.cs.All(ctx, v))
+}
+
+func (mcs *mongoCursor) Next(ctx context.Context) bool {
+	return mcs.cs.Next(ctx)
+}
+
+func (mcs *mongoCursor) Decode(v interface{}) error {
+	return mcs.cs.Decode(v)
+}
+
+// Ошибка, на которой остановился Next
+func (mcs *mongoCursor) Err() error {
+	return timeout.Wrap(mcs.ctx, mcs.cs.Err())
+}
+
+func (mcs *mongoCursor) Close(ctx context.Context) error {
+	return mcs.cs.Close(ctx)
 }
 
 func (msr *mongoSingleResult) Decode(v interface{}) error {
-	return msr.sr.Decode(v)
+	return timeout.Wrap(msr.ctx, msr.sr.Decode(v))
 }
 
 func (msr *mongoSingleResult) Err() error {
-	return msr.sr.Err()
+	return timeout.Wrap(msr.ctx, msr.sr.Err())
 }
 
-func (mc *mongoCollection) Find(ctx context.Context, filter interface{}) (MongoCursor, error) {
-	cursor, err := mc.cln.Find(ctx, filter)
-	return &mongoCursor{cs: cursor}, err
+func (mc *mongoCollection) Find(ctx context.Context, filter interface{}, opts ...*options.FindOptions) (MongoCursor, error) {
+	cursor, err := mc.cln.Find(ctx, filter, opts...)
+	return &mongoCursor{cs: cursor, ctx: ctx}, timeout.Wrap(ctx, err)
 }
 
 func (mc *mongoCollection) FindOne(ctx context.Context, filter interface{}) MongoSingleResult {
 	singleResult := mc.cln.FindOne(ctx, filter)
-	return &mongoSingleResult{sr: singleResult}
+	return &mongoSingleResult{sr: singleResult, ctx: ctx}
 }
 
 func (mc *mongoCollection) InsertOne(ctx context.Context, document interface{}) (interface{}, error) {
 	insertResult, err := mc.cln.InsertOne(ctx, document)
-	return insertResult, err
+	return insertResult, timeout.Wrap(ctx, err)
 }
 
-func (mc *mongoCollection) UpdateOne(ctx context.Context, filter interface{}, update interface{}) (interface{}, error) {
-	updateResult, err := mc.cln.UpdateOne(ctx, filter, update)
-	return updateResult, err
+func (mc *mongoCollection) UpdateOne(ctx context.Context, filter interface{}, update interface{}, opts ...*options.UpdateOptions) (*mongo.UpdateResult, error) {
+	updateResult, err := mc.cln.UpdateOne(ctx, filter, update, opts...)
+	return updateResult, timeout.Wrap(ctx, err)
 }
 
 func (mc *mongoCollection) DeleteOne(ctx context.Context, filter interface{}) (interface{}, error) {
 	deleteResult, err := mc.cln.DeleteOne(ctx, filter)
-	return deleteResult, err
+	return deleteResult, timeout.Wrap(ctx, err)
+}
+
+func (mc *mongoCollection) DeleteMany(ctx context.Context, filter interface{}) (*mongo.DeleteResult, error) {
+	deleteResult, err := mc.cln.DeleteMany(ctx, filter)
+	return deleteResult, timeout.Wrap(ctx, err)
+}
+
+func (mc *mongoCollection) FindOneAndUpdate(ctx context.Context, filter interface{}, update interface{}, opts ...*options.FindOneAndUpdateOptions) MongoSingleResult {
+	singleResult := mc.cln.FindOneAndUpdate(ctx, filter, update, opts...)
+	return &mongoSingleResult{sr: singleResult, ctx: ctx}
+}
+
+func (mc *mongoCollection) FindOneAndDelete(ctx context.Context, filter interface{}) MongoSingleResult {
+	singleResult := mc.cln.FindOneAndDelete(ctx, filter)
+	return &mongoSingleResult{sr: singleResult, ctx: ctx}
 }
@@ -9,6 +9,8 @@ import (
 	reflect "reflect"
 
 	gomock "github.com/golang/mock/gomock"
+	mongo "go.mongodb.org/mongo-driver/mongo"
+	options "go.mongodb.org/mongo-driver/mongo/options"
 )
 
 // MockMongoCursor is a mock of MongoCursor interface.
@@ -48,6 +50,62 @@ func (mr *MockMongoCursorMockRecorder) All(arg0, arg1 interface{}) *gomock.Call
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "All", reflect.TypeOf((*MockMongoCursor)(nil).All), arg0, arg1)
 }
 
+// Close mocks base method.
+func (m *MockMongoCursor) Close(arg0 context.Context) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Close", arg0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Close indicates an expected call of Close.
+func (mr *MockMongoCursorMockRecorder) Close(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Close", reflect.TypeOf((*MockMongoCursor)(nil).Close), arg0)
+}
+
+// Decode mocks base method.
+func (m *MockMongoCursor) Decode(v interface{}) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Decode", v)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Decode indicates an expected call of Decode.
+func (mr *MockMongoCursorMockRecorder) Decode(v interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Decode", reflect.TypeOf((*MockMongoCursor)(nil).Decode), v)
+}
+
+// Err mocks base method.
+func (m *MockMongoCursor) Err() error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Err")
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Err indicates an expected call of Err.
+func (mr *MockMongoCursorMockRecorder) Err() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Err", reflect.TypeOf((*MockMongoCursor)(nil).Err))
+}
+
+// Next mocks base method.
+func (m *MockMongoCursor) Next(arg0 context.Context) bool {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Next", arg0)
+	ret0, _ := ret[0].(bool)
+	return ret0
+}
+
+// Next indicates an expected call of Next.
+func (mr *MockMongoCursorMockRecorder) Next(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Next", reflect.TypeOf((*MockMongoCursor)(nil).Next), arg0)
+}
+
 // MockMongoSingleResult is a mock of MongoSingleResult interface.
 type MockMongoSingleResult struct {
 	ctrl     *gomock.Controller
@@ -122,6 +180,21 @@ func (m *MockMongoCollection) EXPECT() *MockMongoCollectionMockRecorder {
 	return m.recorder
 }
 
+// DeleteMany mocks base method.
+func (m *MockMongoCollection) DeleteMany(arg0 context.Context, arg1 interface{}) (*mongo.DeleteResult, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteMany", arg0, arg1)
+	ret0, _ := ret[0].(*mongo.DeleteResult)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// DeleteMany indicates an expected call of DeleteMany.
+func (mr *MockMongoCollectionMockRecorder) DeleteMany(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteMany", reflect.TypeOf((*MockMongoCollection)(nil).DeleteMany), arg0, arg1)
+}
+
 // DeleteOne mocks base method.
 func (m *MockMongoCollection) DeleteOne(arg0 context.Context, arg1 interface{}) (interface{}, error) {
 	m.ctrl.T.Helper()
@@ -138,18 +211,23 @@ func (mr *MockMongoCollectionMockRecorder) DeleteOne(arg0, arg1 interface{}) *go
 }
 
 // Find mocks base method.
-func (m *MockMongoCollection) Find(arg0 context.Context, arg1 interface{}) (MongoCursor, error) {
+func (m *MockMongoCollection) Find(arg0 context.Context, arg1 interface{}, arg2 ...*options.FindOptions) (MongoCursor, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "Find", arg0, arg1)
+	varargs := []interface{}{arg0, arg1}
+	for _, a := range arg2 {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "Find", varargs...)
 	ret0, _ := ret[0].(MongoCursor)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // Find indicates an expected call of Find.
-func (mr *MockMongoCollectionMockRecorder) Find(arg0, arg1 interface{}) *gomock.Call {
+func (mr *MockMongoCollectionMockRecorder) Find(arg0, arg1 interface{}, arg2 ...interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Find", reflect.TypeOf((*MockMongoCollection)(nil).Find), arg0, arg1)
+	varargs := append([]interface{}{arg0, arg1}, arg2...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Find", reflect.TypeOf((*MockMongoCollection)(nil).Find), varargs...)
 }
 
 // FindOne mocks base method.
@@ -166,6 +244,39 @@ func (mr *MockMongoCollectionMockRecorder) FindOne(arg0, arg1 interface{}) *gomo
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindOne", reflect.TypeOf((*MockMongoCollection)(nil).FindOne), arg0, arg1)
 }
 
+// FindOneAndDelete mocks base method.
+func (m *MockMongoCollection) FindOneAndDelete(arg0 context.Context, arg1 interface{}) MongoSingleResult {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FindOneAndDelete", arg0, arg1)
+	ret0, _ := ret[0].(MongoSingleResult)
+	return ret0
+}
+
+// FindOneAndDelete indicates an expected call of FindOneAndDelete.
+func (mr *MockMongoCollectionMockRecorder) FindOneAndDelete(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindOneAndDelete", reflect.TypeOf((*MockMongoCollection)(nil).FindOneAndDelete), arg0, arg1)
+}
+
+// FindOneAndUpdate mocks base method.
+func (m *MockMongoCollection) FindOneAndUpdate(arg0 context.Context, arg1, arg2 interface{}, arg3 ...*options.FindOneAndUpdateOptions) MongoSingleResult {
+	m.ctrl.T.Helper()
+	varargs := []interface{}{arg0, arg1, arg2}
+	for _, a := range arg3 {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "FindOneAndUpdate", varargs...)
+	ret0, _ := ret[0].(MongoSingleResult)
+	return ret0
+}
+
+// FindOneAndUpdate indicates an expected call of FindOneAndUpdate.
+func (mr *MockMongoCollectionMockRecorder) FindOneAndUpdate(arg0, arg1, arg2 interface{}, arg3 ...interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]interface{}{arg0, arg1, arg2}, arg3...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindOneAndUpdate", reflect.TypeOf((*MockMongoCollection)(nil).FindOneAndUpdate), varargs...)
+}
+
 // InsertOne mocks base method.
 func (m *MockMongoCollection) InsertOne(arg0 context.Context, arg1 interface{}) (interface{}, error) {
 	m.ctrl.T.Helper()
@@ -182,16 +293,21 @@ func (mr *MockMongoCollectionMockRecorder) InsertOne(arg0, arg1 interface{}) *go
 }
 
 // UpdateOne mocks base method.
-func (m *MockMongoCollection) UpdateOne(arg0 context.Context, arg1, arg2 interface{}) (interface{}, error) {
+func (m *MockMongoCollection) UpdateOne(arg0 context.Context, arg1, arg2 interface{}, arg3 ...*options.UpdateOptions) (*mongo.UpdateResult, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "UpdateOne", arg0, arg1, arg2)
-	ret0, _ := ret[0].(interface{})
+	varargs := []interface{}{arg0, arg1, arg2}
+	for _, a := range arg3 {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "UpdateOne", varargs...)
+	ret0, _ := ret[0].(*mongo.UpdateResult)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // UpdateOne indicates an expected call of UpdateOne.
-func (mr *MockMongoCollectionMockRecorder) UpdateOne(arg0, arg1, arg2 interface{}) *gomock.Call {
+func (mr *MockMongoCollectionMockRecorder) UpdateOne(arg0, arg1, arg2 interface{}, arg3 ...interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateOne", reflect.TypeOf((*MockMongoCollection)(nil).UpdateOne), arg0, arg1, arg2)
+	varargs := append([]interface{}{arg0, arg1, arg2}, arg3...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateOne", reflect.TypeOf((*MockMongoCollection)(nil).UpdateOne), varargs...)
 }
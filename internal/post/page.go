@@ -0,0 +1,193 @@
+package post
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"sort"
+	"strings"
+	"time"
+
+	"asperitas/internal/errs"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+const (
+	DefaultPageLimit = 100
+	MaxPageLimit     = 100
+)
+
+type Page struct {
+	Limit int
+	After string
+}
+
+// Значения полей сортировки последнего поста страницы, из которых собирается курсор
+type cursor struct {
+	Score       int64     `json:"s"`
+	Hot         float64   `json:"h"`
+	Rising      float64   `json:"r"`
+	Controversy float64   `json:"v"`
+	Created     time.Time `json:"c"`
+	ID          string    `json:"i"`
+}
+
+type sortKey struct {
+	field string
+	desc  bool
+	value func(p *Post) interface{}
+}
+
+type ordering []sortKey
+
+var (
+	scoreKey   = sortKey{field: "score", desc: true, value: func(p *Post) interface{} { return p.Score }}
+	createdKey = sortKey{field: "created", value: func(p *Post) interface{} { return p.Created }}
+	newestKey  = sortKey{field: "created", desc: true, value: func(p *Post) interface{} { return p.Created }}
+	idKey      = sortKey{field: "id", value: func(p *Post) interface{} { return p.ID }}
+
+	// Лучшие посты выше, при равном рейтинге раньше созданные
+	byScore = ordering{scoreKey, createdKey, idKey}
+	// Сначала новые
+	byNewest = ordering{newestKey, idKey}
+)
+
+// Без limit и курсора лента отдается целиком, как до пагинации: собранный фронтенд
+// не читает курсор следующей страницы. Нулевой лимит означает отсутствие ограничения.
+func (page Page) limit() int {
+	if page.Limit <= 0 && page.After == "" {
+		return 0
+	}
+	if page.Limit <= 0 || page.Limit > MaxPageLimit {
+		return DefaultPageLimit
+	}
+	return page.Limit
+}
+
+func encodeCursor(p *Post) string {
+	data, _ := json.Marshal(cursor{ // nolint:errcheck
+		Score:       p.Score,
+		Hot:         p.Hot,
+		Rising:      p.Rising,
+		Controversy: p.Controversy,
+		Created:     p.Created,
+		ID:          p.ID,
+	})
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// Восстанавливает из курсора пост, содержащий только поля сортировки
+func decodeCursor(after string) (*Post, error) {
+	invalid := errs.MsgError{Msg: "invalid cursor", Status: 400}
+	data, err := base64.RawURLEncoding.DecodeString(after)
+	if err != nil {
+		return nil, invalid
+	}
+	var c cursor
+	if err = json.Unmarshal(data, &c); err != nil || c.ID == "" {
+		return nil, invalid
+	}
+	return &Post{
+		Score:       c.Score,
+		Hot:         c.Hot,
+		Rising:      c.Rising,
+		Controversy: c.Controversy,
+		Created:     c.Created,
+		ID:          c.ID,
+	}, nil
+}
+
+func compareValues(a, b interface{}) int {
+	switch a := a.(type) {
+	case int64:
+		b := b.(int64)
+		if a < b {
+			return -1
+		} else if a > b {
+			return 1
+		}
+	case float64:
+		b := b.(float64)
+		if a < b {
+			return -1
+		} else if a > b {
+			return 1
+		}
+	case time.Time:
+		return a.Compare(b.(time.Time))
+	case string:
+		return strings.Compare(a, b.(string))
+	}
+	return 0
+}
+
+func (o ordering) less(a, b *Post) bool {
+	for _, key := range o {
+		cmp := compareValues(key.value(a), key.value(b))
+		if cmp == 0 {
+			continue
+		}
+		if key.desc {
+			return cmp > 0
+		}
+		return cmp < 0
+	}
+	return false
+}
+
+func (o ordering) mongoSort() bson.D {
+	result := make(bson.D, 0, len(o))
+	for _, key := range o {
+		dir := 1
+		if key.desc {
+			dir = -1
+		}
+		result = append(result, bson.E{Key: key.field, Value: dir})
+	}
+	return result
+}
+
+// Строит условие "пост идет строго после last" для keyset-пагинации:
+// (k1 после v1) или (k1 = v1 и k2 после v2) или ...
+func (o ordering) mongoAfter(last *Post) bson.M {
+	or := make(bson.A, 0, len(o))
+	for i, key := range o {
+		cond := bson.M{}
+		for _, prev := range o[:i] {
+			cond[prev.field] = prev.value(last)
+		}
+		op := "$gt"
+		if key.desc {
+			op = "$lt"
+		}
+		cond[key.field] = bson.M{op: key.value(last)}
+		or = append(or, cond)
+	}
+	return bson.M{"$or": or}
+}
+
+// Сортирует посты и вырезает из них страницу, идущую после курсора
+func (o ordering) paginate(posts []*Post, page Page) ([]*Post, string, error) {
+	sort.Slice(posts, func(i, j int) bool {
+		return o.less(posts[i], posts[j])
+	})
+	if page.After != "" {
+		last, err := decodeCursor(page.After)
+		if err != nil {
+			return nil, "", err
+		}
+		start := sort.Search(len(posts), func(i int) bool {
+			return o.less(last, posts[i])
+		})
+		posts = posts[start:]
+	}
+	return cutPage(posts, page.limit())
+}
+
+func cutPage(posts []*Post, limit int) ([]*Post, string, error) {
+	if limit == 0 || len(posts) <= limit {
+		return posts, "", nil
+	}
+	posts = posts[:limit]
+	return posts, encodeCursor(posts[limit-1]), nil
+}
@@ -0,0 +1,80 @@
+package post
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestPaginate_ByScore(t *testing.T) {
+	now := time.Now()
+	posts := make([]*Post, 5)
+	for i := range posts {
+		posts[i] = NewPost(usr1)
+		posts[i].Created = now.Add(time.Duration(i) * time.Second)
+	}
+	posts[3].Score = 5
+	posts[1].Score = 3
+	expect := []*Post{posts[3], posts[1], posts[0], posts[2], posts[4]}
+
+	var result []*Post
+	page := Page{Limit: 2}
+	for pages := 0; pages < 3; pages++ {
+		data := make([]*Post, len(posts))
+		copy(data, posts)
+		chunk, next, err := byScore.paginate(data, page)
+		if err != nil {
+			t.Fatalf("unexpected err: %s", err)
+		}
+		result = append(result, chunk...)
+		if next == "" {
+			break
+		}
+		page.After = next
+	}
+
+	if !reflect.DeepEqual(expect, result) {
+		t.Errorf("results not match:\nwant:\t%v\nhave\t%v", expect, result)
+	}
+	if page.After == "" {
+		t.Errorf("expected several pages")
+	}
+}
+
+func TestPaginate_LastPage(t *testing.T) {
+	posts := []*Post{NewPost(usr1), NewPost(usr2)}
+
+	result, next, err := byNewest.paginate(posts, Page{Limit: 2})
+
+	if err != nil {
+		t.Errorf("unexpected err: %s", err)
+	}
+	if len(result) != 2 || next != "" {
+		t.Errorf("bad last page: %v, next=%q", result, next)
+	}
+}
+
+func TestPage_Limit(t *testing.T) {
+	for limit, expect := range map[int]int{0: DefaultPageLimit, -1: DefaultPageLimit, 10: 10, MaxPageLimit + 1: DefaultPageLimit} {
+		if result := (Page{Limit: limit, After: "cursor"}).limit(); result != expect {
+			t.Errorf("limit %d:\nwant:\t%d\nhave\t%d", limit, expect, result)
+		}
+	}
+}
+
+// Без limit и курсора лента отдается целиком
+func TestPaginate_Unbounded(t *testing.T) {
+	posts := make([]*Post, MaxPageLimit+1)
+	for i := range posts {
+		posts[i] = NewPost(usr1)
+	}
+
+	result, next, err := byNewest.paginate(posts, Page{})
+
+	if err != nil {
+		t.Errorf("unexpected err: %s", err)
+	}
+	if len(result) != len(posts) || next != "" {
+		t.Errorf("listing cut: %d of %d posts, next=%q", len(result), len(posts), next)
+	}
+}
@@ -1,6 +1,7 @@
 package post
 
 import (
+	"context"
 	"time"
 
 	"asperitas/internal/user"
@@ -14,17 +15,9 @@ const (
 	Link PostType = "link"
 )
 
+// Имя сообщества, в котором опубликован пост
 type PostCategory string
 
-const (
-	Music       PostCategory = "music"
-	Funny       PostCategory = "funny"
-	Videos      PostCategory = "videos"
-	Programming PostCategory = "programming"
-	News        PostCategory = "news"
-	Fashion     PostCategory = "fashion"
-)
-
 type Post struct {
 	Score         int64        `json:"score"`
 	Views         uint32       `json:"views"`
@@ -34,48 +27,81 @@ type Post struct {
 	Author        user.User    `json:"author"`
 	Category      PostCategory `json:"category"`
 	Text          string       `json:"text,omitempty"`
-	Votes         VoteList     `json:"votes"`
-	Comments      CommentList  `json:"comments"`
+	Votes         VoteList     `json:"votes" bson:"-"`
+	Comments      CommentList  `json:"comments" bson:"-"`
 	Created       time.Time    `json:"-"`
 	CreatedFormat string       `json:"created"`
+	Edited        time.Time    `json:"-"`
+	EditedFormat  string       `json:"edited,omitempty"`
+	Revisions     []Revision   `json:"-" bson:"-"`
 	LikesPercent  int          `json:"upvotePercentage"`
+	LikesCount    int          `json:"-"`
+	VotesCount    int          `json:"-"`
+	CommentsCount int          `json:"commentsCount"`
+	Hot           float64      `json:"-"`
+	Rising        float64      `json:"-"`
+	Controversy   float64      `json:"-"`
 	ID            string       `json:"id"`
 }
 
+// Проверки прав на удаление; репозиторий вызывает их уже после загрузки поста,
+// чтобы решение учитывало автора и сообщество
+type (
+	PostPermit    func(p *Post) bool
+	CommentPermit func(p *Post, c *Comment) bool
+)
+
+// Голоса за посты и комментарии отдаются счетчиками; из самих голосов остается только
+// голос viewerID (или userID у изменяющих методов), пустой viewerID означает анонимного читателя
 type PostRepo interface {
-	GetAll() ([]*Post, error)
-	AddPost(post *Post) error
-	GetByCategory(category string) ([]*Post, error)
-	GetByID(postID string) (*Post, error)
-	DeletePost(postID, userID string) error
-	AddComment(postID string, comment *Comment) (*Post, error)
-	DeleteComment(postID, commentID, userID string) (*Post, error)
-	UpvotePost(postID, userID string) (*Post, error)
-	DownvotePost(postID, userID string) (*Post, error)
-	UnvotePost(postID, userID string) (*Post, error)
-	GetByUser(username string) ([]*Post, error)
+	GetAll(ctx context.Context, rank Ranking, page Page, viewerID string) ([]*Post, string, error)
+	AddPost(ctx context.Context, post *Post) error
+	GetByCategory(ctx context.Context, category string, rank Ranking, page Page, viewerID string) ([]*Post, string, error)
+	GetByID(ctx context.Context, postID, viewerID string) (*Post, error)
+	DeletePost(ctx context.Context, postID string, allow PostPermit) error
+	UpdatePost(ctx context.Context, postID, userID string, upd PostEdit) (*Post, error)
+	GetRevisions(ctx context.Context, postID string) ([]Revision, error)
+	AddComment(ctx context.Context, postID string, comment *Comment) (*Post, error)
+	DeleteComment(ctx context.Context, postID, commentID, userID string, allow CommentPermit) (*Post, error)
+	UpvotePost(ctx context.Context, postID, userID string) (*Post, error)
+	DownvotePost(ctx context.Context, postID, userID string) (*Post, error)
+	UnvotePost(ctx context.Context, postID, userID string) (*Post, error)
+	UpvoteComment(ctx context.Context, postID, commentID, userID string) (*Post, error)
+	DownvoteComment(ctx context.Context, postID, commentID, userID string) (*Post, error)
+	UnvoteComment(ctx context.Context, postID, commentID, userID string) (*Post, error)
+	GetByUser(ctx context.Context, username string, rank Ranking, page Page, viewerID string) ([]*Post, string, error)
 }
 
 func (p *Post) updatePostScore() {
-	p.Score = int64(2*p.Votes.LikesCount - len(p.Votes.List))
-	if len(p.Votes.List) == 0 {
+	p.setScore(p.Votes.LikesCount, len(p.Votes.List))
+}
+
+// Пересчитывает рейтинг по числу голосов за и общему числу голосов
+func (p *Post) setScore(likes, total int) {
+	p.LikesCount = likes
+	p.VotesCount = total
+	p.Score = int64(2*likes - total)
+	if total == 0 {
 		p.LikesPercent = 0
 	} else {
-		p.LikesPercent = p.Votes.LikesCount * 100 / len(p.Votes.List)
+		p.LikesPercent = likes * 100 / total
 	}
+	p.Hot = hotRank(p.Score, p.Created)
+	p.Rising = risingRank(p.Score, p.Created, time.Now())
+	p.Controversy = controversyRank(likes, total-likes)
 }
 
 func NewPost(usr user.User) *Post {
 	t := time.Now()
-	return &Post{
-		Score:         1,
+	p := &Post{
 		Views:         0,
-		Author:        usr,
+		Author:        usr.WithoutRoles(),
 		Created:       t,
 		CreatedFormat: t.Format(time.RFC3339Nano),
 		Votes:         NewVoteList(usr.ID),
-		Comments:      make(CommentList, 0, 1000),
-		LikesPercent:  100,
+		Comments:      make(CommentList, 0),
 		ID:            rand.GetRandID(),
 	}
+	p.updatePostScore()
+	return p
 }
@@ -0,0 +1,171 @@
+package post
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"asperitas/internal/errs"
+)
+
+// Момент отсчета для hot-рейтинга, как у reddit
+const hotEpoch = 1134028003
+
+// За столько секунд свежести пост получает столько же, сколько за десятикратный рейтинг
+const hotScale = 45000
+
+// Меньший возраст в rising не учитывается: иначе пара голосов в первые секунды
+// дала бы свежему посту огромную скорость
+const risingMinAge = time.Hour
+
+// Способ упорядочивания ленты. Window ограничивает ленту постами,
+// созданными не раньше чем Window назад; нулевое значение означает все время.
+type Ranking struct {
+	Name   string
+	Window time.Duration
+}
+
+type rankingSpec struct {
+	order ordering
+	// фиксированное окно ранжирования
+	window time.Duration
+	// допускает выбор окна через параметр t
+	windowed bool
+}
+
+var (
+	hotKey         = sortKey{field: "hot", desc: true, value: func(p *Post) interface{} { return p.Hot }}
+	risingKey      = sortKey{field: "rising", desc: true, value: func(p *Post) interface{} { return p.Rising }}
+	controversyKey = sortKey{field: "controversy", desc: true, value: func(p *Post) interface{} { return p.Controversy }}
+
+	// Чтобы добавить новый режим ленты, достаточно описать его здесь
+	rankings = map[string]rankingSpec{
+		"hot":           {order: ordering{hotKey, newestKey, idKey}},
+		"new":           {order: byNewest},
+		"top":           {order: byScore, windowed: true},
+		"rising":        {order: ordering{risingKey, newestKey, idKey}, window: 12 * time.Hour},
+		"controversial": {order: ordering{controversyKey, newestKey, idKey}, windowed: true},
+	}
+
+	rankingWindows = map[string]time.Duration{
+		"day":   24 * time.Hour,
+		"week":  7 * 24 * time.Hour,
+		"month": 30 * 24 * time.Hour,
+		"all":   0,
+	}
+
+	Hot           = Ranking{Name: "hot"}
+	New           = Ranking{Name: "new"}
+	Top           = Ranking{Name: "top"}
+	Rising        = Ranking{Name: "rising", Window: rankings["rising"].window}
+	Controversial = Ranking{Name: "controversial"}
+)
+
+// Разбирает параметры sort и t; пустой sort дает ранжирование по умолчанию
+func ParseRanking(name, window string, def Ranking) (Ranking, error) {
+	if name == "" {
+		name = def.Name
+	}
+	spec, ok := rankings[name]
+	if !ok {
+		return Ranking{}, errs.DetailErrors{Errors: []errs.DetailError{
+			{
+				Location: "query",
+				Param:    "sort",
+				Value:    name,
+				Msg:      "must be one of " + strings.Join(rankingNames(), ", "),
+			},
+		}, Status: 400}
+	}
+	rank := Ranking{Name: name, Window: spec.window}
+	if window == "" {
+		return rank, nil
+	}
+	dur, ok := rankingWindows[window]
+	if !ok || !spec.windowed {
+		return Ranking{}, errs.DetailErrors{Errors: []errs.DetailError{
+			{
+				Location: "query",
+				Param:    "t",
+				Value:    window,
+				Msg:      fmt.Sprintf("not supported for sort %s", name),
+			},
+		}, Status: 400}
+	}
+	rank.Window = dur
+	return rank, nil
+}
+
+func rankingNames() []string {
+	names := make([]string, 0, len(rankings))
+	for name := range rankings {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (rank Ranking) ordering() ordering {
+	return rankings[rank.Name].order
+}
+
+// Самый ранний момент создания поста, попадающего в ленту
+func (rank Ranking) since(now time.Time) time.Time {
+	if rank.Window == 0 {
+		return time.Time{}
+	}
+	return now.Add(-rank.Window)
+}
+
+// Оставляет посты, попавшие в окно ранжирования, и вырезает из них страницу
+func (rank Ranking) paginate(posts []*Post, page Page) ([]*Post, string, error) {
+	if since := rank.since(time.Now()); !since.IsZero() {
+		filtered := posts[:0]
+		for _, post := range posts {
+			if !post.Created.Before(since) {
+				filtered = append(filtered, post)
+			}
+		}
+		posts = filtered
+	}
+	return rank.ordering().paginate(posts, page)
+}
+
+// Порядок рейтинга плюс свежесть. Значение зависит только от рейтинга и момента создания,
+// поэтому его можно хранить и индексировать.
+func hotRank(score int64, created time.Time) float64 {
+	order := math.Log10(math.Max(math.Abs(float64(score)), 1))
+	sign := 0.0
+	if score > 0 {
+		sign = 1
+	} else if score < 0 {
+		sign = -1
+	}
+	seconds := float64(created.Unix() - hotEpoch)
+	return math.Round((sign*order+seconds/hotScale)*1e7) / 1e7
+}
+
+// Скорость набора голосов: рейтинг на час возраста поста. Считается в момент голоса и хранится,
+// поэтому у поста, переставшего набирать голоса, остается скорость на момент последнего голоса,
+// пока он не выйдет из окна rising.
+func risingRank(score int64, created, now time.Time) float64 {
+	age := now.Sub(created)
+	if age < risingMinAge {
+		age = risingMinAge
+	}
+	return math.Round(float64(score)/age.Hours()*1e7) / 1e7
+}
+
+func controversyRank(ups, downs int) float64 {
+	if ups <= 0 || downs <= 0 {
+		return 0
+	}
+	magnitude := float64(ups + downs)
+	balance := float64(downs) / float64(ups)
+	if ups < downs {
+		balance = float64(ups) / float64(downs)
+	}
+	return math.Pow(magnitude, balance)
+}
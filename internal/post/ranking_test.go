@@ -0,0 +1,100 @@
+package post
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"asperitas/internal/errs"
+)
+
+func TestParseRanking(t *testing.T) {
+	cases := []struct {
+		name, window string
+		expect       Ranking
+	}{
+		{"", "", Top},
+		{"hot", "", Hot},
+		{"rising", "", Rising},
+		{"top", "day", Ranking{Name: "top", Window: 24 * time.Hour}},
+		{"controversial", "all", Controversial},
+	}
+	for _, c := range cases {
+		result, err := ParseRanking(c.name, c.window, Top)
+		if err != nil {
+			t.Errorf("sort=%q t=%q: unexpected err: %s", c.name, c.window, err)
+			continue
+		}
+		if result != c.expect {
+			t.Errorf("sort=%q t=%q:\nwant:\t%v\nhave\t%v", c.name, c.window, c.expect, result)
+		}
+	}
+}
+
+func TestParseRanking_Err(t *testing.T) {
+	for _, c := range [][3]string{{"best", "", "sort"}, {"hot", "week", "t"}, {"top", "year", "t"}} {
+		_, err := ParseRanking(c[0], c[1], Top)
+		var detailErr errs.DetailErrors
+		if !errors.As(err, &detailErr) || detailErr.Status != 400 || detailErr.Errors[0].Param != c[2] {
+			t.Errorf("sort=%q t=%q: wrong err: %v", c[0], c[1], err)
+		}
+	}
+}
+
+func TestHotRank(t *testing.T) {
+	now := time.Now()
+	if hotRank(10, now) <= hotRank(1, now) {
+		t.Errorf("higher score must be hotter")
+	}
+	if hotRank(1, now) <= hotRank(10, now.Add(-48*time.Hour)) {
+		t.Errorf("fresh post must outrank two days old one")
+	}
+	if hotRank(-5, now) >= hotRank(0, now) {
+		t.Errorf("negative score must lower rank")
+	}
+}
+
+func TestRisingRank(t *testing.T) {
+	now := time.Now()
+	if risingRank(30, now.Add(-6*time.Hour), now) <= risingRank(0, now, now) {
+		t.Errorf("older post gaining votes fast must outrank brand-new post without votes")
+	}
+	if risingRank(30, now.Add(-6*time.Hour), now) <= risingRank(1, now, now) {
+		t.Errorf("older post gaining votes fast must outrank brand-new post with author vote only")
+	}
+	if risingRank(10, now.Add(-2*time.Hour), now) <= risingRank(10, now.Add(-8*time.Hour), now) {
+		t.Errorf("same score gained faster must rise higher")
+	}
+	if risingRank(3, now.Add(-time.Minute), now) != risingRank(3, now.Add(-risingMinAge), now) {
+		t.Errorf("age below minimum must not inflate rising rank")
+	}
+}
+
+func TestControversyRank(t *testing.T) {
+	if controversyRank(5, 0) != 0 {
+		t.Errorf("one-sided votes are not controversial")
+	}
+	if controversyRank(5, 5) <= controversyRank(9, 1) {
+		t.Errorf("balanced votes must be more controversial")
+	}
+	if controversyRank(50, 50) <= controversyRank(5, 5) {
+		t.Errorf("more votes must be more controversial")
+	}
+}
+
+func TestRankingPaginate_Window(t *testing.T) {
+	now := time.Now()
+	fresh, old := NewPost(usr1), NewPost(usr2)
+	old.Created = now.Add(-48 * time.Hour)
+	old.Score = 10
+
+	result, _, err := Ranking{Name: "top", Window: 24 * time.Hour}.paginate([]*Post{old, fresh}, Page{})
+
+	if err != nil {
+		t.Fatalf("unexpected err: %s", err)
+	}
+	if expect := []*Post{fresh}; !reflect.DeepEqual(expect, result) {
+		t.Errorf("results not match:\nwant:\t%v\nhave\t%v", expect, result)
+	}
+}
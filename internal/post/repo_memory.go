@@ -1,7 +1,7 @@
 package post
 
 import (
-	"sort"
+	"context"
 	"sync"
 
 	"asperitas/internal/errs"
@@ -19,27 +19,22 @@ func NewMemoryRepo() *PostMemoryRepository {
 	}
 }
 
-func (repo *PostMemoryRepository) GetAll() ([]*Post, error) {
-	repo.mu.Lock()
-	defer repo.mu.Unlock()
-	result := repo.data
-	sort.Slice(result, func(i, j int) bool {
-		if result[i].Score == result[j].Score {
-			return result[i].Created.Before(result[j].Created)
-		}
-		return result[i].Score > result[j].Score
-	})
-	return result, nil
+func (repo *PostMemoryRepository) GetAll(_ context.Context, rank Ranking, page Page, _ string) ([]*Post, string, error) {
+	repo.mu.RLock()
+	result := make([]*Post, len(repo.data))
+	copy(result, repo.data)
+	repo.mu.RUnlock()
+	return rank.paginate(result, page)
 }
 
-func (repo *PostMemoryRepository) AddPost(p *Post) error {
+func (repo *PostMemoryRepository) AddPost(_ context.Context, p *Post) error {
 	repo.mu.Lock()
 	defer repo.mu.Unlock()
 	repo.data = append(repo.data, p)
 	return nil
 }
 
-func (repo *PostMemoryRepository) GetByCategory(categoryName string) ([]*Post, error) {
+func (repo *PostMemoryRepository) GetByCategory(_ context.Context, categoryName string, rank Ranking, page Page, _ string) ([]*Post, string, error) {
 	category := PostCategory(categoryName)
 	result := make([]*Post, 0, 1000)
 	repo.mu.RLock()
@@ -49,16 +44,10 @@ func (repo *PostMemoryRepository) GetByCategory(categoryName string) ([]*Post, e
 		}
 	}
 	repo.mu.RUnlock()
-	sort.Slice(result, func(i, j int) bool {
-		if result[i].Score == result[j].Score {
-			return result[i].Created.Before(result[j].Created)
-		}
-		return result[i].Score > result[j].Score
-	})
-	return result, nil
+	return rank.paginate(result, page)
 }
 
-func (repo *PostMemoryRepository) GetByID(id string) (*Post, error) {
+func (repo *PostMemoryRepository) GetByID(_ context.Context, id, _ string) (*Post, error) {
 	repo.mu.Lock()
 	defer repo.mu.Unlock()
 	for _, post := range repo.data {
@@ -70,7 +59,7 @@ func (repo *PostMemoryRepository) GetByID(id string) (*Post, error) {
 	return nil, errs.MsgError{Msg: "post not found", Status: 404}
 }
 
-func (repo *PostMemoryRepository) DeletePost(postID, userID string) error {
+func (repo *PostMemoryRepository) DeletePost(_ context.Context, postID string, allow PostPermit) error {
 	i := -1
 	repo.mu.RLock()
 	for idx, post := range repo.data {
@@ -85,7 +74,7 @@ func (repo *PostMemoryRepository) DeletePost(postID, userID string) error {
 	if i < 0 {
 		return errs.MsgError{Msg: "post not found", Status: 404}
 	}
-	if repo.data[i].Author.ID != userID {
+	if !allow(repo.data[i]) {
 		return errs.MsgError{Msg: "unauthorized", Status: 401}
 	}
 	repo.data[i] = repo.data[len(repo.data)-1]
@@ -93,7 +82,35 @@ func (repo *PostMemoryRepository) DeletePost(postID, userID string) error {
 	return errs.MsgError{Msg: "success", Status: 200}
 }
 
-func (repo *PostMemoryRepository) AddComment(postID string, comm *Comment) (*Post, error) {
+func (repo *PostMemoryRepository) UpdatePost(_ context.Context, postID, userID string, upd PostEdit) (*Post, error) {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+	for _, post := range repo.data {
+		if post.ID == postID {
+			if post.Author.ID != userID {
+				return nil, errs.MsgError{Msg: "unauthorized", Status: 401}
+			}
+			post.edit(upd)
+			return post, nil
+		}
+	}
+	return nil, errs.MsgError{Msg: "post not found", Status: 404}
+}
+
+func (repo *PostMemoryRepository) GetRevisions(_ context.Context, postID string) ([]Revision, error) {
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
+	for _, post := range repo.data {
+		if post.ID == postID {
+			result := make([]Revision, len(post.Revisions))
+			copy(result, post.Revisions)
+			return result, nil
+		}
+	}
+	return nil, errs.MsgError{Msg: "post not found", Status: 404}
+}
+
+func (repo *PostMemoryRepository) AddComment(_ context.Context, postID string, comm *Comment) (*Post, error) {
 	var p *Post
 	repo.mu.RLock()
 	for _, post := range repo.data {
@@ -111,10 +128,11 @@ func (repo *PostMemoryRepository) AddComment(postID string, comm *Comment) (*Pos
 	if err := p.Comments.Add(comm); err != nil {
 		return nil, err
 	}
+	p.CommentsCount = len(p.Comments)
 	return p, nil
 }
 
-func (repo *PostMemoryRepository) DeleteComment(postID, commID, userID string) (*Post, error) {
+func (repo *PostMemoryRepository) DeleteComment(_ context.Context, postID, commID, _ string, allow CommentPermit) (*Post, error) {
 	var p *Post
 	repo.mu.RLock()
 	for _, post := range repo.data {
@@ -129,11 +147,12 @@ func (repo *PostMemoryRepository) DeleteComment(postID, commID, userID string) (
 	if p == nil {
 		return nil, errs.MsgError{Msg: "post not found", Status: 404}
 	}
-	err := p.Comments.Delete(commID, userID)
+	err := p.Comments.Delete(commID, func(c *Comment) bool { return allow(p, c) })
+	p.CommentsCount = len(p.Comments)
 	return p, err
 }
 
-func (repo *PostMemoryRepository) UpvotePost(postID, userID string) (*Post, error) {
+func (repo *PostMemoryRepository) UpvotePost(_ context.Context, postID, userID string) (*Post, error) {
 	var p *Post
 	repo.mu.RLock()
 	for _, post := range repo.data {
@@ -150,17 +169,12 @@ func (repo *PostMemoryRepository) UpvotePost(postID, userID string) (*Post, erro
 	}
 	err := p.Votes.Upvote(userID)
 	if err == nil {
-		p.Score = int64(2*p.Votes.LikesCount - len(p.Votes.List))
-		if len(p.Votes.List) == 0 {
-			p.LikesPercent = 0
-		} else {
-			p.LikesPercent = p.Votes.LikesCount * 100 / len(p.Votes.List)
-		}
+		p.updatePostScore()
 	}
 	return p, err
 }
 
-func (repo *PostMemoryRepository) DownvotePost(postID, userID string) (*Post, error) {
+func (repo *PostMemoryRepository) DownvotePost(_ context.Context, postID, userID string) (*Post, error) {
 	var p *Post
 	repo.mu.RLock()
 	for _, post := range repo.data {
@@ -177,17 +191,12 @@ func (repo *PostMemoryRepository) DownvotePost(postID, userID string) (*Post, er
 	}
 	err := p.Votes.Downvote(userID)
 	if err == nil {
-		p.Score = int64(2*p.Votes.LikesCount - len(p.Votes.List))
-		if len(p.Votes.List) == 0 {
-			p.LikesPercent = 0
-		} else {
-			p.LikesPercent = p.Votes.LikesCount * 100 / len(p.Votes.List)
-		}
+		p.updatePostScore()
 	}
 	return p, err
 }
 
-func (repo *PostMemoryRepository) UnvotePost(postID, userID string) (*Post, error) {
+func (repo *PostMemoryRepository) UnvotePost(_ context.Context, postID, userID string) (*Post, error) {
 	var p *Post
 	repo.mu.RLock()
 	for _, post := range repo.data {
@@ -204,17 +213,42 @@ func (repo *PostMemoryRepository) UnvotePost(postID, userID string) (*Post, erro
 	}
 	err := p.Votes.Unvote(userID)
 	if err == nil {
-		p.Score = int64(2*p.Votes.LikesCount - len(p.Votes.List))
-		if len(p.Votes.List) == 0 {
-			p.LikesPercent = 0
-		} else {
-			p.LikesPercent = p.Votes.LikesCount * 100 / len(p.Votes.List)
-		}
+		p.updatePostScore()
 	}
 	return p, err
 }
 
-func (repo *PostMemoryRepository) GetByUser(username string) ([]*Post, error) {
+func (repo *PostMemoryRepository) UpvoteComment(_ context.Context, postID, commID, userID string) (*Post, error) {
+	return repo.voteComment(postID, commID, userID, (*VoteList).Upvote)
+}
+
+func (repo *PostMemoryRepository) DownvoteComment(_ context.Context, postID, commID, userID string) (*Post, error) {
+	return repo.voteComment(postID, commID, userID, (*VoteList).Downvote)
+}
+
+func (repo *PostMemoryRepository) UnvoteComment(_ context.Context, postID, commID, userID string) (*Post, error) {
+	return repo.voteComment(postID, commID, userID, (*VoteList).Unvote)
+}
+
+func (repo *PostMemoryRepository) voteComment(postID, commID, userID string, vote func(l *VoteList, userID string) error) (*Post, error) {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+	for _, post := range repo.data {
+		if post.ID == postID {
+			comm, err := post.Comments.find(commID)
+			if err != nil {
+				return nil, err
+			}
+			if err = comm.vote(userID, vote); err != nil {
+				return nil, err
+			}
+			return post, nil
+		}
+	}
+	return nil, errs.MsgError{Msg: "post not found", Status: 404}
+}
+
+func (repo *PostMemoryRepository) GetByUser(_ context.Context, username string, rank Ranking, page Page, _ string) ([]*Post, string, error) {
 	result := make([]*Post, 0, 1000)
 	repo.mu.RLock()
 	for _, post := range repo.data {
@@ -223,8 +257,5 @@ func (repo *PostMemoryRepository) GetByUser(username string) ([]*Post, error) {
 		}
 	}
 	repo.mu.RUnlock()
-	sort.Slice(result, func(i, j int) bool {
-		return result[i].Created.After(result[j].Created)
-	})
-	return result, nil
+	return rank.paginate(result, page)
 }
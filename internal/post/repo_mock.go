@@ -5,6 +5,7 @@
 package post
 
 import (
+	context "context"
 	reflect "reflect"
 
 	gomock "github.com/golang/mock/gomock"
@@ -34,164 +35,242 @@ func (m *MockPostRepo) EXPECT() *MockPostRepoMockRecorder {
 }
 
 // AddComment mocks base method.
-func (m *MockPostRepo) AddComment(postID string, comment *Comment) (*Post, error) {
+func (m *MockPostRepo) AddComment(ctx context.Context, postID string, comment *Comment) (*Post, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "AddComment", postID, comment)
+	ret := m.ctrl.Call(m, "AddComment", ctx, postID, comment)
 	ret0, _ := ret[0].(*Post)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // AddComment indicates an expected call of AddComment.
-func (mr *MockPostRepoMockRecorder) AddComment(postID, comment interface{}) *gomock.Call {
+func (mr *MockPostRepoMockRecorder) AddComment(ctx, postID, comment interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddComment", reflect.TypeOf((*MockPostRepo)(nil).AddComment), postID, comment)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddComment", reflect.TypeOf((*MockPostRepo)(nil).AddComment), ctx, postID, comment)
 }
 
 // AddPost mocks base method.
-func (m *MockPostRepo) AddPost(post *Post) error {
+func (m *MockPostRepo) AddPost(ctx context.Context, post *Post) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "AddPost", post)
+	ret := m.ctrl.Call(m, "AddPost", ctx, post)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // AddPost indicates an expected call of AddPost.
-func (mr *MockPostRepoMockRecorder) AddPost(post interface{}) *gomock.Call {
+func (mr *MockPostRepoMockRecorder) AddPost(ctx, post interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddPost", reflect.TypeOf((*MockPostRepo)(nil).AddPost), post)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddPost", reflect.TypeOf((*MockPostRepo)(nil).AddPost), ctx, post)
 }
 
 // DeleteComment mocks base method.
-func (m *MockPostRepo) DeleteComment(postID, commentID, userID string) (*Post, error) {
+func (m *MockPostRepo) DeleteComment(ctx context.Context, postID, commentID, userID string, allow CommentPermit) (*Post, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "DeleteComment", postID, commentID, userID)
+	ret := m.ctrl.Call(m, "DeleteComment", ctx, postID, commentID, userID, allow)
 	ret0, _ := ret[0].(*Post)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // DeleteComment indicates an expected call of DeleteComment.
-func (mr *MockPostRepoMockRecorder) DeleteComment(postID, commentID, userID interface{}) *gomock.Call {
+func (mr *MockPostRepoMockRecorder) DeleteComment(ctx, postID, commentID, userID, allow interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteComment", reflect.TypeOf((*MockPostRepo)(nil).DeleteComment), postID, commentID, userID)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteComment", reflect.TypeOf((*MockPostRepo)(nil).DeleteComment), ctx, postID, commentID, userID, allow)
 }
 
 // DeletePost mocks base method.
-func (m *MockPostRepo) DeletePost(postID, userID string) error {
+func (m *MockPostRepo) DeletePost(ctx context.Context, postID string, allow PostPermit) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "DeletePost", postID, userID)
+	ret := m.ctrl.Call(m, "DeletePost", ctx, postID, allow)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // DeletePost indicates an expected call of DeletePost.
-func (mr *MockPostRepoMockRecorder) DeletePost(postID, userID interface{}) *gomock.Call {
+func (mr *MockPostRepoMockRecorder) DeletePost(ctx, postID, allow interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeletePost", reflect.TypeOf((*MockPostRepo)(nil).DeletePost), postID, userID)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeletePost", reflect.TypeOf((*MockPostRepo)(nil).DeletePost), ctx, postID, allow)
+}
+
+// DownvoteComment mocks base method.
+func (m *MockPostRepo) DownvoteComment(ctx context.Context, postID, commentID, userID string) (*Post, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DownvoteComment", ctx, postID, commentID, userID)
+	ret0, _ := ret[0].(*Post)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// DownvoteComment indicates an expected call of DownvoteComment.
+func (mr *MockPostRepoMockRecorder) DownvoteComment(ctx, postID, commentID, userID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DownvoteComment", reflect.TypeOf((*MockPostRepo)(nil).DownvoteComment), ctx, postID, commentID, userID)
 }
 
 // DownvotePost mocks base method.
-func (m *MockPostRepo) DownvotePost(postID, userID string) (*Post, error) {
+func (m *MockPostRepo) DownvotePost(ctx context.Context, postID, userID string) (*Post, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "DownvotePost", postID, userID)
+	ret := m.ctrl.Call(m, "DownvotePost", ctx, postID, userID)
 	ret0, _ := ret[0].(*Post)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // DownvotePost indicates an expected call of DownvotePost.
-func (mr *MockPostRepoMockRecorder) DownvotePost(postID, userID interface{}) *gomock.Call {
+func (mr *MockPostRepoMockRecorder) DownvotePost(ctx, postID, userID interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DownvotePost", reflect.TypeOf((*MockPostRepo)(nil).DownvotePost), postID, userID)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DownvotePost", reflect.TypeOf((*MockPostRepo)(nil).DownvotePost), ctx, postID, userID)
 }
 
 // GetAll mocks base method.
-func (m *MockPostRepo) GetAll() ([]*Post, error) {
+func (m *MockPostRepo) GetAll(ctx context.Context, rank Ranking, page Page, viewerID string) ([]*Post, string, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetAll")
+	ret := m.ctrl.Call(m, "GetAll", ctx, rank, page, viewerID)
 	ret0, _ := ret[0].([]*Post)
-	ret1, _ := ret[1].(error)
-	return ret0, ret1
+	ret1, _ := ret[1].(string)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
 }
 
 // GetAll indicates an expected call of GetAll.
-func (mr *MockPostRepoMockRecorder) GetAll() *gomock.Call {
+func (mr *MockPostRepoMockRecorder) GetAll(ctx, rank, page, viewerID interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAll", reflect.TypeOf((*MockPostRepo)(nil).GetAll))
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAll", reflect.TypeOf((*MockPostRepo)(nil).GetAll), ctx, rank, page, viewerID)
 }
 
 // GetByCategory mocks base method.
-func (m *MockPostRepo) GetByCategory(category string) ([]*Post, error) {
+func (m *MockPostRepo) GetByCategory(ctx context.Context, category string, rank Ranking, page Page, viewerID string) ([]*Post, string, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetByCategory", category)
+	ret := m.ctrl.Call(m, "GetByCategory", ctx, category, rank, page, viewerID)
 	ret0, _ := ret[0].([]*Post)
-	ret1, _ := ret[1].(error)
-	return ret0, ret1
+	ret1, _ := ret[1].(string)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
 }
 
 // GetByCategory indicates an expected call of GetByCategory.
-func (mr *MockPostRepoMockRecorder) GetByCategory(category interface{}) *gomock.Call {
+func (mr *MockPostRepoMockRecorder) GetByCategory(ctx, category, rank, page, viewerID interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetByCategory", reflect.TypeOf((*MockPostRepo)(nil).GetByCategory), category)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetByCategory", reflect.TypeOf((*MockPostRepo)(nil).GetByCategory), ctx, category, rank, page, viewerID)
 }
 
 // GetByID mocks base method.
-func (m *MockPostRepo) GetByID(postID string) (*Post, error) {
+func (m *MockPostRepo) GetByID(ctx context.Context, postID, viewerID string) (*Post, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetByID", postID)
+	ret := m.ctrl.Call(m, "GetByID", ctx, postID, viewerID)
 	ret0, _ := ret[0].(*Post)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // GetByID indicates an expected call of GetByID.
-func (mr *MockPostRepoMockRecorder) GetByID(postID interface{}) *gomock.Call {
+func (mr *MockPostRepoMockRecorder) GetByID(ctx, postID, viewerID interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetByID", reflect.TypeOf((*MockPostRepo)(nil).GetByID), postID)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetByID", reflect.TypeOf((*MockPostRepo)(nil).GetByID), ctx, postID, viewerID)
 }
 
 // GetByUser mocks base method.
-func (m *MockPostRepo) GetByUser(username string) ([]*Post, error) {
+func (m *MockPostRepo) GetByUser(ctx context.Context, username string, rank Ranking, page Page, viewerID string) ([]*Post, string, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetByUser", username)
+	ret := m.ctrl.Call(m, "GetByUser", ctx, username, rank, page, viewerID)
 	ret0, _ := ret[0].([]*Post)
+	ret1, _ := ret[1].(string)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// GetByUser indicates an expected call of GetByUser.
+func (mr *MockPostRepoMockRecorder) GetByUser(ctx, username, rank, page, viewerID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetByUser", reflect.TypeOf((*MockPostRepo)(nil).GetByUser), ctx, username, rank, page, viewerID)
+}
+
+// GetRevisions mocks base method.
+func (m *MockPostRepo) GetRevisions(ctx context.Context, postID string) ([]Revision, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetRevisions", ctx, postID)
+	ret0, _ := ret[0].([]Revision)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
-// GetByUser indicates an expected call of GetByUser.
-func (mr *MockPostRepoMockRecorder) GetByUser(username interface{}) *gomock.Call {
+// GetRevisions indicates an expected call of GetRevisions.
+func (mr *MockPostRepoMockRecorder) GetRevisions(ctx, postID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetRevisions", reflect.TypeOf((*MockPostRepo)(nil).GetRevisions), ctx, postID)
+}
+
+// UnvoteComment mocks base method.
+func (m *MockPostRepo) UnvoteComment(ctx context.Context, postID, commentID, userID string) (*Post, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UnvoteComment", ctx, postID, commentID, userID)
+	ret0, _ := ret[0].(*Post)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UnvoteComment indicates an expected call of UnvoteComment.
+func (mr *MockPostRepoMockRecorder) UnvoteComment(ctx, postID, commentID, userID interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetByUser", reflect.TypeOf((*MockPostRepo)(nil).GetByUser), username)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UnvoteComment", reflect.TypeOf((*MockPostRepo)(nil).UnvoteComment), ctx, postID, commentID, userID)
 }
 
 // UnvotePost mocks base method.
-func (m *MockPostRepo) UnvotePost(postID, userID string) (*Post, error) {
+func (m *MockPostRepo) UnvotePost(ctx context.Context, postID, userID string) (*Post, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "UnvotePost", postID, userID)
+	ret := m.ctrl.Call(m, "UnvotePost", ctx, postID, userID)
 	ret0, _ := ret[0].(*Post)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // UnvotePost indicates an expected call of UnvotePost.
-func (mr *MockPostRepoMockRecorder) UnvotePost(postID, userID interface{}) *gomock.Call {
+func (mr *MockPostRepoMockRecorder) UnvotePost(ctx, postID, userID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UnvotePost", reflect.TypeOf((*MockPostRepo)(nil).UnvotePost), ctx, postID, userID)
+}
+
+// UpdatePost mocks base method.
+func (m *MockPostRepo) UpdatePost(ctx context.Context, postID, userID string, upd PostEdit) (*Post, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdatePost", ctx, postID, userID, upd)
+	ret0, _ := ret[0].(*Post)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UpdatePost indicates an expected call of UpdatePost.
+func (mr *MockPostRepoMockRecorder) UpdatePost(ctx, postID, userID, upd interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdatePost", reflect.TypeOf((*MockPostRepo)(nil).UpdatePost), ctx, postID, userID, upd)
+}
+
+// UpvoteComment mocks base method.
+func (m *MockPostRepo) UpvoteComment(ctx context.Context, postID, commentID, userID string) (*Post, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpvoteComment", ctx, postID, commentID, userID)
+	ret0, _ := ret[0].(*Post)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UpvoteComment indicates an expected call of UpvoteComment.
+func (mr *MockPostRepoMockRecorder) UpvoteComment(ctx, postID, commentID, userID interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UnvotePost", reflect.TypeOf((*MockPostRepo)(nil).UnvotePost), postID, userID)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpvoteComment", reflect.TypeOf((*MockPostRepo)(nil).UpvoteComment), ctx, postID, commentID, userID)
 }
 
 // UpvotePost mocks base method.
-func (m *MockPostRepo) UpvotePost(postID, userID string) (*Post, error) {
+func (m *MockPostRepo) UpvotePost(ctx context.Context, postID, userID string) (*Post, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "UpvotePost", postID, userID)
+	ret := m.ctrl.Call(m, "UpvotePost", ctx, postID, userID)
 	ret0, _ := ret[0].(*Post)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // UpvotePost indicates an expected call of UpvotePost.
-func (mr *MockPostRepoMockRecorder) UpvotePost(postID, userID interface{}) *gomock.Call {
+func (mr *MockPostRepoMockRecorder) UpvotePost(ctx, postID, userID interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpvotePost", reflect.TypeOf((*MockPostRepo)(nil).UpvotePost), postID, userID)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpvotePost", reflect.TypeOf((*MockPostRepo)(nil).UpvotePost), ctx, postID, userID)
 }
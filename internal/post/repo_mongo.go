@@ -4,9 +4,11 @@ import (
 	"context"
 	"errors"
 	"fmt"
-	"sort"
+	"time"
 
+	"asperitas/internal/config"
 	"asperitas/internal/errs"
+	"asperitas/pkg/timeout"
 
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
@@ -14,36 +16,98 @@ import (
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
-var emptyCtx = context.Background()
-
+// Голоса и комментарии хранятся в отдельных коллекциях, на посте остаются только счетчики
 type PostRepositoryMongo struct {
-	coll MongoCollection
+	coll      MongoCollection
+	votes     MongoCollection
+	comments  MongoCollection
+	revisions MongoCollection
+	timeout   time.Duration
 }
 
-func NewRepoMongo(addr string) (*PostRepositoryMongo, error) {
-	mongoConn, err := mongo.Connect(emptyCtx, options.Client().ApplyURI(addr))
-	if err != nil {
-		return nil, fmt.Errorf("mongo connect err: %w", err)
+// Голос в коллекции votes; у голоса за сам пост commentid пустой
+type voteDoc struct {
+	PostID    string    `bson:"postid"`
+	CommentID string    `bson:"commentid"`
+	UserID    string    `bson:"userid"`
+	Value     VoteValue `bson:"vote"`
+}
+
+type commentDoc struct {
+	PostID  string `bson:"postid"`
+	Comment `bson:",inline"`
+}
+
+// История правок хранится отдельно от поста, чтобы документ поста не рос с каждой правкой
+type revisionDoc struct {
+	PostID   string `bson:"postid"`
+	Revision `bson:",inline"`
+}
+
+// Подключение к базе общее с другими репозиториями и закрывается его владельцем.
+// Таймаут из конфигурации ограничивает каждую операцию репозитория, включая создание индексов при запуске.
+func NewRepoMongo(mongoDB *mongo.Database, cfg config.Mongo) (*PostRepositoryMongo, error) {
+	ctx, cancel := timeout.Context(context.Background(), cfg.Timeout)
+	defer cancel()
+	mongoColl := mongoDB.Collection(cfg.Collections.Posts)
+	if _, err := mongoColl.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "id", Value: 1}}, Options: options.Index().SetUnique(true)},
+	}); err != nil {
+		return nil, fmt.Errorf("mongo create indexes err: %w", err)
+	}
+	if _, err := mongoColl.Indexes().CreateMany(ctx, rankingIndexes()); err != nil {
+		return nil, fmt.Errorf("mongo create indexes err: %w", err)
+	}
+	votesColl := mongoDB.Collection(cfg.Collections.Votes)
+	if _, err := votesColl.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{
+			{Key: "postid", Value: 1},
+			{Key: "commentid", Value: 1},
+			{Key: "userid", Value: 1},
+		},
+		Options: options.Index().SetUnique(true),
+	}); err != nil {
+		return nil, fmt.Errorf("mongo create indexes err: %w", err)
+	}
+	commentsColl := mongoDB.Collection(cfg.Collections.Comments)
+	if _, err := commentsColl.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "postid", Value: 1}, {Key: "id", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		{Keys: bson.D{{Key: "postid", Value: 1}, {Key: "created", Value: 1}}},
+	}); err != nil {
+		return nil, fmt.Errorf("mongo create indexes err: %w", err)
 	}
-	mongoColl := mongoConn.Database("vk-go").Collection("posts")
-	mongoCollAbstract := newMongoCollection(mongoColl)
-	return &PostRepositoryMongo{coll: mongoCollAbstract}, nil
+	revisionsColl := mongoDB.Collection(cfg.Collections.Revisions)
+	if _, err := revisionsColl.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "postid", Value: 1}, {Key: "created", Value: 1}},
+	}); err != nil {
+		return nil, fmt.Errorf("mongo create indexes err: %w", err)
+	}
+	return &PostRepositoryMongo{
+		coll:      newMongoCollection(mongoColl),
+		votes:     newMongoCollection(votesColl),
+		comments:  newMongoCollection(commentsColl),
+		revisions: newMongoCollection(revisionsColl),
+		timeout:   cfg.Timeout,
+	}, nil
 }
 
-func findPosts(coll MongoCollection, filter primitive.M) ([]*Post, error) {
-	cursor, err := coll.Find(emptyCtx, filter)
+func findPosts(ctx context.Context, coll MongoCollection, filter primitive.M, opts ...*options.FindOptions) ([]*Post, error) {
+	cursor, err := coll.Find(ctx, filter, opts...)
 	if err != nil {
 		return nil, fmt.Errorf("mongo find err: %w", err)
 	}
 	posts := []*Post{}
-	if err = cursor.All(emptyCtx, &posts); err != nil {
+	if err = cursor.All(ctx, &posts); err != nil {
 		return nil, fmt.Errorf("mongo all err: %w", err)
 	}
 	return posts, nil
 }
 
-func findPost(coll MongoCollection, id string) (*Post, error) {
-	res := coll.FindOne(emptyCtx, bson.M{"id": id})
+func findPost(ctx context.Context, coll MongoCollection, id string) (*Post, error) {
+	res := coll.FindOne(ctx, bson.M{"id": id})
 	if errors.Is(res.Err(), mongo.ErrNoDocuments) {
 		return nil, errs.MsgError{Msg: "post not found", Status: 404}
 	}
@@ -54,183 +118,545 @@ func findPost(coll MongoCollection, id string) (*Post, error) {
 	return post, nil
 }
 
-func (repo *PostRepositoryMongo) GetAll() ([]*Post, error) {
-	posts, err := findPosts(repo.coll, bson.M{})
+// Сортировка и ограничение выполняются в самой базе: запрашивается на один пост больше
+// лимита, чтобы понять, есть ли следующая страница. Без лимита отдается вся выборка.
+func findPage(ctx context.Context, coll MongoCollection, filter primitive.M, rank Ranking, page Page) ([]*Post, string, error) {
+	order := rank.ordering()
+	if since := rank.since(time.Now()); !since.IsZero() {
+		filter = bson.M{"$and": bson.A{filter, bson.M{"created": bson.M{"$gte": since}}}}
+	}
+	if page.After != "" {
+		last, err := decodeCursor(page.After)
+		if err != nil {
+			return nil, "", err
+		}
+		filter = bson.M{"$and": bson.A{filter, order.mongoAfter(last)}}
+	}
+	limit := page.limit()
+	opts := options.Find().SetSort(order.mongoSort())
+	if limit > 0 {
+		opts.SetLimit(int64(limit + 1))
+	}
+	posts, err := findPosts(ctx, coll, filter, opts)
 	if err != nil {
-		return nil, err
+		return nil, "", err
 	}
-	sort.Slice(posts, func(i, j int) bool {
-		if posts[i].Score == posts[j].Score {
-			return posts[i].Created.Before(posts[j].Created)
+	return cutPage(posts, limit)
+}
+
+// Индексы под сортировку режимов ленты: общей и по категории. Режимам с фиксированным окном
+// хватает индекса по дате создания, а посты автора выбираются по автору и сортируются в памяти.
+func rankingIndexes() []mongo.IndexModel {
+	models := make([]mongo.IndexModel, 0, 2*len(rankings)+1)
+	for _, spec := range rankings {
+		if spec.window != 0 {
+			continue
 		}
-		return posts[i].Score > posts[j].Score
-	})
-	return posts, nil
+		keys := spec.order.mongoSort()
+		models = append(models,
+			mongo.IndexModel{Keys: keys},
+			mongo.IndexModel{Keys: append(bson.D{{Key: "category", Value: 1}}, keys...)},
+		)
+	}
+	return append(models, mongo.IndexModel{Keys: append(bson.D{{Key: "author.username", Value: 1}}, byNewest.mongoSort()...)})
 }
 
-func (repo *PostRepositoryMongo) AddPost(p *Post) error {
-	if _, err := repo.coll.InsertOne(emptyCtx, p); err != nil {
+func (repo *PostRepositoryMongo) GetAll(ctx context.Context, rank Ranking, page Page, viewerID string) ([]*Post, string, error) {
+	return repo.loadPage(ctx, bson.M{}, rank, page, viewerID)
+}
+
+func (repo *PostRepositoryMongo) AddPost(ctx context.Context, p *Post) error {
+	ctx, cancel := timeout.Context(ctx, repo.timeout)
+	defer cancel()
+	if _, err := repo.coll.InsertOne(ctx, p); err != nil {
+		return fmt.Errorf("mongo insert one err: %w", err)
+	}
+	if _, err := repo.votes.InsertOne(ctx, voteDoc{PostID: p.ID, UserID: p.Author.ID, Value: Like}); err != nil {
 		return fmt.Errorf("mongo insert one err: %w", err)
 	}
 	return nil
 }
 
-func (repo *PostRepositoryMongo) GetByCategory(categoryName string) ([]*Post, error) {
-	posts, err := findPosts(repo.coll, bson.M{"category": categoryName})
-	if err != nil {
-		return nil, err
-	}
-	sort.Slice(posts, func(i, j int) bool {
-		if posts[i].Score == posts[j].Score {
-			return posts[i].Created.Before(posts[j].Created)
-		}
-		return posts[i].Score > posts[j].Score
-	})
-	return posts, nil
+func (repo *PostRepositoryMongo) GetByCategory(ctx context.Context, categoryName string, rank Ranking, page Page, viewerID string) ([]*Post, string, error) {
+	return repo.loadPage(ctx, bson.M{"category": categoryName}, rank, page, viewerID)
 }
 
-func (repo *PostRepositoryMongo) GetByID(id string) (*Post, error) {
-	p, err := findPost(repo.coll, id)
+func (repo *PostRepositoryMongo) GetByID(ctx context.Context, id, viewerID string) (*Post, error) {
+	ctx, cancel := timeout.Context(ctx, repo.timeout)
+	defer cancel()
+	p, err := findPost(ctx, repo.coll, id)
 	if err != nil {
 		return nil, err
 	}
 	p.Views++
 	if _, err := repo.coll.UpdateOne(
-		emptyCtx,
+		ctx,
 		bson.M{"id": id},
-		bson.M{"$set": bson.M{"views": p.Views}},
+		bson.M{"$inc": bson.M{"views": 1}},
 	); err != nil {
 		return nil, fmt.Errorf("mongo update one err: %w", err)
 	}
+	if err = repo.attachChildren(ctx, viewerID, p); err != nil {
+		return nil, err
+	}
 	return p, nil
 }
 
-func (repo *PostRepositoryMongo) DeletePost(postID, userID string) error {
-	p, err := findPost(repo.coll, postID)
+// Транзакций на одиночном сервере монги нет, поэтому пост удаляется последним: если удаление
+// оборвется раньше, пост останется на месте, и повторный запрос дочистит остальное
+func (repo *PostRepositoryMongo) DeletePost(ctx context.Context, postID string, allow PostPermit) error {
+	ctx, cancel := timeout.Context(ctx, repo.timeout)
+	defer cancel()
+	p, err := findPost(ctx, repo.coll, postID)
 	if err != nil {
 		return err
 	}
-	if p.Author.ID != userID {
+	if !allow(p) {
 		return errs.MsgError{Msg: "unauthorized", Status: 401}
 	}
-	if _, err := repo.coll.DeleteOne(emptyCtx, bson.M{"id": postID}); err != nil {
+	for _, coll := range []MongoCollection{repo.comments, repo.votes, repo.revisions} {
+		if _, err := coll.DeleteMany(ctx, bson.M{"postid": postID}); err != nil {
+			return fmt.Errorf("mongo delete many err: %w", err)
+		}
+	}
+	if _, err := repo.coll.DeleteOne(ctx, bson.M{"id": postID}); err != nil {
 		return fmt.Errorf("mongo delete one err: %w", err)
 	}
 	return errs.MsgError{Msg: "success", Status: 200}
 }
 
-func (repo *PostRepositoryMongo) AddComment(postID string, comm *Comment) (*Post, error) {
-	p, err := findPost(repo.coll, postID)
+// Правка записывается одной атомарной операцией, а в историю попадает версия,
+// которую вернула база, а не прочитанная заранее: параллельная правка ее не потеряет
+func (repo *PostRepositoryMongo) UpdatePost(ctx context.Context, postID, userID string, upd PostEdit) (*Post, error) {
+	ctx, cancel := timeout.Context(ctx, repo.timeout)
+	defer cancel()
+	p, err := findPost(ctx, repo.coll, postID)
 	if err != nil {
 		return nil, err
 	}
-	if err := p.Comments.Add(comm); err != nil {
+	if p.Author.ID != userID {
+		return nil, errs.MsgError{Msg: "unauthorized", Status: 401}
+	}
+	t := time.Now()
+	res := repo.coll.FindOneAndUpdate(
+		ctx,
+		bson.M{"id": postID, "author.id": userID},
+		bson.M{"$set": p.applyEdit(upd, t)},
+		options.FindOneAndUpdate().SetReturnDocument(options.Before),
+	)
+	if errors.Is(res.Err(), mongo.ErrNoDocuments) {
+		return nil, errs.MsgError{Msg: "post not found", Status: 404}
+	}
+	if err = res.Err(); err != nil {
+		return nil, fmt.Errorf("mongo find one and update err: %w", err)
+	}
+	p = &Post{}
+	if err = res.Decode(p); err != nil {
+		return nil, fmt.Errorf("mongo decode err: %w", err)
+	}
+	rev := p.revision()
+	edited := *p
+	edited.applyEdit(upd, t)
+	if _, err = repo.revisions.InsertOne(ctx, revisionDoc{PostID: postID, Revision: rev}); err != nil {
+		return nil, repo.revertEdit(p, &edited, fmt.Errorf("mongo insert one err: %w", err))
+	}
+	p = &edited
+	if err = repo.attachChildren(ctx, userID, p); err != nil {
 		return nil, err
 	}
+	return p, nil
+}
+
+// Правка без записи в истории не сохраняется: пост возвращается к прежней версии,
+// если его с тех пор не успели изменить снова. Откат выполняется и при отмененном запросе.
+func (repo *PostRepositoryMongo) revertEdit(before, edited *Post, cause error) error {
+	ctx, cancel := timeout.Context(context.Background(), repo.timeout)
+	defer cancel()
 	if _, err := repo.coll.UpdateOne(
-		emptyCtx,
-		bson.M{"id": postID},
-		bson.M{"$set": bson.M{"comments": p.Comments}},
+		ctx,
+		bson.M{"id": before.ID, "editedformat": edited.EditedFormat},
+		bson.M{"$set": bson.M{
+			"title":        before.Title,
+			"url":          before.URL,
+			"text":         before.Text,
+			"edited":       before.Edited,
+			"editedformat": before.EditedFormat,
+		}},
 	); err != nil {
-		return nil, fmt.Errorf("mongo update one err: %w", err)
+		return fmt.Errorf("%w; revert edit err: %s", cause, err)
 	}
-	return p, nil
+	return cause
 }
 
-func (repo *PostRepositoryMongo) DeleteComment(postID, commID, userID string) (*Post, error) {
-	p, err := findPost(repo.coll, postID)
+func (repo *PostRepositoryMongo) GetRevisions(ctx context.Context, postID string) ([]Revision, error) {
+	ctx, cancel := timeout.Context(ctx, repo.timeout)
+	defer cancel()
+	if _, err := findPost(ctx, repo.coll, postID); err != nil {
+		return nil, err
+	}
+	cursor, err := repo.revisions.Find(
+		ctx,
+		bson.M{"postid": postID},
+		options.Find().SetSort(bson.D{{Key: "created", Value: 1}}),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("mongo find err: %w", err)
+	}
+	docs := []revisionDoc{}
+	if err = cursor.All(ctx, &docs); err != nil {
+		return nil, fmt.Errorf("mongo all err: %w", err)
+	}
+	revs := make([]Revision, 0, len(docs))
+	for _, doc := range docs {
+		revs = append(revs, doc.Revision)
+	}
+	return revs, nil
+}
+
+// Комментарий, его голос и счетчик пишутся по отдельности; при сбое уже записанное
+// удаляется, чтобы не остался комментарий, не учтенный в счетчике поста
+func (repo *PostRepositoryMongo) AddComment(ctx context.Context, postID string, comm *Comment) (*Post, error) {
+	ctx, cancel := timeout.Context(ctx, repo.timeout)
+	defer cancel()
+	p, err := repo.loadPost(ctx, postID, comm.Author.ID)
 	if err != nil {
 		return nil, err
 	}
-	if err := p.Comments.Delete(commID, userID); err != nil {
+	if err = p.Comments.Add(comm); err != nil {
 		return nil, err
 	}
-	if _, err := repo.coll.UpdateOne(
-		emptyCtx,
+	if _, err = repo.comments.InsertOne(ctx, commentDoc{PostID: postID, Comment: *comm}); err != nil {
+		return nil, fmt.Errorf("mongo insert one err: %w", err)
+	}
+	if _, err = repo.votes.InsertOne(ctx, voteDoc{
+		PostID:    postID,
+		CommentID: comm.ID,
+		UserID:    comm.Author.ID,
+		Value:     Like,
+	}); err != nil {
+		return nil, repo.removeComment(postID, comm.ID, fmt.Errorf("mongo insert one err: %w", err))
+	}
+	if _, err = repo.coll.UpdateOne(
+		ctx,
 		bson.M{"id": postID},
-		bson.M{"$set": bson.M{"comments": p.Comments}},
+		bson.M{"$inc": bson.M{"commentscount": 1}},
 	); err != nil {
-		return nil, fmt.Errorf("mongo update one err: %w", err)
+		return nil, repo.removeComment(postID, comm.ID, fmt.Errorf("mongo update one err: %w", err))
 	}
+	p.CommentsCount++
 	return p, nil
 }
 
-func (repo *PostRepositoryMongo) UpvotePost(postID, userID string) (*Post, error) {
-	p, err := findPost(repo.coll, postID)
+// Откатывает недописанный AddComment, в том числе при отмененном запросе
+func (repo *PostRepositoryMongo) removeComment(postID, commID string, cause error) error {
+	ctx, cancel := timeout.Context(context.Background(), repo.timeout)
+	defer cancel()
+	if _, err := repo.votes.DeleteMany(ctx, bson.M{"postid": postID, "commentid": commID}); err != nil {
+		return fmt.Errorf("%w; remove comment votes err: %s", cause, err)
+	}
+	if _, err := repo.comments.DeleteOne(ctx, bson.M{"postid": postID, "id": commID}); err != nil {
+		return fmt.Errorf("%w; remove comment err: %s", cause, err)
+	}
+	return cause
+}
+
+// Решение, удалить комментарий или заменить его заглушкой, принимает CommentList.Delete;
+// в базу переносится только разница
+func (repo *PostRepositoryMongo) DeleteComment(ctx context.Context, postID, commID, userID string, allow CommentPermit) (*Post, error) {
+	ctx, cancel := timeout.Context(ctx, repo.timeout)
+	defer cancel()
+	p, err := repo.loadPost(ctx, postID, userID)
 	if err != nil {
 		return nil, err
 	}
-	if err := p.Votes.Upvote(userID); err != nil {
+	before := make(CommentList, len(p.Comments))
+	copy(before, p.Comments)
+	if err = p.Comments.Delete(commID, func(c *Comment) bool { return allow(p, c) }); err != nil {
 		return nil, err
 	}
-	p.updatePostScore()
-	if _, err := repo.coll.UpdateOne(
-		emptyCtx,
+	removed := bson.A{}
+	for _, comm := range before {
+		if p.Comments.index(comm.ID) < 0 {
+			removed = append(removed, comm.ID)
+			continue
+		}
+		if comm.ID == commID && comm.Deleted {
+			if _, err = repo.comments.UpdateOne(
+				ctx,
+				bson.M{"postid": postID, "id": commID},
+				bson.M{"$set": bson.M{"body": comm.Body, "author": comm.Author, "deleted": true}},
+			); err != nil {
+				return nil, fmt.Errorf("mongo update one err: %w", err)
+			}
+		}
+	}
+	if len(removed) == 0 {
+		return p, nil
+	}
+	if _, err = repo.comments.DeleteMany(ctx, bson.M{"postid": postID, "id": bson.M{"$in": removed}}); err != nil {
+		return nil, fmt.Errorf("mongo delete many err: %w", err)
+	}
+	if _, err = repo.votes.DeleteMany(ctx, bson.M{"postid": postID, "commentid": bson.M{"$in": removed}}); err != nil {
+		return nil, fmt.Errorf("mongo delete many err: %w", err)
+	}
+	if _, err = repo.coll.UpdateOne(
+		ctx,
 		bson.M{"id": postID},
-		bson.M{"$set": bson.M{
-			"votes":        p.Votes,
-			"score":        p.Score,
-			"likespercent": p.LikesPercent,
-		}},
+		bson.M{"$inc": bson.M{"commentscount": -len(removed)}},
 	); err != nil {
 		return nil, fmt.Errorf("mongo update one err: %w", err)
 	}
+	p.CommentsCount -= len(removed)
 	return p, nil
 }
 
-func (repo *PostRepositoryMongo) DownvotePost(postID, userID string) (*Post, error) {
-	p, err := findPost(repo.coll, postID)
+func (repo *PostRepositoryMongo) UpvotePost(ctx context.Context, postID, userID string) (*Post, error) {
+	return repo.votePost(ctx, postID, userID, Like)
+}
+
+func (repo *PostRepositoryMongo) DownvotePost(ctx context.Context, postID, userID string) (*Post, error) {
+	return repo.votePost(ctx, postID, userID, Dislike)
+}
+
+func (repo *PostRepositoryMongo) UnvotePost(ctx context.Context, postID, userID string) (*Post, error) {
+	return repo.votePost(ctx, postID, userID, 0)
+}
+
+func (repo *PostRepositoryMongo) votePost(ctx context.Context, postID, userID string, value VoteValue) (*Post, error) {
+	ctx, cancel := timeout.Context(ctx, repo.timeout)
+	defer cancel()
+	if _, err := findPost(ctx, repo.coll, postID); err != nil {
+		return nil, err
+	}
+	old, err := repo.putVote(ctx, postID, "", userID, value)
 	if err != nil {
 		return nil, err
 	}
-	if err := p.Votes.Downvote(userID); err != nil {
+	if likes, total := voteDelta(old, value); likes != 0 || total != 0 {
+		if err = repo.shiftPostVotes(ctx, postID, likes, total); err != nil {
+			return nil, err
+		}
+	}
+	return repo.loadPost(ctx, postID, userID)
+}
+
+func (repo *PostRepositoryMongo) UpvoteComment(ctx context.Context, postID, commID, userID string) (*Post, error) {
+	return repo.voteComment(ctx, postID, commID, userID, Like)
+}
+
+func (repo *PostRepositoryMongo) DownvoteComment(ctx context.Context, postID, commID, userID string) (*Post, error) {
+	return repo.voteComment(ctx, postID, commID, userID, Dislike)
+}
+
+func (repo *PostRepositoryMongo) UnvoteComment(ctx context.Context, postID, commID, userID string) (*Post, error) {
+	return repo.voteComment(ctx, postID, commID, userID, 0)
+}
+
+func (repo *PostRepositoryMongo) voteComment(ctx context.Context, postID, commID, userID string, value VoteValue) (*Post, error) {
+	ctx, cancel := timeout.Context(ctx, repo.timeout)
+	defer cancel()
+	res := repo.comments.FindOne(ctx, bson.M{"postid": postID, "id": commID, "deleted": false})
+	if errors.Is(res.Err(), mongo.ErrNoDocuments) {
+		return nil, errs.MsgError{Msg: "comment not found", Status: 404}
+	}
+	if err := res.Err(); err != nil {
+		return nil, fmt.Errorf("mongo find one err: %w", err)
+	}
+	old, err := repo.putVote(ctx, postID, commID, userID, value)
+	if err != nil {
 		return nil, err
 	}
-	p.updatePostScore()
-	if _, err := repo.coll.UpdateOne(
-		emptyCtx,
+	if likes, total := voteDelta(old, value); likes != 0 || total != 0 {
+		if err = repo.shiftCommentVotes(ctx, postID, commID, likes, total); err != nil {
+			return nil, err
+		}
+	}
+	return repo.loadPost(ctx, postID, userID)
+}
+
+func (repo *PostRepositoryMongo) GetByUser(ctx context.Context, username string, rank Ranking, page Page, viewerID string) ([]*Post, string, error) {
+	return repo.loadPage(ctx, bson.M{"author.username": username}, rank, page, viewerID)
+}
+
+// Записывает голос пользователя одной атомарной операцией и возвращает прежний голос;
+// нулевое значение означает отсутствие голоса
+func (repo *PostRepositoryMongo) putVote(ctx context.Context, postID, commID, userID string, value VoteValue) (VoteValue, error) {
+	filter := bson.M{"postid": postID, "commentid": commID, "userid": userID}
+	var res MongoSingleResult
+	if value == 0 {
+		res = repo.votes.FindOneAndDelete(ctx, filter)
+	} else {
+		res = repo.votes.FindOneAndUpdate(
+			ctx,
+			filter,
+			bson.M{"$set": bson.M{"vote": value}},
+			options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.Before),
+		)
+	}
+	if errors.Is(res.Err(), mongo.ErrNoDocuments) {
+		return 0, nil
+	}
+	if err := res.Err(); err != nil {
+		return 0, fmt.Errorf("mongo put vote err: %w", err)
+	}
+	old := voteDoc{}
+	if err := res.Decode(&old); err != nil {
+		return 0, fmt.Errorf("mongo decode err: %w", err)
+	}
+	return old.Value, nil
+}
+
+// На сколько смена голоса сдвигает число голосов за и общее число голосов
+func voteDelta(old, value VoteValue) (likes, total int) {
+	count := func(cond bool) int {
+		if cond {
+			return 1
+		}
+		return 0
+	}
+	return count(value == Like) - count(old == Like), count(value != 0) - count(old != 0)
+}
+
+// Сдвигает счетчики голосов поста и пересчитывает по ним рейтинг. Рейтинг сохраняется,
+// только если счетчики с тех пор не менялись: иначе его пересчитает тот, кто сдвинул их позже.
+func (repo *PostRepositoryMongo) shiftPostVotes(ctx context.Context, postID string, likes, total int) error {
+	res := repo.coll.FindOneAndUpdate(
+		ctx,
 		bson.M{"id": postID},
+		bson.M{"$inc": bson.M{"likescount": likes, "votescount": total}},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	)
+	if errors.Is(res.Err(), mongo.ErrNoDocuments) {
+		return errs.MsgError{Msg: "post not found", Status: 404}
+	}
+	p := &Post{}
+	if err := res.Decode(p); err != nil {
+		return fmt.Errorf("mongo decode err: %w", err)
+	}
+	p.setScore(p.LikesCount, p.VotesCount)
+	if _, err := repo.coll.UpdateOne(
+		ctx,
+		bson.M{"id": postID, "likescount": p.LikesCount, "votescount": p.VotesCount},
 		bson.M{"$set": bson.M{
-			"votes":        p.Votes,
 			"score":        p.Score,
 			"likespercent": p.LikesPercent,
+			"hot":          p.Hot,
+			"rising":       p.Rising,
+			"controversy":  p.Controversy,
 		}},
 	); err != nil {
-		return nil, fmt.Errorf("mongo update one err: %w", err)
+		return fmt.Errorf("mongo update one err: %w", err)
 	}
-	return p, nil
+	return nil
 }
 
-func (repo *PostRepositoryMongo) UnvotePost(postID, userID string) (*Post, error) {
-	p, err := findPost(repo.coll, postID)
+func (repo *PostRepositoryMongo) shiftCommentVotes(ctx context.Context, postID, commID string, likes, total int) error {
+	res := repo.comments.FindOneAndUpdate(
+		ctx,
+		bson.M{"postid": postID, "id": commID},
+		bson.M{"$inc": bson.M{"likescount": likes, "votescount": total}},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	)
+	if errors.Is(res.Err(), mongo.ErrNoDocuments) {
+		return errs.MsgError{Msg: "comment not found", Status: 404}
+	}
+	doc := &commentDoc{}
+	if err := res.Decode(doc); err != nil {
+		return fmt.Errorf("mongo decode err: %w", err)
+	}
+	doc.setScore(doc.LikesCount, doc.VotesCount)
+	if _, err := repo.comments.UpdateOne(
+		ctx,
+		bson.M{"postid": postID, "id": commID, "likescount": doc.LikesCount, "votescount": doc.VotesCount},
+		bson.M{"$set": bson.M{"score": doc.Score, "likespercent": doc.LikesPercent}},
+	); err != nil {
+		return fmt.Errorf("mongo update one err: %w", err)
+	}
+	return nil
+}
+
+func (repo *PostRepositoryMongo) loadPost(ctx context.Context, postID, viewerID string) (*Post, error) {
+	p, err := findPost(ctx, repo.coll, postID)
 	if err != nil {
 		return nil, err
 	}
-	if err := p.Votes.Unvote(userID); err != nil {
+	if err = repo.attachChildren(ctx, viewerID, p); err != nil {
 		return nil, err
 	}
-	p.updatePostScore()
-	if _, err := repo.coll.UpdateOne(
-		emptyCtx,
-		bson.M{"id": postID},
-		bson.M{"$set": bson.M{
-			"votes":        p.Votes,
-			"score":        p.Score,
-			"likespercent": p.LikesPercent,
-		}},
-	); err != nil {
-		return nil, fmt.Errorf("mongo update one err: %w", err)
-	}
 	return p, nil
 }
 
-func (repo *PostRepositoryMongo) GetByUser(username string) ([]*Post, error) {
-	posts, err := findPosts(repo.coll, bson.M{"author.username": username})
+func (repo *PostRepositoryMongo) loadPage(ctx context.Context, filter primitive.M, rank Ranking, page Page, viewerID string) ([]*Post, string, error) {
+	ctx, cancel := timeout.Context(ctx, repo.timeout)
+	defer cancel()
+	posts, next, err := findPage(ctx, repo.coll, filter, rank, page)
 	if err != nil {
-		return nil, err
+		return nil, "", err
 	}
-	sort.Slice(posts, func(i, j int) bool {
-		return posts[i].Created.After(posts[j].Created)
-	})
-	return posts, nil
+	if err = repo.attachChildren(ctx, viewerID, posts...); err != nil {
+		return nil, "", err
+	}
+	return posts, next, nil
+}
+
+// Подгружает комментарии постов из отдельной коллекции. Голоса за посты и комментарии
+// отдаются хранимыми счетчиками, а из коллекции голосов читаются только голоса viewerID,
+// чтобы показать ему его выбор; пустой viewerID означает анонимного читателя.
+func (repo *PostRepositoryMongo) attachChildren(ctx context.Context, viewerID string, posts ...*Post) error {
+	if len(posts) == 0 {
+		return nil
+	}
+	byID := make(map[string]*Post, len(posts))
+	ids := make(bson.A, 0, len(posts))
+	for _, p := range posts {
+		p.Votes = VoteList{List: []Vote{}, LikesCount: p.LikesCount}
+		p.Comments = make(CommentList, 0)
+		byID[p.ID] = p
+		ids = append(ids, p.ID)
+	}
+
+	cursor, err := repo.comments.Find(
+		ctx,
+		bson.M{"postid": bson.M{"$in": ids}},
+		options.Find().SetSort(bson.D{{Key: "created", Value: 1}, {Key: "id", Value: 1}}),
+	)
+	if err != nil {
+		return fmt.Errorf("mongo find err: %w", err)
+	}
+	comms := []*commentDoc{}
+	if err = cursor.All(ctx, &comms); err != nil {
+		return fmt.Errorf("mongo all err: %w", err)
+	}
+	commByID := make(map[string]*Comment, len(comms))
+	for _, doc := range comms {
+		comm := &doc.Comment
+		comm.Votes = VoteList{List: []Vote{}, LikesCount: comm.LikesCount}
+		p := byID[doc.PostID]
+		p.Comments = append(p.Comments, comm)
+		commByID[comm.ID] = comm
+	}
+	if viewerID == "" {
+		return nil
+	}
+
+	cursor, err = repo.votes.Find(ctx, bson.M{"postid": bson.M{"$in": ids}, "userid": viewerID})
+	if err != nil {
+		return fmt.Errorf("mongo find err: %w", err)
+	}
+	votes := []voteDoc{}
+	if err = cursor.All(ctx, &votes); err != nil {
+		return fmt.Errorf("mongo all err: %w", err)
+	}
+	for _, vote := range votes {
+		list := &byID[vote.PostID].Votes
+		if vote.CommentID != "" {
+			comm, ok := commByID[vote.CommentID]
+			if !ok {
+				continue
+			}
+			list = &comm.Votes
+		}
+		list.List = append(list.List, Vote{UserID: vote.UserID, Value: vote.Value})
+	}
+	return nil
 }
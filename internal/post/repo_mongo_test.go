@@ -1,11 +1,13 @@
 package post
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"reflect"
 	"strings"
 	"testing"
+	"time"
 
 	"asperitas/internal/errs"
 	"asperitas/internal/user"
@@ -16,14 +18,26 @@ import (
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 var (
+	emptyCtx = context.Background()
+
 	usr1   = user.User{Username: "admin1", ID: "id_admin1", Password: "passw"}
 	usr2   = user.User{Username: "admin2", ID: "id_admin2", Password: "passw"}
 	randID = rand.GetRandID()
 )
 
+// Права обычного пользователя: удалить можно только свое
+func ownPost(userID string) PostPermit {
+	return func(p *Post) bool { return p.Author.ID == userID }
+}
+
+func ownComment(userID string) CommentPermit {
+	return func(_ *Post, c *Comment) bool { return c.Author.ID == userID }
+}
+
 func getDoc(v interface{}) (doc bson.D) {
 	data, _ := bson.Marshal(v) // nolint:errcheck
 	bson.Unmarshal(data, &doc) // nolint:errcheck
@@ -41,12 +55,62 @@ func copyPost(src *Post) Post {
 	return dst
 }
 
-func getMockService(t *testing.T) (*PostRepositoryMongo, *MockMongoCollection, *MockMongoSingleResult) {
+// Посты отдает мок, а голоса и комментарии переданных постов лежат в коллекциях в памяти
+func getMockService(t *testing.T, posts ...*Post) (*PostRepositoryMongo, *MockMongoCollection, *MockMongoSingleResult) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
 	cln := NewMockMongoCollection(ctrl)
 	sr := NewMockMongoSingleResult(ctrl)
-	return &PostRepositoryMongo{coll: cln}, cln, sr
+	votes, comments := newFakeChildren(posts...)
+	return &PostRepositoryMongo{coll: cln, votes: votes, comments: comments, revisions: newFakeCollection()}, cln, sr
+}
+
+func expectFindPost(coll *MockMongoCollection, sr *MockMongoSingleResult, post Post) {
+	coll.EXPECT().
+		FindOne(gomock.Any(), bson.M{"id": post.ID}).
+		Return(sr)
+	sr.EXPECT().
+		Err().
+		Return(nil)
+	sr.EXPECT().
+		Decode(&Post{}).SetArg(0, post).
+		Return(nil)
+}
+
+func fakeDocs(coll MongoCollection) []bson.M {
+	return coll.(*fakeCollection).docs
+}
+
+// Пост в том виде, в каком его отдает репозиторий: со счетчиками и только с голосами зрителя
+func viewed(src *Post, viewerID string) *Post {
+	res := copyPost(src)
+	res.Votes = viewerVotes(src.Votes, viewerID)
+	for i, comm := range src.Comments {
+		c := *comm
+		c.Votes = viewerVotes(comm.Votes, viewerID)
+		res.Comments[i] = &c
+	}
+	return &res
+}
+
+func viewerVotes(src VoteList, viewerID string) VoteList {
+	res := VoteList{List: []Vote{}, LikesCount: src.LikesCount}
+	for _, vote := range src.List {
+		if vote.UserID == viewerID {
+			res.List = append(res.List, vote)
+		}
+	}
+	return res
+}
+
+func getMtestRepo(mt *mtest.T, posts ...*Post) *PostRepositoryMongo {
+	votes, comments := newFakeChildren(posts...)
+	return &PostRepositoryMongo{
+		coll:      newMongoCollection(mt.Coll),
+		votes:     votes,
+		comments:  comments,
+		revisions: newFakeCollection(),
+	}
 }
 
 func TestGetAll_OK(t *testing.T) {
@@ -67,9 +131,9 @@ func TestGetAll_OK(t *testing.T) {
 		responses = append(responses, last)
 
 		mt.AddMockResponses(responses...)
-		repo := &PostRepositoryMongo{coll: newMongoCollection(mt.Coll)}
+		repo := getMtestRepo(mt, expect...)
 
-		result, err := repo.GetAll()
+		result, _, err := repo.GetAll(emptyCtx, Top, Page{}, usr2.ID)
 
 		if err != nil {
 			mt.Errorf("unexpected err: %s", err)
@@ -79,9 +143,10 @@ func TestGetAll_OK(t *testing.T) {
 			return
 		}
 		for i := 0; i < len(expect); i++ {
-			expect[i].Created = result[i].Created
-			if !reflect.DeepEqual(expect[i], result[i]) {
-				mt.Errorf("results not match:\nwant:\t%#v\nhave\t%#v", expect[i], result[i])
+			want := viewed(expect[i], usr2.ID)
+			want.Created = result[i].Created
+			if !reflect.DeepEqual(want, result[i]) {
+				mt.Errorf("results not match:\nwant:\t%#v\nhave\t%#v", want, result[i])
 			}
 		}
 	})
@@ -95,10 +160,10 @@ func TestGetAll_FindErr(t *testing.T) {
 		mt.AddMockResponses(mtest.CreateWriteErrorsResponse(mtest.WriteError{
 			Message: "some error",
 		}))
-		repo := &PostRepositoryMongo{coll: newMongoCollection(mt.Coll)}
+		repo := getMtestRepo(mt)
 
 		expect := "mongo find err"
-		result, err := repo.GetAll()
+		result, _, err := repo.GetAll(emptyCtx, Top, Page{}, "")
 
 		if result != nil {
 			mt.Errorf("unexpected result: %#v", result)
@@ -117,10 +182,10 @@ func TestGetAll_CursorErr(t *testing.T) {
 		first := mtest.CreateCursorResponse(1, "db.mock", mtest.FirstBatch, bson.D{})
 
 		mt.AddMockResponses(first)
-		repo := &PostRepositoryMongo{coll: newMongoCollection(mt.Coll)}
+		repo := getMtestRepo(mt)
 
 		expect := "mongo all err"
-		result, err := repo.GetAll()
+		result, _, err := repo.GetAll(emptyCtx, Top, Page{}, "")
 
 		if result != nil {
 			mt.Errorf("unexpected result: %#v", result)
@@ -137,10 +202,10 @@ func TestAddPost_OK(t *testing.T) {
 
 	mt.Run(t.Name(), func(mt *mtest.T) {
 		mt.AddMockResponses(mtest.CreateSuccessResponse())
-		repo := &PostRepositoryMongo{coll: newMongoCollection(mt.Coll)}
+		repo := getMtestRepo(mt)
 
 		expect := error(nil)
-		err := repo.AddPost(NewPost(user.User{}))
+		err := repo.AddPost(emptyCtx, NewPost(user.User{}))
 
 		if err != expect {
 			mt.Errorf("unexpected err: %s", err)
@@ -156,10 +221,10 @@ func TestAddPost_InsertErr(t *testing.T) {
 		mt.AddMockResponses(mtest.CreateWriteErrorsResponse(mtest.WriteError{
 			Message: "some error",
 		}))
-		repo := &PostRepositoryMongo{coll: newMongoCollection(mt.Coll)}
+		repo := getMtestRepo(mt)
 
 		expect := "mongo insert one err"
-		err := repo.AddPost(NewPost(user.User{}))
+		err := repo.AddPost(emptyCtx, NewPost(user.User{}))
 
 		if err == nil || !strings.HasPrefix(err.Error(), expect) {
 			mt.Errorf("unexpected err:\nwant:\t%s\nhave\t%#v", expect, err)
@@ -188,9 +253,9 @@ func TestGetByCategory_OK(t *testing.T) {
 		responses = append(responses, last)
 
 		mt.AddMockResponses(responses...)
-		repo := &PostRepositoryMongo{coll: newMongoCollection(mt.Coll)}
+		repo := getMtestRepo(mt, expect...)
 
-		result, err := repo.GetByCategory("music")
+		result, _, err := repo.GetByCategory(emptyCtx, "music", Top, Page{}, "")
 
 		if err != nil {
 			mt.Errorf("unexpected err: %s", err)
@@ -200,9 +265,10 @@ func TestGetByCategory_OK(t *testing.T) {
 			return
 		}
 		for i := 0; i < len(expect); i++ {
-			expect[i].Created = result[i].Created
-			if !reflect.DeepEqual(expect[i], result[i]) {
-				mt.Errorf("results not match:\nwant:\t%#v\nhave\t%#v", expect[i], result[i])
+			want := viewed(expect[i], "")
+			want.Created = result[i].Created
+			if !reflect.DeepEqual(want, result[i]) {
+				mt.Errorf("results not match:\nwant:\t%#v\nhave\t%#v", want, result[i])
 			}
 		}
 	})
@@ -216,10 +282,10 @@ func TestGetByCategory_FindErr(t *testing.T) {
 		mt.AddMockResponses(mtest.CreateWriteErrorsResponse(mtest.WriteError{
 			Message: "some error",
 		}))
-		repo := &PostRepositoryMongo{coll: newMongoCollection(mt.Coll)}
+		repo := getMtestRepo(mt)
 
 		expect := "mongo find err"
-		result, err := repo.GetByCategory("music")
+		result, _, err := repo.GetByCategory(emptyCtx, "music", Top, Page{}, "")
 
 		if result != nil {
 			mt.Errorf("unexpected result: %#v", result)
@@ -231,12 +297,17 @@ func TestGetByCategory_FindErr(t *testing.T) {
 }
 
 func TestGetByID_OK(t *testing.T) {
-	service, coll, sr := getMockService(t)
-
 	expect := NewPost(usr1)
+	expect.Votes.Downvote(usr2.ID) // nolint:errcheck
+	expect.updatePostScore()
+	comm := NewComment(usr1, "some text")
+	comm.vote(usr2.ID, (*VoteList).Upvote) // nolint:errcheck
+	expect.Comments.Add(comm)              // nolint:errcheck
+	expect.CommentsCount = 1
+	service, coll, sr := getMockService(t, expect)
 
 	coll.EXPECT().
-		FindOne(emptyCtx, bson.M{"id": expect.ID}).
+		FindOne(gomock.Any(), bson.M{"id": expect.ID}).
 		Return(sr)
 	sr.EXPECT().
 		Err().
@@ -247,19 +318,26 @@ func TestGetByID_OK(t *testing.T) {
 	expect.Views++
 	coll.EXPECT().
 		UpdateOne(
-			emptyCtx,
+			gomock.Any(),
 			bson.M{"id": expect.ID},
-			bson.M{"$set": bson.M{"views": expect.Views}},
+			bson.M{"$inc": bson.M{"views": 1}},
 		).
-		Return(gomock.Any(), nil)
+		Return(&mongo.UpdateResult{MatchedCount: 1}, nil)
 
-	result, err := service.GetByID(expect.ID)
+	result, err := service.GetByID(emptyCtx, expect.ID, usr2.ID)
 
 	if err != nil {
 		t.Errorf("unexpected err: %s", err)
 	}
-	if !reflect.DeepEqual(expect, result) {
-		t.Errorf("results not match:\nwant:\t%#v\nhave\t%#v", expect, result)
+	if len(result.Comments) == 1 {
+		comm.Created = result.Comments[0].Created
+	}
+	want := viewed(expect, usr2.ID)
+	if !reflect.DeepEqual(want, result) {
+		t.Errorf("results not match:\nwant:\t%#v\nhave\t%#v", want, result)
+	}
+	if len(result.Votes.List) != 1 || len(result.Comments[0].Votes.List) != 1 {
+		t.Errorf("loaded votes of other users: %#v", result)
 	}
 }
 
@@ -269,13 +347,13 @@ func TestGetByID_ErrNoPost(t *testing.T) {
 	expect := errs.MsgError{Msg: "post not found", Status: 404}
 
 	coll.EXPECT().
-		FindOne(emptyCtx, bson.M{"id": randID}).
+		FindOne(gomock.Any(), bson.M{"id": randID}).
 		Return(sr)
 	sr.EXPECT().
 		Err().
 		Return(mongo.ErrNoDocuments)
 
-	result, err := service.GetByID(randID)
+	result, err := service.GetByID(emptyCtx, randID, "")
 
 	if result != nil {
 		t.Errorf("unexpected result: %#v", result)
@@ -285,13 +363,39 @@ func TestGetByID_ErrNoPost(t *testing.T) {
 	}
 }
 
+func TestGetByID_Deadline(t *testing.T) {
+	service, coll, sr := getMockService(t)
+	service.timeout = time.Second
+
+	coll.EXPECT().
+		FindOne(gomock.Any(), bson.M{"id": randID}).
+		DoAndReturn(func(ctx context.Context, _ interface{}) MongoSingleResult {
+			if _, ok := ctx.Deadline(); !ok {
+				t.Errorf("operation context without deadline")
+			}
+			return sr
+		})
+	sr.EXPECT().
+		Err().
+		Return(nil)
+	sr.EXPECT().
+		Decode(&Post{}).
+		Return(fmt.Errorf("%w: server selection timeout", context.DeadlineExceeded))
+
+	_, err := service.GetByID(emptyCtx, randID, "")
+
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("results not match:\nwant:\t%#v\nhave\t%#v", context.DeadlineExceeded, err)
+	}
+}
+
 func TestGetByID_DecodeErr(t *testing.T) {
 	service, coll, sr := getMockService(t)
 
 	expect := fmt.Errorf("some error")
 
 	coll.EXPECT().
-		FindOne(emptyCtx, bson.M{"id": randID}).
+		FindOne(gomock.Any(), bson.M{"id": randID}).
 		Return(sr)
 	sr.EXPECT().
 		Err().
@@ -300,7 +404,7 @@ func TestGetByID_DecodeErr(t *testing.T) {
 		Decode(&Post{}).
 		Return(expect)
 
-	result, err := service.GetByID(randID)
+	result, err := service.GetByID(emptyCtx, randID, "")
 
 	if result != nil {
 		t.Errorf("unexpected result: %#v", result)
@@ -317,7 +421,7 @@ func TestGetByID_UpdateErr(t *testing.T) {
 	post := NewPost(usr1)
 
 	coll.EXPECT().
-		FindOne(emptyCtx, bson.M{"id": post.ID}).
+		FindOne(gomock.Any(), bson.M{"id": post.ID}).
 		Return(sr)
 	sr.EXPECT().
 		Err().
@@ -328,13 +432,13 @@ func TestGetByID_UpdateErr(t *testing.T) {
 	post.Views++
 	coll.EXPECT().
 		UpdateOne(
-			emptyCtx,
+			gomock.Any(),
 			bson.M{"id": post.ID},
-			bson.M{"$set": bson.M{"views": post.Views}},
+			bson.M{"$inc": bson.M{"views": 1}},
 		).
 		Return(nil, expect)
 
-	result, err := service.GetByID(post.ID)
+	result, err := service.GetByID(emptyCtx, post.ID, "")
 
 	if result != nil {
 		t.Errorf("unexpected result: %#v", result)
@@ -345,13 +449,14 @@ func TestGetByID_UpdateErr(t *testing.T) {
 }
 
 func TestDeletePost_OK(t *testing.T) {
-	service, coll, sr := getMockService(t)
+	post := NewPost(usr1)
+	post.Comments.Add(NewComment(usr2, "some text")) // nolint:errcheck
+	service, coll, sr := getMockService(t, post)
 
 	expect := errs.MsgError{Msg: "success", Status: 200}
-	post := NewPost(usr1)
 
 	coll.EXPECT().
-		FindOne(emptyCtx, bson.M{"id": post.ID}).
+		FindOne(gomock.Any(), bson.M{"id": post.ID}).
 		Return(sr)
 	sr.EXPECT().
 		Err().
@@ -360,14 +465,17 @@ func TestDeletePost_OK(t *testing.T) {
 		Decode(&Post{}).SetArg(0, *post).
 		Return(nil)
 	coll.EXPECT().
-		DeleteOne(emptyCtx, bson.M{"id": post.ID}).
+		DeleteOne(gomock.Any(), bson.M{"id": post.ID}).
 		Return(gomock.Any(), nil)
 
-	err := service.DeletePost(post.ID, usr1.ID)
+	err := service.DeletePost(emptyCtx, post.ID, ownPost(usr1.ID))
 
 	if !reflect.DeepEqual(expect, err) {
 		t.Errorf("results not match:\nwant:\t%#v\nhave\t%#v", expect, err)
 	}
+	if comments, votes := fakeDocs(service.comments), fakeDocs(service.votes); len(comments) != 0 || len(votes) != 0 {
+		t.Errorf("children not deleted: %d comments, %d votes", len(comments), len(votes))
+	}
 }
 
 func TestDeletePost_ErrNoPost(t *testing.T) {
@@ -376,13 +484,13 @@ func TestDeletePost_ErrNoPost(t *testing.T) {
 	expect := errs.MsgError{Msg: "post not found", Status: 404}
 
 	coll.EXPECT().
-		FindOne(emptyCtx, bson.M{"id": randID}).
+		FindOne(gomock.Any(), bson.M{"id": randID}).
 		Return(sr)
 	sr.EXPECT().
 		Err().
 		Return(mongo.ErrNoDocuments)
 
-	err := service.DeletePost(randID, usr1.ID)
+	err := service.DeletePost(emptyCtx, randID, ownPost(usr1.ID))
 
 	if !reflect.DeepEqual(expect, err) {
 		t.Errorf("results not match:\nwant:\t%#v\nhave\t%#v", expect, err)
@@ -396,7 +504,7 @@ func TestDeletePost_AuthErr(t *testing.T) {
 	post := NewPost(usr1)
 
 	coll.EXPECT().
-		FindOne(emptyCtx, bson.M{"id": post.ID}).
+		FindOne(gomock.Any(), bson.M{"id": post.ID}).
 		Return(sr)
 	sr.EXPECT().
 		Err().
@@ -405,7 +513,7 @@ func TestDeletePost_AuthErr(t *testing.T) {
 		Decode(&Post{}).SetArg(0, *post).
 		Return(nil)
 
-	err := service.DeletePost(post.ID, usr2.ID)
+	err := service.DeletePost(emptyCtx, post.ID, ownPost(usr2.ID))
 
 	if !reflect.DeepEqual(expect, err) {
 		t.Errorf("results not match:\nwant:\t%#v\nhave\t%#v", expect, err)
@@ -419,7 +527,7 @@ func TestDeletePost_DeleteErr(t *testing.T) {
 	post := NewPost(usr1)
 
 	coll.EXPECT().
-		FindOne(emptyCtx, bson.M{"id": post.ID}).
+		FindOne(gomock.Any(), bson.M{"id": post.ID}).
 		Return(sr)
 	sr.EXPECT().
 		Err().
@@ -428,48 +536,75 @@ func TestDeletePost_DeleteErr(t *testing.T) {
 		Decode(&Post{}).SetArg(0, *post).
 		Return(nil)
 	coll.EXPECT().
-		DeleteOne(emptyCtx, bson.M{"id": post.ID}).
+		DeleteOne(gomock.Any(), bson.M{"id": post.ID}).
 		Return(nil, expect)
 
-	err := service.DeletePost(post.ID, usr1.ID)
+	err := service.DeletePost(emptyCtx, post.ID, ownPost(usr1.ID))
 
 	if !errors.Is(err, expect) {
 		t.Errorf("results not match:\nwant:\t%#v\nhave\t%#v", expect, err)
 	}
 }
 
+// Оборванное удаление оставляет пост на месте, и повторный запрос удаляет его целиком
+func TestDeletePost_Retry(t *testing.T) {
+	post := NewPost(usr1)
+	post.Comments.Add(NewComment(usr2, "some text")) // nolint:errcheck
+	votes, comments := newFakeChildren(post)
+	expect := fmt.Errorf("some err")
+	service := &PostRepositoryMongo{
+		coll:      newFakeCollection(post),
+		votes:     &failingCollection{fakeCollection: votes, deleteErr: expect},
+		comments:  comments,
+		revisions: newFakeCollection(),
+	}
+
+	if err := service.DeletePost(emptyCtx, post.ID, ownPost(usr1.ID)); !errors.Is(err, expect) {
+		t.Errorf("results not match:\nwant:\t%#v\nhave\t%#v", expect, err)
+	}
+	if posts := fakeDocs(service.coll); len(posts) != 1 {
+		t.Fatalf("post deleted before its children")
+	}
+
+	err := service.DeletePost(emptyCtx, post.ID, ownPost(usr1.ID))
+
+	if expect := (errs.MsgError{Msg: "success", Status: 200}); !reflect.DeepEqual(expect, err) {
+		t.Errorf("results not match:\nwant:\t%#v\nhave\t%#v", expect, err)
+	}
+	if len(fakeDocs(service.coll)) != 0 || len(comments.docs) != 0 || len(votes.docs) != 0 {
+		t.Errorf("post not deleted: %d posts, %d comments, %d votes",
+			len(fakeDocs(service.coll)), len(comments.docs), len(votes.docs))
+	}
+}
+
 func TestAddComment_OK(t *testing.T) {
-	service, coll, sr := getMockService(t)
+	post := NewPost(usr1)
+	service, coll, sr := getMockService(t, post)
 
-	expect := NewPost(usr1)
 	comm := NewComment(usr2, "some text")
-	tmp := copyPost(expect)
+	expect := copyPost(post)
+	expect.Comments = CommentList{comm}
+	expect.CommentsCount = 1
 
-	coll.EXPECT().
-		FindOne(emptyCtx, bson.M{"id": expect.ID}).
-		Return(sr)
-	sr.EXPECT().
-		Err().
-		Return(nil)
-	sr.EXPECT().
-		Decode(&Post{}).SetArg(0, tmp).
-		Return(nil)
-	expect.Comments.Add(comm) // nolint:errcheck
+	expectFindPost(coll, sr, *post)
 	coll.EXPECT().
 		UpdateOne(
-			emptyCtx,
-			bson.M{"id": expect.ID},
-			bson.M{"$set": bson.M{"comments": expect.Comments}},
+			gomock.Any(),
+			bson.M{"id": post.ID},
+			bson.M{"$inc": bson.M{"commentscount": 1}},
 		).
-		Return(gomock.Any(), nil)
+		Return(&mongo.UpdateResult{MatchedCount: 1}, nil)
 
-	result, err := service.AddComment(expect.ID, comm)
+	result, err := service.AddComment(emptyCtx, post.ID, comm)
 
 	if err != nil {
 		t.Errorf("unexpected err: %s", err)
 	}
-	if !reflect.DeepEqual(expect, result) {
-		t.Errorf("results not match:\nwant:\t%#v\nhave\t%#v", expect, result)
+	if want := viewed(&expect, usr2.ID); !reflect.DeepEqual(want, result) {
+		t.Errorf("results not match:\nwant:\t%#v\nhave\t%#v", want, result)
+	}
+	if comments, votes := fakeDocs(service.comments), fakeDocs(service.votes); len(comments) != 1 || len(votes) != 2 {
+		t.Errorf("wrong stored docs: %d comments, %d votes", len(comments), len(votes))
 	}
 }
 
@@ -479,13 +614,13 @@ func TestAddComment_ErrNoPost(t *testing.T) {
 	expect := errs.MsgError{Msg: "post not found", Status: 404}
 
 	coll.EXPECT().
-		FindOne(emptyCtx, bson.M{"id": randID}).
+		FindOne(gomock.Any(), bson.M{"id": randID}).
 		Return(sr)
 	sr.EXPECT().
 		Err().
 		Return(mongo.ErrNoDocuments)
 
-	result, err := service.AddComment(randID, nil)
+	result, err := service.AddComment(emptyCtx, randID, NewComment(usr2, "some text"))
 
 	if result != nil {
 		t.Errorf("unexpected result: %#v", result)
@@ -495,24 +630,15 @@ func TestAddComment_ErrNoPost(t *testing.T) {
 	}
 }
 
-func TestAddComment_AddErr(t *testing.T) {
-	service, coll, sr := getMockService(t)
-
-	expect := fmt.Errorf("nil comment list")
+func TestAddComment_ErrNoParent(t *testing.T) {
 	post := NewPost(usr1)
+	service, coll, sr := getMockService(t, post)
 
-	coll.EXPECT().
-		FindOne(emptyCtx, bson.M{"id": post.ID}).
-		Return(sr)
-	sr.EXPECT().
-		Err().
-		Return(nil)
-	post.Comments = nil
-	sr.EXPECT().
-		Decode(&Post{}).SetArg(0, *post).
-		Return(nil)
+	expect := errs.MsgError{Msg: "parent comment not found", Status: 404}
 
-	result, err := service.AddComment(post.ID, nil)
+	expectFindPost(coll, sr, *post)
+
+	result, err := service.AddComment(emptyCtx, post.ID, NewReply(usr2, randID, "some text"))
 
 	if result != nil {
 		t.Errorf("unexpected result: %#v", result)
@@ -520,35 +646,27 @@ func TestAddComment_AddErr(t *testing.T) {
 	if !reflect.DeepEqual(expect, err) {
 		t.Errorf("results not match:\nwant:\t%#v\nhave\t%#v", expect, err)
 	}
+	if comments := fakeDocs(service.comments); len(comments) != 0 {
+		t.Errorf("unexpected stored comments: %v", comments)
+	}
 }
 
 func TestAddComment_UpdateErr(t *testing.T) {
-	service, coll, sr := getMockService(t)
+	post := NewPost(usr1)
+	service, coll, sr := getMockService(t, post)
 
 	expect := fmt.Errorf("some err")
-	post := NewPost(usr1)
-	comm := NewComment(usr2, "some text")
-	tmp := copyPost(post)
 
-	coll.EXPECT().
-		FindOne(emptyCtx, bson.M{"id": post.ID}).
-		Return(sr)
-	sr.EXPECT().
-		Err().
-		Return(nil)
-	sr.EXPECT().
-		Decode(&Post{}).SetArg(0, tmp).
-		Return(nil)
-	post.Comments.Add(comm) // nolint:errcheck
+	expectFindPost(coll, sr, *post)
 	coll.EXPECT().
 		UpdateOne(
-			emptyCtx,
+			gomock.Any(),
 			bson.M{"id": post.ID},
-			bson.M{"$set": bson.M{"comments": post.Comments}},
+			bson.M{"$inc": bson.M{"commentscount": 1}},
 		).
 		Return(nil, expect)
 
-	result, err := service.AddComment(post.ID, comm)
+	result, err := service.AddComment(emptyCtx, post.ID, NewComment(usr2, "some text"))
 
 	if result != nil {
 		t.Errorf("unexpected result: %#v", result)
@@ -556,84 +674,109 @@ func TestAddComment_UpdateErr(t *testing.T) {
 	if !errors.Is(err, expect) {
 		t.Errorf("results not match:\nwant:\t%#v\nhave\t%#v", expect, err)
 	}
+	if comments, votes := fakeDocs(service.comments), fakeDocs(service.votes); len(comments) != 0 || len(votes) != 1 {
+		t.Errorf("comment not removed: %d comments, %d votes", len(comments), len(votes))
+	}
 }
 
-func TestDeleteComment_OK(t *testing.T) {
-	service, coll, sr := getMockService(t)
+// Комментарий без голоса автора не должен остаться в базе
+func TestAddComment_VoteErr(t *testing.T) {
+	post := NewPost(usr1)
+	votes, comments := newFakeChildren(post)
+	expect := fmt.Errorf("some err")
+	service := &PostRepositoryMongo{
+		coll:     newFakeCollection(post),
+		votes:    &failingCollection{fakeCollection: votes},
+		comments: comments,
+	}
+	service.votes.(*failingCollection).insertErr = expect
 
-	expect := NewPost(usr1)
+	result, err := service.AddComment(emptyCtx, post.ID, NewComment(usr2, "some text"))
+
+	if result != nil {
+		t.Errorf("unexpected result: %#v", result)
+	}
+	if !errors.Is(err, expect) {
+		t.Errorf("results not match:\nwant:\t%#v\nhave\t%#v", expect, err)
+	}
+	if len(comments.docs) != 0 || len(votes.docs) != 1 {
+		t.Errorf("comment not removed: %d comments, %d votes", len(comments.docs), len(votes.docs))
+	}
+	if stored := fakeDocs(service.coll)[0]; toValue(stored["commentscount"]) != int64(0) {
+		t.Errorf("wrong comments count: %v", stored["commentscount"])
+	}
+}
+
+func TestDeleteComment_OK(t *testing.T) {
+	post := NewPost(usr1)
 	comm := NewComment(usr2, "some text")
-	expect.Comments.Add(comm) // nolint:errcheck
-	tmp := copyPost(expect)
+	post.Comments.Add(comm) // nolint:errcheck
+	post.CommentsCount = 1
+	service, coll, sr := getMockService(t, post)
 
-	coll.EXPECT().
-		FindOne(emptyCtx, bson.M{"id": expect.ID}).
-		Return(sr)
-	sr.EXPECT().
-		Err().
-		Return(nil)
-	sr.EXPECT().
-		Decode(&Post{}).SetArg(0, tmp).
-		Return(nil)
-	expect.Comments.Delete(comm.ID, usr2.ID) // nolint:errcheck
+	expect := copyPost(post)
+	expect.Comments = CommentList{}
+	expect.CommentsCount = 0
+
+	expectFindPost(coll, sr, *post)
 	coll.EXPECT().
 		UpdateOne(
-			emptyCtx,
-			bson.M{"id": expect.ID},
-			bson.M{"$set": bson.M{"comments": expect.Comments}},
+			gomock.Any(),
+			bson.M{"id": post.ID},
+			bson.M{"$inc": bson.M{"commentscount": -1}},
 		).
-		Return(gomock.Any(), nil)
+		Return(&mongo.UpdateResult{MatchedCount: 1}, nil)
 
-	result, err := service.DeleteComment(expect.ID, comm.ID, usr2.ID)
+	result, err := service.DeleteComment(emptyCtx, post.ID, comm.ID, usr2.ID, ownComment(usr2.ID))
 
 	if err != nil {
 		t.Errorf("unexpected err: %s", err)
 	}
-	if !reflect.DeepEqual(expect, result) {
-		t.Errorf("results not match:\nwant:\t%#v\nhave\t%#v", expect, result)
+	if want := viewed(&expect, usr2.ID); !reflect.DeepEqual(want, result) {
+		t.Errorf("results not match:\nwant:\t%#v\nhave\t%#v", want, result)
+	}
+	if comments, votes := fakeDocs(service.comments), fakeDocs(service.votes); len(comments) != 0 || len(votes) != 1 {
+		t.Errorf("wrong stored docs: %d comments, %d votes", len(comments), len(votes))
 	}
 }
 
-func TestDeleteComment_ErrNoPost(t *testing.T) {
-	service, coll, sr := getMockService(t)
-
-	expect := errs.MsgError{Msg: "post not found", Status: 404}
+func TestDeleteComment_Tombstone(t *testing.T) {
+	post := NewPost(usr1)
+	comm := NewComment(usr2, "some text")
+	post.Comments.Add(comm)                                  // nolint:errcheck
+	post.Comments.Add(NewReply(usr1, comm.ID, "some reply")) // nolint:errcheck
+	post.CommentsCount = 2
+	service, coll, sr := getMockService(t, post)
 
-	coll.EXPECT().
-		FindOne(emptyCtx, bson.M{"id": randID}).
-		Return(sr)
-	sr.EXPECT().
-		Err().
-		Return(mongo.ErrNoDocuments)
+	expectFindPost(coll, sr, *post)
 
-	result, err := service.DeleteComment(randID, randID, usr1.ID)
+	result, err := service.DeleteComment(emptyCtx, post.ID, comm.ID, usr2.ID, ownComment(usr2.ID))
 
-	if result != nil {
-		t.Errorf("unexpected result: %#v", result)
+	if err != nil {
+		t.Fatalf("unexpected err: %s", err)
 	}
-	if !reflect.DeepEqual(expect, err) {
-		t.Errorf("results not match:\nwant:\t%#v\nhave\t%#v", expect, err)
+	if len(result.Comments) != 2 || !result.Comments[0].Deleted || result.CommentsCount != 2 {
+		t.Errorf("comment not tombstoned: %#v", result.Comments)
+	}
+	stored := fakeDocs(service.comments)
+	if len(stored) != 2 || stored[0]["deleted"] != true || stored[0]["body"] != deletedCommentBody {
+		t.Errorf("wrong stored comments: %v", stored)
 	}
 }
 
-func TestDeleteComment_DeleteErr(t *testing.T) {
+func TestDeleteComment_ErrNoPost(t *testing.T) {
 	service, coll, sr := getMockService(t)
 
-	expect := fmt.Errorf("nil comment list")
-	post := NewPost(usr1)
+	expect := errs.MsgError{Msg: "post not found", Status: 404}
 
 	coll.EXPECT().
-		FindOne(emptyCtx, bson.M{"id": post.ID}).
+		FindOne(gomock.Any(), bson.M{"id": randID}).
 		Return(sr)
 	sr.EXPECT().
 		Err().
-		Return(nil)
-	post.Comments = nil
-	sr.EXPECT().
-		Decode(&Post{}).SetArg(0, *post).
-		Return(nil)
+		Return(mongo.ErrNoDocuments)
 
-	result, err := service.DeleteComment(post.ID, randID, usr1.ID)
+	result, err := service.DeleteComment(emptyCtx, randID, randID, usr1.ID, ownComment(usr1.ID))
 
 	if result != nil {
 		t.Errorf("unexpected result: %#v", result)
@@ -644,22 +787,14 @@ func TestDeleteComment_DeleteErr(t *testing.T) {
 }
 
 func TestDeleteComment_ErrNoComment(t *testing.T) {
-	service, coll, sr := getMockService(t)
+	post := NewPost(usr1)
+	service, coll, sr := getMockService(t, post)
 
 	expect := errs.MsgError{Msg: "comment not found", Status: 404}
-	post := NewPost(usr1)
 
-	coll.EXPECT().
-		FindOne(emptyCtx, bson.M{"id": post.ID}).
-		Return(sr)
-	sr.EXPECT().
-		Err().
-		Return(nil)
-	sr.EXPECT().
-		Decode(&Post{}).SetArg(0, *post).
-		Return(nil)
+	expectFindPost(coll, sr, *post)
 
-	result, err := service.DeleteComment(post.ID, randID, usr1.ID)
+	result, err := service.DeleteComment(emptyCtx, post.ID, randID, usr1.ID, ownComment(usr1.ID))
 
 	if result != nil {
 		t.Errorf("unexpected result: %#v", result)
@@ -670,24 +805,16 @@ func TestDeleteComment_ErrNoComment(t *testing.T) {
 }
 
 func TestDeleteComment_AuthErr(t *testing.T) {
-	service, coll, sr := getMockService(t)
-
-	expect := errs.MsgError{Msg: "unauthorized", Status: 401}
 	post := NewPost(usr1)
 	comm := NewComment(usr2, "some text")
 	post.Comments.Add(comm) // nolint:errcheck
+	service, coll, sr := getMockService(t, post)
 
-	coll.EXPECT().
-		FindOne(emptyCtx, bson.M{"id": post.ID}).
-		Return(sr)
-	sr.EXPECT().
-		Err().
-		Return(nil)
-	sr.EXPECT().
-		Decode(&Post{}).SetArg(0, *post).
-		Return(nil)
+	expect := errs.MsgError{Msg: "unauthorized", Status: 401}
+
+	expectFindPost(coll, sr, *post)
 
-	result, err := service.DeleteComment(post.ID, comm.ID, usr1.ID)
+	result, err := service.DeleteComment(emptyCtx, post.ID, comm.ID, usr1.ID, ownComment(usr1.ID))
 
 	if result != nil {
 		t.Errorf("unexpected result: %#v", result)
@@ -698,32 +825,24 @@ func TestDeleteComment_AuthErr(t *testing.T) {
 }
 
 func TestDeleteComment_UpdateErr(t *testing.T) {
-	service, coll, sr := getMockService(t)
-
-	expect := fmt.Errorf("some err")
 	post := NewPost(usr1)
 	comm := NewComment(usr2, "some text")
 	post.Comments.Add(comm) // nolint:errcheck
+	post.CommentsCount = 1
+	service, coll, sr := getMockService(t, post)
 
-	coll.EXPECT().
-		FindOne(emptyCtx, bson.M{"id": post.ID}).
-		Return(sr)
-	sr.EXPECT().
-		Err().
-		Return(nil)
-	sr.EXPECT().
-		Decode(&Post{}).SetArg(0, *post).
-		Return(nil)
-	post.Comments.Delete(comm.ID, usr2.ID) // nolint:errcheck
+	expect := fmt.Errorf("some err")
+
+	expectFindPost(coll, sr, *post)
 	coll.EXPECT().
 		UpdateOne(
-			emptyCtx,
+			gomock.Any(),
 			bson.M{"id": post.ID},
-			bson.M{"$set": bson.M{"comments": post.Comments}},
+			bson.M{"$inc": bson.M{"commentscount": -1}},
 		).
 		Return(nil, expect)
 
-	result, err := service.DeleteComment(post.ID, comm.ID, usr2.ID)
+	result, err := service.DeleteComment(emptyCtx, post.ID, comm.ID, usr2.ID, ownComment(usr2.ID))
 
 	if result != nil {
 		t.Errorf("unexpected result: %#v", result)
@@ -733,42 +852,58 @@ func TestDeleteComment_UpdateErr(t *testing.T) {
 	}
 }
 
-func TestUpvotePost_OK(t *testing.T) {
-	service, coll, sr := getMockService(t)
-
-	expect := NewPost(usr1)
-	tmp := copyPost(expect)
-
+// Ожидает сдвиг счетчиков голосов поста и сохранение рейтинга, посчитанного по новым счетчикам
+func expectShiftVotes(coll *MockMongoCollection, sr *MockMongoSingleResult, post Post, likes, total int) Post {
+	shifted := post
+	shifted.setScore(post.LikesCount+likes, post.VotesCount+total)
 	coll.EXPECT().
-		FindOne(emptyCtx, bson.M{"id": expect.ID}).
+		FindOneAndUpdate(
+			gomock.Any(),
+			bson.M{"id": post.ID},
+			bson.M{"$inc": bson.M{"likescount": likes, "votescount": total}},
+			options.FindOneAndUpdate().SetReturnDocument(options.After),
+		).
 		Return(sr)
 	sr.EXPECT().
 		Err().
 		Return(nil)
 	sr.EXPECT().
-		Decode(&Post{}).SetArg(0, tmp).
+		Decode(&Post{}).SetArg(0, shifted).
 		Return(nil)
-	expect.Votes.Upvote(usr2.ID) // nolint:errcheck
-	expect.updatePostScore()
 	coll.EXPECT().
 		UpdateOne(
-			emptyCtx,
-			bson.M{"id": expect.ID},
+			gomock.Any(),
+			bson.M{"id": post.ID, "likescount": shifted.LikesCount, "votescount": shifted.VotesCount},
 			bson.M{"$set": bson.M{
-				"votes":        expect.Votes,
-				"score":        expect.Score,
-				"likespercent": expect.LikesPercent,
+				"score":        shifted.Score,
+				"likespercent": shifted.LikesPercent,
+				"hot":          shifted.Hot,
+				"rising":       shifted.Rising,
+				"controversy":  shifted.Controversy,
 			}},
 		).
-		Return(gomock.Any(), nil)
+		Return(&mongo.UpdateResult{MatchedCount: 1}, nil)
+	return shifted
+}
+
+func TestUpvotePost_OK(t *testing.T) {
+	post := NewPost(usr1)
+	service, coll, sr := getMockService(t, post)
 
-	result, err := service.UpvotePost(expect.ID, usr2.ID)
+	expectFindPost(coll, sr, *post)
+	shifted := expectShiftVotes(coll, sr, *post, 1, 1)
+	expectFindPost(coll, sr, shifted)
+	expect := copyPost(post)
+	expect.Votes.Upvote(usr2.ID) // nolint:errcheck
+	expect.updatePostScore()
+
+	result, err := service.UpvotePost(emptyCtx, post.ID, usr2.ID)
 
 	if err != nil {
 		t.Errorf("unexpected err: %s", err)
 	}
-	if !reflect.DeepEqual(expect, result) {
-		t.Errorf("results not match:\nwant:\t%#v\nhave\t%#v", expect, result)
+	if want := viewed(&expect, usr2.ID); !reflect.DeepEqual(want, result) {
+		t.Errorf("results not match:\nwant:\t%#v\nhave\t%#v", want, result)
 	}
 }
 
@@ -778,13 +913,13 @@ func TestUpvotePost_ErrNoPost(t *testing.T) {
 	expect := errs.MsgError{Msg: "post not found", Status: 404}
 
 	coll.EXPECT().
-		FindOne(emptyCtx, bson.M{"id": randID}).
+		FindOne(gomock.Any(), bson.M{"id": randID}).
 		Return(sr)
 	sr.EXPECT().
 		Err().
 		Return(mongo.ErrNoDocuments)
 
-	result, err := service.UpvotePost(randID, usr1.ID)
+	result, err := service.UpvotePost(emptyCtx, randID, usr1.ID)
 
 	if result != nil {
 		t.Errorf("unexpected result: %#v", result)
@@ -794,64 +929,41 @@ func TestUpvotePost_ErrNoPost(t *testing.T) {
 	}
 }
 
-func TestUpvotePost_UpvoteErr(t *testing.T) {
-	service, coll, sr := getMockService(t)
-
-	expect := fmt.Errorf("nil vote list")
+func TestUpvotePost_Repeated(t *testing.T) {
 	post := NewPost(usr1)
+	service, coll, sr := getMockService(t, post)
 
-	coll.EXPECT().
-		FindOne(emptyCtx, bson.M{"id": post.ID}).
-		Return(sr)
-	sr.EXPECT().
-		Err().
-		Return(nil)
-	post.Votes.List = nil
-	sr.EXPECT().
-		Decode(&Post{}).SetArg(0, *post).
-		Return(nil)
+	expectFindPost(coll, sr, *post)
+	expectFindPost(coll, sr, *post)
 
-	result, err := service.UpvotePost(post.ID, usr2.ID)
+	result, err := service.UpvotePost(emptyCtx, post.ID, usr1.ID)
 
-	if result != nil {
-		t.Errorf("unexpected result: %#v", result)
+	if err != nil {
+		t.Fatalf("unexpected err: %s", err)
 	}
-	if !reflect.DeepEqual(expect, err) {
-		t.Errorf("results not match:\nwant:\t%#v\nhave\t%#v", expect, err)
+	if len(result.Votes.List) != 1 || result.Score != 1 {
+		t.Errorf("repeated upvote counted: %d votes, score %d", len(result.Votes.List), result.Score)
 	}
 }
 
 func TestUpvotePost_UpdateErr(t *testing.T) {
-	service, coll, sr := getMockService(t)
+	post := NewPost(usr1)
+	service, coll, sr := getMockService(t, post)
 
 	expect := fmt.Errorf("some err")
-	post := NewPost(usr1)
-	tmp := copyPost(post)
 
+	expectFindPost(coll, sr, *post)
 	coll.EXPECT().
-		FindOne(emptyCtx, bson.M{"id": post.ID}).
+		FindOneAndUpdate(gomock.Any(), bson.M{"id": post.ID}, gomock.Any(), gomock.Any()).
 		Return(sr)
 	sr.EXPECT().
 		Err().
-		Return(nil)
+		Return(expect)
 	sr.EXPECT().
-		Decode(&Post{}).SetArg(0, tmp).
-		Return(nil)
-	post.Votes.Upvote(usr1.ID) // nolint:errcheck
-	post.updatePostScore()
-	coll.EXPECT().
-		UpdateOne(
-			emptyCtx,
-			bson.M{"id": post.ID},
-			bson.M{"$set": bson.M{
-				"votes":        post.Votes,
-				"score":        post.Score,
-				"likespercent": post.LikesPercent,
-			}},
-		).
-		Return(nil, expect)
+		Decode(&Post{}).
+		Return(expect)
 
-	result, err := service.UpvotePost(post.ID, usr1.ID)
+	result, err := service.UpvotePost(emptyCtx, post.ID, usr2.ID)
 
 	if result != nil {
 		t.Errorf("unexpected result: %#v", result)
@@ -862,41 +974,23 @@ func TestUpvotePost_UpdateErr(t *testing.T) {
 }
 
 func TestDownvotePost_OK(t *testing.T) {
-	service, coll, sr := getMockService(t)
+	post := NewPost(usr1)
+	service, coll, sr := getMockService(t, post)
 
-	expect := NewPost(usr1)
-	tmp := copyPost(expect)
+	expectFindPost(coll, sr, *post)
+	shifted := expectShiftVotes(coll, sr, *post, 0, 1)
+	expectFindPost(coll, sr, shifted)
+	expect := copyPost(post)
+	expect.Votes.Downvote(usr2.ID) // nolint:errcheck
+	expect.updatePostScore()
 
-	coll.EXPECT().
-		FindOne(emptyCtx, bson.M{"id": expect.ID}).
-		Return(sr)
-	sr.EXPECT().
-		Err().
-		Return(nil)
-	sr.EXPECT().
-		Decode(&Post{}).SetArg(0, tmp).
-		Return(nil)
-	expect.Votes.Downvote(usr1.ID) // nolint:errcheck
-	expect.updatePostScore()
-	coll.EXPECT().
-		UpdateOne(
-			emptyCtx,
-			bson.M{"id": expect.ID},
-			bson.M{"$set": bson.M{
-				"votes":        expect.Votes,
-				"score":        expect.Score,
-				"likespercent": expect.LikesPercent,
-			}},
-		).
-		Return(gomock.Any(), nil)
-
-	result, err := service.DownvotePost(expect.ID, usr1.ID)
+	result, err := service.DownvotePost(emptyCtx, post.ID, usr2.ID)
 
 	if err != nil {
 		t.Errorf("unexpected err: %s", err)
 	}
-	if !reflect.DeepEqual(expect, result) {
-		t.Errorf("results not match:\nwant:\t%#v\nhave\t%#v", expect, result)
+	if want := viewed(&expect, usr2.ID); !reflect.DeepEqual(want, result) {
+		t.Errorf("results not match:\nwant:\t%#v\nhave\t%#v", want, result)
 	}
 }
 
@@ -906,13 +1000,13 @@ func TestDownvotePost_ErrNoPost(t *testing.T) {
 	expect := errs.MsgError{Msg: "post not found", Status: 404}
 
 	coll.EXPECT().
-		FindOne(emptyCtx, bson.M{"id": randID}).
+		FindOne(gomock.Any(), bson.M{"id": randID}).
 		Return(sr)
 	sr.EXPECT().
 		Err().
 		Return(mongo.ErrNoDocuments)
 
-	result, err := service.DownvotePost(randID, usr1.ID)
+	result, err := service.DownvotePost(emptyCtx, randID, usr1.ID)
 
 	if result != nil {
 		t.Errorf("unexpected result: %#v", result)
@@ -922,64 +1016,42 @@ func TestDownvotePost_ErrNoPost(t *testing.T) {
 	}
 }
 
-func TestDownvotePost_DownvoteErr(t *testing.T) {
-	service, coll, sr := getMockService(t)
-
-	expect := fmt.Errorf("nil vote list")
+func TestDownvotePost_ChangeVote(t *testing.T) {
 	post := NewPost(usr1)
+	service, coll, sr := getMockService(t, post)
 
-	coll.EXPECT().
-		FindOne(emptyCtx, bson.M{"id": post.ID}).
-		Return(sr)
-	sr.EXPECT().
-		Err().
-		Return(nil)
-	post.Votes.List = nil
-	sr.EXPECT().
-		Decode(&Post{}).SetArg(0, *post).
-		Return(nil)
+	expectFindPost(coll, sr, *post)
+	shifted := expectShiftVotes(coll, sr, *post, -1, 0)
+	expectFindPost(coll, sr, shifted)
 
-	result, err := service.DownvotePost(post.ID, usr1.ID)
+	result, err := service.DownvotePost(emptyCtx, post.ID, usr1.ID)
 
-	if result != nil {
-		t.Errorf("unexpected result: %#v", result)
+	if err != nil {
+		t.Fatalf("unexpected err: %s", err)
 	}
-	if !reflect.DeepEqual(expect, err) {
-		t.Errorf("results not match:\nwant:\t%#v\nhave\t%#v", expect, err)
+	if len(result.Votes.List) != 1 || result.Votes.List[0].Value != Dislike || result.Score != -1 {
+		t.Errorf("vote not changed: %#v, score %d", result.Votes.List, result.Score)
 	}
 }
 
 func TestDownvotePost_UpdateErr(t *testing.T) {
-	service, coll, sr := getMockService(t)
+	post := NewPost(usr1)
+	service, coll, sr := getMockService(t, post)
 
 	expect := fmt.Errorf("some err")
-	post := NewPost(usr1)
-	tmp := copyPost(post)
 
+	expectFindPost(coll, sr, *post)
 	coll.EXPECT().
-		FindOne(emptyCtx, bson.M{"id": post.ID}).
+		FindOneAndUpdate(gomock.Any(), bson.M{"id": post.ID}, gomock.Any(), gomock.Any()).
 		Return(sr)
 	sr.EXPECT().
 		Err().
-		Return(nil)
+		Return(expect)
 	sr.EXPECT().
-		Decode(&Post{}).SetArg(0, tmp).
-		Return(nil)
-	post.Votes.Downvote(usr2.ID) // nolint:errcheck
-	post.updatePostScore()
-	coll.EXPECT().
-		UpdateOne(
-			emptyCtx,
-			bson.M{"id": post.ID},
-			bson.M{"$set": bson.M{
-				"votes":        post.Votes,
-				"score":        post.Score,
-				"likespercent": post.LikesPercent,
-			}},
-		).
-		Return(nil, expect)
+		Decode(&Post{}).
+		Return(expect)
 
-	result, err := service.DownvotePost(post.ID, usr2.ID)
+	result, err := service.DownvotePost(emptyCtx, post.ID, usr2.ID)
 
 	if result != nil {
 		t.Errorf("unexpected result: %#v", result)
@@ -990,41 +1062,25 @@ func TestDownvotePost_UpdateErr(t *testing.T) {
 }
 
 func TestUnvotePost_OK(t *testing.T) {
-	service, coll, sr := getMockService(t)
-
-	expect := NewPost(usr1)
-	tmp := copyPost(expect)
+	post := NewPost(usr1)
+	post.Votes.Upvote(usr2.ID) // nolint:errcheck
+	post.updatePostScore()
+	service, coll, sr := getMockService(t, post)
 
-	coll.EXPECT().
-		FindOne(emptyCtx, bson.M{"id": expect.ID}).
-		Return(sr)
-	sr.EXPECT().
-		Err().
-		Return(nil)
-	sr.EXPECT().
-		Decode(&Post{}).SetArg(0, tmp).
-		Return(nil)
-	expect.Votes.Unvote(usr1.ID) // nolint:errcheck
+	expectFindPost(coll, sr, *post)
+	shifted := expectShiftVotes(coll, sr, *post, -1, -1)
+	expectFindPost(coll, sr, shifted)
+	expect := copyPost(post)
+	expect.Votes.Unvote(usr2.ID) // nolint:errcheck
 	expect.updatePostScore()
-	coll.EXPECT().
-		UpdateOne(
-			emptyCtx,
-			bson.M{"id": expect.ID},
-			bson.M{"$set": bson.M{
-				"votes":        expect.Votes,
-				"score":        expect.Score,
-				"likespercent": expect.LikesPercent,
-			}},
-		).
-		Return(gomock.Any(), nil)
 
-	result, err := service.UnvotePost(expect.ID, usr1.ID)
+	result, err := service.UnvotePost(emptyCtx, post.ID, usr2.ID)
 
 	if err != nil {
 		t.Errorf("unexpected err: %s", err)
 	}
-	if !reflect.DeepEqual(expect, result) {
-		t.Errorf("results not match:\nwant:\t%#v\nhave\t%#v", expect, result)
+	if want := viewed(&expect, usr2.ID); !reflect.DeepEqual(want, result) {
+		t.Errorf("results not match:\nwant:\t%#v\nhave\t%#v", want, result)
 	}
 }
 
@@ -1034,13 +1090,13 @@ func TestUnvotePost_ErrNoPost(t *testing.T) {
 	expect := errs.MsgError{Msg: "post not found", Status: 404}
 
 	coll.EXPECT().
-		FindOne(emptyCtx, bson.M{"id": randID}).
+		FindOne(gomock.Any(), bson.M{"id": randID}).
 		Return(sr)
 	sr.EXPECT().
 		Err().
 		Return(mongo.ErrNoDocuments)
 
-	result, err := service.UnvotePost(randID, usr1.ID)
+	result, err := service.UnvotePost(emptyCtx, randID, usr1.ID)
 
 	if result != nil {
 		t.Errorf("unexpected result: %#v", result)
@@ -1050,69 +1106,115 @@ func TestUnvotePost_ErrNoPost(t *testing.T) {
 	}
 }
 
-func TestUnvotePost_UnvoteErr(t *testing.T) {
-	service, coll, sr := getMockService(t)
+func TestUnvotePost_NoVote(t *testing.T) {
+	post := NewPost(usr1)
+	service, coll, sr := getMockService(t, post)
+
+	expectFindPost(coll, sr, *post)
+	expectFindPost(coll, sr, *post)
+
+	result, err := service.UnvotePost(emptyCtx, post.ID, usr2.ID)
 
-	expect := fmt.Errorf("nil vote list")
+	if err != nil {
+		t.Fatalf("unexpected err: %s", err)
+	}
+	if len(result.Votes.List) != 0 || result.Votes.LikesCount != 1 || result.Score != 1 {
+		t.Errorf("wrong votes: %d votes, score %d", len(result.Votes.List), result.Score)
+	}
+}
+
+func TestUnvotePost_UpdateErr(t *testing.T) {
 	post := NewPost(usr1)
+	service, coll, sr := getMockService(t, post)
 
+	expect := fmt.Errorf("some err")
+
+	expectFindPost(coll, sr, *post)
 	coll.EXPECT().
-		FindOne(emptyCtx, bson.M{"id": post.ID}).
+		FindOneAndUpdate(gomock.Any(), bson.M{"id": post.ID}, gomock.Any(), gomock.Any()).
 		Return(sr)
 	sr.EXPECT().
 		Err().
-		Return(nil)
-	post.Votes.List = nil
+		Return(expect)
 	sr.EXPECT().
-		Decode(&Post{}).SetArg(0, *post).
-		Return(nil)
+		Decode(&Post{}).
+		Return(expect)
 
-	result, err := service.UnvotePost(post.ID, usr2.ID)
+	result, err := service.UnvotePost(emptyCtx, post.ID, usr1.ID)
 
 	if result != nil {
 		t.Errorf("unexpected result: %#v", result)
 	}
-	if !reflect.DeepEqual(expect, err) {
+	if !errors.Is(err, expect) {
 		t.Errorf("results not match:\nwant:\t%#v\nhave\t%#v", expect, err)
 	}
 }
 
-func TestUnvotePost_UpdateErr(t *testing.T) {
-	service, coll, sr := getMockService(t)
+func TestUpvoteComment_OK(t *testing.T) {
+	post := NewPost(usr1)
+	comm := NewComment(usr1, "some text")
+	post.Comments.Add(comm) // nolint:errcheck
+	service, coll, sr := getMockService(t, post)
 
-	expect := fmt.Errorf("some err")
+	expectComm := *comm
+	expectComm.Votes.List = append([]Vote{}, comm.Votes.List...)
+	expectComm.vote(usr2.ID, (*VoteList).Upvote) // nolint:errcheck
+	expect := copyPost(post)
+	expect.Comments = CommentList{&expectComm}
+
+	expectFindPost(coll, sr, *post)
+
+	result, err := service.UpvoteComment(emptyCtx, post.ID, comm.ID, usr2.ID)
+
+	if err != nil {
+		t.Fatalf("unexpected err: %s", err)
+	}
+	expectComm.Created = result.Comments[0].Created
+	if want := viewed(&expect, usr2.ID); !reflect.DeepEqual(want, result) {
+		t.Errorf("results not match:\nwant:\t%#v\nhave\t%#v", want, result)
+	}
+	if result.Comments[0].Score != 2 || result.Comments[0].LikesPercent != 100 {
+		t.Errorf("wrong comment score: %d, %d%%", result.Comments[0].Score, result.Comments[0].LikesPercent)
+	}
+}
+
+func TestDownvoteComment_ErrNoComment(t *testing.T) {
 	post := NewPost(usr1)
-	tmp := copyPost(post)
+	service, _, _ := getMockService(t, post)
+
+	expect := errs.MsgError{Msg: "comment not found", Status: 404}
+
+	result, err := service.DownvoteComment(emptyCtx, post.ID, randID, usr2.ID)
+
+	if result != nil {
+		t.Errorf("unexpected result: %#v", result)
+	}
+	if !reflect.DeepEqual(expect, err) {
+		t.Errorf("results not match:\nwant:\t%#v\nhave\t%#v", expect, err)
+	}
+}
+
+func TestUnvoteComment_ErrNoPost(t *testing.T) {
+	post := NewPost(usr1)
+	comm := NewComment(usr1, "some text")
+	post.Comments.Add(comm) // nolint:errcheck
+	service, coll, sr := getMockService(t, post)
+
+	expect := errs.MsgError{Msg: "post not found", Status: 404}
 
 	coll.EXPECT().
-		FindOne(emptyCtx, bson.M{"id": post.ID}).
+		FindOne(gomock.Any(), bson.M{"id": post.ID}).
 		Return(sr)
 	sr.EXPECT().
 		Err().
-		Return(nil)
-	sr.EXPECT().
-		Decode(&Post{}).SetArg(0, tmp).
-		Return(nil)
-	post.Votes.Unvote(usr2.ID) // nolint:errcheck
-	post.updatePostScore()
-	coll.EXPECT().
-		UpdateOne(
-			emptyCtx,
-			bson.M{"id": post.ID},
-			bson.M{"$set": bson.M{
-				"votes":        post.Votes,
-				"score":        post.Score,
-				"likespercent": post.LikesPercent,
-			}},
-		).
-		Return(nil, expect)
+		Return(mongo.ErrNoDocuments)
 
-	result, err := service.UnvotePost(post.ID, usr2.ID)
+	result, err := service.UnvoteComment(emptyCtx, post.ID, comm.ID, usr1.ID)
 
 	if result != nil {
 		t.Errorf("unexpected result: %#v", result)
 	}
-	if !errors.Is(err, expect) {
+	if !reflect.DeepEqual(expect, err) {
 		t.Errorf("results not match:\nwant:\t%#v\nhave\t%#v", expect, err)
 	}
 }
@@ -1124,26 +1226,32 @@ func TestGetByUser_OK(t *testing.T) {
 	cln := NewMockMongoCollection(ctrl)
 	cs := NewMockMongoCursor(ctrl)
 
-	service := &PostRepositoryMongo{coll: cln}
+	votes, comments := newFakeChildren()
+	service := &PostRepositoryMongo{coll: cln, votes: votes, comments: comments}
 
 	postFirst := NewPost(usr1)
 	postSecond := NewPost(usr1)
 	postLast := NewPost(usr1)
-	posts := []*Post{postSecond, postLast, postFirst}
 	expect := []*Post{postLast, postSecond, postFirst}
+	opts := options.Find().
+		SetSort(bson.D{{Key: "created", Value: -1}, {Key: "id", Value: 1}}).
+		SetLimit(DefaultPageLimit + 1)
 
 	cln.EXPECT().
-		Find(emptyCtx, bson.M{"author.username": usr1.ID}).
+		Find(gomock.Any(), bson.M{"author.username": usr1.ID}, opts).
 		Return(cs, nil)
 	cs.EXPECT().
-		All(emptyCtx, &[]*Post{}).SetArg(1, posts).
+		All(gomock.Any(), &[]*Post{}).SetArg(1, expect).
 		Return(nil)
 
-	result, err := service.GetByUser(usr1.ID)
+	result, next, err := service.GetByUser(emptyCtx, usr1.ID, New, Page{Limit: DefaultPageLimit}, "")
 
 	if err != nil {
 		t.Errorf("unexpected err: %s", err)
 	}
+	if next != "" {
+		t.Errorf("unexpected next cursor: %s", next)
+	}
 	if len(result) != len(expect) {
 		t.Errorf("bad result len:\nwant:\t%v\nhave\t%v", len(expect), len(result))
 		return
@@ -1162,18 +1270,179 @@ func TestGetByUser_ErrNoPosts(t *testing.T) {
 	cln := NewMockMongoCollection(ctrl)
 	cs := NewMockMongoCursor(ctrl)
 
-	service := &PostRepositoryMongo{coll: cln}
+	votes, comments := newFakeChildren()
+	service := &PostRepositoryMongo{coll: cln, votes: votes, comments: comments}
 
 	expect := fmt.Errorf("some err")
 
 	cln.EXPECT().
-		Find(emptyCtx, bson.M{"author.username": usr1.ID}).
+		Find(gomock.Any(), bson.M{"author.username": usr1.ID}, gomock.Any()).
 		Return(cs, nil)
 	cs.EXPECT().
-		All(emptyCtx, &[]*Post{}).
+		All(gomock.Any(), &[]*Post{}).
 		Return(expect)
 
-	result, err := service.GetByUser(usr1.ID)
+	result, _, err := service.GetByUser(emptyCtx, usr1.ID, New, Page{}, "")
+
+	if result != nil {
+		t.Errorf("unexpected result: %#v", result)
+	}
+	if !errors.Is(err, expect) {
+		t.Errorf("results not match:\nwant:\t%#v\nhave\t%#v", expect, err)
+	}
+}
+
+func TestGetByUser_NextPage(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	cln := NewMockMongoCollection(ctrl)
+	cs := NewMockMongoCursor(ctrl)
+
+	votes, comments := newFakeChildren()
+	service := &PostRepositoryMongo{coll: cln, votes: votes, comments: comments}
+
+	prev := NewPost(usr1)
+	prev.Created = prev.Created.UTC()
+	posts := []*Post{NewPost(usr1), NewPost(usr1), NewPost(usr1)}
+	filter := bson.M{"$and": bson.A{
+		bson.M{"author.username": usr1.ID},
+		bson.M{"$or": bson.A{
+			bson.M{"created": bson.M{"$lt": prev.Created}},
+			bson.M{"created": prev.Created, "id": bson.M{"$gt": prev.ID}},
+		}},
+	}}
+	opts := options.Find().
+		SetSort(bson.D{{Key: "created", Value: -1}, {Key: "id", Value: 1}}).
+		SetLimit(3)
+
+	cln.EXPECT().
+		Find(gomock.Any(), filter, opts).
+		Return(cs, nil)
+	cs.EXPECT().
+		All(gomock.Any(), &[]*Post{}).SetArg(1, posts).
+		Return(nil)
+
+	result, next, err := service.GetByUser(emptyCtx, usr1.ID, New, Page{Limit: 2, After: encodeCursor(prev)}, "")
+
+	if err != nil {
+		t.Errorf("unexpected err: %s", err)
+	}
+	if !reflect.DeepEqual(posts[:2], result) {
+		t.Errorf("results not match:\nwant:\t%#v\nhave\t%#v", posts[:2], result)
+	}
+	if next != encodeCursor(posts[1]) {
+		t.Errorf("results not match:\nwant:\t%s\nhave\t%s", encodeCursor(posts[1]), next)
+	}
+}
+
+func TestGetByUser_InvalidCursor(t *testing.T) {
+	service, _, _ := getMockService(t)
+
+	expect := errs.MsgError{Msg: "invalid cursor", Status: 400}
+
+	result, _, err := service.GetByUser(emptyCtx, usr1.ID, New, Page{After: "bad cursor"}, "")
+
+	if result != nil {
+		t.Errorf("unexpected result: %#v", result)
+	}
+	if !reflect.DeepEqual(expect, err) {
+		t.Errorf("results not match:\nwant:\t%#v\nhave\t%#v", expect, err)
+	}
+}
+
+// Лента rising укладывается в окно по дате создания, а посты автора в один индекс
+func TestRankingIndexes(t *testing.T) {
+	authorIndexes := 0
+	for _, model := range rankingIndexes() {
+		keys := model.Keys.(bson.D)
+		switch keys[0].Key {
+		case "rising":
+			t.Errorf("unexpected rising index: %v", keys)
+		case "author.username":
+			authorIndexes++
+		case "category":
+			if keys[1].Key == "rising" {
+				t.Errorf("unexpected rising index: %v", keys)
+			}
+		}
+	}
+	if authorIndexes != 1 {
+		t.Errorf("wrong author indexes count:\nwant:\t%d\nhave\t%d", 1, authorIndexes)
+	}
+}
+
+func TestUpdatePost_OK(t *testing.T) {
+	post := NewPost(usr1)
+	post.Type = Text
+	post.Title = "old title"
+	post.Text = "old text"
+	votes, comments := newFakeChildren(post)
+	service := &PostRepositoryMongo{
+		coll:      newFakeCollection(post),
+		votes:     votes,
+		comments:  comments,
+		revisions: newFakeCollection(),
+	}
+	upd := PostEdit{Title: "new title", URL: "http://ignored.for/text/post"}
+
+	result, err := service.UpdatePost(emptyCtx, post.ID, usr1.ID, upd)
+
+	if err != nil {
+		t.Fatalf("unexpected err: %s", err)
+	}
+	if result.Title != upd.Title || result.Text != post.Text || result.URL != "" || result.EditedFormat == "" {
+		t.Errorf("bad updated post: %#v", result)
+	}
+	expectRevs := []Revision{{
+		Title:         post.Title,
+		Text:          post.Text,
+		Created:       post.Created.UTC().Truncate(time.Millisecond),
+		CreatedFormat: post.CreatedFormat,
+	}}
+	stored, err := service.GetRevisions(emptyCtx, post.ID)
+	if err != nil || !reflect.DeepEqual(expectRevs, stored) {
+		t.Errorf("results not match:\nwant:\t%#v\nhave\t%#v", expectRevs, stored)
+	}
+}
+func TestUpdatePost_AuthErr(t *testing.T) {
+	service, coll, sr := getMockService(t)
+
+	expect := errs.MsgError{Msg: "unauthorized", Status: 401}
+	post := NewPost(usr1)
+
+	coll.EXPECT().
+		FindOne(gomock.Any(), bson.M{"id": post.ID}).
+		Return(sr)
+	sr.EXPECT().
+		Err().
+		Return(nil)
+	sr.EXPECT().
+		Decode(&Post{}).SetArg(0, *post).
+		Return(nil)
+
+	result, err := service.UpdatePost(emptyCtx, post.ID, usr2.ID, PostEdit{Title: "new title"})
+
+	if result != nil {
+		t.Errorf("unexpected result: %#v", result)
+	}
+	if !reflect.DeepEqual(expect, err) {
+		t.Errorf("results not match:\nwant:\t%#v\nhave\t%#v", expect, err)
+	}
+}
+
+func TestUpdatePost_UpdateErr(t *testing.T) {
+	service, coll, sr := getMockService(t)
+
+	expect := fmt.Errorf("some error")
+	post := NewPost(usr1)
+
+	expectFindPost(coll, sr, *post)
+	coll.EXPECT().
+		FindOneAndUpdate(gomock.Any(), bson.M{"id": post.ID, "author.id": usr1.ID}, gomock.Any(), gomock.Any()).
+		Return(&fakeSingleResult{err: expect})
+
+	result, err := service.UpdatePost(emptyCtx, post.ID, usr1.ID, PostEdit{Title: "new title"})
 
 	if result != nil {
 		t.Errorf("unexpected result: %#v", result)
@@ -1182,3 +1451,98 @@ func TestGetByUser_ErrNoPosts(t *testing.T) {
 		t.Errorf("results not match:\nwant:\t%#v\nhave\t%#v", expect, err)
 	}
 }
+
+// Пост удалили между чтением и правкой
+func TestUpdatePost_DeletedErr(t *testing.T) {
+	service, coll, sr := getMockService(t)
+
+	expect := errs.MsgError{Msg: "post not found", Status: 404}
+	post := NewPost(usr1)
+
+	expectFindPost(coll, sr, *post)
+	coll.EXPECT().
+		FindOneAndUpdate(gomock.Any(), bson.M{"id": post.ID, "author.id": usr1.ID}, gomock.Any(), gomock.Any()).
+		Return(&fakeSingleResult{err: mongo.ErrNoDocuments})
+
+	result, err := service.UpdatePost(emptyCtx, post.ID, usr1.ID, PostEdit{Title: "new title"})
+
+	if result != nil {
+		t.Errorf("unexpected result: %#v", result)
+	}
+	if !reflect.DeepEqual(expect, err) {
+		t.Errorf("results not match:\nwant:\t%#v\nhave\t%#v", expect, err)
+	}
+}
+func TestGetRevisions_OK(t *testing.T) {
+	post := NewPost(usr1)
+	post.Title = "first title"
+	first := post.edit(PostEdit{Title: "second title"})
+	second := post.edit(PostEdit{Title: "third title"})
+	service := &PostRepositoryMongo{
+		coll: newFakeCollection(post),
+		revisions: newFakeCollection(
+			revisionDoc{PostID: post.ID, Revision: first},
+			revisionDoc{PostID: randID, Revision: first},
+			revisionDoc{PostID: post.ID, Revision: second},
+		),
+	}
+
+	result, err := service.GetRevisions(emptyCtx, post.ID)
+
+	if err != nil {
+		t.Errorf("unexpected err: %s", err)
+	}
+	if len(result) != 2 || result[0].Title != "first title" || result[1].Title != "second title" {
+		t.Errorf("bad revisions: %#v", result)
+	}
+}
+
+// Если запись в историю не удалась, правка откатывается
+func TestUpdatePost_RevisionErr(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	revisions := NewMockMongoCollection(ctrl)
+
+	post := NewPost(usr1)
+	post.Title = "old title"
+	expect := fmt.Errorf("insert err")
+	service := &PostRepositoryMongo{coll: newFakeCollection(post), revisions: revisions}
+
+	revisions.EXPECT().
+		InsertOne(gomock.Any(), gomock.Any()).
+		Return(nil, expect)
+
+	result, err := service.UpdatePost(emptyCtx, post.ID, usr1.ID, PostEdit{Title: "new title"})
+
+	if result != nil {
+		t.Errorf("unexpected result: %#v", result)
+	}
+	if !errors.Is(err, expect) {
+		t.Errorf("results not match:\nwant:\t%#v\nhave\t%#v", expect, err)
+	}
+	stored := fakeDocs(service.coll)[0]
+	if stored["title"] != "old title" || stored["editedformat"] != "" {
+		t.Errorf("edit not reverted: %v", stored)
+	}
+}
+func TestGetRevisions_ErrNoPost(t *testing.T) {
+	service, coll, sr := getMockService(t)
+
+	expect := errs.MsgError{Msg: "post not found", Status: 404}
+
+	coll.EXPECT().
+		FindOne(gomock.Any(), bson.M{"id": randID}).
+		Return(sr)
+	sr.EXPECT().
+		Err().
+		Return(mongo.ErrNoDocuments)
+
+	result, err := service.GetRevisions(emptyCtx, randID)
+
+	if result != nil {
+		t.Errorf("unexpected result: %#v", result)
+	}
+	if !reflect.DeepEqual(expect, err) {
+		t.Errorf("results not match:\nwant:\t%#v\nhave\t%#v", expect, err)
+	}
+}
@@ -0,0 +1,68 @@
+package post
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+type Revision struct {
+	Title         string    `json:"title"`
+	URL           string    `json:"url,omitempty"`
+	Text          string    `json:"text,omitempty"`
+	Created       time.Time `json:"-"`
+	CreatedFormat string    `json:"created"`
+}
+
+type PostEdit struct {
+	Title string `json:"title"`
+	URL   string `json:"url"`
+	Text  string `json:"text"`
+}
+
+// Сохраняет текущую версию поста в истории и применяет к нему изменения
+func (p *Post) edit(upd PostEdit) Revision {
+	rev := p.revision()
+	p.applyEdit(upd, time.Now())
+	p.Revisions = append(p.Revisions, rev)
+	return rev
+}
+
+// Текущая версия поста в виде записи истории
+func (p *Post) revision() Revision {
+	rev := Revision{
+		Title:         p.Title,
+		URL:           p.URL,
+		Text:          p.Text,
+		Created:       p.Created,
+		CreatedFormat: p.CreatedFormat,
+	}
+	if !p.Edited.IsZero() {
+		rev.Created = p.Edited
+		rev.CreatedFormat = p.EditedFormat
+	}
+	return rev
+}
+
+// Применяет правку и возвращает измененные поля документа.
+// Пустые поля правки оставляют прежние значения; набор полей зависит только от типа поста.
+func (p *Post) applyEdit(upd PostEdit, t time.Time) bson.M {
+	set := bson.M{}
+	if upd.Title != "" {
+		p.Title = upd.Title
+		set["title"] = p.Title
+	}
+	if upd.URL != "" && p.Type == Link {
+		p.URL = upd.URL
+		set["url"] = p.URL
+	}
+	if upd.Text != "" && p.Type == Text {
+		p.Text = upd.Text
+		set["text"] = p.Text
+	}
+	p.Edited = t
+	p.EditedFormat = t.Format(time.RFC3339Nano)
+	set["edited"] = p.Edited
+	set["editedformat"] = p.EditedFormat
+	return set
+}
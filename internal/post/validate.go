@@ -0,0 +1,50 @@
+package post
+
+import "asperitas/internal/validate"
+
+const (
+	MaxTitleLen   = 300
+	MaxTextLen    = 40000
+	MaxCommentLen = 10000
+)
+
+// Проверяет поля, пришедшие от клиента. Существование сообщества проверяет
+// обработчик: для этого нужен репозиторий сообществ.
+func (p *Post) Validate(v *validate.Validator) {
+	v.OneOf("type", string(p.Type), string(Text), string(Link))
+	v.Required("title", p.Title)
+	v.Length("title", p.Title, 1, MaxTitleLen)
+	v.Printable("title", p.Title, false)
+	v.Required("category", string(p.Category))
+	switch p.Type {
+	case Link:
+		v.Required("url", p.URL)
+		v.HTTPURL("url", p.URL)
+	case Text:
+		v.Required("text", p.Text)
+		v.Length("text", p.Text, 1, MaxTextLen)
+		v.Printable("text", p.Text, true)
+	}
+}
+
+// Пустые поля правки не меняют пост, поэтому проверяются только заполненные
+func (upd PostEdit) Validate(v *validate.Validator) {
+	v.Check(upd != PostEdit{}, "title", "", "nothing to update")
+	if upd.Title != "" {
+		v.Length("title", upd.Title, 1, MaxTitleLen)
+		v.Printable("title", upd.Title, false)
+	}
+	if upd.URL != "" {
+		v.HTTPURL("url", upd.URL)
+	}
+	if upd.Text != "" {
+		v.Length("text", upd.Text, 1, MaxTextLen)
+		v.Printable("text", upd.Text, true)
+	}
+}
+
+func ValidateComment(v *validate.Validator, body string) {
+	v.Required("comment", body)
+	v.Length("comment", body, 1, MaxCommentLen)
+	v.Printable("comment", body, true)
+}
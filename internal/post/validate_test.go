@@ -0,0 +1,53 @@
+package post
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"asperitas/internal/errs"
+	"asperitas/internal/validate"
+)
+
+func TestPost_Validate(t *testing.T) {
+	cases := []struct {
+		post   Post
+		params []string
+	}{
+		{Post{Type: Text, Title: "title", Category: "music", Text: "text"}, nil},
+		{Post{Type: Link, Title: "title", Category: "music", URL: "http://go.dev"}, nil},
+		{Post{Type: "video", Title: "title", Category: "music"}, []string{"type"}},
+		{Post{Type: Text, Category: "music", Text: "text"}, []string{"title"}},
+		{Post{Type: Text, Title: strings.Repeat("т", MaxTitleLen+1), Category: "music", Text: "text"}, []string{"title"}},
+		{Post{Type: Text, Title: "title\r\n", Category: "music", Text: "text"}, []string{"title"}},
+		{Post{Type: Text, Title: "title", Text: "text"}, []string{"category"}},
+		{Post{Type: Text, Title: "title", Category: "music"}, []string{"text"}},
+		{Post{Type: Link, Title: "title", Category: "music"}, []string{"url"}},
+		{Post{Type: Link, Title: "title", Category: "music", URL: "ftp://go.dev"}, []string{"url"}},
+		{Post{Type: Link, Category: "music", URL: "go.dev"}, []string{"title", "url"}},
+	}
+	for _, c := range cases {
+		v := validate.New()
+		c.post.Validate(v)
+		var params []string
+		if err := v.Err(); err != nil {
+			for _, e := range err.(errs.DetailErrors).Errors {
+				params = append(params, e.Param)
+			}
+		}
+		if !reflect.DeepEqual(c.params, params) {
+			t.Errorf("results not match for %#v:\nwant:\t%#v\nhave\t%#v", c.post, c.params, params)
+		}
+	}
+}
+
+func TestPostEdit_Validate(t *testing.T) {
+	v := validate.New()
+	PostEdit{URL: "mailto:admin@example.com"}.Validate(v)
+	expect := errs.DetailErrors{Errors: []errs.DetailError{
+		{Location: "body", Param: "url", Value: "mailto:admin@example.com", Msg: "must be http or https url"},
+	}, Status: 422}
+	if err := v.Err(); !reflect.DeepEqual(expect, err) {
+		t.Errorf("results not match:\nwant:\t%#v\nhave\t%#v", expect, err)
+	}
+}
@@ -31,10 +31,8 @@ func (l *VoteList) UnmarshalJSON(data []byte) error {
 }
 
 func NewVoteList(id string) VoteList {
-	votes := make([]Vote, 0, 1000)
-	votes = append(votes, Vote{Value: Like, UserID: id})
 	return VoteList{
-		List:       votes,
+		List:       []Vote{{Value: Like, UserID: id}},
 		LikesCount: 1,
 	}
 }
@@ -43,13 +41,16 @@ func (l *VoteList) Upvote(userID string) error {
 	if l == nil || l.List == nil {
 		return fmt.Errorf("nil vote list")
 	}
-	l.LikesCount++
 	for i, vote := range l.List {
 		if vote.UserID == userID {
+			if vote.Value != Like {
+				l.LikesCount++
+			}
 			l.List[i].Value = Like
 			return nil
 		}
 	}
+	l.LikesCount++
 	l.List = append(l.List, Vote{UserID: userID, Value: Like})
 	return nil
 }
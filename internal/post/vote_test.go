@@ -0,0 +1,34 @@
+package post
+
+import (
+	"testing"
+)
+
+func TestVoteList_UpvoteIdempotent(t *testing.T) {
+	votes := NewVoteList(usr1.ID)
+
+	for i := 0; i < 3; i++ {
+		if err := votes.Upvote(usr1.ID); err != nil {
+			t.Fatalf("unexpected err: %s", err)
+		}
+		if err := votes.Upvote(usr2.ID); err != nil {
+			t.Fatalf("unexpected err: %s", err)
+		}
+	}
+
+	if len(votes.List) != 2 || votes.LikesCount != 2 {
+		t.Errorf("wrong votes: %d likes of %d", votes.LikesCount, len(votes.List))
+	}
+}
+
+func TestVoteList_DownvoteThenUpvote(t *testing.T) {
+	votes := NewVoteList(usr1.ID)
+
+	votes.Downvote(usr1.ID) // nolint:errcheck
+	votes.Downvote(usr1.ID) // nolint:errcheck
+	votes.Upvote(usr1.ID)   // nolint:errcheck
+
+	if len(votes.List) != 1 || votes.LikesCount != 1 {
+		t.Errorf("wrong votes: %d likes of %d", votes.LikesCount, len(votes.List))
+	}
+}
@@ -0,0 +1,92 @@
+package ratelimit
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"asperitas/internal/logging"
+	"asperitas/pkg/clientip"
+
+	"github.com/gorilla/mux"
+	"go.uber.org/zap"
+)
+
+// Policy ограничивает маршрут отдельно по IP и по аутентифицированному пользователю,
+// запрос проходит, только если токен нашелся в обеих корзинах.
+// Name — префикс ключей корзин: маршруты с одним Name делят корзины между собой.
+type Policy struct {
+	Name string
+	IP   Limit
+	User Limit
+}
+
+type Limiter struct {
+	Store Store
+	// Идентификатор пользователя из запроса; вызывается на каждый запрос, поэтому не должен ходить в базу
+	Identify func(r *http.Request) (userID string, ok bool)
+	// Адрес клиента для корзин по IP
+	ClientIP clientip.Resolver
+	// Политики по "METHOD шаблон маршрута", для остальных маршрутов Default
+	Policies map[string]Policy
+	Default  Policy
+	Logger   *zap.SugaredLogger
+}
+
+// Подключается через mux.Router.Use: политика выбирается по шаблону сопоставленного маршрута
+func (l *Limiter) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		policy := l.policy(r)
+		keys := make([]string, 0, 2)
+		limits := make([]Limit, 0, 2)
+		if policy.IP.enabled() {
+			keys = append(keys, policy.Name+":ip:"+l.ClientIP.FromRequest(r))
+			limits = append(limits, policy.IP)
+		}
+		if policy.User.enabled() && l.Identify != nil {
+			if userID, ok := l.Identify(r); ok {
+				keys = append(keys, policy.Name+":user:"+userID)
+				limits = append(limits, policy.User)
+			}
+		}
+
+		for i, key := range keys {
+			allowed, retryAfter, err := l.Store.Take(r.Context(), key, limits[i])
+			if err != nil {
+				// недоступное хранилище не должно класть весь сервис, пропускаем запрос
+				logging.FromContext(r.Context(), l.Logger).Errorf("rate limit store err: %s", err)
+				continue
+			}
+			if !allowed {
+				logging.FromContext(r.Context(), l.Logger).Infof("rate limited: key=%s retry_after=%s", key, retryAfter)
+				writeTooManyRequests(w, retryAfter)
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (l *Limiter) policy(r *http.Request) Policy {
+	if current := mux.CurrentRoute(r); current != nil {
+		if tpl, err := current.GetPathTemplate(); err == nil {
+			if policy, ok := l.Policies[r.Method+" "+tpl]; ok {
+				return policy
+			}
+		}
+	}
+	return l.Default
+}
+
+func writeTooManyRequests(w http.ResponseWriter, retryAfter time.Duration) {
+	seconds := int(math.Ceil(retryAfter.Seconds()))
+	if seconds < 1 {
+		seconds = 1
+	}
+	w.Header().Set("Retry-After", strconv.Itoa(seconds))
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(http.StatusTooManyRequests)
+	fmt.Fprint(w, `{"message":"too many requests"}`) // nolint:errcheck
+}
@@ -0,0 +1,156 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"asperitas/pkg/clientip"
+
+	"github.com/gorilla/mux"
+	"go.uber.org/zap"
+)
+
+type storeFunc func(ctx context.Context, key string, limit Limit) (bool, time.Duration, error)
+
+func (f storeFunc) Take(ctx context.Context, key string, limit Limit) (bool, time.Duration, error) {
+	return f(ctx, key, limit)
+}
+
+var (
+	loginPolicy = Policy{Name: "login", IP: Limit{Burst: 1, Interval: 90 * time.Second}}
+	postsPolicy = Policy{
+		Name: "posts",
+		IP:   Limit{Burst: 10, Interval: time.Second},
+		User: Limit{Burst: 1, Interval: time.Minute},
+	}
+)
+
+func getRouter(l *Limiter) *mux.Router {
+	ok := func(w http.ResponseWriter, r *http.Request) {}
+	r := mux.NewRouter()
+	r.Use(l.Middleware)
+	r.HandleFunc("/api/login", ok).Methods("POST")
+	r.HandleFunc("/api/posts", ok).Methods("POST")
+	r.HandleFunc("/api/posts/", ok).Methods("GET")
+	return r
+}
+
+func getLimiter(store Store) *Limiter {
+	return &Limiter{
+		Store: store,
+		Identify: func(r *http.Request) (string, bool) {
+			userID := r.Header.Get("Authorization")
+			return userID, userID != ""
+		},
+		Policies: map[string]Policy{
+			"POST /api/login": loginPolicy,
+			"POST /api/posts": postsPolicy,
+		},
+		Logger: zap.NewNop().Sugar(),
+	}
+}
+
+func serve(r http.Handler, method, target, remoteAddr, userID string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, target, nil)
+	req.RemoteAddr = remoteAddr
+	if userID != "" {
+		req.Header.Set("Authorization", userID)
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestMiddleware_TooManyRequests(t *testing.T) {
+	r := getRouter(getLimiter(NewMemoryStore()))
+
+	if w := serve(r, "POST", "/api/login", "1.1.1.1:5000", ""); w.Code != 200 {
+		t.Fatalf("wrong status code:\nwant:\t%d\nhave\t%d", 200, w.Code)
+	}
+	// другой порт того же клиента делит с ним корзину
+	w := serve(r, "POST", "/api/login", "1.1.1.1:5001", "")
+	expected := `{"message":"too many requests"}`
+	if w.Code != 429 || w.Body.String() != expected {
+		t.Errorf("results not match:\nwant:\t%d %s\nhave\t%d %s", 429, expected, w.Code, w.Body.String())
+	}
+	if retryAfter := w.Header().Get("Retry-After"); retryAfter != "90" {
+		t.Errorf("results not match:\nwant:\t%#v\nhave\t%#v", "90", retryAfter)
+	}
+	if w := serve(r, "POST", "/api/login", "2.2.2.2:5000", ""); w.Code != 200 {
+		t.Errorf("other ip limited:\nwant:\t%d\nhave\t%d", 200, w.Code)
+	}
+}
+
+// Пользователь ограничен на любом IP, анонимный запрос — только по IP
+func TestMiddleware_PerUser(t *testing.T) {
+	r := getRouter(getLimiter(NewMemoryStore()))
+
+	serve(r, "POST", "/api/posts", "1.1.1.1:5000", "user")
+	if w := serve(r, "POST", "/api/posts", "2.2.2.2:5000", "user"); w.Code != 429 {
+		t.Errorf("wrong status code:\nwant:\t%d\nhave\t%d", 429, w.Code)
+	}
+	if w := serve(r, "POST", "/api/posts", "1.1.1.1:5000", "other"); w.Code != 200 {
+		t.Errorf("wrong status code:\nwant:\t%d\nhave\t%d", 200, w.Code)
+	}
+	if w := serve(r, "POST", "/api/posts", "1.1.1.1:5000", ""); w.Code != 200 {
+		t.Errorf("wrong status code:\nwant:\t%d\nhave\t%d", 200, w.Code)
+	}
+}
+
+func TestMiddleware_Policies(t *testing.T) {
+	var keys []string
+	l := getLimiter(storeFunc(func(_ context.Context, key string, _ Limit) (bool, time.Duration, error) {
+		keys = append(keys, key)
+		return true, 0, nil
+	}))
+	r := getRouter(l)
+
+	serve(r, "POST", "/api/posts", "1.1.1.1:5000", "user")
+	serve(r, "GET", "/api/posts/", "1.1.1.1:5000", "user") // без политики и Default не ограничен
+
+	expected := []string{"posts:ip:1.1.1.1", "posts:user:user"}
+	if fmt.Sprint(keys) != fmt.Sprint(expected) {
+		t.Errorf("results not match:\nwant:\t%#v\nhave\t%#v", expected, keys)
+	}
+}
+
+// За доверенным прокси клиенты различаются по X-Forwarded-For, а не по адресу прокси
+func TestMiddleware_TrustedProxy(t *testing.T) {
+	var keys []string
+	l := getLimiter(storeFunc(func(_ context.Context, key string, _ Limit) (bool, time.Duration, error) {
+		keys = append(keys, key)
+		return true, 0, nil
+	}))
+	var err error
+	if l.ClientIP, err = clientip.NewResolver("10.0.0.1"); err != nil {
+		t.Fatalf("unexpected err: %s", err)
+	}
+	r := getRouter(l)
+
+	for _, remote := range []string{"10.0.0.1:5000", "3.3.3.3:5000"} {
+		req := httptest.NewRequest("POST", "/api/login", nil)
+		req.RemoteAddr = remote
+		req.Header.Set("X-Forwarded-For", "2.2.2.2")
+		r.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	expected := []string{"login:ip:2.2.2.2", "login:ip:3.3.3.3"}
+	if fmt.Sprint(keys) != fmt.Sprint(expected) {
+		t.Errorf("results not match:\nwant:\t%#v\nhave\t%#v", expected, keys)
+	}
+}
+
+// Сбой хранилища не должен отказывать в обслуживании
+func TestMiddleware_StoreErr(t *testing.T) {
+	r := getRouter(getLimiter(storeFunc(func(context.Context, string, Limit) (bool, time.Duration, error) {
+		return false, 0, fmt.Errorf("redis err")
+	})))
+
+	if w := serve(r, "POST", "/api/login", "1.1.1.1:5000", ""); w.Code != 200 {
+		t.Errorf("wrong status code:\nwant:\t%d\nhave\t%d", 200, w.Code)
+	}
+}
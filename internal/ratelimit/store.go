@@ -0,0 +1,85 @@
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+)
+
+// Limit — корзина на Burst токенов, в которую каждые Interval добавляется один токен.
+// Нулевой Limit ничего не ограничивает.
+type Limit struct {
+	Burst    int
+	Interval time.Duration
+}
+
+func (l Limit) enabled() bool {
+	return l.Burst > 0 && l.Interval > 0
+}
+
+// Хранилище корзин; в памяти подходит для одного инстанса, для нескольких нужно общее (например, Redis)
+type Store interface {
+	// Забирает токен из корзины key, а если их нет, возвращает время до появления следующего
+	Take(ctx context.Context, key string, limit Limit) (allowed bool, retryAfter time.Duration, err error)
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+	full   time.Time // после этого момента корзина заполнена и ее можно забыть
+}
+
+type MemoryStore struct {
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+// Раз в sweepInterval из памяти удаляются заполненные корзины: новая корзина будет такой же
+const sweepInterval = time.Minute
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		buckets:   make(map[string]*bucket),
+		lastSweep: time.Now(),
+		now:       time.Now,
+	}
+}
+
+func (s *MemoryStore) Take(_ context.Context, key string, limit Limit) (bool, time.Duration, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	if now.Sub(s.lastSweep) >= sweepInterval {
+		s.sweep(now)
+	}
+
+	b, ok := s.buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(limit.Burst), last: now}
+		s.buckets[key] = b
+	}
+	elapsed := now.Sub(b.last)
+	b.tokens = math.Min(float64(limit.Burst), b.tokens+float64(elapsed)/float64(limit.Interval))
+	b.last = now
+
+	if b.tokens < 1 {
+		retryAfter := time.Duration((1 - b.tokens) * float64(limit.Interval))
+		return false, retryAfter, nil
+	}
+	b.tokens--
+	b.full = now.Add(time.Duration((float64(limit.Burst) - b.tokens) * float64(limit.Interval)))
+	return true, 0, nil
+}
+
+func (s *MemoryStore) sweep(now time.Time) {
+	for key, b := range s.buckets {
+		if !now.Before(b.full) {
+			delete(s.buckets, key)
+		}
+	}
+	s.lastSweep = now
+}
@@ -0,0 +1,71 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+var ctx = context.Background()
+
+type fakeClock struct {
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	return c.now
+}
+
+func getMemoryStore() (*MemoryStore, *fakeClock) {
+	clock := &fakeClock{now: time.Date(2023, 7, 1, 12, 0, 0, 0, time.UTC)}
+	store := NewMemoryStore()
+	store.now = clock.Now
+	store.lastSweep = clock.now
+	return store, clock
+}
+
+func TestMemoryStore_Take(t *testing.T) {
+	store, clock := getMemoryStore()
+	limit := Limit{Burst: 2, Interval: 10 * time.Second}
+
+	for i := 0; i < 2; i++ {
+		if allowed, _, _ := store.Take(ctx, "login:ip:1.1.1.1", limit); !allowed {
+			t.Fatalf("request %d within burst rejected", i)
+		}
+	}
+	allowed, retryAfter, err := store.Take(ctx, "login:ip:1.1.1.1", limit)
+	if err != nil || allowed || retryAfter != 10*time.Second {
+		t.Errorf("results not match:\nwant:\t%v %v\nhave\t%v %v", false, 10*time.Second, allowed, retryAfter)
+	}
+
+	// другая корзина не затронута
+	if allowed, _, _ := store.Take(ctx, "login:ip:2.2.2.2", limit); !allowed {
+		t.Errorf("request with other key rejected")
+	}
+
+	clock.now = clock.now.Add(4 * time.Second)
+	if _, retryAfter, _ := store.Take(ctx, "login:ip:1.1.1.1", limit); retryAfter != 6*time.Second {
+		t.Errorf("results not match:\nwant:\t%v\nhave\t%v", 6*time.Second, retryAfter)
+	}
+	clock.now = clock.now.Add(6 * time.Second)
+	if allowed, _, _ := store.Take(ctx, "login:ip:1.1.1.1", limit); !allowed {
+		t.Errorf("request after refill rejected")
+	}
+}
+
+func TestMemoryStore_Sweep(t *testing.T) {
+	store, clock := getMemoryStore()
+	store.Take(ctx, "posts:user:idle", Limit{Burst: 5, Interval: time.Second}) // nolint:errcheck
+	store.Take(ctx, "posts:user:busy", Limit{Burst: 5, Interval: time.Hour})   // nolint:errcheck
+	store.Take(ctx, "posts:user:busy", Limit{Burst: 5, Interval: time.Hour})   // nolint:errcheck
+
+	clock.now = clock.now.Add(sweepInterval)
+	store.Take(ctx, "posts:user:other", Limit{Burst: 5, Interval: time.Second}) // nolint:errcheck
+
+	if _, ok := store.buckets["posts:user:idle"]; ok {
+		t.Errorf("refilled bucket not swept")
+	}
+	if _, ok := store.buckets["posts:user:busy"]; !ok {
+		t.Errorf("not refilled bucket swept")
+	}
+}
@@ -0,0 +1,213 @@
+package session
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"fmt"
+	"math/big"
+	"os"
+	"sort"
+	"strings"
+
+	"asperitas/internal/config"
+
+	jwt "github.com/golang-jwt/jwt/v4"
+)
+
+const (
+	AlgHS256 = "HS256"
+	AlgRS256 = "RS256"
+	AlgEdDSA = "EdDSA"
+)
+
+// Ключ подписи jwt-токенов. Ключ без приватной части годится только для проверки
+// токенов, выпущенных до ротации.
+type SigningKey struct {
+	ID     string
+	Method jwt.SigningMethod
+	sign   interface{}
+	verify interface{}
+}
+
+type KeySet struct {
+	active *SigningKey
+	keys   map[string]*SigningKey
+}
+
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Alg string `json:"alg"`
+	Use string `json:"use"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+}
+
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+func NewHMACKey(id string, secret []byte) (*SigningKey, error) {
+	if len(secret) == 0 {
+		return nil, fmt.Errorf("empty hmac secret for key %q", id)
+	}
+	return &SigningKey{ID: id, Method: jwt.SigningMethodHS256, sign: secret, verify: secret}, nil
+}
+
+func NewRSAKey(id string, priv *rsa.PrivateKey) *SigningKey {
+	return &SigningKey{ID: id, Method: jwt.SigningMethodRS256, sign: priv, verify: &priv.PublicKey}
+}
+
+func NewEdDSAKey(id string, priv ed25519.PrivateKey) *SigningKey {
+	return &SigningKey{ID: id, Method: jwt.SigningMethodEdDSA, sign: priv, verify: priv.Public()}
+}
+
+// Загружает ключ из файла: секрет для HS256 или PEM для RS256 и EdDSA.
+// PEM с публичным ключом дает ключ только для проверки подписи.
+func LoadSigningKey(id, alg, path string) (*SigningKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read key %q err: %w", id, err)
+	}
+	switch alg {
+	case AlgHS256:
+		return NewHMACKey(id, bytes.TrimSpace(data))
+	case AlgRS256:
+		if priv, err := jwt.ParseRSAPrivateKeyFromPEM(data); err == nil {
+			return NewRSAKey(id, priv), nil
+		}
+		pub, err := jwt.ParseRSAPublicKeyFromPEM(data)
+		if err != nil {
+			return nil, fmt.Errorf("parse rsa key %q err: %w", id, err)
+		}
+		return &SigningKey{ID: id, Method: jwt.SigningMethodRS256, verify: pub}, nil
+	case AlgEdDSA:
+		if priv, err := jwt.ParseEdPrivateKeyFromPEM(data); err == nil {
+			return NewEdDSAKey(id, priv.(ed25519.PrivateKey)), nil
+		}
+		pub, err := jwt.ParseEdPublicKeyFromPEM(data)
+		if err != nil {
+			return nil, fmt.Errorf("parse ed25519 key %q err: %w", id, err)
+		}
+		return &SigningKey{ID: id, Method: jwt.SigningMethodEdDSA, verify: pub}, nil
+	default:
+		return nil, fmt.Errorf("unsupported jwt alg %q for key %q", alg, id)
+	}
+}
+
+// Разбирает описание ключей вида "kid:alg:path,kid:alg:path"
+func LoadKeySet(spec, activeID string) (*KeySet, error) {
+	var keys []*SigningKey
+	for _, item := range strings.Split(spec, ",") {
+		parts := strings.SplitN(strings.TrimSpace(item), ":", 3)
+		if len(parts) != 3 {
+			return nil, fmt.Errorf("bad jwt key spec %q, want kid:alg:path", item)
+		}
+		key, err := LoadSigningKey(parts[0], parts[1], parts[2])
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	return NewKeySet(activeID, keys...)
+}
+
+// Без настроенных ключей случайный ключ берется только с явным разрешением для разработки:
+// иначе после перезапуска или на второй реплике выданные токены молча перестают проходить проверку
+func KeySetFromConfig(cfg config.Session) (*KeySet, error) {
+	if cfg.Keys != "" {
+		return LoadKeySet(cfg.Keys, cfg.KeyID)
+	}
+	if !cfg.AllowEphemeralKey {
+		return nil, fmt.Errorf("no jwt keys configured: set session keys or allow ephemeral key for development")
+	}
+	return NewEphemeralKeySet()
+}
+
+// Создает набор из одного случайного HS256 ключа, живущего до перезапуска процесса
+func NewEphemeralKeySet() (*KeySet, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, fmt.Errorf("generate jwt secret err: %w", err)
+	}
+	key, err := NewHMACKey("ephemeral", secret)
+	if err != nil {
+		return nil, err
+	}
+	return NewKeySet(key.ID, key)
+}
+
+func NewKeySet(activeID string, keys ...*SigningKey) (*KeySet, error) {
+	ks := &KeySet{keys: make(map[string]*SigningKey, len(keys))}
+	for _, key := range keys {
+		if _, exist := ks.keys[key.ID]; exist {
+			return nil, fmt.Errorf("duplicate jwt key id %q", key.ID)
+		}
+		ks.keys[key.ID] = key
+	}
+	active, ok := ks.keys[activeID]
+	if !ok {
+		return nil, fmt.Errorf("active jwt key %q not found", activeID)
+	}
+	if active.sign == nil {
+		return nil, fmt.Errorf("active jwt key %q has no private part", activeID)
+	}
+	ks.active = active
+	return ks, nil
+}
+
+func (ks *KeySet) Sign(claims jwt.Claims) (string, error) {
+	token := jwt.NewWithClaims(ks.active.Method, claims)
+	token.Header["kid"] = ks.active.ID
+	return token.SignedString(ks.active.sign)
+}
+
+func (ks *KeySet) Parse(tokenString string, claims jwt.Claims, opts ...jwt.ParserOption) (*jwt.Token, error) {
+	return jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		key, ok := ks.keys[kid]
+		if !ok {
+			return nil, fmt.Errorf("unknown jwt key id %q", kid)
+		}
+		if token.Method.Alg() != key.Method.Alg() {
+			return nil, fmt.Errorf("unexpected jwt alg %q for key %q", token.Method.Alg(), kid)
+		}
+		return key.verify, nil
+	}, opts...)
+}
+
+// Публикует открытые ключи; симметричные ключи наружу не отдаются
+func (ks *KeySet) JWKS() JWKS {
+	jwks := JWKS{Keys: make([]JWK, 0, len(ks.keys))}
+	for _, key := range ks.keys {
+		switch pub := key.verify.(type) {
+		case *rsa.PublicKey:
+			jwks.Keys = append(jwks.Keys, JWK{
+				Kty: "RSA",
+				Kid: key.ID,
+				Alg: AlgRS256,
+				Use: "sig",
+				N:   base64.RawURLEncoding.EncodeToString(pub.N.Bytes()),
+				E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes()),
+			})
+		case ed25519.PublicKey:
+			jwks.Keys = append(jwks.Keys, JWK{
+				Kty: "OKP",
+				Kid: key.ID,
+				Alg: AlgEdDSA,
+				Use: "sig",
+				Crv: "Ed25519",
+				X:   base64.RawURLEncoding.EncodeToString(pub),
+			})
+		}
+	}
+	sort.Slice(jwks.Keys, func(i, j int) bool {
+		return jwks.Keys[i].Kid < jwks.Keys[j].Kid
+	})
+	return jwks
+}
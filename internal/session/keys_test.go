@@ -0,0 +1,164 @@
+package session
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"asperitas/internal/config"
+	"asperitas/internal/errs"
+
+	jwt "github.com/golang-jwt/jwt/v4"
+)
+
+func writePEM(t *testing.T, name, blockType string, der []byte) string {
+	path := filepath.Join(t.TempDir(), name)
+	data := pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der})
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatalf("write key err: %s", err)
+	}
+	return path
+}
+
+func getTestKeys(t *testing.T) (*SigningKey, *SigningKey, *SigningKey) {
+	hmacKey, err := NewHMACKey("hs", []byte("secret"))
+	if err != nil {
+		t.Fatalf("hmac key err: %s", err)
+	}
+	rsaPriv, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("rsa key err: %s", err)
+	}
+	_, edPriv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("ed25519 key err: %s", err)
+	}
+	return hmacKey, NewRSAKey("rs", rsaPriv), NewEdDSAKey("ed", edPriv)
+}
+
+func TestKeySet_SignAndVerify(t *testing.T) {
+	hmacKey, rsaKey, edKey := getTestKeys(t)
+
+	for _, key := range []*SigningKey{hmacKey, rsaKey, edKey} {
+		ks, err := NewKeySet(key.ID, hmacKey, rsaKey, edKey)
+		if err != nil {
+			t.Fatalf("new key set err: %s", err)
+		}
+		sess, err := NewSession(usr, ks, lifetimes)
+		if err != nil {
+			t.Fatalf("new session err: %s", err)
+		}
+		token, _, err := jwt.NewParser().ParseUnverified(sess.Token, &Claims{})
+		if err != nil {
+			t.Fatalf("parse token err: %s", err)
+		}
+		if token.Header["kid"] != key.ID || token.Method.Alg() != key.Method.Alg() {
+			t.Errorf("bad token header: %#v", token.Header)
+		}
+		claims, err := ks.ExtractJwtClaims(sess.Token)
+		if err != nil {
+			t.Errorf("unexpected err: %s", err)
+		}
+		if claims.SessionID != sess.ID {
+			t.Errorf("results not match:\nwant:\t%s\nhave\t%s", sess.ID, claims.SessionID)
+		}
+	}
+}
+
+func TestKeySet_Rotation(t *testing.T) {
+	hmacKey, rsaKey, _ := getTestKeys(t)
+
+	oldKeys, _ := NewKeySet(hmacKey.ID, hmacKey)        // nolint:errcheck
+	newKeys, _ := NewKeySet(rsaKey.ID, hmacKey, rsaKey) // nolint:errcheck
+	onlyNewKeys, _ := NewKeySet(rsaKey.ID, rsaKey)      // nolint:errcheck
+	sess, _ := NewSession(usr, oldKeys, lifetimes)      // nolint:errcheck
+	expect := errs.MsgError{Msg: "unauthorized", Status: 401}
+
+	if _, err := newKeys.ExtractJwtClaims(sess.Token); err != nil {
+		t.Errorf("token signed with previous key rejected: %s", err)
+	}
+	if _, err := onlyNewKeys.ExtractJwtClaims(sess.Token); !reflect.DeepEqual(expect, err) {
+		t.Errorf("results not match:\nwant:\t%#v\nhave\t%#v", expect, err)
+	}
+}
+
+func TestKeySet_AlgMismatch(t *testing.T) {
+	_, rsaKey, _ := getTestKeys(t)
+
+	// HS256 токен, подписанный открытым RSA ключом, с kid RSA ключа
+	pubDER, _ := x509.MarshalPKIXPublicKey(rsaKey.verify) // nolint:errcheck
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, &Claims{SessionID: "some"})
+	token.Header["kid"] = rsaKey.ID
+	tokenString, _ := token.SignedString(pubDER) // nolint:errcheck
+
+	ks, _ := NewKeySet(rsaKey.ID, rsaKey) // nolint:errcheck
+	expect := errs.MsgError{Msg: "unauthorized", Status: 401}
+
+	if _, err := ks.ExtractJwtClaims(tokenString); !reflect.DeepEqual(expect, err) {
+		t.Errorf("results not match:\nwant:\t%#v\nhave\t%#v", expect, err)
+	}
+}
+
+func TestKeySet_JWKS(t *testing.T) {
+	hmacKey, rsaKey, edKey := getTestKeys(t)
+	ks, _ := NewKeySet(hmacKey.ID, hmacKey, rsaKey, edKey) // nolint:errcheck
+
+	jwks := ks.JWKS()
+
+	if len(jwks.Keys) != 2 {
+		t.Fatalf("bad jwks len:\nwant:\t%d\nhave\t%d", 2, len(jwks.Keys))
+	}
+	if jwks.Keys[0].Kid != edKey.ID || jwks.Keys[0].Kty != "OKP" || jwks.Keys[0].X == "" {
+		t.Errorf("bad ed25519 jwk: %#v", jwks.Keys[0])
+	}
+	if jwks.Keys[1].Kid != rsaKey.ID || jwks.Keys[1].Kty != "RSA" || jwks.Keys[1].E != "AQAB" {
+		t.Errorf("bad rsa jwk: %#v", jwks.Keys[1])
+	}
+}
+
+func TestLoadKeySet_OK(t *testing.T) {
+	_, rsaKey, edKey := getTestKeys(t)
+
+	rsaPath := writePEM(t, "rs.pem", "RSA PRIVATE KEY", x509.MarshalPKCS1PrivateKey(rsaKey.sign.(*rsa.PrivateKey)))
+	edDER, _ := x509.MarshalPKIXPublicKey(edKey.verify) // nolint:errcheck
+	edPath := writePEM(t, "ed.pub.pem", "PUBLIC KEY", edDER)
+
+	ks, err := LoadKeySet("rs:RS256:"+rsaPath+", ed:EdDSA:"+edPath, "rs")
+
+	if err != nil {
+		t.Fatalf("unexpected err: %s", err)
+	}
+	if ks.active.ID != "rs" || ks.keys["ed"].sign != nil {
+		t.Errorf("bad key set: %#v", ks)
+	}
+	if _, err = LoadKeySet("ed:EdDSA:"+edPath, "ed"); err == nil {
+		t.Errorf("expected err for verify-only active key")
+	}
+}
+
+func TestLoadKeySet_BadSpec(t *testing.T) {
+	for _, spec := range []string{"rs:RS256", "rs:XX256:/dev/null", "rs:RS256:/not/exist"} {
+		if _, err := LoadKeySet(spec, "rs"); err == nil {
+			t.Errorf("expected err for spec %q", spec)
+		}
+	}
+}
+
+func TestKeySetFromConfig_Ephemeral(t *testing.T) {
+	if _, err := KeySetFromConfig(config.Session{}); err == nil {
+		t.Errorf("expected err without keys")
+	}
+	ks, err := KeySetFromConfig(config.Session{AllowEphemeralKey: true})
+	if err != nil {
+		t.Fatalf("unexpected err: %s", err)
+	}
+	if ks.active.ID != "ephemeral" {
+		t.Errorf("bad key set: %#v", ks)
+	}
+}
@@ -6,6 +6,7 @@ package session
 
 import (
 	user "asperitas/internal/user"
+	context "context"
 	reflect "reflect"
 
 	gomock "github.com/golang/mock/gomock"
@@ -35,31 +36,74 @@ func (m *MockSessionManager) EXPECT() *MockSessionManagerMockRecorder {
 }
 
 // Check mocks base method.
-func (m *MockSessionManager) Check(authHeader string) (user.User, error) {
+func (m *MockSessionManager) Check(ctx context.Context, authHeader string) (user.User, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "Check", authHeader)
+	ret := m.ctrl.Call(m, "Check", ctx, authHeader)
 	ret0, _ := ret[0].(user.User)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // Check indicates an expected call of Check.
-func (mr *MockSessionManagerMockRecorder) Check(authHeader interface{}) *gomock.Call {
+func (mr *MockSessionManagerMockRecorder) Check(ctx, authHeader interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Check", reflect.TypeOf((*MockSessionManager)(nil).Check), authHeader)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Check", reflect.TypeOf((*MockSessionManager)(nil).Check), ctx, authHeader)
 }
 
 // Create mocks base method.
-func (m *MockSessionManager) Create(usr *user.User) (Session, error) {
+func (m *MockSessionManager) Create(ctx context.Context, usr *user.User) (Session, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "Create", usr)
+	ret := m.ctrl.Call(m, "Create", ctx, usr)
 	ret0, _ := ret[0].(Session)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // Create indicates an expected call of Create.
-func (mr *MockSessionManagerMockRecorder) Create(usr interface{}) *gomock.Call {
+func (mr *MockSessionManagerMockRecorder) Create(ctx, usr interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Create", reflect.TypeOf((*MockSessionManager)(nil).Create), usr)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Create", reflect.TypeOf((*MockSessionManager)(nil).Create), ctx, usr)
+}
+
+// Destroy mocks base method.
+func (m *MockSessionManager) Destroy(ctx context.Context, authHeader string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Destroy", ctx, authHeader)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Destroy indicates an expected call of Destroy.
+func (mr *MockSessionManagerMockRecorder) Destroy(ctx, authHeader interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Destroy", reflect.TypeOf((*MockSessionManager)(nil).Destroy), ctx, authHeader)
+}
+
+// DestroyAllForUser mocks base method.
+func (m *MockSessionManager) DestroyAllForUser(ctx context.Context, userID string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DestroyAllForUser", ctx, userID)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DestroyAllForUser indicates an expected call of DestroyAllForUser.
+func (mr *MockSessionManagerMockRecorder) DestroyAllForUser(ctx, userID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DestroyAllForUser", reflect.TypeOf((*MockSessionManager)(nil).DestroyAllForUser), ctx, userID)
+}
+
+// Refresh mocks base method.
+func (m *MockSessionManager) Refresh(ctx context.Context, refreshToken string) (Session, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Refresh", ctx, refreshToken)
+	ret0, _ := ret[0].(Session)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Refresh indicates an expected call of Refresh.
+func (mr *MockSessionManagerMockRecorder) Refresh(ctx, refreshToken interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Refresh", reflect.TypeOf((*MockSessionManager)(nil).Refresh), ctx, refreshToken)
 }
@@ -1,62 +1,251 @@
 package session
 
 import (
+	"context"
 	"database/sql"
 	"errors"
 	"fmt"
 	"strings"
+	"time"
 
+	"asperitas/internal/config"
 	"asperitas/internal/errs"
+	"asperitas/internal/tracing"
 	"asperitas/internal/user"
+	"asperitas/pkg/timeout"
 )
 
 type SessionManagerMySQL struct {
-	db *sql.DB
+	db      *sql.DB
+	keys    *KeySet
+	cfg     config.Session
+	timeout time.Duration
 }
 
-func NewManagerMySQL(addr string) (*SessionManagerMySQL, error) {
-	mySQL, err := sql.Open("mysql", addr)
+func NewManagerMySQL(db config.MySQL, cfg config.Session, keys *KeySet) (*SessionManagerMySQL, error) {
+	mySQL, err := sql.Open("mysql", db.Addr)
 	if err != nil {
 		return nil, fmt.Errorf("mysql open err: %w", err)
 	}
-	err = mySQL.Ping()
+	ctx, cancel := timeout.Context(context.Background(), db.Timeout)
+	defer cancel()
+	err = mySQL.PingContext(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("mysql connect err: %w", err)
 	}
-	return &SessionManagerMySQL{db: mySQL}, nil
+	return &SessionManagerMySQL{db: mySQL, keys: keys, cfg: cfg, timeout: db.Timeout}, nil
 }
 
-func (sm *SessionManagerMySQL) Create(usr *user.User) (Session, error) {
-	sess, err := NewSession(usr)
+func (sm *SessionManagerMySQL) Ping(ctx context.Context) error {
+	if err := sm.db.PingContext(ctx); err != nil {
+		return fmt.Errorf("mysql ping err: %w", err)
+	}
+	return nil
+}
+
+func (sm *SessionManagerMySQL) Close() error {
+	if err := sm.db.Close(); err != nil {
+		return fmt.Errorf("mysql close err: %w", err)
+	}
+	return nil
+}
+
+func (sm *SessionManagerMySQL) Create(ctx context.Context, usr *user.User) (Session, error) {
+	ctx, cancel := timeout.Context(ctx, sm.timeout)
+	defer cancel()
+	sess, err := NewSession(usr, sm.keys, sm.cfg)
 	if err != nil {
 		return Session{}, err
 	}
-	_, err = sm.db.Exec(
-		"INSERT INTO `sessions` (`session_id`) VALUES (?)",
+	_, err = tracing.Exec(
+		ctx,
+		sm.db,
+		"INSERT INTO `sessions` (`session_id`, `user_id`, `refresh_token`, `expires`) VALUES (?, ?, ?, ?)",
 		sess.ID,
+		sess.UserID,
+		sess.refreshHash,
+		sess.Expires,
 	)
 	if err != nil {
-		return Session{}, fmt.Errorf("mysql exec insert err: %w", err)
+		return Session{}, fmt.Errorf("mysql exec insert err: %w", timeout.Wrap(ctx, err))
 	}
 	return sess, nil
 }
 
-func (sm *SessionManagerMySQL) Check(authHeader string) (user.User, error) {
-	authFields := strings.Fields(authHeader) // "Authorization": "Bearer <token>"
-	if len(authFields) != 2 || authFields[0] != "Bearer" {
-		return user.User{}, errs.MsgError{Msg: "unauthorized", Status: 401}
-	}
-	claims, err := ExtractJwtClaims(authFields[1])
+func (sm *SessionManagerMySQL) Check(ctx context.Context, authHeader string) (user.User, error) {
+	ctx, cancel := timeout.Context(ctx, sm.timeout)
+	defer cancel()
+	claims, err := sm.keys.ExtractAuthClaims(authHeader)
 	if err != nil {
 		return user.User{}, err
 	}
-	err = sm.db.
-		QueryRow("SELECT `session_id` FROM `sessions` WHERE `session_id` = ?", claims.SessionID).
+	err = tracing.
+		QueryRow(
+			ctx,
+			sm.db,
+			"SELECT `session_id` FROM `sessions` WHERE `session_id` = ? AND `user_id` = ? AND `expires` > ?",
+			claims.SessionID,
+			claims.User.ID,
+			time.Now(),
+		).
 		Scan(&claims.SessionID)
 	if errors.Is(err, sql.ErrNoRows) {
 		return user.User{}, errs.MsgError{Msg: "unauthorized", Status: 401}
 	} else if err != nil {
-		return user.User{}, fmt.Errorf("mysql scan err: %w", err)
+		return user.User{}, fmt.Errorf("mysql scan err: %w", timeout.Wrap(ctx, err))
 	}
 	return claims.User, nil
 }
+
+// Сколько хэшей уже обмененных refresh-токенов хранится в сессии для распознавания повторов
+const rotatedTokensLimit = 16
+
+// Обменивает refresh-токен на новую пару токенов. Предъявление действительно выданного, но уже
+// обмененного refresh-токена считается признаком кражи: вся сессия вместе с выданными в ней токенами
+// отзывается. Любой другой неверный секрет отклоняется без отзыва: session_id виден в access-токене,
+// и подобранный секрет не должен позволять разлогинить пользователя.
+func (sm *SessionManagerMySQL) Refresh(ctx context.Context, refreshToken string) (Session, error) {
+	ctx, cancel := timeout.Context(ctx, sm.timeout)
+	defer cancel()
+	sessionID, secretHash, err := parseRefreshToken(refreshToken)
+	if err != nil {
+		return Session{}, err
+	}
+	var (
+		storedHash, rotated string
+		usr                 user.User
+	)
+	sess := Session{ID: sessionID}
+	err = tracing.
+		QueryRow(
+			ctx,
+			sm.db,
+			"SELECT `s`.`refresh_token`, `s`.`rotated_tokens`, `s`.`expires`, `u`.`id`, `u`.`username` FROM `sessions` AS `s` "+
+				"JOIN `users` AS `u` ON `u`.`id` = `s`.`user_id` WHERE `s`.`session_id` = ?",
+			sessionID,
+		).
+		Scan(&storedHash, &rotated, &sess.Expires, &usr.ID, &usr.Username)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Session{}, errs.MsgError{Msg: "invalid refresh token", Status: 401}
+	} else if err != nil {
+		return Session{}, fmt.Errorf("mysql scan err: %w", timeout.Wrap(ctx, err))
+	}
+	if storedHash != secretHash {
+		if !wasRotated(rotated, secretHash) {
+			return Session{}, errs.MsgError{Msg: "invalid refresh token", Status: 401}
+		}
+		if err = sm.revoke(ctx, sessionID); err != nil {
+			return Session{}, err
+		}
+		return Session{}, errs.MsgError{Msg: "refresh token reused", Status: 401}
+	}
+	if !sess.Expires.After(time.Now()) {
+		if err = sm.revoke(ctx, sessionID); err != nil {
+			return Session{}, err
+		}
+		return Session{}, errs.MsgError{Msg: "session expired", Status: 401}
+	}
+	// роли перечитываются, чтобы выданные и отозванные роли попали в новый access-токен
+	if usr.Roles, err = user.QueryRoles(ctx, sm.db, usr.ID); err != nil {
+		return Session{}, err
+	}
+	sess.UserID = usr.ID
+	if err = sess.rotate(&usr, sm.keys, sm.cfg.AccessLifetime); err != nil {
+		return Session{}, err
+	}
+	res, err := tracing.Exec(
+		ctx,
+		sm.db,
+		"UPDATE `sessions` SET `refresh_token` = ?, `rotated_tokens` = ? WHERE `session_id` = ? AND `refresh_token` = ?",
+		sess.refreshHash,
+		appendRotated(rotated, storedHash),
+		sessionID,
+		storedHash,
+	)
+	if err != nil {
+		return Session{}, fmt.Errorf("mysql exec update err: %w", timeout.Wrap(ctx, err))
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return Session{}, fmt.Errorf("mysql rows affected err: %w", err)
+	}
+	if affected == 0 {
+		// тот же refresh-токен параллельно уже был обменян
+		if err = sm.revoke(ctx, sessionID); err != nil {
+			return Session{}, err
+		}
+		return Session{}, errs.MsgError{Msg: "refresh token reused", Status: 401}
+	}
+	return sess, nil
+}
+
+func wasRotated(rotated, hash string) bool {
+	for _, item := range strings.Fields(rotated) {
+		if item == hash {
+			return true
+		}
+	}
+	return false
+}
+
+// Добавляет хэш обмененного токена в список, самые старые хэши вытесняются
+func appendRotated(rotated, hash string) string {
+	hashes := append(strings.Fields(rotated), hash)
+	if len(hashes) > rotatedTokensLimit {
+		hashes = hashes[len(hashes)-rotatedTokensLimit:]
+	}
+	return strings.Join(hashes, " ")
+}
+
+func (sm *SessionManagerMySQL) revoke(ctx context.Context, sessionID string) error {
+	if _, err := tracing.Exec(
+		ctx,
+		sm.db,
+		"DELETE FROM `sessions` WHERE `session_id` = ?",
+		sessionID,
+	); err != nil {
+		return fmt.Errorf("mysql exec delete err: %w", timeout.Wrap(ctx, err))
+	}
+	return nil
+}
+
+func (sm *SessionManagerMySQL) Destroy(ctx context.Context, authHeader string) error {
+	ctx, cancel := timeout.Context(ctx, sm.timeout)
+	defer cancel()
+	claims, err := sm.keys.ExtractLogoutClaims(authHeader)
+	if err != nil {
+		return err
+	}
+	res, err := tracing.Exec(
+		ctx,
+		sm.db,
+		"DELETE FROM `sessions` WHERE `session_id` = ? AND `user_id` = ?",
+		claims.SessionID,
+		claims.User.ID,
+	)
+	if err != nil {
+		return fmt.Errorf("mysql exec delete err: %w", timeout.Wrap(ctx, err))
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("mysql rows affected err: %w", err)
+	}
+	if affected == 0 {
+		return errs.MsgError{Msg: "unauthorized", Status: 401}
+	}
+	return nil
+}
+
+func (sm *SessionManagerMySQL) DestroyAllForUser(ctx context.Context, userID string) error {
+	ctx, cancel := timeout.Context(ctx, sm.timeout)
+	defer cancel()
+	if _, err := tracing.Exec(
+		ctx,
+		sm.db,
+		"DELETE FROM `sessions` WHERE `user_id` = ?",
+		userID,
+	); err != nil {
+		return fmt.Errorf("mysql exec delete err: %w", timeout.Wrap(ctx, err))
+	}
+	return nil
+}
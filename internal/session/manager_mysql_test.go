@@ -0,0 +1,473 @@
+package session
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"asperitas/internal/config"
+	"asperitas/internal/errs"
+	"asperitas/internal/user"
+
+	sqlmock "gopkg.in/DATA-DOG/go-sqlmock.v1"
+)
+
+var (
+	ctx     = context.Background()
+	usr     = &user.User{Username: "admin", ID: "id_admin"}
+	keys, _ = NewEphemeralKeySet() // nolint:errcheck
+
+	lifetimes = config.Default().Session
+)
+
+func getAuthHeader(t *testing.T) (string, Session) {
+	sess, err := NewSession(usr, keys, lifetimes)
+	if err != nil {
+		t.Fatalf("new session err: %s", err)
+	}
+	return "Bearer " + sess.Token, sess
+}
+
+func TestCreate_OK(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("mock create err: %s", err)
+	}
+	defer db.Close()
+
+	sm := &SessionManagerMySQL{db: db, keys: keys, cfg: lifetimes}
+
+	mock.
+		ExpectExec("INSERT INTO `sessions`").
+		WithArgs(sqlmock.AnyArg(), usr.ID, sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+
+	sess, err := sm.Create(ctx, usr)
+
+	if err != nil {
+		t.Errorf("unexpected err: %s", err)
+	}
+	if sess.UserID != usr.ID || sess.Token == "" || sess.RefreshToken == "" {
+		t.Errorf("bad session: %#v", sess)
+	}
+	if err = mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestCheck_OK(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("mock create err: %s", err)
+	}
+	defer db.Close()
+
+	sm := &SessionManagerMySQL{db: db, keys: keys, cfg: lifetimes}
+	header, sess := getAuthHeader(t)
+
+	mock.
+		ExpectQuery("SELECT `session_id` FROM `sessions` WHERE").
+		WithArgs(sess.ID, usr.ID, sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{`session_id`}).AddRow(sess.ID))
+
+	result, err := sm.Check(ctx, header)
+
+	if err != nil {
+		t.Errorf("unexpected err: %s", err)
+	}
+	if !reflect.DeepEqual(*usr, result) {
+		t.Errorf("results not match:\nwant:\t%#v\nhave\t%#v", *usr, result)
+	}
+	if err = mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestCheck_Revoked(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("mock create err: %s", err)
+	}
+	defer db.Close()
+
+	sm := &SessionManagerMySQL{db: db, keys: keys, cfg: lifetimes}
+	header, sess := getAuthHeader(t)
+	expect := errs.MsgError{Msg: "unauthorized", Status: 401}
+
+	mock.
+		ExpectQuery("SELECT `session_id` FROM `sessions` WHERE").
+		WithArgs(sess.ID, usr.ID, sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{`session_id`}))
+
+	_, err = sm.Check(ctx, header)
+
+	if !reflect.DeepEqual(expect, err) {
+		t.Errorf("results not match:\nwant:\t%#v\nhave\t%#v", expect, err)
+	}
+	if err = mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestRefresh_OK(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("mock create err: %s", err)
+	}
+	defer db.Close()
+
+	sm := &SessionManagerMySQL{db: db, keys: keys, cfg: lifetimes}
+	_, old := getAuthHeader(t)
+
+	rows := sqlmock.
+		NewRows([]string{`refresh_token`, `rotated_tokens`, `expires`, `id`, `username`}).
+		AddRow(old.refreshHash, "", old.Expires, usr.ID, usr.Username)
+	mock.
+		ExpectQuery("SELECT (.+) FROM `sessions`").
+		WithArgs(old.ID).
+		WillReturnRows(rows)
+	mock.
+		ExpectQuery("SELECT `role`, `scope` FROM `user_roles` WHERE").
+		WithArgs(usr.ID).
+		WillReturnRows(sqlmock.NewRows([]string{`role`, `scope`}).AddRow("moderator", "music"))
+	mock.
+		ExpectExec("UPDATE `sessions` SET `refresh_token`").
+		WithArgs(sqlmock.AnyArg(), old.refreshHash, old.ID, old.refreshHash).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	sess, err := sm.Refresh(ctx, old.RefreshToken)
+	moderator := *usr
+	moderator.Roles = []user.Role{{Name: user.RoleModerator, Scope: "music"}}
+
+	if err != nil {
+		t.Errorf("unexpected err: %s", err)
+	}
+	if sess.ID != old.ID || sess.RefreshToken == old.RefreshToken || sess.refreshHash == old.refreshHash {
+		t.Errorf("refresh token not rotated: %#v", sess)
+	}
+	claims, err := keys.ExtractJwtClaims(sess.Token)
+	if err != nil || claims.SessionID != old.ID || !reflect.DeepEqual(moderator, claims.User) {
+		t.Errorf("bad access token claims: %#v, err: %v", claims, err)
+	}
+	if err = mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestRefresh_Reused(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("mock create err: %s", err)
+	}
+	defer db.Close()
+
+	sm := &SessionManagerMySQL{db: db, keys: keys, cfg: lifetimes}
+	_, old := getAuthHeader(t)
+	expect := errs.MsgError{Msg: "refresh token reused", Status: 401}
+
+	rows := sqlmock.
+		NewRows([]string{`refresh_token`, `rotated_tokens`, `expires`, `id`, `username`}).
+		AddRow(hashRefreshSecret("next secret"), "hash "+old.refreshHash, old.Expires, usr.ID, usr.Username)
+	mock.
+		ExpectQuery("SELECT (.+) FROM `sessions`").
+		WithArgs(old.ID).
+		WillReturnRows(rows)
+	mock.
+		ExpectExec("DELETE FROM `sessions` WHERE `session_id`").
+		WithArgs(old.ID).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	_, err = sm.Refresh(ctx, old.RefreshToken)
+
+	if !reflect.DeepEqual(expect, err) {
+		t.Errorf("results not match:\nwant:\t%#v\nhave\t%#v", expect, err)
+	}
+	if err = mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+// Неизвестный секрет при известном session_id не отзывает сессию
+func TestRefresh_UnknownSecret(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("mock create err: %s", err)
+	}
+	defer db.Close()
+
+	sm := &SessionManagerMySQL{db: db, keys: keys, cfg: lifetimes}
+	_, old := getAuthHeader(t)
+	expect := errs.MsgError{Msg: "invalid refresh token", Status: 401}
+
+	rows := sqlmock.
+		NewRows([]string{`refresh_token`, `rotated_tokens`, `expires`, `id`, `username`}).
+		AddRow(old.refreshHash, hashRefreshSecret("previous secret"), old.Expires, usr.ID, usr.Username)
+	mock.
+		ExpectQuery("SELECT (.+) FROM `sessions`").
+		WithArgs(old.ID).
+		WillReturnRows(rows)
+
+	_, err = sm.Refresh(ctx, old.ID+".garbage")
+
+	if !reflect.DeepEqual(expect, err) {
+		t.Errorf("results not match:\nwant:\t%#v\nhave\t%#v", expect, err)
+	}
+	if err = mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestAppendRotated(t *testing.T) {
+	rotated := ""
+	for i := 0; i < rotatedTokensLimit+2; i++ {
+		rotated = appendRotated(rotated, fmt.Sprint(i))
+	}
+	hashes := strings.Fields(rotated)
+	if len(hashes) != rotatedTokensLimit || hashes[0] != "2" || !wasRotated(rotated, fmt.Sprint(rotatedTokensLimit+1)) {
+		t.Errorf("bad rotated tokens: %q", rotated)
+	}
+}
+
+func TestRefresh_ConcurrentReuse(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("mock create err: %s", err)
+	}
+	defer db.Close()
+
+	sm := &SessionManagerMySQL{db: db, keys: keys, cfg: lifetimes}
+	_, old := getAuthHeader(t)
+	expect := errs.MsgError{Msg: "refresh token reused", Status: 401}
+
+	rows := sqlmock.
+		NewRows([]string{`refresh_token`, `rotated_tokens`, `expires`, `id`, `username`}).
+		AddRow(old.refreshHash, "", old.Expires, usr.ID, usr.Username)
+	mock.
+		ExpectQuery("SELECT (.+) FROM `sessions`").
+		WithArgs(old.ID).
+		WillReturnRows(rows)
+	mock.
+		ExpectQuery("SELECT `role`, `scope` FROM `user_roles` WHERE").
+		WithArgs(usr.ID).
+		WillReturnRows(sqlmock.NewRows([]string{`role`, `scope`}))
+	mock.
+		ExpectExec("UPDATE `sessions` SET `refresh_token`").
+		WithArgs(sqlmock.AnyArg(), old.refreshHash, old.ID, old.refreshHash).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.
+		ExpectExec("DELETE FROM `sessions` WHERE `session_id`").
+		WithArgs(old.ID).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	_, err = sm.Refresh(ctx, old.RefreshToken)
+
+	if !reflect.DeepEqual(expect, err) {
+		t.Errorf("results not match:\nwant:\t%#v\nhave\t%#v", expect, err)
+	}
+	if err = mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestRefresh_Expired(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("mock create err: %s", err)
+	}
+	defer db.Close()
+
+	sm := &SessionManagerMySQL{db: db, keys: keys, cfg: lifetimes}
+	_, old := getAuthHeader(t)
+	expect := errs.MsgError{Msg: "session expired", Status: 401}
+
+	rows := sqlmock.
+		NewRows([]string{`refresh_token`, `rotated_tokens`, `expires`, `id`, `username`}).
+		AddRow(old.refreshHash, "", time.Now().Add(-time.Minute), usr.ID, usr.Username)
+	mock.
+		ExpectQuery("SELECT (.+) FROM `sessions`").
+		WithArgs(old.ID).
+		WillReturnRows(rows)
+	mock.
+		ExpectExec("DELETE FROM `sessions` WHERE `session_id`").
+		WithArgs(old.ID).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	_, err = sm.Refresh(ctx, old.RefreshToken)
+
+	if !reflect.DeepEqual(expect, err) {
+		t.Errorf("results not match:\nwant:\t%#v\nhave\t%#v", expect, err)
+	}
+	if err = mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestRefresh_BadToken(t *testing.T) {
+	sm := &SessionManagerMySQL{keys: keys}
+	expect := errs.MsgError{Msg: "invalid refresh token", Status: 401}
+
+	_, err := sm.Refresh(ctx, "some token")
+
+	if !reflect.DeepEqual(expect, err) {
+		t.Errorf("results not match:\nwant:\t%#v\nhave\t%#v", expect, err)
+	}
+}
+
+func TestDestroy_OK(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("mock create err: %s", err)
+	}
+	defer db.Close()
+
+	sm := &SessionManagerMySQL{db: db, keys: keys, cfg: lifetimes}
+	header, sess := getAuthHeader(t)
+
+	mock.
+		ExpectExec("DELETE FROM `sessions` WHERE `session_id`").
+		WithArgs(sess.ID, usr.ID).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	if err = sm.Destroy(ctx, header); err != nil {
+		t.Errorf("unexpected err: %s", err)
+	}
+	if err = mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+// Сессию можно закрыть и после истечения access-токена
+func TestDestroy_ExpiredToken(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("mock create err: %s", err)
+	}
+	defer db.Close()
+
+	expired := lifetimes
+	expired.AccessLifetime = -time.Minute
+	sm := &SessionManagerMySQL{db: db, keys: keys, cfg: expired}
+	sess, err := NewSession(usr, keys, expired)
+	if err != nil {
+		t.Fatalf("new session err: %s", err)
+	}
+	header := "Bearer " + sess.Token
+	if _, err = sm.keys.ExtractAuthClaims(header); err == nil {
+		t.Fatalf("access token must be expired")
+	}
+
+	mock.
+		ExpectExec("DELETE FROM `sessions` WHERE `session_id`").
+		WithArgs(sess.ID, usr.ID).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	if err = sm.Destroy(ctx, header); err != nil {
+		t.Errorf("unexpected err: %s", err)
+	}
+	if err = mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+// Подделанная подпись не дает закрыть чужую сессию даже без проверки срока
+func TestDestroy_ForeignKey(t *testing.T) {
+	foreign, err := NewEphemeralKeySet()
+	if err != nil {
+		t.Fatalf("key set err: %s", err)
+	}
+	sess, err := NewSession(usr, foreign, lifetimes)
+	if err != nil {
+		t.Fatalf("new session err: %s", err)
+	}
+	sm := &SessionManagerMySQL{keys: keys}
+	expect := errs.MsgError{Msg: "unauthorized", Status: 401}
+
+	err = sm.Destroy(ctx, "Bearer "+sess.Token)
+
+	if !reflect.DeepEqual(expect, err) {
+		t.Errorf("results not match:\nwant:\t%#v\nhave\t%#v", expect, err)
+	}
+}
+
+func TestDestroy_NotFound(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("mock create err: %s", err)
+	}
+	defer db.Close()
+
+	sm := &SessionManagerMySQL{db: db, keys: keys, cfg: lifetimes}
+	header, sess := getAuthHeader(t)
+	expect := errs.MsgError{Msg: "unauthorized", Status: 401}
+
+	mock.
+		ExpectExec("DELETE FROM `sessions` WHERE `session_id`").
+		WithArgs(sess.ID, usr.ID).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	err = sm.Destroy(ctx, header)
+
+	if !reflect.DeepEqual(expect, err) {
+		t.Errorf("results not match:\nwant:\t%#v\nhave\t%#v", expect, err)
+	}
+	if err = mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestDestroy_BadHeader(t *testing.T) {
+	sm := &SessionManagerMySQL{keys: keys}
+	expect := errs.MsgError{Msg: "unauthorized", Status: 401}
+
+	err := sm.Destroy(ctx, "Token some")
+
+	if !reflect.DeepEqual(expect, err) {
+		t.Errorf("results not match:\nwant:\t%#v\nhave\t%#v", expect, err)
+	}
+}
+
+func TestDestroyAllForUser_ExecErr(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("mock create err: %s", err)
+	}
+	defer db.Close()
+
+	sm := &SessionManagerMySQL{db: db, keys: keys, cfg: lifetimes}
+	expect := "mysql exec delete err"
+
+	mock.
+		ExpectExec("DELETE FROM `sessions` WHERE `user_id`").
+		WithArgs(usr.ID).
+		WillReturnError(fmt.Errorf("bad exec"))
+
+	err = sm.DestroyAllForUser(ctx, usr.ID)
+
+	if err == nil || !strings.HasPrefix(err.Error(), expect) {
+		t.Errorf("unexpected err:\nwant:\t%s\nhave\t%#v", expect, err)
+	}
+	if err = mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestClose_Err(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("mock create err: %s", err)
+	}
+
+	sm := &SessionManagerMySQL{db: db, keys: keys, cfg: lifetimes}
+
+	mock.ExpectClose().WillReturnError(fmt.Errorf("some error"))
+
+	if err = sm.Close(); err == nil {
+		t.Errorf("expected close err")
+	}
+	if err = mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
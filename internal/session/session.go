@@ -1,9 +1,15 @@
 package session
 
 import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"fmt"
+	"strings"
 	"time"
 
+	"asperitas/internal/config"
 	"asperitas/internal/errs"
 	"asperitas/internal/user"
 	"asperitas/pkg/rand"
@@ -11,8 +17,6 @@ import (
 	jwt "github.com/golang-jwt/jwt/v4"
 )
 
-const jwtKey = "secretKey"
-
 type Claims struct {
 	User      user.User `json:"user"`
 	SessionID string    `json:"session_id"`
@@ -20,45 +24,112 @@ type Claims struct {
 }
 
 type Session struct {
-	Token string `json:"token"`
-	ID    string `json:"-"`
+	Token        string    `json:"token"`
+	RefreshToken string    `json:"refresh_token"`
+	ID           string    `json:"-"`
+	UserID       string    `json:"-"`
+	Expires      time.Time `json:"-"`
+	refreshHash  string
 }
 
 type SessionManager interface {
-	Create(usr *user.User) (Session, error)
-	Check(authHeader string) (user.User, error)
+	Create(ctx context.Context, usr *user.User) (Session, error)
+	Check(ctx context.Context, authHeader string) (user.User, error)
+	Refresh(ctx context.Context, refreshToken string) (Session, error)
+	Destroy(ctx context.Context, authHeader string) error
+	DestroyAllForUser(ctx context.Context, userID string) error
 }
 
-func NewSession(usr *user.User) (Session, error) {
+func NewSession(usr *user.User, keys *KeySet, cfg config.Session) (Session, error) {
 	if usr == nil {
 		return Session{}, fmt.Errorf("nil input user")
 	}
-	randID := rand.GetRandID()
+	sess := Session{
+		ID:      rand.GetRandID(),
+		UserID:  usr.ID,
+		Expires: time.Now().Add(cfg.Lifetime),
+	}
+	if err := sess.rotate(usr, keys, cfg.AccessLifetime); err != nil {
+		return Session{}, err
+	}
+	return sess, nil
+}
+
+// Выпускает новый access-токен и новый refresh-токен в рамках той же сессии
+func (sess *Session) rotate(usr *user.User, keys *KeySet, accessLifetime time.Duration) error {
+	now := time.Now()
 	claims := &Claims{
 		User:      *usr,
-		SessionID: randID,
+		SessionID: sess.ID,
 		RegisteredClaims: jwt.RegisteredClaims{
-			IssuedAt:  jwt.NewNumericDate(time.Now()),
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(7 * 24 * time.Hour)),
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(accessLifetime)),
 		},
 	}
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	tokenString, err := token.SignedString([]byte(jwtKey))
+	tokenString, err := keys.Sign(claims)
 	if err != nil {
-		return Session{}, err
+		return err
 	}
-	return Session{Token: tokenString, ID: randID}, nil
+	secret := rand.GetRandID() + rand.GetRandID()
+	sess.Token = tokenString
+	sess.RefreshToken = sess.ID + "." + secret
+	sess.refreshHash = hashRefreshSecret(secret)
+	return nil
 }
 
-func ExtractJwtClaims(tokenString string) (Claims, error) {
-	var claims Claims
-	token, err := jwt.ParseWithClaims(tokenString, &claims, func(*jwt.Token) (interface{}, error) {
-		return []byte(jwtKey), nil
-	})
+// Refresh-токен имеет вид "<session_id>.<secret>", в базе хранится только хэш секрета
+func parseRefreshToken(refreshToken string) (sessionID, secretHash string, err error) {
+	sessionID, secret, ok := strings.Cut(refreshToken, ".")
+	if !ok || len(sessionID) != rand.LengthOfID || secret == "" {
+		return "", "", errs.MsgError{Msg: "invalid refresh token", Status: 401}
+	}
+	return sessionID, hashRefreshSecret(secret), nil
+}
+
+func hashRefreshSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+// Достает jwt-claims из заголовка вида "Authorization": "Bearer <token>"
+func (ks *KeySet) ExtractAuthClaims(authHeader string) (Claims, error) {
+	tokenString, err := bearerToken(authHeader)
 	if err != nil {
-		return claims, fmt.Errorf("jwt parse err: %w", err)
+		return Claims{}, err
+	}
+	return ks.ExtractJwtClaims(tokenString)
+}
+
+// Для выхода годится и просроченный access-токен: проверяется только подпись, а жива ли
+// сессия, решает база. Иначе после истечения токена сессию нельзя было бы закрыть.
+func (ks *KeySet) ExtractLogoutClaims(authHeader string) (Claims, error) {
+	tokenString, err := bearerToken(authHeader)
+	if err != nil {
+		return Claims{}, err
+	}
+	var claims Claims
+	token, err := ks.Parse(tokenString, &claims, jwt.WithoutClaimsValidation())
+	if err != nil || !token.Valid {
+		return Claims{}, errs.MsgError{Msg: "unauthorized", Status: 401}
+	}
+	return claims, nil
+}
+
+func bearerToken(authHeader string) (string, error) {
+	authFields := strings.Fields(authHeader)
+	if len(authFields) != 2 || authFields[0] != "Bearer" {
+		return "", errs.MsgError{Msg: "unauthorized", Status: 401}
+	}
+	return authFields[1], nil
+}
+
+func (ks *KeySet) ExtractJwtClaims(tokenString string) (Claims, error) {
+	var claims Claims
+	token, err := ks.Parse(tokenString, &claims)
+	if errors.Is(err, jwt.ErrTokenExpired) {
+		return claims, errs.MsgError{Msg: "token expired", Status: 401}
 	}
-	if !token.Valid {
+	if err != nil || !token.Valid {
 		return claims, errs.MsgError{Msg: "unauthorized", Status: 401}
 	}
 	return claims, nil
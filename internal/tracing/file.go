@@ -0,0 +1,106 @@
+package tracing
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+
+	coltracepb "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	tracepb "go.opentelemetry.io/proto/otlp/trace/v1"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+// Пишет каждую пачку спанов отдельной строкой в формате OTLP/JSON, как файловый экспортер
+// коллектора, поэтому файл можно скормить коллектору или просмотреть глазами
+type fileClient struct {
+	path string
+	mu   sync.Mutex
+	file *os.File
+}
+
+func newFileClient(path string) *fileClient {
+	return &fileClient{path: path}
+}
+
+func (c *fileClient) Start(_ context.Context) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	file, err := os.OpenFile(c.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return fmt.Errorf("open traces file err: %w", err)
+	}
+	c.file = file
+	return nil
+}
+
+func (c *fileClient) Stop(_ context.Context) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.file == nil {
+		return nil
+	}
+	err := c.file.Close()
+	c.file = nil
+	return err
+}
+
+func (c *fileClient) UploadTraces(_ context.Context, spans []*tracepb.ResourceSpans) error {
+	data, err := marshalOTLP(&coltracepb.ExportTraceServiceRequest{ResourceSpans: spans})
+	if err != nil {
+		return err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.file == nil {
+		return fmt.Errorf("traces file is closed")
+	}
+	_, err = c.file.Write(append(data, '\n'))
+	return err
+}
+
+// OTLP/JSON отличается от обычного protojson: перечисления пишутся числами,
+// а идентификаторы трейсов и спанов hex-строками вместо base64
+func marshalOTLP(req *coltracepb.ExportTraceServiceRequest) ([]byte, error) {
+	data, err := protojson.MarshalOptions{UseEnumNumbers: true}.Marshal(req)
+	if err != nil {
+		return nil, fmt.Errorf("marshal spans err: %w", err)
+	}
+	var doc interface{}
+	if err = json.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("unmarshal spans err: %w", err)
+	}
+	if err = hexIDs(doc); err != nil {
+		return nil, err
+	}
+	return json.Marshal(doc)
+}
+
+func hexIDs(node interface{}) error {
+	switch v := node.(type) {
+	case map[string]interface{}:
+		for key, value := range v {
+			if id, ok := value.(string); ok && (key == "traceId" || key == "spanId" || key == "parentSpanId") {
+				raw, err := base64.StdEncoding.DecodeString(id)
+				if err != nil {
+					return fmt.Errorf("decode %s err: %w", key, err)
+				}
+				v[key] = hex.EncodeToString(raw)
+				continue
+			}
+			if err := hexIDs(value); err != nil {
+				return err
+			}
+		}
+	case []interface{}:
+		for _, item := range v {
+			if err := hexIDs(item); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,48 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"go.mongodb.org/mongo-driver/event"
+	semconv "go.opentelemetry.io/otel/semconv/v1.21.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Открывает span на каждую команду драйвера монги; подключается через options.Client().SetMonitor.
+// Начало и конец команды приходят разными событиями, их связывает RequestID.
+func NewMongoMonitor() *event.CommandMonitor {
+	spans := &sync.Map{}
+	finish := func(requestID int64, err error) {
+		if span, ok := spans.LoadAndDelete(requestID); ok {
+			End(span.(trace.Span), err, err != nil)
+		}
+	}
+	return &event.CommandMonitor{
+		Started: func(ctx context.Context, evt *event.CommandStartedEvent) {
+			name := "mongo " + evt.CommandName
+			attrs := []trace.SpanStartOption{
+				trace.WithSpanKind(trace.SpanKindClient),
+				trace.WithAttributes(
+					semconv.DBSystemMongoDB,
+					semconv.DBName(evt.DatabaseName),
+					semconv.DBOperation(evt.CommandName),
+				),
+			}
+			// у команд над коллекцией ее имя лежит значением первого ключа
+			if coll, ok := evt.Command.Lookup(evt.CommandName).StringValueOK(); ok {
+				name = "mongo " + coll + "." + evt.CommandName
+				attrs = append(attrs, trace.WithAttributes(semconv.DBMongoDBCollection(coll)))
+			}
+			_, span := Start(ctx, name, attrs...)
+			spans.Store(evt.RequestID, span)
+		},
+		Succeeded: func(_ context.Context, evt *event.CommandSucceededEvent) {
+			finish(evt.RequestID, nil)
+		},
+		Failed: func(_ context.Context, evt *event.CommandFailedEvent) {
+			finish(evt.RequestID, errors.New(evt.Failure))
+		},
+	}
+}
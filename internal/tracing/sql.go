@@ -0,0 +1,54 @@
+package tracing
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+
+	semconv "go.opentelemetry.io/otel/semconv/v1.21.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Обертки над *sql.DB и *sql.Tx, открывающие span на каждый запрос к MySQL.
+// Запросы параметризованы, поэтому текст попадает в атрибут без пользовательских данных.
+
+type Querier interface {
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+}
+
+func startSQL(ctx context.Context, query string) (context.Context, trace.Span) {
+	op, _, _ := strings.Cut(query, " ")
+	return Start(ctx, "mysql "+strings.ToUpper(op),
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			semconv.DBSystemMySQL,
+			semconv.DBOperation(strings.ToUpper(op)),
+			semconv.DBStatement(query),
+		),
+	)
+}
+
+func Query(ctx context.Context, db Querier, query string, args ...interface{}) (*sql.Rows, error) {
+	ctx, span := startSQL(ctx, query)
+	rows, err := db.QueryContext(ctx, query, args...)
+	End(span, err, err != nil)
+	return rows, err
+}
+
+// Ошибка запроса доступна у sql.Row сразу, до Scan
+func QueryRow(ctx context.Context, db Querier, query string, args ...interface{}) *sql.Row {
+	ctx, span := startSQL(ctx, query)
+	row := db.QueryRowContext(ctx, query, args...)
+	err := row.Err()
+	End(span, err, err != nil)
+	return row
+}
+
+func Exec(ctx context.Context, db Querier, query string, args ...interface{}) (sql.Result, error) {
+	ctx, span := startSQL(ctx, query)
+	res, err := db.ExecContext(ctx, query, args...)
+	End(span, err, err != nil)
+	return res, err
+}
@@ -0,0 +1,56 @@
+package tracing
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"asperitas/internal/config"
+)
+
+// Спаны в файле должны быть в OTLP/JSON: hex-идентификаторы и числовые перечисления
+func TestSetup_File(t *testing.T) {
+	cfg := config.Default().Tracing
+	cfg.Exporter = "file"
+	cfg.File = filepath.Join(t.TempDir(), "traces.jsonl")
+
+	stop, err := Setup(context.Background(), cfg)
+	if err != nil {
+		t.Fatalf("unexpected err: %s", err)
+	}
+	_, span := Start(context.Background(), "PostRepo.GetByID")
+	span.End()
+	if err = stop(context.Background()); err != nil {
+		t.Fatalf("unexpected stop err: %s", err)
+	}
+
+	data, err := os.ReadFile(cfg.File)
+	if err != nil {
+		t.Fatalf("read traces err: %s", err)
+	}
+	var req struct {
+		ResourceSpans []struct {
+			ScopeSpans []struct {
+				Spans []struct {
+					TraceID string `json:"traceId"`
+					SpanID  string `json:"spanId"`
+					Name    string `json:"name"`
+					Kind    int    `json:"kind"`
+				} `json:"spans"`
+			} `json:"scopeSpans"`
+		} `json:"resourceSpans"`
+	}
+	if err = json.Unmarshal(data, &req); err != nil {
+		t.Fatalf("decode traces err: %s\n%s", err, data)
+	}
+	got := req.ResourceSpans[0].ScopeSpans[0].Spans[0]
+	if got.Name != "PostRepo.GetByID" || got.Kind != 1 {
+		t.Errorf("wrong span: %+v", got)
+	}
+	if got.TraceID != span.SpanContext().TraceID().String() || got.SpanID != span.SpanContext().SpanID().String() {
+		t.Errorf("ids not match:\nwant:\t%s %s\nhave\t%s %s",
+			span.SpanContext().TraceID(), span.SpanContext().SpanID(), got.TraceID, got.SpanID)
+	}
+}
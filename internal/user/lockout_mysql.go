@@ -0,0 +1,191 @@
+package user
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"asperitas/internal/config"
+	"asperitas/internal/errs"
+	"asperitas/internal/tracing"
+	"asperitas/pkg/timeout"
+)
+
+// Одинаков для существующих и несуществующих логинов, чтобы блокировка не выдавала, какие логины заняты
+var errLocked = errs.MsgError{Msg: "too many failed login attempts, try again later", Status: 429}
+
+type LoginGuardMySQL struct {
+	db      *sql.DB
+	cfg     config.Lockout
+	timeout time.Duration
+	now     func() time.Time
+}
+
+func NewLoginGuardMySQL(db config.MySQL, cfg config.Lockout) (*LoginGuardMySQL, error) {
+	mySQL, err := sql.Open("mysql", db.Addr)
+	if err != nil {
+		return nil, fmt.Errorf("mysql open err: %w", err)
+	}
+	ctx, cancel := timeout.Context(context.Background(), db.Timeout)
+	defer cancel()
+	err = mySQL.PingContext(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("mysql connect err: %w", err)
+	}
+	return &LoginGuardMySQL{db: mySQL, cfg: cfg, timeout: db.Timeout, now: time.Now}, nil
+}
+
+func (g *LoginGuardMySQL) Ping(ctx context.Context) error {
+	if err := g.db.PingContext(ctx); err != nil {
+		return fmt.Errorf("mysql ping err: %w", err)
+	}
+	return nil
+}
+
+func (g *LoginGuardMySQL) Close() error {
+	if err := g.db.Close(); err != nil {
+		return fmt.Errorf("mysql close err: %w", err)
+	}
+	return nil
+}
+
+func usernameKey(username string) string {
+	return "username:" + username
+}
+
+func ipKey(ip string) string {
+	return "ip:" + ip
+}
+
+func (g *LoginGuardMySQL) Check(ctx context.Context, username, ip string) error {
+	ctx, cancel := timeout.Context(ctx, g.timeout)
+	defer cancel()
+	var lockedUntil sql.NullTime
+	err := tracing.
+		QueryRow(
+			ctx,
+			g.db,
+			"SELECT MAX(`locked_until`) FROM `login_failures` WHERE `key` IN (?, ?) AND `locked_until` > ?",
+			usernameKey(username),
+			ipKey(ip),
+			g.now(),
+		).
+		Scan(&lockedUntil)
+	if err != nil {
+		return fmt.Errorf("mysql scan err: %w", timeout.Wrap(ctx, err))
+	}
+	if lockedUntil.Valid {
+		return errLocked
+	}
+	return nil
+}
+
+// Счетчики логина и IP пишутся независимо: сбой записи одного не должен отключать
+// ограничение по другому
+func (g *LoginGuardMySQL) Fail(ctx context.Context, username, ip string) error {
+	ctx, cancel := timeout.Context(ctx, g.timeout)
+	defer cancel()
+	userErr := g.fail(ctx, usernameKey(username), username, ip, g.cfg.Threshold)
+	ipErr := g.fail(ctx, ipKey(ip), username, ip, g.cfg.IPThreshold)
+	if userErr != nil && ipErr != nil {
+		return fmt.Errorf("%w; ip counter err: %s", userErr, ipErr)
+	}
+	if userErr != nil {
+		return userErr
+	}
+	return ipErr
+}
+
+// Счетчик читается под блокировкой строки, чтобы параллельные неудачи не потерялись.
+// Каждая блокировка попадает в журнал login_audit.
+func (g *LoginGuardMySQL) fail(ctx context.Context, key, username, ip string, threshold int) (err error) {
+	tx, err := g.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("mysql begin err: %w", timeout.Wrap(ctx, err))
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback() // nolint:errcheck
+		}
+	}()
+
+	now := g.now()
+	var (
+		failures    int
+		lastFailure time.Time
+	)
+	err = tracing.
+		QueryRow(ctx, tx, "SELECT `failures`, `last_failure` FROM `login_failures` WHERE `key` = ? FOR UPDATE", key).
+		Scan(&failures, &lastFailure)
+	if errors.Is(err, sql.ErrNoRows) || err == nil && now.Sub(lastFailure) > g.cfg.Window {
+		failures = 0
+	} else if err != nil {
+		return fmt.Errorf("mysql scan err: %w", timeout.Wrap(ctx, err))
+	}
+	failures++
+
+	var lockedUntil sql.NullTime
+	if failures >= threshold {
+		lockedUntil = sql.NullTime{Time: now.Add(g.delay(failures - threshold)), Valid: true}
+	}
+	if _, err = tracing.Exec(
+		ctx,
+		tx,
+		"INSERT INTO `login_failures` (`key`, `failures`, `last_failure`, `locked_until`) VALUES (?, ?, ?, ?) "+
+			"ON DUPLICATE KEY UPDATE `failures` = VALUES(`failures`), `last_failure` = VALUES(`last_failure`), "+
+			"`locked_until` = VALUES(`locked_until`)",
+		key,
+		failures,
+		now,
+		lockedUntil,
+	); err != nil {
+		return fmt.Errorf("mysql exec upsert err: %w", timeout.Wrap(ctx, err))
+	}
+	if lockedUntil.Valid {
+		if _, err = tracing.Exec(
+			ctx,
+			tx,
+			"INSERT INTO `login_audit` (`key`, `username`, `ip`, `failures`, `locked_until`, `created`) VALUES (?, ?, ?, ?, ?, ?)",
+			key,
+			username,
+			ip,
+			failures,
+			lockedUntil.Time,
+			now,
+		); err != nil {
+			return fmt.Errorf("mysql exec insert err: %w", timeout.Wrap(ctx, err))
+		}
+	}
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("mysql commit err: %w", timeout.Wrap(ctx, err))
+	}
+	return nil
+}
+
+// Первая блокировка длится Delay, каждая следующая вдвое дольше, но не больше MaxDelay
+func (g *LoginGuardMySQL) delay(extraFailures int) time.Duration {
+	d := g.cfg.Delay
+	for i := 0; i < extraFailures && d < g.cfg.MaxDelay; i++ {
+		d *= 2
+	}
+	if d > g.cfg.MaxDelay {
+		d = g.cfg.MaxDelay
+	}
+	return d
+}
+
+func (g *LoginGuardMySQL) Reset(ctx context.Context, username string) error {
+	ctx, cancel := timeout.Context(ctx, g.timeout)
+	defer cancel()
+	if _, err := tracing.Exec(
+		ctx,
+		g.db,
+		"DELETE FROM `login_failures` WHERE `key` = ?",
+		usernameKey(username),
+	); err != nil {
+		return fmt.Errorf("mysql exec delete err: %w", timeout.Wrap(ctx, err))
+	}
+	return nil
+}
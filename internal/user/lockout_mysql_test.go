@@ -0,0 +1,195 @@
+package user
+
+import (
+	"database/sql"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"asperitas/internal/config"
+	"asperitas/internal/errs"
+
+	sqlmock "gopkg.in/DATA-DOG/go-sqlmock.v1"
+)
+
+var lockoutNow = time.Date(2023, 7, 1, 12, 0, 0, 0, time.UTC)
+
+func getLoginGuard(db *sql.DB) *LoginGuardMySQL {
+	return &LoginGuardMySQL{
+		db:  db,
+		cfg: config.Default().Lockout,
+		now: func() time.Time { return lockoutNow },
+	}
+}
+
+func TestCheck_Locked(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("mock create err: %s", err)
+	}
+	defer db.Close()
+	guard := getLoginGuard(db)
+
+	mock.
+		ExpectQuery("SELECT MAX\\(`locked_until`\\) FROM `login_failures` WHERE").
+		WithArgs("username:admin", "ip:1.1.1.1", lockoutNow).
+		WillReturnRows(sqlmock.NewRows([]string{"locked_until"}).AddRow(lockoutNow.Add(time.Minute)))
+	mock.
+		ExpectQuery("SELECT MAX\\(`locked_until`\\) FROM `login_failures` WHERE").
+		WithArgs("username:admin", "ip:2.2.2.2", lockoutNow).
+		WillReturnRows(sqlmock.NewRows([]string{"locked_until"}).AddRow(nil))
+
+	expect := errs.MsgError{Msg: "too many failed login attempts, try again later", Status: 429}
+	if err = guard.Check(ctx, "admin", "1.1.1.1"); !reflect.DeepEqual(expect, err) {
+		t.Errorf("results not match:\nwant:\t%#v\nhave\t%#v", expect, err)
+	}
+	if err = guard.Check(ctx, "admin", "2.2.2.2"); err != nil {
+		t.Errorf("unexpected err: %s", err)
+	}
+	if err = mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+// Пятая неудача подряд блокирует логин и пишет блокировку в журнал, счетчик IP еще ниже порога
+func TestFail_Lockout(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("mock create err: %s", err)
+	}
+	defer db.Close()
+	guard := getLoginGuard(db)
+	lockedUntil := lockoutNow.Add(guard.cfg.Delay)
+
+	mock.ExpectBegin()
+	mock.
+		ExpectQuery("SELECT `failures`, `last_failure` FROM `login_failures` WHERE").
+		WithArgs("username:admin").
+		WillReturnRows(sqlmock.NewRows([]string{"failures", "last_failure"}).AddRow(4, lockoutNow.Add(-time.Minute)))
+	mock.
+		ExpectExec("INSERT INTO `login_failures`").
+		WithArgs("username:admin", 5, lockoutNow, sql.NullTime{Time: lockedUntil, Valid: true}).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.
+		ExpectExec("INSERT INTO `login_audit`").
+		WithArgs("username:admin", "admin", "1.1.1.1", 5, lockedUntil, lockoutNow).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	mock.ExpectBegin()
+	mock.
+		ExpectQuery("SELECT `failures`, `last_failure` FROM `login_failures` WHERE").
+		WithArgs("ip:1.1.1.1").
+		WillReturnRows(sqlmock.NewRows([]string{"failures", "last_failure"}))
+	mock.
+		ExpectExec("INSERT INTO `login_failures`").
+		WithArgs("ip:1.1.1.1", 1, lockoutNow, sql.NullTime{}).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	if err = guard.Fail(ctx, "admin", "1.1.1.1"); err != nil {
+		t.Errorf("unexpected err: %s", err)
+	}
+	if err = mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+// Неудачи старше окна забываются, а ошибка базы откатывает транзакцию,
+// но не мешает записать неудачу в счетчик IP
+func TestFail_WindowExpired(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("mock create err: %s", err)
+	}
+	defer db.Close()
+	guard := getLoginGuard(db)
+
+	mock.ExpectBegin()
+	mock.
+		ExpectQuery("SELECT `failures`, `last_failure` FROM `login_failures` WHERE").
+		WithArgs("username:admin").
+		WillReturnRows(sqlmock.NewRows([]string{"failures", "last_failure"}).
+			AddRow(10, lockoutNow.Add(-guard.cfg.Window-time.Second)))
+	mock.
+		ExpectExec("INSERT INTO `login_failures`").
+		WithArgs("username:admin", 1, lockoutNow, sql.NullTime{}).
+		WillReturnError(fmt.Errorf("mysql exec err"))
+	mock.ExpectRollback()
+
+	mock.ExpectBegin()
+	mock.
+		ExpectQuery("SELECT `failures`, `last_failure` FROM `login_failures` WHERE").
+		WithArgs("ip:1.1.1.1").
+		WillReturnRows(sqlmock.NewRows([]string{"failures", "last_failure"}))
+	mock.
+		ExpectExec("INSERT INTO `login_failures`").
+		WithArgs("ip:1.1.1.1", 1, lockoutNow, sql.NullTime{}).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	if err = guard.Fail(ctx, "admin", "1.1.1.1"); err == nil {
+		t.Errorf("expected err, got nil")
+	}
+	if err = mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestFail_BothErr(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("mock create err: %s", err)
+	}
+	defer db.Close()
+	guard := getLoginGuard(db)
+	expect := "mysql begin err: user begin err; ip counter err: mysql begin err: ip begin err"
+
+	mock.ExpectBegin().WillReturnError(fmt.Errorf("user begin err"))
+	mock.ExpectBegin().WillReturnError(fmt.Errorf("ip begin err"))
+
+	if err = guard.Fail(ctx, "admin", "1.1.1.1"); err == nil || err.Error() != expect {
+		t.Errorf("unexpected err:\nwant:\t%s\nhave\t%#v", expect, err)
+	}
+	if err = mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestLockoutDelay(t *testing.T) {
+	guard := getLoginGuard(nil)
+	cases := map[int]time.Duration{
+		0:  time.Minute,
+		1:  2 * time.Minute,
+		5:  32 * time.Minute,
+		6:  time.Hour,
+		60: time.Hour,
+	}
+	for extra, expect := range cases {
+		if delay := guard.delay(extra); delay != expect {
+			t.Errorf("results not match for %d:\nwant:\t%v\nhave\t%v", extra, expect, delay)
+		}
+	}
+}
+
+func TestReset_OK(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("mock create err: %s", err)
+	}
+	defer db.Close()
+	guard := getLoginGuard(db)
+
+	mock.
+		ExpectExec("DELETE FROM `login_failures` WHERE").
+		WithArgs("username:admin").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	if err = guard.Reset(ctx, "admin"); err != nil {
+		t.Errorf("unexpected err: %s", err)
+	}
+	if err = mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
@@ -0,0 +1,48 @@
+package user
+
+import (
+	"crypto/subtle"
+	"fmt"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+type PasswordHasher interface {
+	Hash(passw string) (string, error)
+	Verify(hash, passw string) (match, rehash bool)
+}
+
+type BcryptHasher struct {
+	cost int
+}
+
+func NewBcryptHasher(cost int) (*BcryptHasher, error) {
+	if cost < bcrypt.MinCost || cost > bcrypt.MaxCost {
+		return nil, fmt.Errorf("bcrypt cost %d out of range [%d, %d]", cost, bcrypt.MinCost, bcrypt.MaxCost)
+	}
+	return &BcryptHasher{cost: cost}, nil
+}
+
+// Хэширует пароль со случайной солью, которая хранится внутри самого хэша
+func (h *BcryptHasher) Hash(passw string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(passw), h.cost)
+	if err != nil {
+		return "", fmt.Errorf("bcrypt hash err: %w", err)
+	}
+	return string(hash), nil
+}
+
+// Сравнивает пароль с сохраненным значением за постоянное время.
+// Сохраненное значение, не являющееся bcrypt-хэшем, считается паролем в открытом виде
+// из старых записей: при совпадении его нужно перехэшировать, как и хэш с устаревшей стоимостью.
+func (h *BcryptHasher) Verify(hash, passw string) (match, rehash bool) {
+	cost, err := bcrypt.Cost([]byte(hash))
+	if err != nil {
+		match = subtle.ConstantTimeCompare([]byte(hash), []byte(passw)) == 1
+		return match, match
+	}
+	if err = bcrypt.CompareHashAndPassword([]byte(hash), []byte(passw)); err != nil {
+		return false, false
+	}
+	return true, cost != h.cost
+}
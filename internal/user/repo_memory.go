@@ -1,6 +1,7 @@
 package user
 
 import (
+	"context"
 	"sync"
 
 	"asperitas/internal/errs"
@@ -8,11 +9,19 @@ import (
 )
 
 type UserMemoryRepository struct {
-	data map[string]*User
-	mu   *sync.RWMutex
+	data   map[string]*User
+	mu     *sync.RWMutex
+	hasher PasswordHasher
+	// Хэш, с которым сравнивается пароль несуществующего пользователя,
+	// чтобы по времени ответа нельзя было узнать, занят ли логин
+	dummyHash string
 }
 
-func NewMemoryRepo() *UserMemoryRepository {
+func NewMemoryRepo(hasher PasswordHasher) (*UserMemoryRepository, error) {
+	dummyHash, err := hasher.Hash(rand.GetRandID())
+	if err != nil {
+		return nil, err
+	}
 	return &UserMemoryRepository{
 		data: map[string]*User{
 			"admin1": {
@@ -26,24 +35,47 @@ func NewMemoryRepo() *UserMemoryRepository {
 				Password: "adminadmin",
 			},
 		},
-		mu: &sync.RWMutex{},
-	}
+		mu:        &sync.RWMutex{},
+		hasher:    hasher,
+		dummyHash: dummyHash,
+	}, nil
 }
 
-func (repo *UserMemoryRepository) Authorize(username, passw string) (*User, error) {
+// Хэши сравниваются вне блокировки, чтобы медленный bcrypt не задерживал остальные запросы;
+// запись нужна только для сохранения перехэшированного пароля
+func (repo *UserMemoryRepository) Authorize(_ context.Context, username, passw string) (*User, error) {
 	repo.mu.RLock()
-	defer repo.mu.RUnlock()
-	usr, ok := repo.data[username]
+	stored, ok := repo.data[username]
+	var usr User
+	if ok {
+		usr = *stored
+	}
+	repo.mu.RUnlock()
 	if !ok {
-		return nil, errs.MsgError{Msg: "user not found", Status: 401}
+		repo.hasher.Verify(repo.dummyHash, passw)
+		return nil, errInvalidCredentials
 	}
-	if usr.Password != passw {
-		return nil, errs.MsgError{Msg: "invalid password", Status: 401}
+	match, rehash := repo.hasher.Verify(usr.Password, passw)
+	if !match {
+		return nil, errInvalidCredentials
 	}
-	return usr, nil
+	if rehash {
+		hash, err := repo.hasher.Hash(passw)
+		if err != nil {
+			return nil, err
+		}
+		repo.mu.Lock()
+		// пароль могли сменить, пока шло сравнение: тогда новый хэш уже не к нему
+		if stored.Password == usr.Password {
+			stored.Password = hash
+		}
+		repo.mu.Unlock()
+		usr.Password = hash
+	}
+	return &usr, nil
 }
 
-func (repo *UserMemoryRepository) SignUp(username, passw string) (*User, error) {
+func (repo *UserMemoryRepository) SignUp(_ context.Context, username, passw string) (*User, error) {
 	repo.mu.RLock()
 	_, exist := repo.data[username]
 	repo.mu.RUnlock()
@@ -57,10 +89,14 @@ func (repo *UserMemoryRepository) SignUp(username, passw string) (*User, error)
 			},
 		}, Status: 422}
 	}
+	hash, err := repo.hasher.Hash(passw)
+	if err != nil {
+		return nil, err
+	}
 	usr := &User{
 		ID:       rand.GetRandID(),
 		Username: username,
-		Password: passw,
+		Password: hash,
 	}
 	repo.mu.Lock()
 	repo.data[username] = usr
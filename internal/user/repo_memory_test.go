@@ -0,0 +1,52 @@
+package user
+
+import (
+	"reflect"
+	"testing"
+)
+
+// Хэшер, запоминающий, с какими хэшами сравнивался пароль
+type recordingHasher struct {
+	*BcryptHasher
+	verified []string
+}
+
+func (h *recordingHasher) Verify(hash, passw string) (match, rehash bool) {
+	h.verified = append(h.verified, hash)
+	return h.BcryptHasher.Verify(hash, passw)
+}
+
+func TestMemoryAuthorize_NoUser(t *testing.T) {
+	rec := &recordingHasher{BcryptHasher: hasher}
+	repo, err := NewMemoryRepo(rec)
+	if err != nil {
+		t.Fatalf("unexpected err: %s", err)
+	}
+
+	result, err := repo.Authorize(ctx, "nobody", "adminadmin")
+
+	if result != nil || !reflect.DeepEqual(errInvalidCredentials, err) {
+		t.Errorf("results not match:\nwant:\t%#v\nhave\t%#v", errInvalidCredentials, err)
+	}
+	if expect := []string{repo.dummyHash}; !reflect.DeepEqual(expect, rec.verified) {
+		t.Errorf("results not match:\nwant:\t%#v\nhave\t%#v", expect, rec.verified)
+	}
+}
+
+// Пароль в открытом виде из начальных данных перехэшируется при первом входе
+func TestMemoryAuthorize_Rehash(t *testing.T) {
+	repo, err := NewMemoryRepo(hasher)
+	if err != nil {
+		t.Fatalf("unexpected err: %s", err)
+	}
+
+	result, err := repo.Authorize(ctx, "admin1", "adminadmin")
+
+	if err != nil {
+		t.Fatalf("unexpected err: %s", err)
+	}
+	stored := repo.data["admin1"].Password
+	if match, rehash := hasher.Verify(stored, "adminadmin"); !match || rehash || result.Password != stored {
+		t.Errorf("password not rehashed: stored %q, returned %q", stored, result.Password)
+	}
+}
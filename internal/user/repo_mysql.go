@@ -1,67 +1,128 @@
 package user
 
 import (
+	"context"
 	"database/sql"
 	"errors"
 	"fmt"
+	"time"
 
+	"asperitas/internal/config"
 	"asperitas/internal/errs"
+	"asperitas/internal/tracing"
 	"asperitas/pkg/rand"
+	"asperitas/pkg/timeout"
 
 	_ "github.com/go-sql-driver/mysql"
 )
 
 type UserRepositoryMySQL struct {
-	db *sql.DB
+	db      *sql.DB
+	hasher  PasswordHasher
+	timeout time.Duration
+	// Хэш случайного пароля: проверяется вместо отсутствующего, чтобы ответ
+	// по неизвестному логину занимал столько же времени, сколько по неверному паролю
+	dummyHash string
 }
 
-func NewRepoMySQL(addr string) (*UserRepositoryMySQL, error) {
-	mySQL, err := sql.Open("mysql", addr)
+func NewRepoMySQL(cfg config.MySQL, hasher PasswordHasher) (*UserRepositoryMySQL, error) {
+	mySQL, err := sql.Open("mysql", cfg.Addr)
 	if err != nil {
 		return nil, fmt.Errorf("mysql open err: %w", err)
 	}
-	err = mySQL.Ping()
+	ctx, cancel := timeout.Context(context.Background(), cfg.Timeout)
+	defer cancel()
+	err = mySQL.PingContext(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("mysql connect err: %w", err)
 	}
-	return &UserRepositoryMySQL{db: mySQL}, nil
+	dummyHash, err := hasher.Hash(rand.GetRandID())
+	if err != nil {
+		return nil, err
+	}
+	return &UserRepositoryMySQL{db: mySQL, hasher: hasher, timeout: cfg.Timeout, dummyHash: dummyHash}, nil
+}
+
+func (repo *UserRepositoryMySQL) Ping(ctx context.Context) error {
+	if err := repo.db.PingContext(ctx); err != nil {
+		return fmt.Errorf("mysql ping err: %w", err)
+	}
+	return nil
+}
+
+func (repo *UserRepositoryMySQL) Close() error {
+	if err := repo.db.Close(); err != nil {
+		return fmt.Errorf("mysql close err: %w", err)
+	}
+	return nil
 }
 
-func (repo *UserRepositoryMySQL) Authorize(username, passw string) (*User, error) {
+func (repo *UserRepositoryMySQL) Authorize(ctx context.Context, username, passw string) (*User, error) {
+	ctx, cancel := timeout.Context(ctx, repo.timeout)
+	defer cancel()
 	usr := &User{Username: username}
-	err := repo.db.
-		QueryRow("SELECT `id`, `password` FROM `users` WHERE `username` = ?", username).
+	err := tracing.
+		QueryRow(ctx, repo.db, "SELECT `id`, `password` FROM `users` WHERE `username` = ?", username).
 		Scan(&usr.ID, &usr.Password)
 	if errors.Is(err, sql.ErrNoRows) {
-		return nil, errs.MsgError{Msg: "user not found", Status: 401}
+		repo.hasher.Verify(repo.dummyHash, passw)
+		return nil, errInvalidCredentials
 	} else if err != nil {
-		return nil, fmt.Errorf("mysql scan err: %w", err)
+		return nil, fmt.Errorf("mysql scan err: %w", timeout.Wrap(ctx, err))
 	}
-	if usr.Password != passw {
-		return nil, errs.MsgError{Msg: "invalid password", Status: 401}
+	match, rehash := repo.hasher.Verify(usr.Password, passw)
+	if !match {
+		return nil, errInvalidCredentials
+	}
+	if rehash {
+		hash, err := repo.hasher.Hash(passw)
+		if err != nil {
+			return nil, err
+		}
+		if _, err = tracing.Exec(
+			ctx,
+			repo.db,
+			"UPDATE `users` SET `password` = ? WHERE `id` = ?",
+			hash,
+			usr.ID,
+		); err != nil {
+			return nil, fmt.Errorf("mysql exec update err: %w", timeout.Wrap(ctx, err))
+		}
+		usr.Password = hash
+	}
+	if usr.Roles, err = QueryRoles(ctx, repo.db, usr.ID); err != nil {
+		return nil, err
 	}
 	return usr, nil
 }
 
-func (repo *UserRepositoryMySQL) SignUp(username, passw string) (*User, error) {
+func (repo *UserRepositoryMySQL) SignUp(ctx context.Context, username, passw string) (*User, error) {
+	ctx, cancel := timeout.Context(ctx, repo.timeout)
+	defer cancel()
 	usr := &User{Username: username}
-	err := repo.db.
-		QueryRow("SELECT `id`, `password` FROM `users` WHERE `username` = ?", username).
+	err := tracing.
+		QueryRow(ctx, repo.db, "SELECT `id`, `password` FROM `users` WHERE `username` = ?", username).
 		Scan(&usr.ID, &usr.Password)
 	if errors.Is(err, sql.ErrNoRows) {
+		hash, err := repo.hasher.Hash(passw)
+		if err != nil {
+			return nil, err
+		}
 		usr.ID = rand.GetRandID()
-		usr.Password = passw
-		if _, err = repo.db.Exec(
+		usr.Password = hash
+		if _, err = tracing.Exec(
+			ctx,
+			repo.db,
 			"INSERT INTO `users` (`username`, `id`, `password`) VALUES (?, ?, ?)",
 			username,
 			usr.ID,
-			passw,
+			hash,
 		); err != nil {
-			return nil, fmt.Errorf("mysql exec insert err: %w", err)
+			return nil, fmt.Errorf("mysql exec insert err: %w", timeout.Wrap(ctx, err))
 		}
 		return usr, nil
 	} else if err != nil {
-		return nil, fmt.Errorf("mysql scan err: %w", err)
+		return nil, fmt.Errorf("mysql scan err: %w", timeout.Wrap(ctx, err))
 	}
 	return nil, errs.DetailErrors{Errors: []errs.DetailError{
 		{
@@ -1,6 +1,7 @@
 package user
 
 import (
+	"context"
 	"fmt"
 	"reflect"
 	"strings"
@@ -9,9 +10,20 @@ import (
 	"asperitas/internal/errs"
 	"asperitas/pkg/rand"
 
+	"golang.org/x/crypto/bcrypt"
 	sqlmock "gopkg.in/DATA-DOG/go-sqlmock.v1"
 )
 
+var (
+	ctx       = context.Background()
+	hasher, _ = NewBcryptHasher(bcrypt.MinCost) // nolint:errcheck
+)
+
+func getHash(passw string) string {
+	hash, _ := hasher.Hash(passw) // nolint:errcheck
+	return hash
+}
+
 func TestAuthorize_OK(t *testing.T) {
 	db, mock, err := sqlmock.New()
 	if err != nil {
@@ -20,9 +32,9 @@ func TestAuthorize_OK(t *testing.T) {
 	defer db.Close()
 
 	creds := Credentials{Username: "admin", Password: "passw"}
-	repo := &UserRepositoryMySQL{db: db}
+	repo := &UserRepositoryMySQL{db: db, hasher: hasher}
 
-	expect := &User{Username: "admin", ID: rand.GetRandID(), Password: "passw"}
+	expect := &User{Username: "admin", ID: rand.GetRandID(), Password: getHash("passw")}
 	rows := sqlmock.
 		NewRows([]string{`id`, `password`}).
 		AddRow(expect.ID, expect.Password)
@@ -31,8 +43,13 @@ func TestAuthorize_OK(t *testing.T) {
 		ExpectQuery("SELECT `id`, `password` FROM `users` WHERE").
 		WithArgs(creds.Username).
 		WillReturnRows(rows)
+	mock.
+		ExpectQuery("SELECT `role`, `scope` FROM `user_roles` WHERE").
+		WithArgs(expect.ID).
+		WillReturnRows(sqlmock.NewRows([]string{`role`, `scope`}).AddRow("admin", ""))
+	expect.Roles = []Role{{Name: RoleAdmin}}
 
-	usr, err := repo.Authorize(creds.Username, creds.Password)
+	usr, err := repo.Authorize(ctx, creds.Username, creds.Password)
 
 	if err != nil {
 		t.Errorf("unexpected err: %s", err)
@@ -53,9 +70,9 @@ func TestAuthorize_NoRows(t *testing.T) {
 	defer db.Close()
 
 	creds := Credentials{Username: "admin", Password: "passw"}
-	repo := &UserRepositoryMySQL{db: db}
+	repo := &UserRepositoryMySQL{db: db, hasher: hasher}
 
-	expect := errs.MsgError{Msg: "user not found", Status: 401}
+	expect := errs.MsgError{Msg: "invalid credentials", Status: 401}
 	rows := sqlmock.NewRows([]string{`id`, `password`})
 
 	mock.
@@ -63,7 +80,7 @@ func TestAuthorize_NoRows(t *testing.T) {
 		WithArgs(creds.Username).
 		WillReturnRows(rows)
 
-	usr, err := repo.Authorize(creds.Username, creds.Password)
+	usr, err := repo.Authorize(ctx, creds.Username, creds.Password)
 
 	if usr != nil {
 		t.Errorf("unexpected usr: %#v", usr)
@@ -88,7 +105,7 @@ func TestAuthorize_ScanErr(t *testing.T) {
 	defer db.Close()
 
 	creds := Credentials{Username: "admin", Password: "passw"}
-	repo := &UserRepositoryMySQL{db: db}
+	repo := &UserRepositoryMySQL{db: db, hasher: hasher}
 
 	expect := "mysql scan err"
 	rows := sqlmock.
@@ -100,7 +117,7 @@ func TestAuthorize_ScanErr(t *testing.T) {
 		WithArgs(creds.Username).
 		WillReturnRows(rows)
 
-	usr, err := repo.Authorize(creds.Username, creds.Password)
+	usr, err := repo.Authorize(ctx, creds.Username, creds.Password)
 
 	if usr != nil {
 		t.Errorf("unexpected usr: %#v", usr)
@@ -121,9 +138,9 @@ func TestAuthorize_WrongPassw(t *testing.T) {
 	defer db.Close()
 
 	creds := Credentials{Username: "admin", Password: "passw"}
-	repo := &UserRepositoryMySQL{db: db}
+	repo := &UserRepositoryMySQL{db: db, hasher: hasher}
 
-	expect := errs.MsgError{Msg: "invalid password", Status: 401}
+	expect := errs.MsgError{Msg: "invalid credentials", Status: 401}
 	rows := sqlmock.
 		NewRows([]string{`id`, `password`}).
 		AddRow(creds.Username, "wrong passw")
@@ -133,7 +150,7 @@ func TestAuthorize_WrongPassw(t *testing.T) {
 		WithArgs(creds.Username).
 		WillReturnRows(rows)
 
-	usr, err := repo.Authorize(creds.Username, creds.Password)
+	usr, err := repo.Authorize(ctx, creds.Username, creds.Password)
 
 	if usr != nil {
 		t.Errorf("unexpected usr: %#v", usr)
@@ -150,6 +167,91 @@ func TestAuthorize_WrongPassw(t *testing.T) {
 	}
 }
 
+func TestAuthorize_Rehash(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("mock create err: %s", err)
+	}
+	defer db.Close()
+
+	creds := Credentials{Username: "admin", Password: "passw"}
+	repo := &UserRepositoryMySQL{db: db, hasher: hasher}
+
+	expect := &User{Username: "admin", ID: rand.GetRandID()}
+	rows := sqlmock.
+		NewRows([]string{`id`, `password`}).
+		AddRow(expect.ID, creds.Password)
+
+	mock.
+		ExpectQuery("SELECT `id`, `password` FROM `users` WHERE").
+		WithArgs(creds.Username).
+		WillReturnRows(rows)
+
+	mock.
+		ExpectExec("UPDATE `users` SET `password`").
+		WithArgs(sqlmock.AnyArg(), expect.ID).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.
+		ExpectQuery("SELECT `role`, `scope` FROM `user_roles` WHERE").
+		WithArgs(expect.ID).
+		WillReturnRows(sqlmock.NewRows([]string{`role`, `scope`}))
+
+	usr, err := repo.Authorize(ctx, creds.Username, creds.Password)
+	if usr != nil {
+		expect.Password = usr.Password
+	}
+
+	if err != nil {
+		t.Errorf("unexpected err: %s", err)
+	}
+	if !reflect.DeepEqual(expect, usr) {
+		t.Errorf("results not match:\nwant:\t%#v\nhave\t%#v", expect, usr)
+	}
+	if match, rehash := hasher.Verify(expect.Password, creds.Password); !match || rehash {
+		t.Errorf("password not rehashed: %s", expect.Password)
+	}
+	if err = mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestAuthorize_RehashErr(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("mock create err: %s", err)
+	}
+	defer db.Close()
+
+	creds := Credentials{Username: "admin", Password: "passw"}
+	repo := &UserRepositoryMySQL{db: db, hasher: hasher}
+
+	expect := "mysql exec update err"
+	rows := sqlmock.
+		NewRows([]string{`id`, `password`}).
+		AddRow(rand.GetRandID(), creds.Password)
+
+	mock.
+		ExpectQuery("SELECT `id`, `password` FROM `users` WHERE").
+		WithArgs(creds.Username).
+		WillReturnRows(rows)
+
+	mock.
+		ExpectExec("UPDATE `users` SET `password`").
+		WillReturnError(fmt.Errorf("bad exec"))
+
+	usr, err := repo.Authorize(ctx, creds.Username, creds.Password)
+
+	if usr != nil {
+		t.Errorf("unexpected usr: %#v", usr)
+	}
+	if err == nil || !strings.HasPrefix(err.Error(), expect) {
+		t.Errorf("unexpected err:\nwant:\t%s\nhave\t%#v", expect, err)
+	}
+	if err = mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
 func TestSignUp_OK(t *testing.T) {
 	db, mock, err := sqlmock.New()
 	if err != nil {
@@ -158,9 +260,9 @@ func TestSignUp_OK(t *testing.T) {
 	defer db.Close()
 
 	creds := Credentials{Username: "admin", Password: "passw"}
-	repo := &UserRepositoryMySQL{db: db}
+	repo := &UserRepositoryMySQL{db: db, hasher: hasher}
 
-	expect := &User{Username: "admin", Password: "passw"}
+	expect := &User{Username: "admin"}
 	rows := sqlmock.NewRows([]string{`id`, `password`})
 
 	mock.
@@ -170,12 +272,13 @@ func TestSignUp_OK(t *testing.T) {
 
 	mock.
 		ExpectExec("INSERT INTO `users`").
-		WithArgs(creds.Username, sqlmock.AnyArg(), creds.Password).
+		WithArgs(creds.Username, sqlmock.AnyArg(), sqlmock.AnyArg()).
 		WillReturnResult(sqlmock.NewResult(1, 1))
 
-	usr, err := repo.SignUp(creds.Username, creds.Password)
+	usr, err := repo.SignUp(ctx, creds.Username, creds.Password)
 	if usr != nil {
 		expect.ID = usr.ID
+		expect.Password = usr.Password
 	}
 
 	if err != nil {
@@ -184,6 +287,9 @@ func TestSignUp_OK(t *testing.T) {
 	if len(expect.ID) != rand.LengthOfID || !reflect.DeepEqual(expect, usr) {
 		t.Errorf("results not match:\nwant:\t%#v\nhave\t%#v", expect, usr)
 	}
+	if match, _ := hasher.Verify(expect.Password, creds.Password); !match {
+		t.Errorf("password not hashed: %s", expect.Password)
+	}
 	if err = mock.ExpectationsWereMet(); err != nil {
 		t.Errorf("there were unfulfilled expectations: %s", err)
 	}
@@ -197,7 +303,7 @@ func TestSignUp_ExecErr(t *testing.T) {
 	defer db.Close()
 
 	creds := Credentials{Username: "admin", Password: "passw"}
-	repo := &UserRepositoryMySQL{db: db}
+	repo := &UserRepositoryMySQL{db: db, hasher: hasher}
 
 	expect := "mysql exec insert err"
 	rows := sqlmock.NewRows([]string{`id`, `password`})
@@ -209,10 +315,10 @@ func TestSignUp_ExecErr(t *testing.T) {
 
 	mock.
 		ExpectExec("INSERT INTO `users`").
-		WithArgs(creds.Username, sqlmock.AnyArg(), creds.Password).
+		WithArgs(creds.Username, sqlmock.AnyArg(), sqlmock.AnyArg()).
 		WillReturnError(fmt.Errorf("bad exec"))
 
-	usr, err := repo.SignUp(creds.Username, creds.Password)
+	usr, err := repo.SignUp(ctx, creds.Username, creds.Password)
 
 	if usr != nil {
 		t.Errorf("unexpected usr: %#v", usr)
@@ -233,7 +339,7 @@ func TestSignUp_ScanErr(t *testing.T) {
 	defer db.Close()
 
 	creds := Credentials{Username: "admin", Password: "passw"}
-	repo := &UserRepositoryMySQL{db: db}
+	repo := &UserRepositoryMySQL{db: db, hasher: hasher}
 
 	expect := "mysql scan err"
 	rows := sqlmock.
@@ -245,7 +351,7 @@ func TestSignUp_ScanErr(t *testing.T) {
 		WithArgs(creds.Username).
 		WillReturnRows(rows)
 
-	usr, err := repo.SignUp(creds.Username, creds.Password)
+	usr, err := repo.SignUp(ctx, creds.Username, creds.Password)
 
 	if usr != nil {
 		t.Errorf("unexpected usr: %#v", usr)
@@ -266,7 +372,7 @@ func TestSignUp_AlreadyExists(t *testing.T) {
 	defer db.Close()
 
 	creds := Credentials{Username: "admin", Password: "passw"}
-	repo := &UserRepositoryMySQL{db: db}
+	repo := &UserRepositoryMySQL{db: db, hasher: hasher}
 
 	expect := errs.DetailErrors{
 		Errors: []errs.DetailError{
@@ -288,7 +394,7 @@ func TestSignUp_AlreadyExists(t *testing.T) {
 		WithArgs(creds.Username).
 		WillReturnRows(rows)
 
-	usr, err := repo.SignUp(creds.Username, creds.Password)
+	usr, err := repo.SignUp(ctx, creds.Username, creds.Password)
 
 	if usr != nil {
 		t.Errorf("unexpected usr: %#v", usr)
@@ -305,3 +411,21 @@ func TestSignUp_AlreadyExists(t *testing.T) {
 		t.Errorf("there were unfulfilled expectations: %s", err)
 	}
 }
+
+func TestClose_OK(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("mock create err: %s", err)
+	}
+
+	repo := &UserRepositoryMySQL{db: db, hasher: hasher}
+
+	mock.ExpectClose()
+
+	if err = repo.Close(); err != nil {
+		t.Errorf("unexpected err: %s", err)
+	}
+	if err = mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
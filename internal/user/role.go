@@ -0,0 +1,64 @@
+package user
+
+import (
+	"context"
+	"fmt"
+
+	"asperitas/internal/tracing"
+	"asperitas/pkg/timeout"
+)
+
+type RoleName string
+
+const (
+	// Есть у любого зарегистрированного пользователя и в базе не хранится
+	RoleUser      RoleName = "user"
+	RoleModerator RoleName = "moderator"
+	RoleAdmin     RoleName = "admin"
+)
+
+// Scope — сообщество для модератора, у админа пусто
+type Role struct {
+	Name  RoleName `json:"name"`
+	Scope string   `json:"scope,omitempty"`
+}
+
+func (usr User) HasRole(name RoleName, scope string) bool {
+	if name == RoleUser {
+		return usr.ID != ""
+	}
+	for _, role := range usr.Roles {
+		if role.Name == name && role.Scope == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// Автор поста или комментария хранится и отдается без ролей
+func (usr User) WithoutRoles() User {
+	usr.Roles = nil
+	return usr
+}
+
+// Роли читаются при входе и обновлении сессии и дальше живут в access-токене,
+// поэтому отзыв роли вступает в силу не позже чем через время жизни access-токена
+func QueryRoles(ctx context.Context, db tracing.Querier, userID string) ([]Role, error) {
+	rows, err := tracing.Query(ctx, db, "SELECT `role`, `scope` FROM `user_roles` WHERE `user_id` = ?", userID)
+	if err != nil {
+		return nil, fmt.Errorf("mysql query roles err: %w", timeout.Wrap(ctx, err))
+	}
+	defer rows.Close()
+	var roles []Role
+	for rows.Next() {
+		var role Role
+		if err = rows.Scan(&role.Name, &role.Scope); err != nil {
+			return nil, fmt.Errorf("mysql scan roles err: %w", timeout.Wrap(ctx, err))
+		}
+		roles = append(roles, role)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("mysql rows roles err: %w", timeout.Wrap(ctx, err))
+	}
+	return roles, nil
+}
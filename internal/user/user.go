@@ -1,5 +1,14 @@
 package user
 
+import (
+	"context"
+
+	"asperitas/internal/errs"
+)
+
+// Общая ошибка для неизвестного логина и неверного пароля, чтобы по ответу нельзя было перебирать логины
+var errInvalidCredentials = errs.MsgError{Msg: "invalid credentials", Status: 401}
+
 type Credentials struct {
 	Username string `json:"username"`
 	Password string `json:"password"`
@@ -9,9 +18,20 @@ type User struct {
 	Username string `json:"username"`
 	ID       string `json:"id"`
 	Password string `json:"-"`
+	Roles    []Role `json:"roles,omitempty" bson:"-"`
 }
 
 type UserRepo interface {
-	Authorize(username, passw string) (*User, error)
-	SignUp(username, passw string) (*User, error)
+	Authorize(ctx context.Context, username, passw string) (*User, error)
+	SignUp(ctx context.Context, username, passw string) (*User, error)
+}
+
+// Учет неудачных входов по логину и IP для защиты от перебора паролей
+type LoginGuard interface {
+	// Отказывает, если логин или IP сейчас заблокированы
+	Check(ctx context.Context, username, ip string) error
+	// Учитывает неудачный вход и при превышении порога блокирует логин или IP
+	Fail(ctx context.Context, username, ip string) error
+	// Сбрасывает счетчик логина после успешного входа, счетчик IP остается
+	Reset(ctx context.Context, username string) error
 }
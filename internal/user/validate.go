@@ -0,0 +1,42 @@
+package user
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"asperitas/internal/validate"
+)
+
+const (
+	MinUsernameLen = 3
+	MaxUsernameLen = 32
+	MinPasswLen    = 8
+	// bcrypt учитывает только первые 72 байта пароля
+	MaxPasswBytes = 72
+)
+
+var usernameRe = regexp.MustCompile(`^[a-zA-Z0-9_-]*$`)
+
+// Правила регистрации. При входе не проверяются: пароли, заведенные до их появления, должны работать.
+// Пароль никогда не попадает в ответ с ошибкой.
+func (c Credentials) Validate(v *validate.Validator) {
+	v.Required("username", c.Username)
+	v.Length("username", c.Username, MinUsernameLen, MaxUsernameLen)
+	v.Check(usernameRe.MatchString(c.Username), "username", c.Username,
+		"must contain only latin letters, digits, underscores and hyphens")
+
+	var hasLetter, hasDigit, hasControl bool
+	for _, r := range c.Password {
+		hasLetter = hasLetter || unicode.IsLetter(r)
+		hasDigit = hasDigit || unicode.IsDigit(r)
+		hasControl = hasControl || unicode.IsControl(r)
+	}
+	v.Required("password", c.Password)
+	v.Check(utf8.RuneCountInString(c.Password) >= MinPasswLen, "password", "", "must be at least 8 characters long")
+	v.Check(len(c.Password) <= MaxPasswBytes, "password", "", "must be at most 72 bytes long")
+	v.Check(utf8.ValidString(c.Password) && !hasControl, "password", "", "must not contain control characters")
+	v.Check(hasLetter && hasDigit, "password", "", "must contain a letter and a digit")
+	v.Check(!strings.EqualFold(c.Password, c.Username), "password", "", "must differ from username")
+}
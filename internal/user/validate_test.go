@@ -0,0 +1,50 @@
+package user
+
+import (
+	"strings"
+	"testing"
+
+	"asperitas/internal/errs"
+	"asperitas/internal/validate"
+)
+
+func TestCredentials_Validate(t *testing.T) {
+	cases := []struct {
+		creds Credentials
+		param string
+		msg   string
+	}{
+		{Credentials{Username: "admin", Password: "passw0rd"}, "", ""},
+		{Credentials{Username: "", Password: "passw0rd"}, "username", "is required"},
+		{Credentials{Username: "ad", Password: "passw0rd"}, "username", "must be 3 to 32 characters long"},
+		{Credentials{Username: "ad min", Password: "passw0rd"}, "username", "must contain only latin letters, digits, underscores and hyphens"},
+		{Credentials{Username: "admin\x00", Password: "passw0rd"}, "username", "must contain only latin letters, digits, underscores and hyphens"},
+		{Credentials{Username: "admin", Password: "p4ss"}, "password", "must be at least 8 characters long"},
+		{Credentials{Username: "admin", Password: strings.Repeat("p4", 37)}, "password", "must be at most 72 bytes long"},
+		{Credentials{Username: "admin", Password: "passw0rd\t"}, "password", "must not contain control characters"},
+		{Credentials{Username: "admin", Password: "password"}, "password", "must contain a letter and a digit"},
+		{Credentials{Username: "admin123", Password: "ADMIN123"}, "password", "must differ from username"},
+	}
+	for _, c := range cases {
+		v := validate.New()
+		c.creds.Validate(v)
+		err := v.Err()
+		if c.param == "" {
+			if err != nil {
+				t.Errorf("unexpected err for %#v: %s", c.creds, err)
+			}
+			continue
+		}
+		detailErrs, ok := err.(errs.DetailErrors)
+		if !ok || len(detailErrs.Errors) != 1 {
+			t.Errorf("wrong err for %#v: %v", c.creds, err)
+			continue
+		}
+		if e := detailErrs.Errors[0]; e.Param != c.param || e.Msg != c.msg {
+			t.Errorf("results not match:\nwant:\t%s %s\nhave\t%s %s", c.param, c.msg, e.Param, e.Msg)
+		}
+		if e := detailErrs.Errors[0]; e.Param == "password" && e.Value != "" {
+			t.Errorf("password leaked into err: %#v", e)
+		}
+	}
+}
@@ -0,0 +1,102 @@
+package validate
+
+import (
+	"net/url"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"asperitas/internal/errs"
+)
+
+// Собирает все ошибки тела запроса, чтобы клиент получил их одним ответом 422,
+// а не исправлял поля по одному
+type Validator struct {
+	errors []errs.DetailError
+}
+
+func New() *Validator {
+	return &Validator{}
+}
+
+// Добавляет ошибку поля, если условие не выполнено. Для каждого поля
+// запоминается только первая ошибка: дальнейшие проверки обычно из нее и следуют.
+func (v *Validator) Check(ok bool, param, value, msg string) {
+	if ok || v.failed(param) {
+		return
+	}
+	v.errors = append(v.errors, errs.DetailError{
+		Location: "body",
+		Param:    param,
+		Value:    value,
+		Msg:      msg,
+	})
+}
+
+func (v *Validator) failed(param string) bool {
+	for _, e := range v.errors {
+		if e.Param == param {
+			return true
+		}
+	}
+	return false
+}
+
+func (v *Validator) Valid() bool {
+	return len(v.errors) == 0
+}
+
+func (v *Validator) Err() error {
+	if v.Valid() {
+		return nil
+	}
+	return errs.DetailErrors{Errors: v.errors, Status: 422}
+}
+
+func (v *Validator) Required(param, value string) {
+	v.Check(value != "", param, value, "is required")
+}
+
+// Длина считается в символах, а не в байтах
+func (v *Validator) Length(param, value string, min, max int) {
+	n := utf8.RuneCountInString(value)
+	v.Check(n >= min && n <= max, param, value, lengthMsg(min, max))
+}
+
+// Управляющие символы (кроме переводов строк и табуляции при multiline) ломают отображение и логи
+func (v *Validator) Printable(param, value string, multiline bool) {
+	ok := utf8.ValidString(value)
+	for _, r := range value {
+		if multiline && (r == '\n' || r == '\r' || r == '\t') {
+			continue
+		}
+		if unicode.IsControl(r) || r == utf8.RuneError {
+			ok = false
+			break
+		}
+	}
+	v.Check(ok, param, value, "must not contain control characters")
+}
+
+func (v *Validator) OneOf(param, value string, allowed ...string) {
+	for _, a := range allowed {
+		if value == a {
+			return
+		}
+	}
+	v.Check(false, param, value, "must be one of "+strings.Join(allowed, ", "))
+}
+
+func (v *Validator) HTTPURL(param, value string) {
+	u, err := url.Parse(value)
+	ok := err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+	v.Check(ok, param, value, "must be http or https url")
+}
+
+func lengthMsg(min, max int) string {
+	if min == max {
+		return "must be " + strconv.Itoa(min) + " characters long"
+	}
+	return "must be " + strconv.Itoa(min) + " to " + strconv.Itoa(max) + " characters long"
+}
@@ -0,0 +1,39 @@
+package validate
+
+import (
+	"reflect"
+	"testing"
+
+	"asperitas/internal/errs"
+)
+
+func TestValidator_Err(t *testing.T) {
+	v := New()
+	v.Required("title", "")
+	v.Length("title", "", 1, 300) // вторая ошибка того же поля не добавляется
+	v.Length("text", "привет", 1, 6)
+	v.Printable("text", "line\nline", true)
+	v.Printable("comment", "bell\a", true)
+	v.OneOf("type", "video", "text", "link")
+	v.HTTPURL("url", "javascript:alert(1)")
+
+	expect := errs.DetailErrors{Errors: []errs.DetailError{
+		{Location: "body", Param: "title", Msg: "is required"},
+		{Location: "body", Param: "comment", Value: "bell\a", Msg: "must not contain control characters"},
+		{Location: "body", Param: "type", Value: "video", Msg: "must be one of text, link"},
+		{Location: "body", Param: "url", Value: "javascript:alert(1)", Msg: "must be http or https url"},
+	}, Status: 422}
+	if err := v.Err(); !reflect.DeepEqual(expect, err) {
+		t.Errorf("results not match:\nwant:\t%#v\nhave\t%#v", expect, err)
+	}
+}
+
+func TestValidator_Valid(t *testing.T) {
+	v := New()
+	v.Required("title", "some title")
+	v.HTTPURL("url", "https://go.dev/doc")
+	v.Printable("title", "заголовок", false)
+	if err := v.Err(); err != nil {
+		t.Errorf("unexpected err: %s", err)
+	}
+}
@@ -0,0 +1,91 @@
+package clientip
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// Определяет адрес клиента. X-Forwarded-For читается, только если соединение пришло
+// от доверенного прокси: иначе заголовок клиент может подделать. Нулевое значение
+// не доверяет никому и всегда берет адрес соединения.
+type Resolver struct {
+	trusted []*net.IPNet
+}
+
+// Принимает доверенные прокси через запятую, как в конфигурации: подсети в нотации CIDR
+// или отдельные адреса
+func NewResolver(proxies string) (Resolver, error) {
+	res := Resolver{}
+	for _, item := range strings.Split(proxies, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		if !strings.Contains(item, "/") {
+			ip := net.ParseIP(item)
+			if ip == nil {
+				return Resolver{}, fmt.Errorf("bad trusted proxy %q", item)
+			}
+			bits := 8 * net.IPv6len
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 8*net.IPv4len
+			}
+			res.trusted = append(res.trusted, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, network, err := net.ParseCIDR(item)
+		if err != nil {
+			return Resolver{}, fmt.Errorf("bad trusted proxy %q: %w", item, err)
+		}
+		res.trusted = append(res.trusted, network)
+	}
+	return res, nil
+}
+
+// Каждый прокси дописывает в конец X-Forwarded-For адрес, от которого получил запрос,
+// поэтому цепочка проходится справа налево до первого недоверенного адреса
+func (res Resolver) FromRequest(r *http.Request) string {
+	addr := remoteHost(r)
+	if !res.trusts(addr) {
+		return addr
+	}
+	hops := strings.Split(strings.Join(r.Header.Values("X-Forwarded-For"), ","), ",")
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop := strings.TrimSpace(hops[i])
+		if hop == "" {
+			continue
+		}
+		// мусор в заголовке не должен становиться ключом лимитов, берется последний разобранный адрес
+		if net.ParseIP(hop) == nil {
+			break
+		}
+		addr = hop
+		if !res.trusts(addr) {
+			break
+		}
+	}
+	return addr
+}
+
+func (res Resolver) trusts(addr string) bool {
+	ip := net.ParseIP(addr)
+	if ip == nil {
+		return false
+	}
+	for _, network := range res.trusted {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+func remoteHost(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
@@ -0,0 +1,53 @@
+package clientip
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestResolver_FromRequest(t *testing.T) {
+	res, err := NewResolver("10.0.0.0/8, 192.168.1.1")
+	if err != nil {
+		t.Fatalf("unexpected err: %s", err)
+	}
+	cases := []struct {
+		name, remote, forwarded, expect string
+	}{
+		{"direct client", "203.0.113.5:1234", "", "203.0.113.5"},
+		{"spoofed header from untrusted", "203.0.113.5:1234", "198.51.100.1", "203.0.113.5"},
+		{"one trusted proxy", "10.0.0.1:1234", "198.51.100.1", "198.51.100.1"},
+		{"spoofed prefix behind proxy", "10.0.0.1:1234", "1.2.3.4, 198.51.100.1", "198.51.100.1"},
+		{"chain of trusted proxies", "192.168.1.1:1234", "198.51.100.1, 10.1.1.1", "198.51.100.1"},
+		{"trusted proxy without header", "10.0.0.1:1234", "", "10.0.0.1"},
+		{"garbage behind proxy", "10.0.0.1:1234", "not an ip", "10.0.0.1"},
+	}
+	for _, c := range cases {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.RemoteAddr = c.remote
+		if c.forwarded != "" {
+			req.Header.Set("X-Forwarded-For", c.forwarded)
+		}
+		if result := res.FromRequest(req); result != c.expect {
+			t.Errorf("%s:\nwant:\t%s\nhave\t%s", c.name, c.expect, result)
+		}
+	}
+}
+
+// Без настроенных прокси заголовок игнорируется
+func TestResolver_Zero(t *testing.T) {
+	req := httptest.NewRequest("GET", "/", nil)
+	req.RemoteAddr = "10.0.0.1:1234"
+	req.Header.Set("X-Forwarded-For", "198.51.100.1")
+
+	if result := (Resolver{}).FromRequest(req); result != "10.0.0.1" {
+		t.Errorf("results not match:\nwant:\t%s\nhave\t%s", "10.0.0.1", result)
+	}
+}
+
+func TestNewResolver_Err(t *testing.T) {
+	for _, proxies := range []string{"10.0.0.0/33", "proxy.local"} {
+		if _, err := NewResolver(proxies); err == nil {
+			t.Errorf("%q: expected err", proxies)
+		}
+	}
+}
@@ -0,0 +1,25 @@
+package timeout
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Ограничивает время операции; нулевая длительность означает отсутствие ограничения
+func Context(parent context.Context, d time.Duration) (context.Context, context.CancelFunc) {
+	if d <= 0 {
+		return context.WithCancel(parent)
+	}
+	return context.WithTimeout(parent, d)
+}
+
+// Драйверы баз по-разному сообщают об истекшем дедлайне, поэтому ошибка операции,
+// не уложившейся в дедлайн, приводится к context.DeadlineExceeded
+func Wrap(ctx context.Context, err error) error {
+	if err == nil || errors.Is(err, context.DeadlineExceeded) || !errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return err
+	}
+	return fmt.Errorf("%w: %s", context.DeadlineExceeded, err)
+}